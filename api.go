@@ -0,0 +1,585 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/russross/schedule/engine"
+)
+
+var (
+	apiMaxJobs = 2
+	apiMaxTime = 10 * time.Minute
+	apiKeepFor = time.Hour
+)
+
+// An APIRequest is the body of a POST to /api/score, /api/validate,
+// or /api/solve. Input is the contents of a schedule.txt file and
+// Schedule is the contents of a schedule.json file (in either format).
+// The remaining fields only apply to /api/solve.
+type APIRequest struct {
+	Input    string          `json:"input"`
+	Schedule json.RawMessage `json:"schedule,omitempty"`
+
+	// Method is gen (the default) or swap. gen refines Schedule if
+	// one is given and starts from scratch otherwise. swap needs a
+	// Schedule and tries every sequence of up to MaxSwaps moves.
+	Method   string   `json:"method,omitempty"`
+	Time     string   `json:"time,omitempty"`
+	Workers  int      `json:"workers,omitempty"`
+	Pin      *float64 `json:"pin,omitempty"`
+	PinDev   *float64 `json:"pindev,omitempty"`
+	MaxSwaps int      `json:"maxswaps,omitempty"`
+}
+
+type APIProblem struct {
+	Category string `json:"category"`
+	Message  string `json:"message"`
+	Badness  int    `json:"badness"`
+}
+
+// An APIScore is the response to /api/score and /api/schedule.
+// Schedule is the schedule in the current file format.
+type APIScore struct {
+	Badness  int             `json:"badness"`
+	Problems []APIProblem    `json:"problems"`
+	Schedule json.RawMessage `json:"schedule"`
+}
+
+type APIInputError struct {
+	Line    int    `json:"line,omitempty"`
+	Index   int    `json:"index,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+// An APIValidation is the response to /api/validate
+type APIValidation struct {
+	Valid       bool            `json:"valid"`
+	Errors      []APIInputError `json:"errors,omitempty"`
+	Rooms       int             `json:"rooms,omitempty"`
+	Times       int             `json:"times,omitempty"`
+	Instructors int             `json:"instructors,omitempty"`
+	Sections    int             `json:"sections,omitempty"`
+}
+
+// An APIJob is a search started by /api/solve. Its state is
+// running, done, failed, or cancelled. Badness and Schedule give the
+// best schedule found so far once there is one.
+type APIJob struct {
+	ID       string          `json:"id"`
+	State    string          `json:"state"`
+	Method   string          `json:"method"`
+	Started  time.Time       `json:"started"`
+	Finished *time.Time      `json:"finished,omitempty"`
+	Badness  *int            `json:"badness,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Schedule json.RawMessage `json:"schedule,omitempty"`
+
+	// set under the server's mutex, like the fields above, so a job
+	// can be copied while holding it
+	cancelled bool
+	cancel    context.CancelFunc
+}
+
+// APIServer holds the jobs started through the API
+type APIServer struct {
+	mutex sync.Mutex
+	jobs  map[string]*APIJob
+}
+
+func NewAPIServer() *APIServer {
+	return &APIServer{jobs: make(map[string]*APIJob)}
+}
+
+// Register adds the API handlers to a mux
+func (api *APIServer) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/schedule", api.handleSchedule)
+	mux.HandleFunc("/api/score", api.handleScore)
+	mux.HandleFunc("/api/validate", api.handleValidate)
+	mux.HandleFunc("/api/solve", api.handleSolve)
+	mux.HandleFunc("/api/jobs", api.handleJobs)
+	mux.HandleFunc("/api/jobs/", api.handleJob)
+}
+
+func apiReply(w http.ResponseWriter, status int, value interface{}) {
+	raw, err := json.MarshalIndent(value, "", "    ")
+	if err != nil {
+		log.Printf("api: encoding response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(raw, '\n'))
+}
+
+func apiError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	apiReply(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+// apiMethod rejects requests that do not use the expected method
+func apiMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		apiError(w, http.StatusMethodNotAllowed, "use %s for %s", method, r.URL.Path)
+		return false
+	}
+	return true
+}
+
+// readRequest decodes the body of a POST
+func readRequest(w http.ResponseWriter, r *http.Request) (*APIRequest, bool) {
+	if !apiMethod(w, r, http.MethodPost) {
+		return nil, false
+	}
+	req := new(APIRequest)
+	decoder := json.NewDecoder(io.LimitReader(r.Body, 10<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		apiError(w, http.StatusBadRequest, "decoding request: %v", err)
+		return nil, false
+	}
+	return req, true
+}
+
+// parseRequest parses the input and the schedule (if there is one).
+// The errors it returns are the fault of the request.
+func parseRequest(req *APIRequest, needSchedule bool) (*engine.InputData, []engine.Placement, error) {
+	if req.Input == "" {
+		return nil, nil, fmt.Errorf("the request has no input")
+	}
+	lines, err := engine.SplitInput(req.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := engine.Parse("schedule.txt", lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(req.Schedule) == 0 {
+		if needSchedule {
+			return nil, nil, fmt.Errorf("the request has no schedule")
+		}
+		return data, nil, nil
+	}
+	placements, err := data.ReadJSON(bytes.NewReader(req.Schedule))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading schedule: %v", err)
+	}
+	return data, placements, nil
+}
+
+// scoreResponse describes a scored schedule
+func scoreResponse(data *engine.InputData, schedule engine.Schedule) (*APIScore, error) {
+	buf := new(bytes.Buffer)
+	if err := data.WriteJSON(buf, schedule.Placements, nil); err != nil {
+		return nil, err
+	}
+	out := &APIScore{
+		Badness:  schedule.Badness,
+		Problems: []APIProblem{},
+		Schedule: buf.Bytes(),
+	}
+	for _, problem := range schedule.Problems {
+		out.Problems = append(out.Problems, APIProblem{
+			Category: problem.Category(),
+			Message:  problem.Message,
+			Badness:  problem.Badness,
+		})
+	}
+	return out, nil
+}
+
+// GET /api/schedule: the current schedule from <prefix>.txt and <prefix>.json
+func (api *APIServer) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+	lines, err := fetchFile(prefix + ".txt")
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	data, err := engine.Parse(prefix+".txt", lines)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	fp, err := openFile(prefix + ".json")
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	placements, err := data.ReadJSON(fp)
+	fp.Close()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "reading %s.json: %v", prefix, err)
+		return
+	}
+	out, err := scoreResponse(data, data.Score(placements))
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	apiReply(w, http.StatusOK, out)
+}
+
+// POST /api/score: score a schedule
+func (api *APIServer) handleScore(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	data, placements, err := parseRequest(req, true)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	out, err := scoreResponse(data, data.Score(placements))
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	apiReply(w, http.StatusOK, out)
+}
+
+// POST /api/validate: check an input (and schedule, if given) for errors
+func (api *APIServer) handleValidate(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	apiReply(w, http.StatusOK, validateRequest(req))
+}
+
+// validateRequest checks an input (and schedule, if given) for errors
+func validateRequest(req *APIRequest) *APIValidation {
+	data, _, err := parseRequest(req, false)
+	if err == nil {
+		_, err = data.BuildSectionList()
+	}
+	out := &APIValidation{Valid: err == nil}
+	var parseErrors engine.ParseErrors
+	switch {
+	case errors.As(err, &parseErrors):
+		for _, e := range parseErrors {
+			msg := e.Error()
+			if e.Err != nil {
+				msg = e.Err.Error()
+			}
+			out.Errors = append(out.Errors, APIInputError{Line: e.Line, Index: e.Index, Field: e.Field, Kind: string(e.Kind), Message: msg})
+		}
+	case err != nil:
+		out.Errors = append(out.Errors, APIInputError{Message: err.Error()})
+	default:
+		out.Rooms, out.Times, out.Instructors, out.Sections = len(data.Rooms), len(data.Times), len(data.Instructors), len(data.Courses)
+	}
+	return out
+}
+
+// POST /api/solve: start a search and return its job
+func (api *APIServer) handleSolve(w http.ResponseWriter, r *http.Request) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	data, placements, err := parseRequest(req, false)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	sections, err := data.BuildSectionList()
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	settings, method, err := solveSettings(req, placements)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	job, _, err := api.startSolve(data, sections, placements, method, req.MaxSwaps, settings, nil)
+	if err != nil {
+		apiError(w, http.StatusServiceUnavailable, "%v", err)
+		return
+	}
+
+	apiReply(w, http.StatusAccepted, api.snapshot(job))
+}
+
+// solveSettings checks the search settings in a solve request and
+// returns them with the search method. The errors it returns are the
+// fault of the request.
+func solveSettings(req *APIRequest, placements []engine.Placement) (engine.GenSettings, string, error) {
+	var err error
+	settings := genSettingsFromFlags()
+	settings.Duration = apiMaxTime
+	if req.Time != "" {
+		if settings.Duration, err = time.ParseDuration(req.Time); err != nil {
+			return settings, "", fmt.Errorf("time: %v", err)
+		}
+		if settings.Duration > apiMaxTime {
+			return settings, "", fmt.Errorf("time must be no more than %v", apiMaxTime)
+		}
+	}
+	if req.Workers > 0 && req.Workers < settings.Workers {
+		settings.Workers = req.Workers
+	}
+	if req.Pin != nil {
+		settings.Pin = *req.Pin
+	}
+	if req.PinDev != nil {
+		settings.PinDev = *req.PinDev
+	}
+	if err := settings.Check(); err != nil {
+		return settings, "", err
+	}
+	method := req.Method
+	switch method {
+	case "", "gen":
+		method = "gen"
+	case "swap":
+		if placements == nil {
+			return settings, "", fmt.Errorf("swap needs a schedule to start from")
+		}
+		if req.MaxSwaps < 0 {
+			return settings, "", fmt.Errorf("maxswaps must be >= 1")
+		}
+		if req.MaxSwaps == 0 {
+			req.MaxSwaps = maxSwapDepth
+		}
+	default:
+		return settings, "", fmt.Errorf("unknown method %q", method)
+	}
+	return settings, method, nil
+}
+
+// startSolve starts a search as a new job. onBest (if not nil) is also
+// called with each new best schedule, and the best schedule found is
+// sent on the returned channel once the job is finished.
+func (api *APIServer) startSolve(data *engine.InputData, sections []*engine.Section, placements []engine.Placement, method string, maxSwaps int, settings engine.GenSettings, onBest func(engine.Schedule)) (*APIJob, <-chan engine.Schedule, error) {
+	// the job can be cancelled as soon as it is listed
+	ctx, cancel := context.WithCancel(interruptContext)
+	job, err := api.newJob(method, cancel)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	start := engine.Schedule{Badness: engine.Worst}
+	if placements != nil {
+		start = data.Score(placements)
+		api.update(job, data, start)
+	}
+	done := make(chan engine.Schedule, 1)
+	go func() {
+		defer cancel()
+		update := func(schedule engine.Schedule) {
+			api.update(job, data, schedule)
+			if onBest != nil {
+				onBest(schedule)
+			}
+		}
+		var best engine.Schedule
+		if method == "swap" {
+			best = runSwaps(ctx, data, sections, start, maxSwaps, settings, update)
+		} else {
+			// the settings and sections were already checked
+			best, _, _ = engine.Search(ctx, data, engine.Options{GenSettings: settings, Start: start, OnBest: update})
+		}
+		api.finish(job, data, best)
+		done <- best
+	}()
+	return job, done, nil
+}
+
+// GET /api/jobs: list the jobs, without their schedules
+func (api *APIServer) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+	api.mutex.Lock()
+	jobs := []APIJob{}
+	for _, job := range api.jobs {
+		summary := *job
+		summary.Schedule = nil
+		jobs = append(jobs, summary)
+	}
+	api.mutex.Unlock()
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].Started.Before(jobs[b].Started)
+	})
+	apiReply(w, http.StatusOK, jobs)
+}
+
+// GET /api/jobs/ID: the status of a job
+// DELETE /api/jobs/ID: cancel a job, keeping the best schedule it found
+func (api *APIServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+	api.mutex.Lock()
+	job, present := api.jobs[id]
+	api.mutex.Unlock()
+	if !present {
+		apiError(w, http.StatusNotFound, "no job with ID %q", id)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		apiReply(w, http.StatusOK, api.snapshot(job))
+	case http.MethodDelete:
+		api.cancelJob(job)
+		apiReply(w, http.StatusAccepted, api.snapshot(job))
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		apiError(w, http.StatusMethodNotAllowed, "use GET or DELETE for %s", r.URL.Path)
+	}
+}
+
+// newJob registers a job that cancel stops, unless too many are already running.
+// Finished jobs are forgotten once they are old enough.
+func (api *APIServer) newJob(method string, cancel context.CancelFunc) (*APIJob, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	running := 0
+	for key, job := range api.jobs {
+		switch {
+		case job.State == "running":
+			running++
+		case job.Finished != nil && time.Since(*job.Finished) > apiKeepFor:
+			delete(api.jobs, key)
+		}
+	}
+	if running >= apiMaxJobs {
+		return nil, fmt.Errorf("%d jobs are already running; try again later", running)
+	}
+	job := &APIJob{
+		ID:      fmt.Sprintf("%x", id),
+		State:   "running",
+		Method:  method,
+		Started: time.Now().UTC(),
+		cancel:  cancel,
+	}
+	api.jobs[job.ID] = job
+	return job, nil
+}
+
+// cancelJob stops a job early, keeping the best schedule it found
+func (api *APIServer) cancelJob(job *APIJob) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	job.cancelled = true
+	if job.cancel != nil {
+		job.cancel()
+	}
+}
+
+// update records a schedule as the best so far for a job
+func (api *APIServer) update(job *APIJob, data *engine.InputData, schedule engine.Schedule) {
+	buf := new(bytes.Buffer)
+	if err := data.WriteJSON(buf, schedule.Placements, nil); err != nil {
+		log.Printf("api: job %s: %v", job.ID, err)
+		return
+	}
+	badness := schedule.Badness
+	api.mutex.Lock()
+	job.Badness = &badness
+	job.Schedule = buf.Bytes()
+	api.mutex.Unlock()
+}
+
+func (api *APIServer) finish(job *APIJob, data *engine.InputData, best engine.Schedule) {
+	if len(best.Placements) > 0 {
+		api.update(job, data, best)
+	}
+	now := time.Now().UTC()
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	job.Finished = &now
+	switch {
+	case job.cancelled:
+		job.State = "cancelled"
+	case len(best.Placements) == 0:
+		job.State = "failed"
+		job.Error = "no valid schedule found"
+	default:
+		job.State = "done"
+	}
+}
+
+// snapshot copies a job so it can be encoded without holding the lock
+func (api *APIServer) snapshot(job *APIJob) *APIJob {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	out := *job
+	return &out
+}
+
+// runSwaps tries every sequence of up to depth swaps starting from a
+// schedule, repeating from each improvement until none is found, and
+// returns the best schedule. It stops when the time runs out or ctx is
+// done, and calls onBest with each improvement.
+func runSwaps(ctx context.Context, data *engine.InputData, sections []*engine.Section, start engine.Schedule, depth int, settings engine.GenSettings, onBest func(engine.Schedule)) engine.Schedule {
+	ctx, cancel := context.WithTimeout(ctx, settings.Duration)
+	defer cancel()
+	best := start
+	for improved := true; improved; {
+		improved = false
+		tasks := data.SwapTasks(sections, best)
+		baseline := best
+		var mutex sync.Mutex
+		var wg sync.WaitGroup
+		next := 0
+		for worker := 0; worker < settings.Workers; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				scratch := new(engine.ScoreScratch)
+				for {
+					mutex.Lock()
+					if next >= len(tasks) || ctx.Err() != nil {
+						mutex.Unlock()
+						return
+					}
+					task := tasks[next]
+					next++
+					mutex.Unlock()
+
+					found := data.SearchSwaps(ctx, sections, baseline, depth, task, scratch)
+
+					mutex.Lock()
+					if len(found.Placements) > 0 && found.Badness < best.Badness {
+						best = found
+						improved = true
+						onBest(found)
+					}
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return best
+}
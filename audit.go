@@ -0,0 +1,131 @@
+// +build !wasm
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/russross/schedule/engine"
+)
+
+var (
+	auditActor = ""
+)
+
+// An AuditEntry records one change to a schedule file: when it
+// happened, who or what made it, and the sections that moved
+type AuditEntry struct {
+	Time    time.Time     `json:"time"`
+	Actor   string        `json:"actor"`
+	Command string        `json:"command"`
+	Host    string        `json:"host"`
+	File    string        `json:"file"`
+	Badness int           `json:"badness"`
+	Changes []AuditChange `json:"changes"`
+}
+
+// An AuditChange is one section whose placement changed. Before or
+// After is nil if the section was not in the file before or after.
+type AuditChange struct {
+	Section string          `json:"section"`
+	Before  *AuditPlacement `json:"before"`
+	After   *AuditPlacement `json:"after"`
+}
+
+type AuditPlacement struct {
+	Room string `json:"room"`
+	Time string `json:"time"`
+}
+
+// currentActor names who is running the command: the --actor option
+// (or SCHEDULE_ACTOR) if given, or else the login name and host
+func currentActor() string {
+	if auditActor != "" {
+		return auditActor
+	}
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("USERNAME")
+	}
+	if user == "" {
+		user = "unknown"
+	}
+	return user + "@" + hostname
+}
+
+// auditScheduleFile appends an entry to the audit log (<prefix>.audit.jsonl)
+// for the placements that differ between the old and new contents of
+// a schedule file. old is nil if the file did not exist. Nothing is
+// recorded if no placement changed. Failures are logged but do not
+// stop the command.
+func auditScheduleFile(filename string, old, new []byte, actor, command string) {
+	var before, after engine.JSONSchedule
+	if old != nil {
+		// an older format or a damaged file counts as empty
+		if err := json.Unmarshal(old, &before); err != nil || before.Version < 2 {
+			before = engine.JSONSchedule{}
+		}
+	}
+	if err := json.Unmarshal(new, &after); err != nil {
+		log.Printf("writing audit log: %v", err)
+		return
+	}
+	entry := AuditEntry{
+		Time:    time.Now().UTC(),
+		Actor:   actor,
+		Command: command,
+		Host:    hostname,
+		File:    filepath.Base(filename),
+		Badness: after.Badness,
+		Changes: auditChanges(before.Placements, after.Placements),
+	}
+	if len(entry.Changes) == 0 {
+		return
+	}
+
+	fp, err := os.OpenFile(prefix+".audit.jsonl", os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		log.Printf("opening audit log: %v", err)
+		return
+	}
+	if err := json.NewEncoder(fp).Encode(entry); err != nil {
+		log.Printf("writing audit log: %v", err)
+	}
+	if err := fp.Close(); err != nil {
+		log.Printf("closing audit log: %v", err)
+	}
+}
+
+// auditChanges lists the sections placed differently in two lists of
+// placements, in the order of the new list followed by any sections
+// that were removed
+func auditChanges(before, after []engine.JSONPlacement) []AuditChange {
+	old := make(map[string]engine.JSONPlacement)
+	for _, elt := range before {
+		old[elt.ID] = elt
+	}
+	var changes []AuditChange
+	seen := make(map[string]bool)
+	for _, elt := range after {
+		seen[elt.ID] = true
+		prev, present := old[elt.ID]
+		if present && prev.Room == elt.Room && prev.Time == elt.Time {
+			continue
+		}
+		change := AuditChange{Section: elt.ID, After: &AuditPlacement{Room: elt.Room, Time: elt.Time}}
+		if present {
+			change.Before = &AuditPlacement{Room: prev.Room, Time: prev.Time}
+		}
+		changes = append(changes, change)
+	}
+	for _, elt := range before {
+		if !seen[elt.ID] {
+			changes = append(changes, AuditChange{Section: elt.ID, Before: &AuditPlacement{Room: elt.Room, Time: elt.Time}})
+		}
+	}
+	return changes
+}
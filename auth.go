@@ -0,0 +1,212 @@
+// +build !wasm
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// extra headers ("Name: value") to send when downloading the input
+	inputHeaders = []string{}
+
+	// a Google service account key file; when set, input downloads
+	// are made as that account
+	credentialsFile = ""
+)
+
+// the access Google service accounts ask for when downloading inputs
+const googleScope = "https://www.googleapis.com/auth/drive.readonly"
+
+// where to get tokens when running in Google Cloud with no key file
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// fetchURL downloads a URL with any headers and credentials the user
+// supplied. If etag is not empty, the download is conditional and a
+// 304 Not Modified response is returned too; anything else other than
+// a 200 OK response is an error.
+func fetchURL(target, etag string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range inputHeaders {
+		colon := strings.Index(header, ":")
+		if colon < 1 {
+			return nil, fmt.Errorf("header %q must be in the form \"Name: value\"", header)
+		}
+		req.Header.Add(strings.TrimSpace(header[:colon]), strings.TrimSpace(header[colon+1:]))
+	}
+	if credentialsFile != "" {
+		token, err := googleToken.get(credentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("getting an access token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK && !(etag != "" && res.StatusCode == http.StatusNotModified) {
+		res.Body.Close()
+		if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+			return nil, fmt.Errorf("downloading %s: %s (check --header or --credentials)", target, res.Status)
+		}
+		return nil, fmt.Errorf("downloading %s: %s", target, res.Status)
+	}
+	return res, nil
+}
+
+// A ServiceAccountKey is the part of a Google service account key
+// file needed to get access tokens
+type ServiceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// an access token is kept until shortly before it expires, so the
+// daemon does not ask for a new one on every run
+type tokenCache struct {
+	scope   string
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+var googleToken = tokenCache{scope: googleScope}
+
+// get returns an access token for the service account in a key file,
+// or for the account the program runs as in Google Cloud if there is
+// no key file
+func (cache *tokenCache) get(keyFile string) (string, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.token != "" && time.Now().Before(cache.expires) {
+		return cache.token, nil
+	}
+	if keyFile == "" {
+		token, lifetime, err := metadataToken()
+		if err != nil {
+			return "", err
+		}
+		cache.token = token
+		cache.expires = time.Now().Add(lifetime - time.Minute)
+		return token, nil
+	}
+
+	raw, err := os.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	var key ServiceAccountKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return "", fmt.Errorf("reading %s: %v", keyFile, err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" || key.TokenURI == "" {
+		return "", fmt.Errorf("%s is not a service account key file", keyFile)
+	}
+	token, lifetime, err := key.accessToken(cache.scope, time.Now())
+	if err != nil {
+		return "", err
+	}
+	cache.token = token
+	cache.expires = time.Now().Add(lifetime - time.Minute)
+	return token, nil
+}
+
+// accessToken trades a signed assertion for an access token, following
+// Google's OAuth 2.0 flow for server to server applications
+func (key *ServiceAccountKey) accessToken(scope string, now time.Time) (string, time.Duration, error) {
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return "", 0, fmt.Errorf("the service account key has no private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", 0, fmt.Errorf("parsing the service account key: %v", err)
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", 0, fmt.Errorf("the service account key is not an RSA key")
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   key.ClientEmail,
+		"scope": scope,
+		"aud":   key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := encode(header) + "." + encode(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, private, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", 0, err
+	}
+	assertion := unsigned + "." + encode(signature)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	res, err := client.PostForm(key.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return readTokenReply(res)
+}
+
+// metadataToken gets an access token from the metadata server, for
+// the service account a Google Cloud machine runs as
+func metadataToken() (string, time.Duration, error) {
+	req, err := http.NewRequest(http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	client := &http.Client{Timeout: 5 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("no credentials given and no metadata server found: %v", err)
+	}
+	return readTokenReply(res)
+}
+
+func readTokenReply(res *http.Response) (string, time.Duration, error) {
+	defer res.Body.Close()
+	var reply struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&reply); err != nil {
+		return "", 0, fmt.Errorf("decoding token response: %v", err)
+	}
+	if res.StatusCode != http.StatusOK || reply.AccessToken == "" {
+		return "", 0, fmt.Errorf("token request refused: %s %s", reply.Error, reply.Description)
+	}
+	return reply.AccessToken, time.Duration(reply.ExpiresIn) * time.Second, nil
+}
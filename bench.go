@@ -0,0 +1,181 @@
+// +build !wasm
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchRooms             = 10
+	benchTimes             = 16
+	benchInstructors       = 25
+	benchCourses           = 3
+	benchConflicts         = 0.05
+	benchSeed        int64 = 1
+	benchDuration          = 10 * time.Second
+)
+
+func CommandBench(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	if benchRooms < 1 {
+		log.Fatalf("rooms must be >= 1")
+	}
+	if benchTimes < 2 {
+		log.Fatalf("times must be >= 2")
+	}
+	if benchInstructors < 1 {
+		log.Fatalf("instructors must be >= 1")
+	}
+	if benchCourses < 1 {
+		log.Fatalf("courses must be >= 1")
+	}
+	if benchConflicts < 0.0 || benchConflicts > 1.0 {
+		log.Fatalf("conflicts must be between 0 and 1")
+	}
+	if benchDuration <= 0 {
+		log.Fatalf("time must be > 0")
+	}
+	if benchInstructors*benchCourses > benchRooms*benchTimes {
+		log.Fatalf("%d sections will not fit in %d rooms with %d times",
+			benchInstructors*benchCourses, benchRooms, benchTimes)
+	}
+
+	// generate and parse the synthetic input
+	lines := SyntheticInput(benchRooms, benchTimes, benchInstructors, benchCourses, benchConflicts, benchSeed)
+	data, err := engine.Parse("synthetic", lines)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	conflicts := 0
+	for _, conflict := range data.Conflicts {
+		conflicts += len(conflict.Courses) * (len(conflict.Courses) - 1) / 2
+	}
+	log.Printf("synthetic input: %d rooms, %d times, %d instructors, %d sections, %d conflicts",
+		len(data.Rooms), len(data.Times), len(data.Instructors), benchInstructors*benchCourses, conflicts)
+
+	// time the section list separately since it only happens once per run
+	rand.Seed(benchSeed)
+	start := time.Now()
+	sections := makeSectionList(data)
+	log.Printf("section list built in %v", time.Since(start))
+
+	// run the placement/scoring loop the same way the optimization
+	// phase of gen does: refine the best schedule found so far
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	best := engine.Schedule{Badness: engine.Worst}
+	successfulAttempts := 0
+	failedAttempts := 0
+	start = time.Now()
+	for time.Since(start) < benchDuration {
+		candidate := data.PlaceSections(context.Background(), sections, best.Placements, pin, weightedOptimization, 0)
+		if len(candidate) == 0 {
+			failedAttempts++
+			continue
+		}
+		successfulAttempts++
+		if schedule := data.Score(candidate); schedule.Badness < best.Badness {
+			best = schedule
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	attempts := successfulAttempts + failedAttempts
+	if attempts == 0 {
+		log.Fatalf("no attempts completed in %v", elapsed)
+	}
+	fmt.Printf("attempts:       %d (%d successful, %d failed)\n", attempts, successfulAttempts, failedAttempts)
+	fmt.Printf("elapsed:        %v\n", elapsed)
+	fmt.Printf("attempts/sec:   %.1f\n", float64(attempts)/elapsed.Seconds())
+	fmt.Printf("allocs/attempt: %d\n", (after.Mallocs-before.Mallocs)/uint64(attempts))
+	fmt.Printf("bytes/attempt:  %d\n", (after.TotalAlloc-before.TotalAlloc)/uint64(attempts))
+	fmt.Printf("gc cycles:      %d (%v paused)\n", after.NumGC-before.NumGC,
+		time.Duration(after.PauseTotalNs-before.PauseTotalNs))
+	fmt.Printf("best badness:   %d\n", best.Badness)
+}
+
+// SyntheticInput generates the lines of a schedule.txt-style input with
+// the given number of rooms, times, instructors, and courses per instructor.
+// Each pair of courses is put in conflict with the given probability.
+// The same seed always produces the same input.
+func SyntheticInput(rooms, times, instructors, courses int, conflictDensity float64, seed int64) [][]string {
+	rng := rand.New(rand.NewSource(seed))
+	var lines [][]string
+
+	// rooms: every other room is a lab
+	for i := 0; i < rooms; i++ {
+		kind := "lecture"
+		if i%2 == 1 {
+			kind = "lab"
+		}
+		lines = append(lines, []string{"room:", fmt.Sprintf("R%03d", 100+i), "rooms", kind})
+	}
+
+	// times: the first half are hourly MWF slots, the rest are 90-minute TR slots
+	mwf := (times + 1) / 2
+	for i := 0; i < mwf; i++ {
+		lines = append(lines, []string{"time:", fmt.Sprintf("MWF%02d00", 8+i), "mwf"})
+	}
+	lines = append(lines, []string{"time:"})
+	for i := 0; i < times-mwf; i++ {
+		minutes := 7*60 + 30 + 90*i
+		lines = append(lines, []string{"time:", fmt.Sprintf("TR%02d%02d", minutes/60, minutes%60), "tr"})
+	}
+
+	// instructors and their courses
+	var names []string
+	for i := 0; i < instructors; i++ {
+		line := []string{"instructor:", fmt.Sprintf("Instructor%03d", i+1), "mwf", "tr"}
+		if rng.Intn(4) == 0 {
+			line = append(line, fmt.Sprintf("mwf:%d", 5+rng.Intn(20)))
+		}
+		switch rng.Intn(4) {
+		case 0:
+			line = append(line, "oneday")
+		case 1:
+			line = append(line, "twodays")
+		}
+		lines = append(lines, line)
+
+		for j := 0; j < courses; j++ {
+			name := fmt.Sprintf("C%04d", 1000+i*courses+j)
+			line := []string{"course:", name, "lecture", "lab:20"}
+			if rng.Intn(5) == 0 {
+				line = []string{"course:", name, "lab"}
+			}
+			if rng.Intn(10) == 0 {
+				line = append(line, "twoslots")
+			}
+			lines = append(lines, line)
+			names = append(names, name)
+		}
+	}
+
+	// pairwise conflicts
+	for i := 0; i < len(names); i++ {
+		for j := i + 1; j < len(names); j++ {
+			if rng.Float64() < conflictDensity {
+				badness := fmt.Sprintf("%d", 10*(1+rng.Intn(9)))
+				lines = append(lines, []string{"conflict:", badness, names[i], names[j]})
+			}
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,128 @@
+// +build !wasm
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/russross/schedule/engine"
+)
+
+var (
+	// use only cached copies of downloaded inputs
+	offline = false
+
+	// the downloads behind the most recent input, for the run record
+	inputSources []engine.JSONSource
+)
+
+// inputCacheDir is where downloaded inputs are kept. Each URL has an
+// index file recording the latest copy, and every distinct version
+// downloaded is kept as a snapshot, so the exact input behind a
+// schedule can be recovered later.
+func inputCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "schedule"), nil
+}
+
+func cacheKey(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// fetchCached downloads a URL, keeping a copy in the cache. If the
+// cached copy is still current (by its ETag) it is used without
+// downloading it again, and if the download fails it is used anyway
+// with a warning. With --offline only the cache is used.
+func fetchCached(target string) ([]byte, error) {
+	dir, err := inputCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	indexFile := filepath.Join(dir, cacheKey(target)+".json")
+
+	var cached engine.JSONSource
+	var body []byte
+	if raw, err := os.ReadFile(indexFile); err == nil {
+		if err := json.Unmarshal(raw, &cached); err == nil {
+			body, err = os.ReadFile(cached.Snapshot)
+			if err != nil {
+				body, cached = nil, engine.JSONSource{}
+			}
+		}
+	}
+
+	if offline {
+		if body == nil {
+			return nil, fmt.Errorf("%s is not in the cache, so it cannot be used offline", target)
+		}
+		log.Printf("using the copy of %s cached at %s", target, cached.Fetched.Local().Format("2006-01-02 15:04:05"))
+		cached.Cached = true
+		inputSources = append(inputSources, cached)
+		return body, nil
+	}
+
+	etag := ""
+	if body != nil {
+		etag = cached.ETag
+	}
+	res, err := fetchURL(target, etag)
+	if err != nil {
+		if body == nil {
+			return nil, err
+		}
+		log.Printf("%v", err)
+		log.Printf("using the copy cached at %s instead", cached.Fetched.Local().Format("2006-01-02 15:04:05"))
+		cached.Cached = true
+		inputSources = append(inputSources, cached)
+		return body, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotModified {
+		cached.Cached = true
+		inputSources = append(inputSources, cached)
+		return body, nil
+	}
+
+	body, err = io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	source := engine.JSONSource{
+		URL:      target,
+		ETag:     res.Header.Get("ETag"),
+		Fetched:  time.Now().UTC(),
+		Snapshot: filepath.Join(dir, cacheKey(target)+"-"+cacheKey(string(body))),
+	}
+	inputSources = append(inputSources, source)
+
+	// failing to update the cache should not stop the run
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("caching input: %v", err)
+		return body, nil
+	}
+	if err := replaceFile(source.Snapshot, body); err != nil {
+		log.Printf("caching input: %v", err)
+		return body, nil
+	}
+	raw, err := json.MarshalIndent(source, "", "    ")
+	if err == nil {
+		err = replaceFile(indexFile, append(raw, '\n'))
+	}
+	if err != nil {
+		log.Printf("caching input: %v", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,258 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	calendarFormat     = "text"
+	calendarInstructor = ""
+	calendarFirstDay   = ""
+	calendarLastDay    = ""
+)
+
+func CommandCalendar(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	placements := readPlacements(data, prefix+".json")
+
+	var instructors []*engine.Instructor
+	for _, instructor := range data.Instructors {
+		if calendarInstructor == "" || instructor.Name == calendarInstructor {
+			instructors = append(instructors, instructor)
+		}
+	}
+	if len(instructors) == 0 {
+		log.Fatalf("no instructor named %q", calendarInstructor)
+	}
+
+	format := outputFormat(cmd, calendarFormat, map[string]string{".html": "html", ".htm": "html", ".ics": "ics"})
+	if format != "text" && format != "html" && format != "ics" {
+		log.Fatalf("unknown format %q", format)
+	}
+	// the term in the input can be overridden with flags
+	term := data.Term
+	if format == "ics" && (calendarFirstDay != "" || calendarLastDay != "" || term == nil) {
+		if calendarFirstDay == "" || calendarLastDay == "" {
+			log.Fatalf("the ics format needs --first-day and --last-day (or a term: line in the input)")
+		}
+		first, last, err := engine.ParseTermDates(calendarFirstDay, calendarLastDay)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		term = data.TermDates(first, last)
+	}
+
+	out := openOutput()
+	defer out.Close()
+	var err error
+	switch format {
+	case "text":
+		err = WriteCalendarText(data, out, placements, instructors)
+	case "html":
+		err = WriteCalendarHTML(data, out, placements, instructors)
+	case "ics":
+		err = data.WriteICal(out, placements, instructors, term, time.Now())
+	}
+	if err != nil {
+		log.Fatalf("writing calendar: %v", err)
+	}
+}
+
+// minutesClock formats minutes after midnight in 12-hour form
+func minutesClock(minutes int) string {
+	return clockTime(fmt.Sprintf("%02d%02d", minutes/60, minutes%60))
+}
+
+// calendarHours gives the range of hours (first inclusive,
+// last exclusive) needed to show all of the meetings
+func calendarHours(meetings []engine.Meeting) (int, int) {
+	first, last := 24, 0
+	for _, meeting := range meetings {
+		if meeting.Start/60 < first {
+			first = meeting.Start / 60
+		}
+		if (meeting.End+59)/60 > last {
+			last = (meeting.End + 59) / 60
+		}
+	}
+	return first, last
+}
+
+func meetingLabel(data *engine.InputData, meeting engine.Meeting) string {
+	return meeting.Placement.Course.Name + " " + data.Rooms[meeting.Placement.Room].Name
+}
+
+// WriteCalendarText writes a Monday-Friday weekly calendar for each
+// instructor with one row per hour. A section is named in the hour
+// it starts and marked with | in any later hours it runs into.
+func WriteCalendarText(data *engine.InputData, w io.Writer, placements []engine.Placement, instructors []*engine.Instructor) error {
+	buf := new(bytes.Buffer)
+	for i, instructor := range instructors {
+		meetings, err := data.Meetings(placements, instructor)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Fprintln(buf)
+		}
+		fmt.Fprintf(buf, "%s\n", instructor.Name)
+		if len(meetings) == 0 {
+			fmt.Fprintf(buf, "  no scheduled meetings\n")
+			continue
+		}
+
+		colLen := len("Mon")
+		for _, meeting := range meetings {
+			if n := displayWidth(meetingLabel(data, meeting)); n > colLen {
+				colLen = n
+			}
+		}
+		fmt.Fprintf(buf, "     ")
+		for _, weekday := range engine.Weekdays {
+			fmt.Fprintf(buf, "  %-*s", colLen, weekday.Name)
+		}
+		fmt.Fprintln(buf)
+
+		first, last := calendarHours(meetings)
+		for hour := first; hour < last; hour++ {
+			// a row may need more than one line if meetings overlap
+			var cells [][]string
+			lines := 1
+			for day := range engine.Weekdays {
+				var cell []string
+				for _, meeting := range meetings {
+					if meeting.Day != day || meeting.Start >= (hour+1)*60 || meeting.End <= hour*60 {
+						continue
+					}
+					if meeting.Start/60 == hour {
+						cell = append(cell, meetingLabel(data, meeting))
+					} else {
+						cell = append(cell, "|")
+					}
+				}
+				if len(cell) > lines {
+					lines = len(cell)
+				}
+				cells = append(cells, cell)
+			}
+			for line := 0; line < lines; line++ {
+				if line == 0 {
+					fmt.Fprintf(buf, "%02d:00", hour)
+				} else {
+					fmt.Fprintf(buf, "     ")
+				}
+				for _, cell := range cells {
+					text := ""
+					if line < len(cell) {
+						text = cell[line]
+					}
+					fmt.Fprintf(buf, "  %s", padRight(text, colLen))
+				}
+				fmt.Fprintf(buf, "\n")
+			}
+		}
+	}
+
+	// trim the padding at the end of each line
+	var trimmed bytes.Buffer
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.HasSuffix(line, "\n") {
+			trimmed.WriteString(strings.TrimRight(line, " \n") + "\n")
+		} else {
+			trimmed.WriteString(line)
+		}
+	}
+	_, err := trimmed.WriteTo(w)
+	return err
+}
+
+// WriteCalendarHTML writes a standalone web page with a
+// Monday-Friday weekly calendar for each instructor
+func WriteCalendarHTML(data *engine.InputData, w io.Writer, placements []engine.Placement, instructors []*engine.Instructor) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "<!DOCTYPE html>\n")
+	fmt.Fprintf(buf, "<html lang=\"en\">\n")
+	fmt.Fprintf(buf, "<head>\n")
+	fmt.Fprintf(buf, "  <meta charset=\"utf-8\">\n")
+	fmt.Fprintf(buf, "  <title>Weekly calendars</title>\n")
+	fmt.Fprintf(buf, "  <style>\n")
+	fmt.Fprintf(buf, "    table { border-collapse: collapse; margin-bottom: 2em; page-break-inside: avoid; }\n")
+	fmt.Fprintf(buf, "    table, td, th { border: 1px solid darkgray; }\n")
+	fmt.Fprintf(buf, "    td, th { padding: 0.2em 0.5em; vertical-align: top; width: 8em; }\n")
+	fmt.Fprintf(buf, "    td.course { background-color: #eef; }\n")
+	fmt.Fprintf(buf, "    span.continued { color: gray; }\n")
+	fmt.Fprintf(buf, "  </style>\n")
+	fmt.Fprintf(buf, "</head>\n")
+	fmt.Fprintf(buf, "<body>\n")
+
+	for _, instructor := range instructors {
+		meetings, err := data.Meetings(placements, instructor)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "  <h2>%s</h2>\n", html.EscapeString(instructor.Name))
+		if len(meetings) == 0 {
+			fmt.Fprintf(buf, "  <p>No scheduled meetings.</p>\n")
+			continue
+		}
+		fmt.Fprintf(buf, "  <table>\n")
+		fmt.Fprintf(buf, "    <tr>\n      <th>&nbsp;</th>\n")
+		for _, weekday := range engine.Weekdays {
+			fmt.Fprintf(buf, "      <th>%s</th>\n", weekday.Name)
+		}
+		fmt.Fprintf(buf, "    </tr>\n")
+
+		first, last := calendarHours(meetings)
+		for hour := first; hour < last; hour++ {
+			fmt.Fprintf(buf, "    <tr>\n      <th>%02d:00</th>\n", hour)
+			for day := range engine.Weekdays {
+				var lines []string
+				for _, meeting := range meetings {
+					if meeting.Day != day || meeting.Start >= (hour+1)*60 || meeting.End <= hour*60 {
+						continue
+					}
+					if meeting.Start/60 != hour {
+						lines = append(lines, fmt.Sprintf("<span class=\"continued\">%s continued</span>",
+							html.EscapeString(meeting.Placement.Course.Name)))
+						continue
+					}
+					lines = append(lines, fmt.Sprintf("%s<br>%s<br>%s&ndash;%s",
+						html.EscapeString(meeting.Placement.Course.Name),
+						html.EscapeString(data.Rooms[meeting.Placement.Room].Name),
+						minutesClock(meeting.Start), minutesClock(meeting.End)))
+				}
+				if len(lines) == 0 {
+					fmt.Fprintf(buf, "      <td>&nbsp;</td>\n")
+				} else {
+					fmt.Fprintf(buf, "      <td class=\"course\">%s</td>\n", strings.Join(lines, "<hr>"))
+				}
+			}
+			fmt.Fprintf(buf, "    </tr>\n")
+		}
+		fmt.Fprintf(buf, "  </table>\n")
+	}
+
+	fmt.Fprintf(buf, "</body>\n")
+	fmt.Fprintf(buf, "</html>\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,226 @@
+// +build !wasm
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	changesPublished = ""
+	changesReasons   = ""
+	changesFormat    = "text"
+)
+
+// A registrarChange is one section whose placement differs between the
+// published schedule and the proposed one. From or To is nil if the
+// section is only placed in the other schedule.
+type registrarChange struct {
+	Course   *engine.Course
+	From, To *engine.Placement
+	Reason   string
+}
+
+// CommandChanges lists the sections whose room or time changed since
+// the schedule was published, grouped by instructor, for the
+// registrar. The published schedule is <prefix>-published.json unless
+// another is given with --published, and the proposed schedule is
+// <prefix>.json unless another is named. Reasons for the changes can
+// be given in a file with one line per section as SECTION: reason.
+func CommandChanges(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		log.Fatalf("usage: schedule changes [proposed.json]")
+	}
+	format := outputFormat(cmd, changesFormat, map[string]string{".csv": "csv", ".txt": "text"})
+	if format != "text" && format != "csv" {
+		log.Fatalf("unknown changes format %q", format)
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	publishedFile := changesPublished
+	if publishedFile == "" {
+		publishedFile = prefix + "-published.json"
+	}
+	proposedFile := prefix + ".json"
+	if len(args) == 1 {
+		proposedFile = args[0]
+	}
+	published := readPlacements(data, publishedFile)
+	proposed := readPlacements(data, proposedFile)
+
+	reasons := make(map[*engine.Course]string)
+	if changesReasons != "" {
+		var err error
+		if reasons, err = readReasons(data, changesReasons); err != nil {
+			log.Fatalf("reading reasons: %v", err)
+		}
+	}
+
+	// every section that moved, appeared, or disappeared, in input order
+	from := make(map[*engine.Course]engine.Placement)
+	for _, placement := range published {
+		from[placement.Course] = placement
+	}
+	to := make(map[*engine.Course]engine.Placement)
+	for _, placement := range proposed {
+		to[placement.Course] = placement
+	}
+	var changes []registrarChange
+	for _, course := range data.Courses {
+		a, aPresent := from[course]
+		b, bPresent := to[course]
+		if aPresent && bPresent && a.Room == b.Room && a.Time == b.Time || !aPresent && !bPresent {
+			continue
+		}
+		change := registrarChange{Course: course, Reason: reasons[course]}
+		if aPresent {
+			change.From = &a
+		}
+		if bPresent {
+			change.To = &b
+		}
+		changes = append(changes, change)
+	}
+	changed := make(map[*engine.Course]bool)
+	for _, change := range changes {
+		changed[change.Course] = true
+	}
+	for _, course := range data.Courses {
+		if _, present := reasons[course]; present && !changed[course] {
+			log.Printf("warning: %s has a reason but did not change", course.SectionID())
+		}
+	}
+
+	// group them by instructor; a co-taught section is listed under
+	// each of its instructors
+	var instructors []string
+	byInstructor := make(map[string][]registrarChange)
+	for _, instructor := range data.Instructors {
+		for _, change := range changes {
+			if change.Course.TaughtBy(instructor) {
+				if byInstructor[instructor.Name] == nil {
+					instructors = append(instructors, instructor.Name)
+				}
+				byInstructor[instructor.Name] = append(byInstructor[instructor.Name], change)
+			}
+		}
+	}
+
+	out := openOutput()
+	defer out.Close()
+	var err error
+	if format == "csv" {
+		err = writeChangesCSV(data, out, instructors, byInstructor)
+	} else {
+		err = writeChangesText(data, out, instructors, byInstructor, len(changes))
+	}
+	if err != nil {
+		log.Fatalf("writing changes: %v", err)
+	}
+}
+
+// writeChangesText writes the changes as a plain text report with one
+// heading per instructor
+func writeChangesText(data *engine.InputData, w io.Writer, instructors []string, byInstructor map[string][]registrarChange, count int) error {
+	if _, err := fmt.Fprintf(w, "%d section(s) changed since the schedule was published\n", count); err != nil {
+		return err
+	}
+	for _, name := range instructors {
+		if _, err := fmt.Fprintf(w, "\n%s\n", name); err != nil {
+			return err
+		}
+		for _, change := range byInstructor[name] {
+			reason := change.Reason
+			if reason == "" {
+				reason = "________________"
+			}
+			_, err := fmt.Fprintf(w, "    %-12s %-22s -> %-22s reason: %s\n",
+				change.Course.SectionID(),
+				changePlacement(data, change.From, "(not scheduled)"),
+				changePlacement(data, change.To, "(removed)"),
+				reason)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeChangesCSV writes one row per instructor and changed section,
+// with an empty reason column to be filled in if none was given
+func writeChangesCSV(data *engine.InputData, w io.Writer, instructors []string, byInstructor map[string][]registrarChange) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"instructor", "section", "old room", "old time", "new room", "new time", "reason"}); err != nil {
+		return err
+	}
+	for _, name := range instructors {
+		for _, change := range byInstructor[name] {
+			row := []string{name, change.Course.SectionID(), "", "", "", "", change.Reason}
+			if change.From != nil {
+				row[2], row[3] = data.Rooms[change.From.Room].Name, data.Times[change.From.Time].Name
+			}
+			if change.To != nil {
+				row[4], row[5] = data.Rooms[change.To.Room].Name, data.Times[change.To.Time].Name
+			}
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// changePlacement describes a room and time, or gives the fallback if
+// the section is not placed
+func changePlacement(data *engine.InputData, placement *engine.Placement, fallback string) string {
+	if placement == nil {
+		return fallback
+	}
+	return data.Rooms[placement.Room].Name + " " + data.Times[placement.Time].Name
+}
+
+// readReasons reads the reason given for each change, one per line as
+// SECTION: reason. Blank lines and anything after # are ignored.
+func readReasons(data *engine.InputData, filename string) (map[*engine.Course]string, error) {
+	fp, err := openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	reasons := make(map[*engine.Course]string)
+	scanner := bufio.NewScanner(fp)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("%s line %d: expected SECTION: reason", filename, lineNumber)
+		}
+		course, err := data.FindCourse(strings.TrimSpace(line[:colon]))
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", filename, lineNumber, err)
+		}
+		reasons[course] = strings.TrimSpace(line[colon+1:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return reasons, nil
+}
@@ -4,20 +4,28 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
-	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/russross/schedule/engine"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var (
@@ -36,21 +44,44 @@ var (
 	weightedOptimization = false
 	hostname             = "UNKNOWN"
 	prevFile             = ""
+	prevHtmlFile         = ""
+	scoreFormat          = "text"
+	byCourseSort         = "instructor"
+	byInstructorSort     = "course"
+	roomTag              = ""
+	colorMode            = "auto"
+	outputFile           = ""
+	maxBadness           = -1
+	unplaced             = 0
+	continueSearch       = false
+	historyFile          = ""
+	historyInterval      = 10 * time.Second
+	archiveDir           = ""
+	archiveHTML          = false
 	verbose              = false
-)
 
-const (
-	worst int = 1e9
+	// a gen run with --seed counts attempts instead of time
+	seed                  = int64(0)
+	attempts              = 20000
+	warmupAttempts        = 1000
+	restartLocalAttempts  = 4000
+	restartGlobalAttempts = 8000
+
+	// set when the user asks a long search to stop early, at which
+	// point interruptContext is cancelled too
+	interrupted int32
 
-	reportInterval = time.Minute
+	interruptContext, interruptCancel = context.WithCancel(context.Background())
 
-	ModeWarmup int = iota
-	ModeLocalBest
-	ModeGlobalBest
+	// the seed for the random number generator and a record of
+	// the command being run, saved with each schedule written
+	randomSeed int64
+	runInfo    *engine.JSONRun
 )
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	randomSeed = time.Now().UnixNano()
+	rand.Seed(randomSeed)
 	log.SetFlags(log.Ltime)
 	if host, err := os.Hostname(); err != nil {
 		log.Fatalf("getting hostname: %v", err)
@@ -64,7 +95,39 @@ func main() {
 		Long: "A tool to generate course schedules while optimizing curriculum conflicts\n" +
 			"and instructor schedules\n" +
 			"by Russ Ross <russ@russross.com>",
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if err := applyEnv(cmd); err != nil {
+				log.Fatalf("reading environment: %v", err)
+			}
+			if err := applyConfig(cmd); err != nil {
+				log.Fatalf("reading config file: %v", err)
+			}
+			runInfo = newRunInfo(cmd)
+
+			switch colorMode {
+			case "always":
+				useColor = true
+			case "never":
+				useColor = false
+			case "auto":
+				useColor = isTerminal(os.Stdout) && os.Getenv("TERM") != "dumb" && os.Getenv("NO_COLOR") == "" &&
+					(outputFile == "" || outputFile == "-")
+			default:
+				log.Fatalf("color must be auto, always, or never")
+			}
+			if err := engine.CheckClockStyle(timeStyle); err != nil {
+				log.Fatalf("%v", err)
+			}
+		},
 	}
+	cmdSchedule.PersistentFlags().StringVar(&colorMode, "color", colorMode, "use colors in terminal output (auto, always, or never)")
+	cmdSchedule.PersistentFlags().StringVar(&auditActor, "actor", auditActor, "who to name in the audit log for changes to the schedule (default is the login name and host)")
+	cmdSchedule.PersistentFlags().StringVar(&timeStyle, "times", timeStyle, "how to show times: raw for the slot names, 12 for TR 9:30–10:45am, or 24 for TR 09:30–10:45")
+	cmdSchedule.PersistentFlags().StringVar(&configFile, "config", configFile, "config file with default options (default is schedule.toml or schedule.yaml next to the prefix)")
+	cmdSchedule.PersistentFlags().StringSliceVar(&sheetTabs, "tabs", sheetTabs, "when the input is a Google Sheets link, read these tabs in order and join them")
+	cmdSchedule.PersistentFlags().StringArrayVar(&inputHeaders, "header", inputHeaders, "send this header (\"Name: value\") when downloading the input; may be repeated")
+	cmdSchedule.PersistentFlags().StringVar(&credentialsFile, "credentials", credentialsFile, "Google service account key file to download the input with")
+	cmdSchedule.PersistentFlags().BoolVar(&offline, "offline", offline, "use the cached copy of a downloaded input instead of downloading it again")
 
 	cmdGen := &cobra.Command{
 		Use:   "gen",
@@ -72,8 +135,8 @@ func main() {
 		Run:   CommandGen,
 	}
 	cmdGen.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
-	cmdGen.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, and .json suffixes will be added)")
-	cmdGen.Flags().BoolVar(&scoreInName, "scorename", scoreInName, "name the output file <prefix>-<score>.json")
+	cmdGen.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdGen.Flags().BoolVar(&scoreInName, "scorename", scoreInName, "name the output files <prefix>-<score>.json and <prefix>-<score>.html")
 	cmdGen.Flags().Float64VarP(&pin, "pin", "p", pin, "the mean percentage that a prior placement will be kept")
 	cmdGen.Flags().Float64VarP(&pindev, "pindev", "d", pindev, "the stddev for how much to vary the pin between attempts")
 	cmdGen.Flags().DurationVarP(&dur, "time", "t", dur, "total time to spend searching")
@@ -83,6 +146,20 @@ func main() {
 	cmdGen.Flags().BoolVar(&weightedWarmup, "weightedwarmup", weightedWarmup, "bias course placement toward low-badness slots during warmup period")
 	cmdGen.Flags().BoolVar(&weightedOptimization, "weightedoptimization", weightedOptimization, "bias course placement toward low-badness slots during optimization period")
 	cmdGen.Flags().BoolVarP(&verbose, "verbose", "v", verbose, "verbose output")
+	cmdGen.Flags().Int64Var(&seed, "seed", seed, "run a deterministic search on one worker with this random seed, counting attempts instead of time")
+	cmdGen.Flags().IntVar(&attempts, "attempts", attempts, "total attempts for a search with --seed")
+	cmdGen.Flags().IntVar(&warmupAttempts, "warmupattempts", warmupAttempts, "attempts without improvement before a search with --seed ends its warmup")
+	cmdGen.Flags().IntVar(&restartLocalAttempts, "restartlocalattempts", restartLocalAttempts, "attempts since a local best before a search with --seed restarts")
+	cmdGen.Flags().IntVar(&restartGlobalAttempts, "restartglobalattempts", restartGlobalAttempts, "attempts since the global best before a search with --seed restarts")
+	cmdGen.Flags().StringVar(&historyFile, "history", historyFile, "write the progress of the search to this CSV file")
+	cmdGen.Flags().DurationVar(&historyInterval, "historyinterval", historyInterval, "how often to write a line to the history file")
+	cmdGen.Flags().BoolVar(&continueSearch, "continue", continueSearch, "start from the schedule in <prefix>.json instead of from scratch")
+	cmdGen.Flags().IntVar(&maxBadness, "max-badness", maxBadness, "exit with status 4 if the final badness is higher than this (-1 for no limit)")
+	cmdGen.Flags().IntVar(&unplaced, "unplaced", unplaced, "allow a schedule to leave out up to this many sections that cannot be placed")
+	cmdGen.Flags().BoolVar(&gitCommit, "git-commit", gitCommit, "commit each new best schedule to the git repository holding it")
+	cmdGen.Flags().StringVar(&notifyURL, "notify", notifyURL, "post a JSON message to this URL for each new best schedule and when the run ends")
+	cmdGen.Flags().StringVar(&archiveDir, "archive", archiveDir, "also save a copy of each new best schedule in this directory")
+	cmdGen.Flags().BoolVar(&archiveHTML, "archivehtml", archiveHTML, "save an .html file with each archived schedule")
 	cmdSchedule.AddCommand(cmdGen)
 
 	cmdOpt := &cobra.Command{
@@ -91,12 +168,18 @@ func main() {
 		Run:   CommandOpt,
 	}
 	cmdOpt.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
-	cmdOpt.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, and .json suffixes will be added)")
-	cmdOpt.Flags().BoolVar(&scoreInName, "scorename", scoreInName, "name the output file <prefix>-<score>.json")
+	cmdOpt.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdOpt.Flags().BoolVar(&scoreInName, "scorename", scoreInName, "name the output files <prefix>-<score>.json and <prefix>-<score>.html")
 	cmdOpt.Flags().Float64VarP(&pin, "pin", "p", pin, "the mean percentage that a prior placement will be kept")
 	cmdOpt.Flags().Float64VarP(&pindev, "pindev", "d", pindev, "the stddev for how much to vary the pin between attempts")
 	cmdOpt.Flags().DurationVarP(&dur, "time", "t", dur, "total time to spend searching")
 	cmdOpt.Flags().BoolVar(&weightedOptimization, "weightedoptimization", weightedOptimization, "bias course placement toward low-badness slots during optimization period")
+	cmdOpt.Flags().IntVar(&maxBadness, "max-badness", maxBadness, "exit with status 4 if the final badness is higher than this (-1 for no limit)")
+	cmdOpt.Flags().IntVar(&unplaced, "unplaced", unplaced, "allow a schedule to leave out up to this many sections that cannot be placed")
+	cmdOpt.Flags().BoolVar(&gitCommit, "git-commit", gitCommit, "commit each new best schedule to the git repository holding it")
+	cmdOpt.Flags().StringVar(&notifyURL, "notify", notifyURL, "post a JSON message to this URL for each new best schedule and when the run ends")
+	cmdOpt.Flags().StringVar(&archiveDir, "archive", archiveDir, "also save a copy of each new best schedule in this directory")
+	cmdOpt.Flags().BoolVar(&archiveHTML, "archivehtml", archiveHTML, "save an .html file with each archived schedule")
 	cmdSchedule.AddCommand(cmdOpt)
 
 	cmdSwap := &cobra.Command{
@@ -105,10 +188,15 @@ func main() {
 		Run:   CommandSwap,
 	}
 	cmdSwap.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
-	cmdSwap.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, and .json suffixes will be added)")
-	cmdSwap.Flags().BoolVar(&scoreInName, "scorename", scoreInName, "name the output file <prefix>-<score>.json")
+	cmdSwap.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdSwap.Flags().BoolVar(&scoreInName, "scorename", scoreInName, "name the output files <prefix>-<score>.json and <prefix>-<score>.html")
 	cmdSwap.Flags().IntVarP(&maxSwapDepth, "max", "m", maxSwapDepth, "maximum number of swaps to attempt")
 	cmdSwap.Flags().BoolVarP(&restartAfterSwap, "restart", "r", restartAfterSwap, "restart after finding a successful swap")
+	cmdSwap.Flags().IntVar(&maxBadness, "max-badness", maxBadness, "exit with status 4 if the final badness is higher than this (-1 for no limit)")
+	cmdSwap.Flags().BoolVar(&gitCommit, "git-commit", gitCommit, "commit each new best schedule to the git repository holding it")
+	cmdSwap.Flags().StringVar(&notifyURL, "notify", notifyURL, "post a JSON message to this URL for each new best schedule and when the run ends")
+	cmdSwap.Flags().StringVar(&archiveDir, "archive", archiveDir, "also save a copy of each new best schedule in this directory")
+	cmdSwap.Flags().BoolVar(&archiveHTML, "archivehtml", archiveHTML, "save an .html file with each archived schedule")
 	cmdSchedule.AddCommand(cmdSwap)
 
 	cmdScore := &cobra.Command{
@@ -116,7 +204,14 @@ func main() {
 		Short: "score and display the current schedule",
 		Run:   CommandScore,
 	}
-	cmdScore.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, and .json suffixes will be added)")
+	cmdScore.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdScore.Flags().StringVarP(&scoreFormat, "format", "f", scoreFormat, "output format (text, markdown, or html)")
+	cmdScore.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdScore.Flags().StringArrayVar(&filter.Instructors, "instructor", filter.Instructors, "only show this instructor's sections; may be repeated")
+	cmdScore.Flags().StringArrayVar(&filter.Courses, "course", filter.Courses, "only show this course (e.g., CS1400) or section (e.g., CS1400-01); may be repeated")
+	cmdScore.Flags().StringArrayVar(&filter.Rooms, "room", filter.Rooms, "only show sections in this room; may be repeated")
+	cmdScore.Flags().BoolVar(&byDepartment, "by-department", byDepartment, "group the report by department (the letters at the start of each course name)")
+	cmdScore.Flags().IntVar(&maxBadness, "max-badness", maxBadness, "exit with status 4 if the final badness is higher than this (-1 for no limit)")
 	cmdSchedule.AddCommand(cmdScore)
 
 	cmdByCourse := &cobra.Command{
@@ -124,7 +219,13 @@ func main() {
 		Short: "print a schedule ordered by course",
 		Run:   CommandByCourse,
 	}
-	cmdByCourse.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, and .json suffixes will be added)")
+	cmdByCourse.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdByCourse.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdByCourse.Flags().StringVar(&byCourseSort, "sort", byCourseSort, "order the sections of each course by instructor, time, or room")
+	cmdByCourse.Flags().StringArrayVar(&filter.Instructors, "instructor", filter.Instructors, "only show this instructor's sections; may be repeated")
+	cmdByCourse.Flags().StringArrayVar(&filter.Courses, "course", filter.Courses, "only show this course (e.g., CS1400) or section (e.g., CS1400-01); may be repeated")
+	cmdByCourse.Flags().StringArrayVar(&filter.Rooms, "room", filter.Rooms, "only show sections in this room; may be repeated")
+	cmdByCourse.Flags().BoolVar(&byDepartment, "by-department", byDepartment, "group the report by department (the letters at the start of each course name)")
 	cmdSchedule.AddCommand(cmdByCourse)
 
 	cmdByInstructor := &cobra.Command{
@@ -132,9 +233,351 @@ func main() {
 		Short: "print a schedule ordered by instructor",
 		Run:   CommandByInstructor,
 	}
-	cmdByInstructor.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, and .json suffixes will be added)")
+	cmdByInstructor.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdByInstructor.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdByInstructor.Flags().StringVar(&byInstructorSort, "sort", byInstructorSort, "order each instructor's sections by course, time, or room")
+	cmdByInstructor.Flags().StringArrayVar(&filter.Instructors, "instructor", filter.Instructors, "only show this instructor's sections; may be repeated")
+	cmdByInstructor.Flags().StringArrayVar(&filter.Courses, "course", filter.Courses, "only show this course (e.g., CS1400) or section (e.g., CS1400-01); may be repeated")
+	cmdByInstructor.Flags().StringArrayVar(&filter.Rooms, "room", filter.Rooms, "only show sections in this room; may be repeated")
 	cmdSchedule.AddCommand(cmdByInstructor)
 
+	cmdByRoom := &cobra.Command{
+		Use:   "byroom",
+		Short: "print a schedule ordered by room",
+		Run:   CommandByRoom,
+	}
+	cmdByRoom.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdByRoom.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdByRoom.Flags().StringArrayVar(&filter.Instructors, "instructor", filter.Instructors, "only show this instructor's sections; may be repeated")
+	cmdByRoom.Flags().StringArrayVar(&filter.Courses, "course", filter.Courses, "only show this course (e.g., CS1400) or section (e.g., CS1400-01); may be repeated")
+	cmdByRoom.Flags().StringArrayVar(&filter.Rooms, "room", filter.Rooms, "only show sections in this room; may be repeated")
+	cmdSchedule.AddCommand(cmdByRoom)
+
+	cmdByTime := &cobra.Command{
+		Use:   "bytime",
+		Short: "print a schedule ordered by time",
+		Run:   CommandByTime,
+	}
+	cmdByTime.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdByTime.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdByTime.Flags().StringArrayVar(&filter.Instructors, "instructor", filter.Instructors, "only show this instructor's sections; may be repeated")
+	cmdByTime.Flags().StringArrayVar(&filter.Courses, "course", filter.Courses, "only show this course (e.g., CS1400) or section (e.g., CS1400-01); may be repeated")
+	cmdByTime.Flags().StringArrayVar(&filter.Rooms, "room", filter.Rooms, "only show sections in this room; may be repeated")
+	cmdSchedule.AddCommand(cmdByTime)
+
+	cmdFree := &cobra.Command{
+		Use:   "free",
+		Short: "list the open room/time slots in the current schedule",
+		Run:   CommandFree,
+	}
+	cmdFree.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdFree.Flags().StringVar(&roomTag, "tag", roomTag, "only list rooms with this tag")
+	cmdFree.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdFree)
+
+	cmdCompare := &cobra.Command{
+		Use:   "compare a.json b.json ...",
+		Short: "compare the scores of several candidate schedules side by side",
+		Run:   CommandCompare,
+	}
+	cmdCompare.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdCompare.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdCompare)
+
+	cmdDiff := &cobra.Command{
+		Use:   "diff old.json new.json",
+		Short: "show the differences between two schedules",
+		Run:   CommandDiff,
+	}
+	cmdDiff.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdDiff.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdDiff)
+
+	cmdExport := &cobra.Command{
+		Use:   "export",
+		Short: "export the current schedule in another format",
+		Run:   CommandExport,
+	}
+	cmdExport.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdExport.Flags().StringVarP(&exportFormat, "format", "f", exportFormat, "output format (csv, sis, 25live, or dot)")
+	cmdExport.Flags().StringVar(&sisMappingFile, "mapping", sisMappingFile, "JSON file describing the SIS import layout (implies --format sis)")
+	cmdExport.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdExport)
+
+	cmdPressure := &cobra.Command{
+		Use:   "pressure",
+		Short: "show how many sections could use each room/time slot",
+		Run:   CommandPressure,
+	}
+	cmdPressure.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdPressure.Flags().StringVarP(&pressureFormat, "format", "f", pressureFormat, "output format (text or html)")
+	cmdPressure.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdPressure)
+
+	cmdInput := &cobra.Command{
+		Use:   "input [input.json]",
+		Short: "write the input as JSON, or convert an input written as JSON to text",
+		Run:   CommandInput,
+	}
+	cmdInput.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdInput.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdInput)
+
+	cmdMove := &cobra.Command{
+		Use:   "move COURSE ROOM TIME",
+		Short: "move one course in the current schedule",
+		Run:   CommandMove,
+	}
+	cmdMove.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdSchedule.AddCommand(cmdMove)
+
+	cmdWhatIf := &cobra.Command{
+		Use:   "whatif [moves.txt | --remove-instructor NAME]",
+		Short: "evaluate a list of proposed moves without changing the schedule",
+		Run:   CommandWhatIf,
+	}
+	cmdWhatIf.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdWhatIf.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdWhatIf.Flags().StringVar(&whatIfRemove, "remove-instructor", whatIfRemove, "instead of reading moves, see how the schedule fares without this instructor")
+	cmdWhatIf.Flags().BoolVar(&whatIfDrop, "drop", whatIfDrop, "with --remove-instructor, drop the instructor's sections instead of handing them to STAFF")
+	cmdWhatIf.Flags().DurationVarP(&whatIfBudget, "time", "t", whatIfBudget, "with --remove-instructor, time to spend searching")
+	cmdWhatIf.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
+	cmdSchedule.AddCommand(cmdWhatIf)
+
+	cmdRoomLoss := &cobra.Command{
+		Use:   "roomloss [ROOM...]",
+		Short: "see how the current schedule would fare without each room",
+		Run:   CommandRoomLoss,
+	}
+	cmdRoomLoss.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdRoomLoss.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdRoomLoss.Flags().DurationVarP(&roomLossBudget, "time", "t", roomLossBudget, "time to spend searching without each room")
+	cmdRoomLoss.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
+	cmdSchedule.AddCommand(cmdRoomLoss)
+
+	cmdYear := &cobra.Command{
+		Use:   "year [year.txt]",
+		Short: "schedule the terms of a year together",
+		Run:   CommandYear,
+	}
+	cmdYear.Flags().DurationVarP(&yearBudget, "time", "t", yearBudget, "time to spend on each search of a term")
+	cmdYear.Flags().IntVar(&yearRounds, "rounds", yearRounds, "number of times to refine each term in turn")
+	cmdYear.Flags().BoolVar(&yearSequential, "sequential", yearSequential, "keep the first term as it is and fit each later term to the one before it")
+	cmdYear.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
+	cmdSchedule.AddCommand(cmdYear)
+
+	cmdMerge := &cobra.Command{
+		Use:   "merge [departments.txt]",
+		Short: "schedule several departments together in a shared pool of rooms and times",
+		Run:   CommandMerge,
+	}
+	cmdMerge.Flags().StringVar(&mergePrefix, "prefix", mergePrefix, "file name prefix for the combined input and schedule")
+	cmdMerge.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
+	cmdMerge.Flags().Float64VarP(&pin, "pin", "p", pin, "the mean percentage that a prior placement will be kept")
+	cmdMerge.Flags().Float64VarP(&pindev, "pindev", "d", pindev, "the stddev for how much to vary the pin between attempts")
+	cmdMerge.Flags().DurationVarP(&dur, "time", "t", dur, "total time to spend searching")
+	cmdMerge.Flags().DurationVarP(&warmup, "warmup", "w", warmup, "time to spend finding best random schedule before refining it")
+	cmdMerge.Flags().DurationVarP(&restartLocal, "restartlocal", "l", restartLocal, "restart after this long since finding a local best score")
+	cmdMerge.Flags().DurationVarP(&restartGlobal, "restartglobal", "g", restartGlobal, "restart after this long since finding the global best score")
+	cmdSchedule.AddCommand(cmdMerge)
+
+	cmdFairness := &cobra.Command{
+		Use:   "fairness [schedule.json | prefix ...]",
+		Short: "report how well each instructor's preferences are honored",
+		Run:   CommandFairness,
+	}
+	cmdFairness.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdFairness.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdFairness)
+
+	cmdPath := &cobra.Command{
+		Use:   "path [COURSE...]",
+		Short: "check that a student can take a list of courses without a time conflict",
+		Run:   CommandPath,
+	}
+	cmdPath.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdPath.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdPath.Flags().StringVar(&pathsFile, "paths", pathsFile, "check each list of courses in this file, one per line as NAME: COURSE COURSE ...")
+	cmdSchedule.AddCommand(cmdPath)
+
+	cmdChanges := &cobra.Command{
+		Use:   "changes [proposed.json]",
+		Short: "list the sections that changed since the schedule was published, by instructor",
+		Run:   CommandChanges,
+	}
+	cmdChanges.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdChanges.Flags().StringVar(&changesPublished, "published", changesPublished, "the published schedule (default <prefix>-published.json)")
+	cmdChanges.Flags().StringVar(&changesReasons, "reasons", changesReasons, "file giving the reason for each change, one per line as SECTION: reason")
+	cmdChanges.Flags().StringVarP(&changesFormat, "format", "f", changesFormat, "output format (text or csv)")
+	cmdChanges.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdChanges)
+
+	cmdSnapshot := &cobra.Command{
+		Use:   "snapshot",
+		Short: "save, list, and restore named copies of the schedule",
+	}
+	cmdSnapshotSave := &cobra.Command{
+		Use:   "save NAME",
+		Short: "save a copy of the current schedule under a name",
+		Run:   CommandSnapshotSave,
+	}
+	cmdSnapshotSave.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdSnapshotSave.Flags().StringVar(&snapshotNote, "note", snapshotNote, "a note to keep with the snapshot")
+	cmdSnapshotSave.Flags().BoolVar(&snapshotForce, "force", snapshotForce, "replace a snapshot with the same name")
+	cmdSnapshot.AddCommand(cmdSnapshotSave)
+	cmdSnapshotRestore := &cobra.Command{
+		Use:   "restore NAME",
+		Short: "replace the current schedule with a saved snapshot",
+		Run:   CommandSnapshotRestore,
+	}
+	cmdSnapshotRestore.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdSnapshot.AddCommand(cmdSnapshotRestore)
+	cmdSnapshotList := &cobra.Command{
+		Use:   "list",
+		Short: "list the saved snapshots",
+		Run:   CommandSnapshotList,
+	}
+	cmdSnapshotList.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdSnapshotList.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSnapshot.AddCommand(cmdSnapshotList)
+	cmdSchedule.AddCommand(cmdSnapshot)
+
+	cmdTUI := &cobra.Command{
+		Use:   "tui",
+		Short: "adjust the current schedule by hand in a full-screen terminal editor",
+		Run:   CommandTUI,
+	}
+	cmdTUI.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdSchedule.AddCommand(cmdTUI)
+
+	cmdCalendar := &cobra.Command{
+		Use:   "calendar",
+		Short: "show a weekly calendar for each instructor",
+		Run:   CommandCalendar,
+	}
+	cmdCalendar.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdCalendar.Flags().StringVarP(&calendarFormat, "format", "f", calendarFormat, "output format (text, html, or ics)")
+	cmdCalendar.Flags().StringVar(&calendarInstructor, "instructor", calendarInstructor, "only show the calendar for this instructor")
+	cmdCalendar.Flags().StringVar(&calendarFirstDay, "first-day", calendarFirstDay, "first day of classes, for ics output (YYYY-MM-DD)")
+	cmdCalendar.Flags().StringVar(&calendarLastDay, "last-day", calendarLastDay, "last day of classes, for ics output (YYYY-MM-DD)")
+	cmdCalendar.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdCalendar)
+
+	cmdPublish := &cobra.Command{
+		Use:   "publish",
+		Short: "render the current schedule as a static web site",
+		Run:   CommandPublish,
+	}
+	cmdPublish.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdPublish.Flags().StringVar(&publishDir, "dir", publishDir, "directory to write the site to (default is the prefix followed by -site)")
+	cmdSchedule.AddCommand(cmdPublish)
+
+	cmdStats := &cobra.Command{
+		Use:   "stats",
+		Short: "summarize the input and the current schedule",
+		Run:   CommandStats,
+	}
+	cmdStats.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdStats.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdStats.Flags().BoolVar(&byDepartment, "by-department", byDepartment, "group the report by department (the letters at the start of each course name)")
+	cmdSchedule.AddCommand(cmdStats)
+
+	cmdLint := &cobra.Command{
+		Use:   "lint",
+		Short: "list constraints in the input that can have no effect",
+		Run:   CommandLint,
+	}
+	cmdLint.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdLint.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdLint)
+
+	cmdTune := &cobra.Command{
+		Use:   "tune",
+		Short: "find good gen settings for this input by trying many short searches",
+		Run:   CommandTune,
+	}
+	cmdTune.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
+	cmdTune.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdTune.Flags().DurationVarP(&tuneBudget, "time", "t", tuneBudget, "time to spend on each search")
+	cmdTune.Flags().IntVar(&tuneTrials, "trials", tuneTrials, "number of combinations to sample at random (0 to try them all)")
+	cmdTune.Flags().IntVar(&tuneRepeat, "repeat", tuneRepeat, "number of searches to run with each combination")
+	cmdTune.Flags().Float64SliceVar(&tunePins, "pin", tunePins, "pin values to try")
+	cmdTune.Flags().Float64SliceVar(&tunePinDevs, "pindev", tunePinDevs, "pindev values to try")
+	cmdTune.Flags().DurationSliceVar(&tuneWarmups, "warmup", tuneWarmups, "warmup times to try")
+	cmdTune.Flags().DurationSliceVar(&tuneRestartLocals, "restartlocal", tuneRestartLocals, "restartlocal times to try")
+	cmdTune.Flags().DurationSliceVar(&tuneRestartGlobals, "restartglobal", tuneRestartGlobals, "restartglobal times to try")
+	cmdTune.Flags().BoolVar(&weightedWarmup, "weightedwarmup", weightedWarmup, "bias course placement toward low-badness slots during warmup period")
+	cmdTune.Flags().BoolVar(&weightedOptimization, "weightedoptimization", weightedOptimization, "bias course placement toward low-badness slots during optimization period")
+	cmdTune.Flags().StringVar(&tuneSaveFile, "save", tuneSaveFile, "write the best settings to this config file")
+	cmdTune.Flags().StringVarP(&outputFile, "output", "o", outputFile, "write to this file instead of standard output")
+	cmdSchedule.AddCommand(cmdTune)
+
+	cmdServe := &cobra.Command{
+		Use:   "serve",
+		Short: "serve the web page for viewing and editing the current schedule",
+		Run:   CommandServe,
+	}
+	cmdServe.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdServe.Flags().StringVar(&serveAddr, "addr", serveAddr, "address to listen on (use :8080 to allow other machines to connect)")
+	cmdServe.Flags().StringVar(&grpcAddr, "grpc", grpcAddr, "also offer the API over gRPC at this address (e.g., localhost:8081)")
+	cmdServe.Flags().IntVar(&workers, "workers", workers, "maximum number of concurrent workers for each search started through the API")
+	cmdServe.Flags().IntVar(&apiMaxJobs, "maxjobs", apiMaxJobs, "maximum number of searches the API will run at once")
+	cmdServe.Flags().DurationVar(&apiMaxTime, "maxtime", apiMaxTime, "maximum time for a search started through the API")
+	cmdServe.Flags().BoolVar(&serveGen, "gen", serveGen, "run a gen search while serving and stream each new best schedule to the page")
+	cmdServe.Flags().BoolVar(&continueSearch, "continue", continueSearch, "with --gen, start from the schedule in <prefix>.json instead of from scratch")
+	cmdServe.Flags().Float64VarP(&pin, "pin", "p", pin, "with --gen, the mean percentage that a prior placement will be kept")
+	cmdServe.Flags().Float64VarP(&pindev, "pindev", "d", pindev, "with --gen, the stddev for how much to vary the pin between attempts")
+	cmdServe.Flags().DurationVarP(&dur, "time", "t", dur, "with --gen, total time to spend searching")
+	cmdServe.Flags().DurationVarP(&warmup, "warmup", "w", warmup, "with --gen, time to spend finding best random schedule before refining it")
+	cmdServe.Flags().DurationVarP(&restartLocal, "restartlocal", "l", restartLocal, "with --gen, restart after this long since finding a local best score")
+	cmdServe.Flags().DurationVarP(&restartGlobal, "restartglobal", "g", restartGlobal, "with --gen, restart after this long since finding the global best score")
+	cmdServe.Flags().IntVar(&unplaced, "unplaced", unplaced, "with --gen, allow a schedule to leave out up to this many sections that cannot be placed")
+	cmdServe.Flags().BoolVar(&gitCommit, "git-commit", gitCommit, "with --gen, commit each new best schedule to the git repository holding it")
+	cmdServe.Flags().StringVar(&notifyURL, "notify", notifyURL, "with --gen, post a JSON message to this URL for each new best schedule")
+	cmdServe.Flags().StringVar(&archiveDir, "archive", archiveDir, "with --gen, also save a copy of each new best schedule in this directory")
+	cmdSchedule.AddCommand(cmdServe)
+
+	cmdDaemon := &cobra.Command{
+		Use:   "daemon",
+		Short: "keep re-fetching the input and re-optimizing the schedule on a timetable",
+		Run:   CommandDaemon,
+	}
+	cmdDaemon.Flags().IntVar(&workers, "workers", workers, "number of concurrent workers")
+	cmdDaemon.Flags().StringVar(&prefix, "prefix", prefix, "file name prefix (.txt, .json, and .html suffixes will be added)")
+	cmdDaemon.Flags().StringVar(&daemonInput, "input", daemonInput, "file or URL (including a Google Sheets sharing link) to fetch the input from (default is the prefix followed by .txt)")
+	cmdDaemon.Flags().DurationVar(&daemonEvery, "every", daemonEvery, "time between the start of one run and the next")
+	cmdDaemon.Flags().StringSliceVar(&daemonAt, "at", daemonAt, "start runs at these times of day (HH:MM) instead of using --every")
+	cmdDaemon.Flags().Float64VarP(&pin, "pin", "p", pin, "the mean percentage that a prior placement will be kept")
+	cmdDaemon.Flags().Float64VarP(&pindev, "pindev", "d", pindev, "the stddev for how much to vary the pin between attempts")
+	cmdDaemon.Flags().DurationVarP(&dur, "time", "t", dur, "time to spend searching in each run")
+	cmdDaemon.Flags().DurationVarP(&warmup, "warmup", "w", warmup, "time to spend finding best random schedule before refining it")
+	cmdDaemon.Flags().DurationVarP(&restartLocal, "restartlocal", "l", restartLocal, "restart after this long since finding a local best score")
+	cmdDaemon.Flags().DurationVarP(&restartGlobal, "restartglobal", "g", restartGlobal, "restart after this long since finding the global best score")
+	cmdDaemon.Flags().BoolVar(&weightedWarmup, "weightedwarmup", weightedWarmup, "bias course placement toward low-badness slots during warmup period")
+	cmdDaemon.Flags().BoolVar(&weightedOptimization, "weightedoptimization", weightedOptimization, "bias course placement toward low-badness slots during optimization period")
+	cmdDaemon.Flags().BoolVar(&gitCommit, "git-commit", gitCommit, "commit each new best schedule to the git repository holding it")
+	cmdDaemon.Flags().StringVar(&notifyURL, "notify", notifyURL, "post a JSON message to this URL for each new best schedule")
+	cmdDaemon.Flags().StringVar(&archiveDir, "archive", archiveDir, "also save a copy of each new best schedule in this directory")
+	cmdDaemon.Flags().BoolVar(&archiveHTML, "archivehtml", archiveHTML, "save an .html file with each archived schedule")
+	cmdSchedule.AddCommand(cmdDaemon)
+
+	cmdBench := &cobra.Command{
+		Use:   "bench",
+		Short: "benchmark placement and scoring on a synthetic input",
+		Run:   CommandBench,
+	}
+	cmdBench.Flags().IntVar(&benchRooms, "rooms", benchRooms, "number of rooms to generate")
+	cmdBench.Flags().IntVar(&benchTimes, "times", benchTimes, "number of time slots to generate")
+	cmdBench.Flags().IntVar(&benchInstructors, "instructors", benchInstructors, "number of instructors to generate")
+	cmdBench.Flags().IntVar(&benchCourses, "courses", benchCourses, "number of courses per instructor")
+	cmdBench.Flags().Float64Var(&benchConflicts, "conflicts", benchConflicts, "probability that a given pair of courses conflicts")
+	cmdBench.Flags().Int64Var(&benchSeed, "seed", benchSeed, "random seed for the generated input and the search")
+	cmdBench.Flags().DurationVarP(&benchDuration, "time", "t", benchDuration, "time to spend in the placement/scoring loop")
+	cmdBench.Flags().Float64VarP(&pin, "pin", "p", pin, "the percentage that a prior placement will be kept")
+	cmdBench.Flags().BoolVar(&weightedOptimization, "weightedoptimization", weightedOptimization, "bias course placement toward low-badness slots")
+	cmdSchedule.AddCommand(cmdBench)
+
+	documentEnv(cmdSchedule)
 	cmdSchedule.Execute()
 }
 
@@ -143,185 +586,113 @@ func CommandGen(cmd *cobra.Command, args []string) {
 		log.Fatalf("unknown option: %s", strings.Join(args, " "))
 	}
 
-	if workers < 1 {
-		log.Fatalf("workers must be >= 1")
-	}
-	if pin < 0.0 || pin > 100.0 {
-		log.Fatalf("pin must be between 0 and 100")
-	}
-	if pindev < 0.0 {
-		log.Fatalf("pindev must be >= 0")
-	}
-	if dur <= 0 {
-		log.Fatalf("time must be > 0")
-	}
-	if warmup <= 0 {
-		log.Fatalf("warmup time must be > 0")
+	settings := genSettingsFromFlags()
+	if err := settings.Check(); err != nil {
+		log.Fatalf("%v", err)
 	}
-	if restartLocal <= 0 {
-		log.Fatalf("restartlocal time must be > 0")
+	deterministic := cmd.Flags().Changed("seed")
+	det := engine.DeterministicSettings{
+		Seed:                 seed,
+		Attempts:             attempts,
+		Pin:                  pin,
+		PinDev:               pindev,
+		Warmup:               warmupAttempts,
+		RestartLocal:         restartLocalAttempts,
+		RestartGlobal:        restartGlobalAttempts,
+		WeightedWarmup:       weightedWarmup,
+		WeightedOptimization: weightedOptimization,
+		Unplaced:             unplaced,
+	}
+	if deterministic {
+		if err := det.Check(); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if runInfo != nil {
+			runInfo.Seed = seed
+		}
 	}
-	if restartGlobal <= 0 {
-		log.Fatalf("restartglobal time must be > 0")
+	if historyInterval <= 0 {
+		log.Fatalf("historyinterval must be > 0")
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	options := engine.Options{
+		GenSettings: settings,
+		Report:      true,
+		Verbose:     verbose,
+		OnBest: func(schedule engine.Schedule) {
+			printSchedule(data, schedule)
+
+			// write schedule to .json and .html files
+			writeOutputFiles(data, schedule)
+		},
+		OnReport: func(schedule engine.Schedule) {
+			printSchedule(data, schedule)
+		},
+	}
+	var events *EventLog
+	if !isRemote(prefix) {
+		// there is nowhere to append events in a bucket
+		events = openEventLog(prefix + ".events.jsonl")
+		options.OnEvent = events.Write
+	}
+	if continueSearch {
+		// pick up where an earlier run left off: the warmup refines
+		// the saved schedule and only a better one will be written
+		options.Start = data.Score(readPlacements(data, prefix+".json"))
+		printSchedule(data, options.Start)
+		log.Printf("continuing from %s.json with a badness score of %d", prefix, options.Start.Badness)
+	}
+	var history *HistoryLog
+	if historyFile != "" {
+		history = createHistoryLog(historyFile, time.Now())
+		options.OnProgress = history.Progress
+	}
+	catchInterrupt()
+	var globalBest engine.Schedule
+	var stats engine.Stats
+	var err error
+	if deterministic {
+		log.Printf("starting deterministic search with seed %d", seed)
+		globalBest, stats, err = engine.SearchDeterministic(interruptContext, data, det, options)
+	} else {
+		log.Printf("starting main search")
+		globalBest, stats, err = engine.Search(interruptContext, data, options)
 	}
-
-	// get the input data
-	lines, err := fetchFile(prefix + ".txt")
 	if err != nil {
-		log.Fatalf("%v", err)
+		log.Printf("%v", err)
+		os.Exit(ExitInfeasible)
 	}
 
-	// parse it
-	data, err := Parse(prefix+".txt", lines)
-	if err != nil {
-		log.Fatalf("%v", err)
+	if events != nil {
+		events.Close()
 	}
+	if history != nil {
+		history.Close()
+	}
+	if stats.GaveUp {
+		notifyFinished(globalBest, "infeasible")
+		os.Exit(ExitInfeasible)
+	}
+	exitStatus(globalBest)
+}
 
-	// generate the list of sections and constraints
-	sections := data.MakeSectionList()
-	log.Printf("starting main search")
-	startTime := time.Now()
-	lastReport := startTime
-
-	//
-	// start the main search
-	//
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
-
-	mode := ModeWarmup
-	baseline := Schedule{Badness: worst}
-	localBest := Schedule{Badness: worst}
-	globalBest := Schedule{Badness: worst}
-	lastImprovement := time.Now()
-	successfullAttempts := 0
-	failedAttempts := 0
-
-	for worker := 0; worker < workers; worker++ {
-		wg.Add(1)
-		go func(workerN int) {
-			for {
-				now := time.Now()
-				if time.Since(startTime) > dur {
-					break
-				}
-
-				mutex.Lock()
-				if time.Since(lastReport) >= reportInterval {
-					lastReport = lastReport.Add(reportInterval)
-					data.PrintSchedule(globalBest)
-					log.Printf("so far: %d runs in %v, badness score of %d",
-						successfullAttempts+failedAttempts,
-						lastReport.Sub(startTime),
-						globalBest.Badness)
-				}
-
-				switch {
-				case mode == ModeWarmup:
-					// is it time to move on to refinement?
-					if now.Sub(lastImprovement) >= warmup {
-						if len(localBest.Placements) == 0 {
-							// we did not find any valid schedules
-							log.Fatalf("no valid schedule found in warmup period")
-						}
-						baseline = localBest
-						lastImprovement = now
-						log.Printf("ending warmup")
-						mode = ModeLocalBest
-					}
-
-				// is it time to restart from local or global best?
-				case mode == ModeLocalBest && now.Sub(lastImprovement) >= restartLocal:
-					fallthrough
-				case mode == ModeGlobalBest && now.Sub(lastImprovement) >= restartGlobal:
-					baseline = Schedule{Badness: worst}
-					localBest = Schedule{Badness: worst}
-					lastImprovement = now
-					log.Printf("restarting")
-					mode = ModeWarmup
-				}
-
-				base := baseline.Placements
-				mutex.Unlock()
-
-				// the pin value to use for this round
-				var localPin float64
-				switch {
-				case pin >= 100.0:
-					localPin = 100.0
-				case pin <= 0.0:
-					localPin = 0.0
-				default:
-					localPin = -1.0
-					for localPin >= 100.0 || localPin < 0.0 {
-						localPin = rand.NormFloat64()*pindev + pin
-					}
-				}
-
-				// generate a schedule
-				weighted := mode == ModeWarmup && weightedWarmup ||
-					(mode == ModeLocalBest || mode == ModeGlobalBest) && weightedOptimization
-				candidate := data.PlaceSections(sections, base, localPin, weighted)
-				if len(candidate) == 0 {
-					mutex.Lock()
-					failedAttempts++
-					mutex.Unlock()
-					continue
-				}
-
-				// score it
-				schedule := data.Score(candidate)
-
-				// see how it compares
-				now = time.Now()
-				mutex.Lock()
-				successfullAttempts++
-
-				if schedule.Badness < globalBest.Badness {
-					// new global best? always keep it
-					globalBest = schedule
-					localBest = schedule
-
-					if mode == ModeWarmup {
-						// if we are in a warmup, just keep going
-						log.Printf("global best of %d found in warmup", schedule.Badness)
-					} else {
-						// if we are in a refinement period, reset the counter and the baseline
-						baseline = schedule
-						lastImprovement = now
-						log.Printf("global best of %d found (pin %.1f)", schedule.Badness, localPin)
-						mode = ModeGlobalBest
-					}
-					data.PrintSchedule(schedule)
-
-					// write schedule to .json file
-					writeJsonFile(data, candidate, schedule.Badness)
-				} else if schedule.Badness < localBest.Badness {
-					// new local best?
-					switch {
-					case mode == ModeWarmup && len(base) > 0:
-						// it was a holdover from before a restart, so discard it
-
-					case mode == ModeWarmup:
-						localBest = schedule
-						log.Printf("warmup best of %d found (global best is %d)", schedule.Badness, globalBest.Badness)
-
-					default:
-						// refinement
-						baseline = schedule
-						localBest = schedule
-						lastImprovement = now
-						log.Printf("local best of %d found (pin %.1f, global best is %d)", schedule.Badness, localPin, globalBest.Badness)
-					}
-				}
-
-				mutex.Unlock()
-			}
-			wg.Done()
-		}(worker)
+// genSettingsFromFlags collects the gen settings from the command line
+func genSettingsFromFlags() engine.GenSettings {
+	return engine.GenSettings{
+		Workers:              workers,
+		Pin:                  pin,
+		PinDev:               pindev,
+		Duration:             dur,
+		Warmup:               warmup,
+		RestartLocal:         restartLocal,
+		RestartGlobal:        restartGlobal,
+		WeightedWarmup:       weightedWarmup,
+		WeightedOptimization: weightedOptimization,
+		Unplaced:             unplaced,
 	}
-	wg.Wait()
-	log.Printf("%d successful and %d failed attempts in %v", successfullAttempts, failedAttempts, time.Since(startTime))
 }
 
 func CommandOpt(cmd *cobra.Command, args []string) {
@@ -329,137 +700,43 @@ func CommandOpt(cmd *cobra.Command, args []string) {
 		log.Fatalf("unknown option: %s", strings.Join(args, " "))
 	}
 
-	if workers < 1 {
-		log.Fatalf("workers must be >= 1")
-	}
-	if pin < 0.0 || pin > 100.0 {
-		log.Fatalf("pin must be between 0 and 100")
-	}
-	if pindev < 0.0 {
-		log.Fatalf("pindev must be >= 0")
-	}
-	if dur <= 0 {
-		log.Fatalf("time must be > 0")
-	}
-	if restartGlobal <= 0 {
-		log.Fatalf("restartglobal time must be > 0")
-	}
-
-	// get the input data
-	lines, err := fetchFile(prefix + ".txt")
-	if err != nil {
+	settings := genSettingsFromFlags()
+	if err := settings.Check(); err != nil {
 		log.Fatalf("%v", err)
 	}
 
-	// parse it
-	data, err := Parse(prefix+".txt", lines)
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
-
-	// generate the list of sections and constraints
-	sections := data.MakeSectionList()
-	startTime := time.Now()
-	lastReport := startTime
+	// get the input data and parse it
+	data := readInputData()
 
 	// read the starting schedule
-	fp, err := os.Open(prefix + ".json")
-	if err != nil {
-		if err == os.ErrNotExist {
-			log.Fatalf("the list of course placements must be in %s.json", prefix)
-		} else {
-			log.Fatalf("opening %s: %v", prefix+".json", err)
-		}
-	}
-	placements, err := data.ReadJSON(fp)
-	if err != nil {
-		log.Fatalf("reading %s: %v", prefix+".json", err)
-	}
-	if err = fp.Close(); err != nil {
-		log.Fatalf("closing %s: %v", prefix+".json", err)
-	}
+	placements := readPlacements(data, prefix+".json")
 
-	globalBest := data.Score(placements)
-	data.PrintSchedule(globalBest)
+	start := data.Score(placements)
+	printSchedule(data, start)
+	catchInterrupt()
 	log.Printf("attempting to optimize the schedule with no restarts")
 
-	//
-	// start the main search
-	//
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
-
-	successfullAttempts := 0
-	failedAttempts := 0
-
-	for worker := 0; worker < workers; worker++ {
-		wg.Add(1)
-		go func(workerN int) {
-			for {
-				if time.Since(startTime) > dur {
-					break
-				}
-
-				mutex.Lock()
-				if time.Since(lastReport) >= reportInterval {
-					lastReport = lastReport.Add(reportInterval)
-					data.PrintSchedule(globalBest)
-					log.Printf("so far: %d runs in %v, badness score of %d",
-						successfullAttempts+failedAttempts,
-						lastReport.Sub(startTime),
-						globalBest.Badness)
-				}
-
-				base := globalBest.Placements
-				mutex.Unlock()
-
-				// the pin value to use for this round
-				var localPin float64
-				switch {
-				case pin >= 100.0:
-					localPin = 100.0
-				case pin <= 0.0:
-					localPin = 0.0
-				default:
-					localPin = -1.0
-					for localPin >= 100.0 || localPin < 0.0 {
-						localPin = rand.NormFloat64()*pindev + pin
-					}
-				}
-
-				// generate a schedule
-				weighted := weightedOptimization
-				candidate := data.PlaceSections(sections, base, localPin, weighted)
-				if len(candidate) == 0 {
-					mutex.Lock()
-					failedAttempts++
-					mutex.Unlock()
-					continue
-				}
-
-				// score it
-				schedule := data.Score(candidate)
-
-				// see how it compares
-				mutex.Lock()
-				successfullAttempts++
-
-				if schedule.Badness < globalBest.Badness {
-					// new global best? always keep it
-					globalBest = schedule
-					log.Printf("global best of %d found (pin %.1f)", schedule.Badness, localPin)
-					data.PrintSchedule(schedule)
-
-					// write schedule to .json file
-					writeJsonFile(data, candidate, schedule.Badness)
-				}
-				mutex.Unlock()
-			}
-			wg.Done()
-		}(worker)
+	options := engine.Options{
+		GenSettings: settings,
+		Start:       start,
+		NoRestarts:  true,
+		Report:      true,
+		OnBest: func(schedule engine.Schedule) {
+			printSchedule(data, schedule)
+
+			// write schedule to .json and .html files
+			writeOutputFiles(data, schedule)
+		},
+		OnReport: func(schedule engine.Schedule) {
+			printSchedule(data, schedule)
+		},
+	}
+	globalBest, _, err := engine.Search(interruptContext, data, options)
+	if err != nil {
+		log.Printf("%v", err)
+		os.Exit(ExitInfeasible)
 	}
-	wg.Wait()
-	log.Printf("%d successful and %d failed attempts in %v", successfullAttempts, failedAttempts, time.Since(startTime))
+	exitStatus(globalBest)
 }
 
 func CommandSwap(cmd *cobra.Command, args []string) {
@@ -474,43 +751,21 @@ func CommandSwap(cmd *cobra.Command, args []string) {
 		log.Fatalf("max must be >= 1")
 	}
 
-	// get the input data
-	lines, err := fetchFile(prefix + ".txt")
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
-
-	// parse it
-	data, err := Parse(prefix+".txt", lines)
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
+	// get the input data and parse it
+	data := readInputData()
 
 	// generate the list of sections and constraints
-	sections := data.MakeSectionList()
+	sections := makeSectionList(data)
 
 	// read the starting schedule
-	fp, err := os.Open(prefix + ".json")
-	if err != nil {
-		if err == os.ErrNotExist {
-			log.Fatalf("the list of course placements must be in %s.json", prefix)
-		} else {
-			log.Fatalf("opening %s: %v", prefix+".json", err)
-		}
-	}
-	placements, err := data.ReadJSON(fp)
-	if err != nil {
-		log.Fatalf("reading %s: %v", prefix+".json", err)
-	}
-	if err = fp.Close(); err != nil {
-		log.Fatalf("closing %s: %v", prefix+".json", err)
-	}
+	placements := readPlacements(data, prefix+".json")
 
 	globalBest := data.Score(placements)
 	newBest := globalBest
 	repeat := true
+	catchInterrupt()
 
-	for repeat {
+	for repeat && !isInterrupted() {
 		repeat = false
 		log.Printf("starting a swap search with maximum of %d swaps", maxSwapDepth)
 		log.Printf("trying to beat a badness score of %d", globalBest.Badness)
@@ -519,33 +774,37 @@ func CommandSwap(cmd *cobra.Command, args []string) {
 		var wg sync.WaitGroup
 		var mutex sync.Mutex
 
-		nextToDisplace := 0
+		// split the search at the first move so that a few expensive
+		// subtrees do not leave the other workers idle
+		tasks := data.SwapTasks(sections, globalBest)
+		nextTask := 0
 
 		for worker := 0; worker < workers; worker++ {
 			wg.Add(1)
 			go func() {
+				scratch := new(engine.ScoreScratch)
 				for {
 					mutex.Lock()
 
 					// nothing to do?
-					if nextToDisplace >= len(sections) {
+					if nextTask >= len(tasks) || isInterrupted() {
 						mutex.Unlock()
 						break
 					}
 
-					n := nextToDisplace
-					nextToDisplace++
+					task := tasks[nextTask]
+					nextTask++
 					mutex.Unlock()
 
-					best := data.SearchSwaps(sections, globalBest, maxSwapDepth, n)
+					best := data.SearchSwaps(interruptContext, sections, globalBest, maxSwapDepth, task, scratch)
 
 					mutex.Lock()
 					if best.Badness < newBest.Badness && len(best.Placements) > 0 {
-						log.Printf("swapping found a new best score of %d", best.Badness)
+						log.Print(colorize(ansiBold+ansiGreen, fmt.Sprintf("swapping found a new best score of %d", best.Badness)))
 						newBest = best
 						repeat = restartAfterSwap
-						data.PrintSchedule(newBest)
-						writeJsonFile(data, best.Placements, best.Badness)
+						printSchedule(data, newBest)
+						writeOutputFiles(data, best)
 					}
 					mutex.Unlock()
 				}
@@ -564,6 +823,7 @@ func CommandSwap(cmd *cobra.Command, args []string) {
 			}
 		}
 	}
+	exitStatus(globalBest)
 }
 
 func CommandScore(cmd *cobra.Command, args []string) {
@@ -571,37 +831,40 @@ func CommandScore(cmd *cobra.Command, args []string) {
 		log.Fatalf("unknown option: %s", strings.Join(args, " "))
 	}
 
-	// get the input data
-	lines, err := fetchFile(prefix + ".txt")
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
-
-	// parse it
-	data, err := Parse(prefix+".txt", lines)
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
+	// get the input data and parse it
+	data := readInputData()
 
 	// read the schedule
-	fp, err := os.Open(prefix + ".json")
-	if err != nil {
-		if err == os.ErrNotExist {
-			log.Fatalf("the list of course placements must be in %s.json", prefix)
-		} else {
-			log.Fatalf("opening %s: %v", prefix+".json", err)
-		}
+	placements := readPlacements(data, prefix+".json")
+
+	schedule := data.Score(placements)
+	format := outputFormat(cmd, scoreFormat, map[string]string{".md": "markdown", ".html": "html", ".htm": "html"})
+	if format != "text" && format != "markdown" && format != "html" {
+		log.Fatalf("unknown format %q", format)
 	}
-	placements, err := data.ReadJSON(fp)
-	if err != nil {
-		log.Fatalf("reading %s: %v", prefix+".json", err)
+	if err := filter.check(data); err != nil {
+		log.Fatalf("%v", err)
 	}
-	if err = fp.Close(); err != nil {
-		log.Fatalf("closing %s: %v", prefix+".json", err)
+	shown := schedule
+	if filter.active() {
+		shown = filter.schedule(data, schedule)
 	}
 
-	schedule := data.Score(placements)
-	data.PrintSchedule(schedule)
+	out := openOutput()
+	switch format {
+	case "text":
+		writeSchedule(out, data, shown)
+	case "markdown":
+		if err := WriteMarkdown(data, out, shown); err != nil {
+			log.Fatalf("writing markdown: %v", err)
+		}
+	case "html":
+		if err := WriteHTML(data, out, shown); err != nil {
+			log.Fatalf("writing html: %v", err)
+		}
+	}
+	out.Close()
+	exitStatus(schedule)
 }
 
 func CommandByCourse(cmd *cobra.Command, args []string) {
@@ -609,55 +872,40 @@ func CommandByCourse(cmd *cobra.Command, args []string) {
 		log.Fatalf("unknown option: %s", strings.Join(args, " "))
 	}
 
-	// get the input data
-	lines, err := fetchFile(prefix + ".txt")
-	if err != nil {
-		log.Fatalf("%v", err)
+	if byCourseSort != "instructor" && byCourseSort != "time" && byCourseSort != "room" {
+		log.Fatalf("unknown sort order %q; use instructor, time, or room", byCourseSort)
 	}
 
-	// parse it
-	data, err := Parse(prefix+".txt", lines)
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
+	// get the input data and parse it
+	data := readInputData()
 
 	// read the schedule
-	fp, err := os.Open(prefix + ".json")
-	if err != nil {
-		if err == os.ErrNotExist {
-			log.Fatalf("the list of course placements must be in %s.json", prefix)
-		} else {
-			log.Fatalf("opening %s: %v", prefix+".json", err)
-		}
-	}
-	placements, err := data.ReadJSON(fp)
-	if err != nil {
-		log.Fatalf("reading %s: %v", prefix+".json", err)
-	}
-	if err = fp.Close(); err != nil {
-		log.Fatalf("closing %s: %v", prefix+".json", err)
+	placements := readPlacements(data, prefix+".json")
+	if err := filter.check(data); err != nil {
+		log.Fatalf("%v", err)
 	}
+	placements = filter.placements(data, placements)
 
-	courseToPlacements := make(map[string][]Placement)
+	courseToPlacements := make(map[string][]engine.Placement)
 	var courseNames []string
 	courseLen, instructorLen, roomLen, timeLen := 0, 0, 0, 0
 	for _, placement := range placements {
 		name := placement.Course.Name
-		if len(placement.Course.Name) > courseLen {
-			courseLen = len(placement.Course.Name)
+		if displayWidth(placement.Course.Name) > courseLen {
+			courseLen = displayWidth(placement.Course.Name)
 		}
 		instructorName := placement.Course.Instructors[0].Name
 		if len(placement.Course.Instructors) > 1 {
 			instructorName += "+"
 		}
-		if len(instructorName) > instructorLen {
-			instructorLen = len(instructorName)
+		if displayWidth(instructorName) > instructorLen {
+			instructorLen = displayWidth(instructorName)
 		}
-		if len(data.Times[placement.Time].Name) > timeLen {
-			timeLen = len(data.Times[placement.Time].Name)
+		if n := displayWidth(placementTime(data, placement)); n > timeLen {
+			timeLen = n
 		}
-		if len(data.Rooms[placement.Room].Name) > roomLen {
-			roomLen = len(data.Rooms[placement.Room].Name)
+		if displayWidth(data.Rooms[placement.Room].Name) > roomLen {
+			roomLen = displayWidth(data.Rooms[placement.Room].Name)
 		}
 		if _, present := courseToPlacements[name]; !present {
 			courseNames = append(courseNames, name)
@@ -666,25 +914,59 @@ func CommandByCourse(cmd *cobra.Command, args []string) {
 	}
 	sort.Strings(courseNames)
 
-	fmt.Printf("Schedule by course:\n")
-	for _, name := range courseNames {
+	out := openOutput()
+	defer out.Close()
+	fmt.Fprintf(out, "Schedule by course:\n")
+	writeCourse := func(name string) {
 		lst := courseToPlacements[name]
 		sort.Slice(lst, func(a, b int) bool {
-			if lst[a].Course.Instructors[0].Name != lst[b].Course.Instructors[0].Name {
-				return lst[a].Course.Instructors[0].Name < lst[b].Course.Instructors[0].Name
+			x, y := lst[a], lst[b]
+			switch {
+			case byCourseSort == "time" && x.Time != y.Time:
+				return timeBefore(data, x.Time, y.Time)
+			case byCourseSort == "room" && x.Room != y.Room:
+				return x.Room < y.Room
+			case x.Course.Instructors[0].Name != y.Course.Instructors[0].Name:
+				return x.Course.Instructors[0].Name < y.Course.Instructors[0].Name
+			default:
+				return timeBefore(data, x.Time, y.Time)
 			}
-			return data.Times[lst[a].Time].Name < data.Times[lst[b].Time].Name
 		})
 		for _, elt := range lst {
 			instructorName := elt.Course.Instructors[0].Name
 			if len(elt.Course.Instructors) > 1 {
 				instructorName += "+"
 			}
-			fmt.Printf("%*s  %*s  %-*s  %*s\n",
-				courseLen, elt.Course.Name,
-				timeLen, data.Times[elt.Time].Name,
-				instructorLen, instructorName,
-				roomLen, data.Rooms[elt.Room].Name)
+			fmt.Fprintf(out, "%s  %s  %s  %s\n",
+				padLeft(elt.Course.Name, courseLen),
+				padLeft(placementTime(data, elt), timeLen),
+				padRight(instructorName, instructorLen),
+				padLeft(data.Rooms[elt.Room].Name, roomLen))
+		}
+	}
+	if !byDepartment {
+		for _, name := range courseNames {
+			writeCourse(name)
+		}
+		return
+	}
+
+	// each department under a heading with its number of sections
+	for _, department := range data.Departments() {
+		var names []string
+		sections := 0
+		for _, name := range courseNames {
+			if courseToPlacements[name][0].Course.Department() == department {
+				names = append(names, name)
+				sections += len(courseToPlacements[name])
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s: %d section(s)\n", departmentName(department), sections)
+		for _, name := range names {
+			writeCourse(name)
 		}
 	}
 }
@@ -694,94 +976,453 @@ func CommandByInstructor(cmd *cobra.Command, args []string) {
 		log.Fatalf("unknown option: %s", strings.Join(args, " "))
 	}
 
-	// get the input data
-	lines, err := fetchFile(prefix + ".txt")
-	if err != nil {
-		log.Fatalf("%v", err)
+	if byInstructorSort != "course" && byInstructorSort != "time" && byInstructorSort != "room" {
+		log.Fatalf("unknown sort order %q; use course, time, or room", byInstructorSort)
 	}
 
-	// parse it
-	data, err := Parse(prefix+".txt", lines)
-	if err != nil {
-		log.Fatalf("%v", err)
-	}
+	// get the input data and parse it
+	data := readInputData()
 
 	// read the schedule
-	fp, err := os.Open(prefix + ".json")
-	if err != nil {
-		if err == os.ErrNotExist {
-			log.Fatalf("the list of course placements must be in %s.json", prefix)
-		} else {
-			log.Fatalf("opening %s: %v", prefix+".json", err)
-		}
-	}
-	placements, err := data.ReadJSON(fp)
-	if err != nil {
-		log.Fatalf("reading %s: %v", prefix+".json", err)
-	}
-	if err = fp.Close(); err != nil {
-		log.Fatalf("closing %s: %v", prefix+".json", err)
+	placements := readPlacements(data, prefix+".json")
+	if err := filter.check(data); err != nil {
+		log.Fatalf("%v", err)
 	}
+	placements = filter.placements(data, placements)
 
-	instructorToPlacements := make(map[string][]Placement)
+	instructorToPlacements := make(map[string][]engine.Placement)
 	courseLen, instructorLen, roomLen, timeLen := 0, 0, 0, 0
 	for _, placement := range placements {
 		for _, instructor := range placement.Course.Instructors {
 			name := instructor.Name
-			if len(placement.Course.Name) > courseLen {
-				courseLen = len(placement.Course.Name)
+			if displayWidth(placement.Course.Name) > courseLen {
+				courseLen = displayWidth(placement.Course.Name)
 			}
-			if len(name) > instructorLen {
-				instructorLen = len(name)
+			if displayWidth(name) > instructorLen {
+				instructorLen = displayWidth(name)
 			}
-			if len(data.Times[placement.Time].Name) > timeLen {
-				timeLen = len(data.Times[placement.Time].Name)
+			if n := displayWidth(placementTime(data, placement)); n > timeLen {
+				timeLen = n
 			}
-			if len(data.Rooms[placement.Room].Name) > roomLen {
-				roomLen = len(data.Rooms[placement.Room].Name)
+			if displayWidth(data.Rooms[placement.Room].Name) > roomLen {
+				roomLen = displayWidth(data.Rooms[placement.Room].Name)
 			}
 			instructorToPlacements[name] = append(instructorToPlacements[name], placement)
 		}
 	}
 
-	fmt.Printf("Schedule by instructor:\n")
+	out := openOutput()
+	defer out.Close()
+	fmt.Fprintf(out, "Schedule by instructor:\n")
 	for _, instructor := range data.Instructors {
-		lst := instructorToPlacements[instructor.Name]
+		// sections in the order the instructor's courses are listed,
+		// unless another order was asked for
+		var lst []engine.Placement
 		for _, course := range instructor.Courses {
-			for _, elt := range lst {
+			for _, elt := range instructorToPlacements[instructor.Name] {
 				if elt.Course == course {
-					fmt.Printf("%-*s  %*s  %*s  %*s\n",
-						instructorLen, instructor.Name,
-						courseLen, elt.Course.Name,
-						roomLen, data.Rooms[elt.Room].Name,
-						timeLen, data.Times[elt.Time].Name)
+					lst = append(lst, elt)
 				}
 			}
 		}
+		sort.SliceStable(lst, func(a, b int) bool {
+			x, y := lst[a], lst[b]
+			switch {
+			case byInstructorSort == "time" && x.Time != y.Time:
+				return timeBefore(data, x.Time, y.Time)
+			case byInstructorSort == "room" && x.Room != y.Room:
+				return x.Room < y.Room
+			default:
+				return false
+			}
+		})
+		for _, elt := range lst {
+			fmt.Fprintf(out, "%s  %s  %s  %s\n",
+				padRight(instructor.Name, instructorLen),
+				padLeft(elt.Course.Name, courseLen),
+				padLeft(data.Rooms[elt.Room].Name, roomLen),
+				padLeft(placementTime(data, elt), timeLen))
+		}
 	}
 }
 
-func fetchFile(filename string) ([][]string, error) {
-	var lines [][]string
+// timeBefore reports whether one time slot comes before another in the
+// day, by the time of day it starts, or by its position in the input
+// if that is the same or cannot be read from the names
+func timeBefore(data *engine.InputData, a, b int) bool {
+	x, y := data.Times[a], data.Times[b]
+	if x.Start >= 0 && y.Start >= 0 && x.Start != y.Start {
+		return x.Start < y.Start
+	}
+	return a < b
+}
 
+func CommandByRoom(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	placements := readPlacements(data, prefix+".json")
+	if err := filter.check(data); err != nil {
+		log.Fatalf("%v", err)
+	}
+	placements = filter.placements(data, placements)
+
+	roomToPlacements := make(map[int][]engine.Placement)
+	courseLen, instructorLen, roomLen, timeLen := 0, 0, 0, 0
+	for _, placement := range placements {
+		if displayWidth(placement.Course.Name) > courseLen {
+			courseLen = displayWidth(placement.Course.Name)
+		}
+		instructorName := placement.Course.Instructors[0].Name
+		if len(placement.Course.Instructors) > 1 {
+			instructorName += "+"
+		}
+		if displayWidth(instructorName) > instructorLen {
+			instructorLen = displayWidth(instructorName)
+		}
+		if n := displayWidth(placementTime(data, placement)); n > timeLen {
+			timeLen = n
+		}
+		if displayWidth(data.Rooms[placement.Room].Name) > roomLen {
+			roomLen = displayWidth(data.Rooms[placement.Room].Name)
+		}
+		roomToPlacements[placement.Room] = append(roomToPlacements[placement.Room], placement)
+	}
+
+	out := openOutput()
+	defer out.Close()
+	fmt.Fprintf(out, "Schedule by room:\n")
+	for r, room := range data.Rooms {
+		lst := roomToPlacements[r]
+		sort.Slice(lst, func(a, b int) bool {
+			return lst[a].Time < lst[b].Time
+		})
+		for _, elt := range lst {
+			instructorName := elt.Course.Instructors[0].Name
+			if len(elt.Course.Instructors) > 1 {
+				instructorName += "+"
+			}
+			fmt.Fprintf(out, "%s  %s  %s  %s\n",
+				padRight(room.Name, roomLen),
+				padLeft(placementTime(data, elt), timeLen),
+				padLeft(elt.Course.Name, courseLen),
+				padRight(instructorName, instructorLen))
+		}
+	}
+}
+
+func CommandByTime(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	placements := readPlacements(data, prefix+".json")
+	if err := filter.check(data); err != nil {
+		log.Fatalf("%v", err)
+	}
+	placements = filter.placements(data, placements)
+	grid := data.MakeGrid(placements)
+
+	courseLen, instructorLen, roomLen, timeLen := 0, 0, 0, 0
+	for _, placement := range placements {
+		if displayWidth(placement.Course.Name) > courseLen {
+			courseLen = displayWidth(placement.Course.Name)
+		}
+		instructorName := placement.Course.Instructors[0].Name
+		if len(placement.Course.Instructors) > 1 {
+			instructorName += "+"
+		}
+		if displayWidth(instructorName) > instructorLen {
+			instructorLen = displayWidth(instructorName)
+		}
+		if displayWidth(data.Rooms[placement.Room].Name) > roomLen {
+			roomLen = displayWidth(data.Rooms[placement.Room].Name)
+		}
+	}
+	for t := range data.Times {
+		if n := displayWidth(timeLabel(data, t)); n > timeLen {
+			timeLen = n
+		}
+	}
+
+	out := openOutput()
+	defer out.Close()
+
+	// courses that started in an earlier slot are marked as continued
+	fmt.Fprintf(out, "Schedule by time:\n")
+	for t := range data.Times {
+		for r, room := range data.Rooms {
+			cell := grid[r][t]
+			if cell.Course == nil {
+				continue
+			}
+			instructorName := cell.Course.Instructors[0].Name
+			if len(cell.Course.Instructors) > 1 {
+				instructorName += "+"
+			}
+			continued := ""
+			if cell.IsSpillover {
+				continued = "  (continued)"
+			}
+			fmt.Fprintf(out, "%s  %s  %s  %s%s\n",
+				padLeft(timeLabel(data, t), timeLen),
+				padLeft(cell.Course.Name, courseLen),
+				padRight(instructorName, instructorLen),
+				padLeft(room.Name, roomLen),
+				continued)
+		}
+	}
+}
+
+func CommandFree(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	placements := readPlacements(data, prefix+".json")
+	grid := data.MakeGrid(placements)
+
+	// find the rooms to report on
+	var rooms []*engine.Room
+	for _, room := range data.Rooms {
+		if roomTag == "" {
+			rooms = append(rooms, room)
+			continue
+		}
+		for _, tag := range room.Tags {
+			if tag == roomTag {
+				rooms = append(rooms, room)
+				break
+			}
+		}
+	}
+	if len(rooms) == 0 {
+		log.Fatalf("no rooms found with tag %q", roomTag)
+	}
+
+	roomLen, timeLen := 0, 0
+	for _, room := range rooms {
+		if displayWidth(room.Name) > roomLen {
+			roomLen = displayWidth(room.Name)
+		}
+	}
+	for _, time := range data.Times {
+		if displayWidth(time.Name) > timeLen {
+			timeLen = displayWidth(time.Name)
+		}
+	}
+
+	// for each open cell, also report how many open slots
+	// in a row start there so multi-slot courses can be fit
+	out := openOutput()
+	defer out.Close()
+	fmt.Fprintf(out, "Open slots by room:\n")
+	for _, room := range rooms {
+		for t, time := range data.Times {
+			if grid[room.Position][t].Course != nil {
+				continue
+			}
+			slots := 1
+			for cur := time; cur.Next != nil && grid[room.Position][cur.Next.Position].Course == nil; cur = cur.Next {
+				slots++
+			}
+			plural := "s"
+			if slots == 1 {
+				plural = ""
+			}
+			fmt.Fprintf(out, "%s  %s  %d open slot%s in a row\n",
+				padRight(room.Name, roomLen),
+				padLeft(time.Name, timeLen),
+				slots, plural)
+		}
+	}
+}
+
+// An Output is where a report command writes its results
+type Output struct {
+	*bufio.Writer
+	fp *os.File
+}
+
+// openOutput opens the file named by --output (if any) for writing,
+// or standard output otherwise
+func openOutput() *Output {
+	if outputFile == "" || outputFile == "-" {
+		return &Output{Writer: bufio.NewWriter(os.Stdout)}
+	}
+	fp, err := os.Create(outputFile)
+	if err != nil {
+		log.Fatalf("creating %s: %v", outputFile, err)
+	}
+	return &Output{Writer: bufio.NewWriter(fp), fp: fp}
+}
+
+func (out *Output) Close() {
+	if err := out.Flush(); err != nil {
+		log.Fatalf("writing output: %v", err)
+	}
+	if out.fp != nil {
+		if err := out.fp.Close(); err != nil {
+			log.Fatalf("closing %s: %v", outputFile, err)
+		}
+	}
+}
+
+// outputFormat picks the format for a report: an explicit --format wins,
+// then the extension of the --output file name, then the default
+func outputFormat(cmd *cobra.Command, format string, extensions map[string]string) string {
+	if cmd.Flags().Changed("format") || outputFile == "" {
+		return format
+	}
+	if elt, present := extensions[strings.ToLower(filepath.Ext(outputFile))]; present {
+		return elt
+	}
+	return format
+}
+
+// isTerminal reports whether the file is a terminal (as opposed to a file or pipe)
+func isTerminal(fp *os.File) bool {
+	info, err := fp.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+
+	// the null device is also a character device
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
+}
+
+// catchInterrupt lets the first interrupt stop a search gracefully,
+// keeping the best schedule found so far. A second one quits at once.
+func catchInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		log.Printf("interrupted; finishing up (interrupt again to quit immediately)")
+		atomic.StoreInt32(&interrupted, 1)
+		interruptCancel()
+	}()
+}
+
+func isInterrupted() bool {
+	return atomic.LoadInt32(&interrupted) != 0
+}
+
+// exitStatus exits with a nonzero status if the search was interrupted,
+// the final schedule is infeasible, or it is worse than --max-badness.
+// It sends the --notify message for the end of the run first.
+func exitStatus(schedule engine.Schedule) {
+	impossible := len(schedule.Placements) == 0
+	for _, problem := range schedule.Problems {
+		if problem.Badness >= engine.Impossible {
+			impossible = true
+		}
+	}
+	code, status := ExitOK, "ok"
+	switch {
+	case isInterrupted():
+		code, status = ExitInterrupted, "interrupted"
+	case impossible:
+		log.Printf("the schedule is not feasible")
+		code, status = ExitInfeasible, "infeasible"
+	case len(schedule.Unplaced) > 0:
+		log.Printf("the schedule leaves out %d section(s)", len(schedule.Unplaced))
+		code, status = ExitPartial, "partial"
+	case maxBadness >= 0 && schedule.Badness > maxBadness:
+		log.Printf("badness %d is over the maximum of %d", schedule.Badness, maxBadness)
+		code, status = ExitOverBudget, "over-budget"
+	}
+	notifyFinished(schedule, status)
+	if code != ExitOK {
+		os.Exit(code)
+	}
+}
+
+// readInputData fetches and parses <prefix>.txt, exiting on failure
+func readInputData() *engine.InputData {
+	lines, err := fetchFile(prefix + ".txt")
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	data, err := engine.Parse(prefix+".txt", lines)
+	if err != nil {
+		log.Printf("%v", err)
+		os.Exit(ExitParse)
+	}
+	for _, warning := range data.Warnings {
+		log.Printf("warning: %v", warning)
+	}
+	return data
+}
+
+// makeSectionList forms a list of sections in order from most- to
+// least-constrained, exiting if some section has nowhere it can be placed
+func makeSectionList(data *engine.InputData) []*engine.Section {
+	sections, err := data.BuildSectionList()
+	if err != nil {
+		log.Printf("%v", err)
+		os.Exit(ExitInfeasible)
+	}
+	return sections
+}
+
+// readPlacements reads a schedule from a .json file, exiting on failure
+func readPlacements(data *engine.InputData, filename string) []engine.Placement {
+	fp, err := openFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Fatalf("the list of course placements must be in %s", filename)
+		} else {
+			log.Fatalf("opening %s: %v", filename, err)
+		}
+	}
+	placements, err := data.ReadJSON(fp)
+	if err != nil {
+		log.Fatalf("reading %s: %v", filename, err)
+	}
+	if err = fp.Close(); err != nil {
+		log.Fatalf("closing %s: %v", filename, err)
+	}
+	return placements
+}
+
+func fetchFile(filename string) ([][]string, error) {
+	inputSources = nil
 	var reader io.Reader
 	isCsv := false
 	if strings.HasPrefix(filename, "http:") || strings.HasPrefix(filename, "https:") {
 		const docsSuffix = "/edit?usp=sharing"
 		if strings.HasSuffix(filename, docsSuffix) {
+			if len(sheetTabs) > 0 {
+				return fetchSheetTabs(filename[:len(filename)-len(docsSuffix)], sheetTabs)
+			}
 			filename = filename[:len(filename)-len(docsSuffix)] + "/export?format=csv"
 			isCsv = true
 		}
 		log.Printf("downloading input URL %s", filename)
-		res, err := http.Get(filename)
+		body, err := fetchCached(filename)
 		if err != nil {
 			return nil, err
 		}
-		defer res.Body.Close()
-		reader = res.Body
+		reader = bytes.NewReader(body)
 	} else {
 		log.Printf("reading input file %s", filename)
-		fp, err := os.Open(filename)
+		fp, err := openFile(filename)
 		if err != nil {
 			return nil, err
 		}
@@ -790,6 +1431,33 @@ func fetchFile(filename string) ([][]string, error) {
 		isCsv = strings.HasSuffix(filename, ".csv")
 	}
 
+	return readLines(reader, isCsv)
+}
+
+// fetchSheetTabs downloads the named tabs of a Google Sheets document
+// and joins them into one input, in the order given
+func fetchSheetTabs(doc string, tabs []string) ([][]string, error) {
+	var lines [][]string
+	for _, tab := range tabs {
+		tabURL := doc + "/gviz/tq?tqx=out:csv&headers=0&sheet=" + url.QueryEscape(tab)
+		log.Printf("downloading tab %q from %s", tab, doc)
+		body, err := fetchCached(tabURL)
+		if err != nil {
+			return nil, fmt.Errorf("tab %q: %v", tab, err)
+		}
+		tabLines, err := readLines(bytes.NewReader(body), true)
+		if err != nil {
+			return nil, fmt.Errorf("reading tab %q: %v", tab, err)
+		}
+		lines = append(lines, tabLines...)
+	}
+	return lines, nil
+}
+
+// readLines splits an input into lines of fields, either as CSV or
+// as whitespace-separated text
+func readLines(reader io.Reader, isCsv bool) ([][]string, error) {
+	var lines [][]string
 	if isCsv {
 		buf := bufio.NewReader(reader)
 		reader := csv.NewReader(buf)
@@ -818,17 +1486,128 @@ func fetchFile(filename string) ([][]string, error) {
 	return lines, nil
 }
 
-func writeJsonFile(data *InputData, placements []Placement, badness int) {
-	filename := fmt.Sprintf("%s.json", prefix)
+// write the .json and .html files for a new best schedule
+func writeOutputFiles(data *engine.InputData, schedule engine.Schedule) {
+	oldFiles := []string{prevFile, prevHtmlFile}
+	writeOutputFile("json", schedule.Badness, &prevFile, func(w io.Writer) error {
+		if runInfo != nil {
+			runInfo.Elapsed = time.Since(runInfo.Started).Round(time.Millisecond).Seconds()
+			runInfo.Sources = inputSources
+		}
+		return data.WriteJSON(w, schedule.Placements, runInfo)
+	})
+	writeOutputFile("html", schedule.Badness, &prevHtmlFile, func(w io.Writer) error {
+		return WriteHTML(data, w, schedule)
+	})
+
+	if gitCommit {
+		written := []string{prevFile, prevHtmlFile}
+		var removed []string
+		for i, name := range oldFiles {
+			if name != "" && name != written[i] {
+				removed = append(removed, name)
+			}
+		}
+		if err := gitCommitFiles(schedule.Badness, written, removed); err != nil {
+			log.Printf("committing to git: %v", err)
+		}
+	}
+	if archiveDir != "" {
+		archiveSchedule(data, schedule)
+	}
+	notifyBest(schedule)
+}
+
+// archiveSchedule saves a copy of a new best schedule in the archive
+// directory under a name with the time and badness, so earlier
+// versions can be recovered after later ones replace them
+func archiveSchedule(data *engine.InputData, schedule engine.Schedule) {
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		log.Printf("archiving schedule: %v", err)
+		return
+	}
+	name := filepath.Join(archiveDir, fmt.Sprintf("%s-%s-%d",
+		filepath.Base(prefix), time.Now().Format("20060102-150405"), schedule.Badness))
+	buf := new(bytes.Buffer)
+	if err := data.WriteJSON(buf, schedule.Placements, runInfo); err != nil {
+		log.Printf("archiving schedule: %v", err)
+		return
+	}
+	if err := os.WriteFile(name+".json", buf.Bytes(), 0644); err != nil {
+		log.Printf("archiving schedule: %v", err)
+		return
+	}
+	if archiveHTML {
+		buf.Reset()
+		if err := WriteHTML(data, buf, schedule); err != nil {
+			log.Printf("archiving schedule: %v", err)
+			return
+		}
+		if err := os.WriteFile(name+".html", buf.Bytes(), 0644); err != nil {
+			log.Printf("archiving schedule: %v", err)
+		}
+	}
+}
+
+// newRunInfo records the command being run and the final value
+// of each of its options, including defaults
+// secretFlags are the options whose values may be credentials, such as
+// an Authorization header or a webhook URL. schedule.json is served,
+// uploaded, archived, and committed, so the run record only says
+// whether they were set.
+var secretFlags = map[string]bool{
+	"header": true,
+	"notify": true,
+}
+
+func newRunInfo(cmd *cobra.Command) *engine.JSONRun {
+	run := &engine.JSONRun{
+		Command: cmd.Name(),
+		Host:    hostname,
+		Seed:    randomSeed,
+		Flags:   make(map[string]string),
+		Started: time.Now().UTC(),
+	}
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		value := flag.Value.String()
+		switch {
+		case flag.Name == "help":
+		case secretFlags[flag.Name] && value != "" && value != "[]":
+			run.Flags[flag.Name] = "(set)"
+		default:
+			run.Flags[flag.Name] = value
+		}
+	})
+	return run
+}
+
+// write an output file with the given suffix by writing to a temporary
+// file and renaming it, so readers never see a partial file.
+// prev tracks the last file written so it can be cleaned up when
+// the name includes the score.
+func writeOutputFile(suffix string, badness int, prev *string, write func(io.Writer) error) {
+	filename := fmt.Sprintf("%s.%s", prefix, suffix)
 	if scoreInName {
-		filename = fmt.Sprintf("%s-%d.json", prefix, badness)
+		filename = fmt.Sprintf("%s-%d.%s", prefix, badness, suffix)
+	}
+	if isRemote(filename) {
+		writeRemoteOutputFile(filename, suffix, prev, write)
+		return
+	}
+	var old []byte
+	if suffix == "json" {
+		// the file being replaced, for the audit log
+		var err error
+		if old, err = os.ReadFile(filename); err != nil && *prev != "" {
+			old, _ = os.ReadFile(*prev)
+		}
 	}
 	tmpFile := fmt.Sprintf("%s.%s.tmp", filename, hostname)
 	fp, err := os.Create(tmpFile)
 	if err != nil {
 		log.Fatalf("creating %s: %v", tmpFile, err)
 	}
-	if err = data.WriteJSON(fp, placements); err != nil {
+	if err = write(fp); err != nil {
 		log.Fatalf("writing %s: %v", tmpFile, err)
 	}
 	if err = fp.Close(); err != nil {
@@ -837,10 +1616,45 @@ func writeJsonFile(data *InputData, placements []Placement, badness int) {
 	if err = os.Rename(tmpFile, filename); err != nil {
 		log.Fatalf("renaming %s to %s: %v", tmpFile, filename, err)
 	}
-	if prevFile != "" && prevFile != filename {
-		if err = os.Remove(prevFile); err != nil && err != os.ErrNotExist {
+	if suffix == "json" {
+		if raw, err := os.ReadFile(filename); err != nil {
+			log.Printf("writing audit log: %v", err)
+		} else {
+			command := ""
+			if runInfo != nil {
+				command = runInfo.Command
+			}
+			auditScheduleFile(filename, old, raw, currentActor(), command)
+		}
+	}
+	if *prev != "" && *prev != filename {
+		if err = os.Remove(*prev); err != nil && err != os.ErrNotExist {
+			log.Printf("deleting previous file: %v", err)
+		}
+	}
+	*prev = filename
+}
+
+// writeRemoteOutputFile stores an output file in a bucket. A failed
+// upload is logged rather than ending the run, so a long search is not
+// lost to a network problem; the next new best will try again.
+func writeRemoteOutputFile(filename, suffix string, prev *string, write func(io.Writer) error) {
+	buf := new(bytes.Buffer)
+	if err := write(buf); err != nil {
+		log.Fatalf("writing %s: %v", filename, err)
+	}
+	contentType := "application/json"
+	if suffix == "html" {
+		contentType = "text/html; charset=utf-8"
+	}
+	if err := putRemote(filename, buf.Bytes(), contentType); err != nil {
+		log.Printf("%v", err)
+		return
+	}
+	if *prev != "" && *prev != filename {
+		if err := removeRemote(*prev); err != nil {
 			log.Printf("deleting previous file: %v", err)
 		}
 	}
-	prevFile = filename
+	*prev = filename
 }
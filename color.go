@@ -0,0 +1,38 @@
+package main
+
+import "github.com/russross/schedule/engine"
+
+// ANSI terminal colors. Text is only colored when useColor is set.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiInvert = "\x1b[7m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+
+	// problems at least this bad are highlighted
+	highBadness = 20
+)
+
+var useColor = false
+
+func colorize(color, s string) string {
+	if !useColor || s == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// the color to use for a problem with the given badness
+func badnessColor(badness int) string {
+	switch {
+	case badness >= engine.Impossible:
+		return ansiRed
+	case badness >= highBadness:
+		return ansiYellow
+	default:
+		return ""
+	}
+}
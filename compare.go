@@ -0,0 +1,107 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+func CommandCompare(cmd *cobra.Command, args []string) {
+	if len(args) < 2 {
+		log.Fatalf("usage: schedule compare a.json b.json ...")
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read and score every candidate
+	var schedules []engine.Schedule
+	for _, filename := range args {
+		schedules = append(schedules, data.Score(readPlacements(data, filename)))
+	}
+
+	out := openOutput()
+	defer out.Close()
+
+	// gather the badness totals by category across all schedules
+	byCategory := make([]map[string]int, len(schedules))
+	categorySet := make(map[string]bool)
+	impossible := make([]int, len(schedules))
+	for i, schedule := range schedules {
+		byCategory[i] = make(map[string]int)
+		for _, problem := range schedule.Problems {
+			byCategory[i][problem.Category()] += problem.Badness
+			categorySet[problem.Category()] = true
+			if problem.Badness >= engine.Impossible {
+				impossible[i]++
+			}
+		}
+	}
+	var categories []string
+	for category := range categorySet {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	// work out column widths
+	labelLen := len("impossible problems")
+	for _, category := range categories {
+		if len(category) > labelLen {
+			labelLen = len(category)
+		}
+	}
+	colLen := 0
+	for _, filename := range args {
+		if len(filename) > colLen {
+			colLen = len(filename)
+		}
+	}
+	row := func(label string, values []int) {
+		fmt.Fprintf(out, "%-*s", labelLen, label)
+		for _, value := range values {
+			fmt.Fprintf(out, "  %*d", colLen, value)
+		}
+		fmt.Fprintln(out)
+	}
+
+	// summary table with one column per schedule
+	fmt.Fprintf(out, "%-*s", labelLen, "")
+	for _, filename := range args {
+		fmt.Fprintf(out, "  %*s", colLen, filename)
+	}
+	fmt.Fprintln(out)
+	totals := make([]int, len(schedules))
+	for i, schedule := range schedules {
+		totals[i] = schedule.Badness
+	}
+	row("total badness", totals)
+	row("impossible problems", impossible)
+	for _, category := range categories {
+		values := make([]int, len(schedules))
+		for i := range schedules {
+			values[i] = byCategory[i][category]
+		}
+		row(category, values)
+	}
+
+	// pairwise distance: how many courses are placed differently
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Courses placed differently:\n")
+	fmt.Fprintf(out, "%-*s", colLen, "")
+	for _, filename := range args {
+		fmt.Fprintf(out, "  %*s", colLen, filename)
+	}
+	fmt.Fprintln(out)
+	for i := range schedules {
+		fmt.Fprintf(out, "%-*s", colLen, args[i])
+		for j := range schedules {
+			fmt.Fprintf(out, "  %*d", colLen, len(data.Moves(schedules[i].Placements, schedules[j].Placements)))
+		}
+		fmt.Fprintln(out)
+	}
+}
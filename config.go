@@ -0,0 +1,223 @@
+// +build !wasm
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	configFile = ""
+)
+
+// A ConfigEntry is one key/value setting from a config file. Section
+// is the command it applies to, or empty if it applies to any command
+// that has a flag with that name.
+type ConfigEntry struct {
+	Section string
+	Key     string
+	Value   string
+	Line    int
+}
+
+// findConfig looks for schedule.toml, schedule.yaml, or schedule.yml
+// in the same directory as the file name prefix
+func findConfig() string {
+	dir := filepath.Dir(prefix)
+	for _, name := range []string{"schedule.toml", "schedule.yaml", "schedule.yml"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// envName is the environment variable that can set a flag,
+// e.g., SCHEDULE_RESTARTLOCAL for --restartlocal
+func envName(flag string) string {
+	return "SCHEDULE_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// documentEnv adds the environment variable for each flag
+// to its help text, for a command and all of its subcommands
+func documentEnv(cmd *cobra.Command) {
+	document := func(flag *pflag.Flag) {
+		flag.Usage += fmt.Sprintf(" [$%s]", envName(flag.Name))
+	}
+	cmd.LocalNonPersistentFlags().VisitAll(document)
+	cmd.PersistentFlags().VisitAll(document)
+	for _, sub := range cmd.Commands() {
+		documentEnv(sub)
+	}
+}
+
+// applyEnv sets flags for the command being run from SCHEDULE_*
+// environment variables, leaving alone any flag that was given on
+// the command line. It runs before applyConfig so the environment
+// takes priority over the config file.
+func applyEnv(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		value, present := os.LookupEnv(envName(flag.Name))
+		if err != nil || !present || flag.Changed {
+			return
+		}
+		if e := cmd.Flags().Set(flag.Name, value); e != nil {
+			err = fmt.Errorf("%s: %v", envName(flag.Name), e)
+		}
+	})
+	return err
+}
+
+// applyConfig sets flags for the command being run from the config
+// file, leaving alone any flag that was given on the command line
+// or in the environment
+func applyConfig(cmd *cobra.Command) error {
+	path := configFile
+	if path == "" {
+		// the prefix may only be known from the command line here,
+		// so it decides where to look
+		if path = findConfig(); path == "" {
+			return nil
+		}
+	}
+
+	entries, err := ReadConfig(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Section != "" && entry.Section != cmd.Name() {
+			continue
+		}
+		flag := cmd.Flags().Lookup(entry.Key)
+		if flag == nil {
+			if entry.Section == "" {
+				// a default for some other command
+				continue
+			}
+			return fmt.Errorf("%s:%d: %s has no option named %q", path, entry.Line, entry.Section, entry.Key)
+		}
+		if flag.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(entry.Key, entry.Value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, entry.Line, entry.Key, err)
+		}
+	}
+	return nil
+}
+
+// ReadConfig reads a config file. Only a small subset of TOML and
+// YAML is supported: key/value pairs at the top level, and tables
+// (TOML) or nested maps (YAML) named after a command, e.g.,
+//
+//     workers = 8
+//     [gen]
+//     time = "2h"
+//
+// or
+//
+//     workers: 8
+//     gen:
+//       time: 2h
+func ReadConfig(path string) ([]ConfigEntry, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	yaml := strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+	var entries []ConfigEntry
+	section := ""
+	scanner := bufio.NewScanner(fp)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		raw := stripConfigComment(scanner.Text())
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+
+		var key, value string
+		if yaml {
+			indented := raw[0] == ' ' || raw[0] == '\t'
+			colon := strings.Index(line, ":")
+			if colon < 0 {
+				return nil, fmt.Errorf("%s:%d: expected key: value", path, lineNumber)
+			}
+			key, value = strings.TrimSpace(line[:colon]), strings.TrimSpace(line[colon+1:])
+			if !indented {
+				section = ""
+				if value == "" {
+					section = key
+					continue
+				}
+			} else if section == "" {
+				return nil, fmt.Errorf("%s:%d: unexpected indentation", path, lineNumber)
+			}
+		} else {
+			if strings.HasPrefix(line, "[") {
+				if !strings.HasSuffix(line, "]") {
+					return nil, fmt.Errorf("%s:%d: expected [section]", path, lineNumber)
+				}
+				section = strings.TrimSpace(line[1 : len(line)-1])
+				continue
+			}
+			equals := strings.Index(line, "=")
+			if equals < 0 {
+				return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNumber)
+			}
+			key, value = strings.TrimSpace(line[:equals]), strings.TrimSpace(line[equals+1:])
+		}
+
+		if key == "" {
+			return nil, fmt.Errorf("%s:%d: missing key", path, lineNumber)
+		}
+		if value, err = unquoteConfigValue(value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		entries = append(entries, ConfigEntry{Section: section, Key: key, Value: value, Line: lineNumber})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// stripConfigComment removes a # comment that is not inside quotes
+func stripConfigComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquoteConfigValue(value string) (string, error) {
+	switch {
+	case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+		return strconv.Unquote(value)
+	case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+		return value[1 : len(value)-1], nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,144 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonInput = ""
+	daemonEvery = 24 * time.Hour
+	daemonAt    = []string{}
+
+	// the tabs to read when the input is a Google Sheets link, or
+	// empty to read only the first tab
+	sheetTabs = []string{}
+)
+
+func CommandDaemon(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	settings := genSettingsFromFlags()
+	if err := settings.Check(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	if daemonEvery <= 0 {
+		log.Fatalf("every must be > 0")
+	}
+	at, err := parseClockTimes(daemonAt)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	input := daemonInput
+	if input == "" {
+		input = prefix + ".txt"
+	}
+
+	catchInterrupt()
+	for !isInterrupted() {
+		started := time.Now()
+		if err := daemonRun(input, settings); err != nil {
+			log.Printf("%v", err)
+		}
+		if isInterrupted() {
+			break
+		}
+
+		next := nextDaemonRun(started, time.Now(), daemonEvery, at)
+		log.Printf("next run at %s", next.Format("2006-01-02 15:04:05"))
+		for time.Now().Before(next) && !isInterrupted() {
+			time.Sleep(time.Second)
+		}
+	}
+	log.Printf("stopping")
+}
+
+// daemonRun fetches the input again and refines the current schedule
+// (or starts from scratch if there is none that fits the input), then
+// writes the result if it is better than the current schedule
+func daemonRun(input string, settings engine.GenSettings) error {
+	lines, err := fetchFile(input)
+	if err != nil {
+		return fmt.Errorf("fetching input: %v", err)
+	}
+	data, err := engine.Parse(input, lines)
+	if err != nil {
+		return err
+	}
+
+	current := engine.Schedule{Badness: engine.Worst}
+	if fp, err := openFile(prefix + ".json"); err == nil {
+		placements, err := data.ReadJSON(fp)
+		fp.Close()
+		if err != nil {
+			log.Printf("%s.json does not fit the input, so starting from scratch: %v", prefix, err)
+		} else {
+			current = data.Score(placements)
+			log.Printf("the current schedule has a badness score of %d", current.Badness)
+		}
+	}
+
+	best, stats, err := engine.Search(interruptContext, data, engine.Options{GenSettings: settings, Start: current})
+	if err != nil {
+		return err
+	}
+	log.Printf("%d successful and %d failed attempts", stats.Successful, stats.Failed)
+
+	switch {
+	case isInterrupted():
+		return nil
+	case len(best.Placements) == 0:
+		return fmt.Errorf("no valid schedule found")
+	case best.Badness >= current.Badness:
+		log.Printf("no improvement found")
+		return nil
+	}
+
+	log.Print(colorize(ansiBold+ansiGreen, fmt.Sprintf("new best of %d found", best.Badness)))
+	writeOutputFiles(data, best)
+	return nil
+}
+
+// parseClockTimes parses a list of HH:MM times into minutes after midnight, sorted
+func parseClockTimes(times []string) ([]int, error) {
+	var out []int
+	for _, s := range times {
+		t, err := time.Parse("15:04", s)
+		if err != nil {
+			return nil, fmt.Errorf("time of day %q is not in HH:MM format", s)
+		}
+		out = append(out, t.Hour()*60+t.Minute())
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+// nextDaemonRun finds when the next run should start: the next of the
+// given times of day after now if there are any, or else the interval
+// after the last run started. A run that took longer than the interval
+// is followed right away.
+func nextDaemonRun(started, now time.Time, every time.Duration, at []int) time.Time {
+	if len(at) == 0 {
+		return started.Add(every)
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for day := 0; day < 2; day++ {
+		for _, minutes := range at {
+			next := midnight.AddDate(0, 0, day).Add(time.Duration(minutes) * time.Minute)
+			if next.After(now) {
+				return next
+			}
+		}
+	}
+	return now.Add(every)
+}
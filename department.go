@@ -0,0 +1,58 @@
+package main
+
+import "github.com/russross/schedule/engine"
+
+// byDepartment splits reports up by department, using the letters at
+// the start of each course name (see engine.Course.Department)
+var byDepartment = false
+
+// A departmentProblems lists the problems tied to the sections of one
+// department and their total badness
+type departmentProblems struct {
+	Department string
+	Problems   []engine.Problem
+	Badness    int
+}
+
+// problemsByDepartment groups problems by the departments of the
+// sections they are tied to, in input order, followed by any problems
+// not tied to a section under "other". A problem that involves more
+// than one department is listed under each of them.
+func problemsByDepartment(data *engine.InputData, problems []engine.Problem) []departmentProblems {
+	var groups []departmentProblems
+	index := make(map[string]int)
+	add := func(department string, problem engine.Problem) {
+		n, present := index[department]
+		if !present {
+			n = len(groups)
+			index[department] = n
+			groups = append(groups, departmentProblems{Department: department})
+		}
+		groups[n].Problems = append(groups[n].Problems, problem)
+		groups[n].Badness += problem.Badness
+	}
+	for _, department := range data.Departments() {
+		for _, problem := range problems {
+			for _, elt := range problem.Departments() {
+				if elt == department {
+					add(departmentName(department), problem)
+				}
+			}
+		}
+	}
+	for _, problem := range problems {
+		if len(problem.Departments()) == 0 {
+			add("other", problem)
+		}
+	}
+	return groups
+}
+
+// departmentName gives the name to show for a department, which is
+// "other" for courses whose names do not start with a letter
+func departmentName(department string) string {
+	if department == "" {
+		return "other"
+	}
+	return department
+}
@@ -0,0 +1,75 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+func CommandDiff(cmd *cobra.Command, args []string) {
+	if len(args) != 2 {
+		log.Fatalf("usage: schedule diff old.json new.json")
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read both schedules
+	oldSchedule := data.Score(readPlacements(data, args[0]))
+	newSchedule := data.Score(readPlacements(data, args[1]))
+
+	out := openOutput()
+	defer out.Close()
+
+	moves := data.Moves(oldSchedule.Placements, newSchedule.Placements)
+	fmt.Fprintf(out, "%d course(s) moved:\n", len(moves))
+	for _, move := range moves {
+		instructorName := move.Course.Instructors[0].Name
+		if len(move.Course.Instructors) > 1 {
+			instructorName += "+"
+		}
+		fmt.Fprintf(out, "* %s (%s): %s %s -> %s %s\n",
+			move.Course.SectionID(), instructorName,
+			data.Rooms[move.From.Room].Name, data.Times[move.From.Time].Name,
+			data.Rooms[move.To.Room].Name, data.Times[move.To.Time].Name)
+	}
+
+	// sections that are only placed in one of the schedules
+	oldPlaced := make(map[*engine.Course]bool)
+	for _, placement := range oldSchedule.Placements {
+		oldPlaced[placement.Course] = true
+	}
+	newPlaced := make(map[*engine.Course]bool)
+	for _, placement := range newSchedule.Placements {
+		newPlaced[placement.Course] = true
+	}
+	for _, course := range data.Courses {
+		switch {
+		case oldPlaced[course] && !newPlaced[course]:
+			fmt.Fprintf(out, "* %s is only placed in %s\n", course.SectionID(), args[0])
+		case newPlaced[course] && !oldPlaced[course]:
+			fmt.Fprintf(out, "* %s is only placed in %s\n", course.SectionID(), args[1])
+		}
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Badness of %s: %d\n", args[0], oldSchedule.Badness)
+	fmt.Fprintf(out, "Badness of %s: %d\n", args[1], newSchedule.Badness)
+	fmt.Fprintf(out, "Change: %+d\n", newSchedule.Badness-oldSchedule.Badness)
+
+	gone, added := engine.DiffProblems(oldSchedule.Problems, newSchedule.Problems)
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Problems that disappeared:\n")
+	for _, problem := range gone {
+		fmt.Fprintln(out, "- "+problem.Message)
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Problems that appeared:\n")
+	for _, problem := range added {
+		fmt.Fprintln(out, "+ "+problem.Message)
+	}
+}
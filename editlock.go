@@ -0,0 +1,286 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/russross/schedule/engine"
+)
+
+var (
+	editLockTime = 2 * time.Minute
+)
+
+// scheduleFileMutex keeps saves from the page and writes from a
+// search run by serve --gen from interleaving
+var scheduleFileMutex sync.Mutex
+
+// scheduleVersion is the version token for the contents of a schedule
+// file. It changes whenever the file does, so an editor that saves
+// with an old token must have missed someone else's change.
+func scheduleVersion(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8])
+}
+
+// currentScheduleVersion gives the version token of <prefix>.json
+func currentScheduleVersion() (string, error) {
+	raw, err := os.ReadFile(prefix + ".json")
+	if err != nil {
+		return "", err
+	}
+	return scheduleVersion(raw), nil
+}
+
+// An EditLock is a soft lock on one course, held by one open editor
+// page while its user has moved the course but not saved. Locks
+// expire unless the page renews them.
+type EditLock struct {
+	Course  string    `json:"course"`
+	Editor  string    `json:"editor"`
+	Expires time.Time `json:"expires"`
+}
+
+// EditLocks tracks the locks held by editor pages, by course
+type EditLocks struct {
+	mutex sync.Mutex
+	locks map[string]EditLock
+}
+
+func NewEditLocks() *EditLocks {
+	return &EditLocks{locks: make(map[string]EditLock)}
+}
+
+// Register adds the lock handlers and the handler for reading and
+// saving the schedule file to a mux
+func (locks *EditLocks) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/locks", locks.handleLocks)
+	mux.HandleFunc("/schedule.json", locks.handleScheduleFile)
+}
+
+// expire drops stale locks; the caller must hold the mutex
+func (locks *EditLocks) expire(now time.Time) {
+	for course, lock := range locks.locks {
+		if now.After(lock.Expires) {
+			delete(locks.locks, course)
+		}
+	}
+}
+
+// heldByOther returns the lock on a course if an editor other than
+// the given one holds it
+func (locks *EditLocks) heldByOther(course, editor string) (EditLock, bool) {
+	locks.mutex.Lock()
+	defer locks.mutex.Unlock()
+	locks.expire(time.Now())
+	lock, present := locks.locks[course]
+	if !present || lock.Editor == editor {
+		return EditLock{}, false
+	}
+	return lock, true
+}
+
+// GET /api/locks: list the current locks
+// POST /api/locks: take or renew locks, with {"editor": ..., "courses": [...]}
+// DELETE /api/locks?editor=...: release all of an editor's locks
+func (locks *EditLocks) handleLocks(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	switch r.Method {
+	case http.MethodGet:
+		locks.mutex.Lock()
+		locks.expire(now)
+		list := []EditLock{}
+		for _, lock := range locks.locks {
+			list = append(list, lock)
+		}
+		locks.mutex.Unlock()
+		sort.Slice(list, func(a, b int) bool { return list[a].Course < list[b].Course })
+		apiReply(w, http.StatusOK, list)
+
+	case http.MethodPost:
+		var req struct {
+			Editor  string   `json:"editor"`
+			Courses []string `json:"courses"`
+		}
+		decoder := json.NewDecoder(io.LimitReader(r.Body, 1<<20))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			apiError(w, http.StatusBadRequest, "decoding request: %v", err)
+			return
+		}
+		if req.Editor == "" {
+			apiError(w, http.StatusBadRequest, "the request has no editor")
+			return
+		}
+
+		// take all of the locks or none of them
+		locks.mutex.Lock()
+		defer locks.mutex.Unlock()
+		locks.expire(now)
+		for _, course := range req.Courses {
+			if lock, present := locks.locks[course]; present && lock.Editor != req.Editor {
+				apiError(w, http.StatusConflict, "%s is being edited by someone else", course)
+				return
+			}
+		}
+		taken := []EditLock{}
+		for _, course := range req.Courses {
+			lock := EditLock{Course: course, Editor: req.Editor, Expires: now.Add(editLockTime)}
+			locks.locks[course] = lock
+			taken = append(taken, lock)
+		}
+		apiReply(w, http.StatusOK, taken)
+
+	case http.MethodDelete:
+		editor := r.URL.Query().Get("editor")
+		if editor == "" {
+			apiError(w, http.StatusBadRequest, "the request has no editor")
+			return
+		}
+		locks.mutex.Lock()
+		for course, lock := range locks.locks {
+			if lock.Editor == editor {
+				delete(locks.locks, course)
+			}
+		}
+		locks.mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		apiError(w, http.StatusMethodNotAllowed, "use GET, POST, or DELETE for %s", r.URL.Path)
+	}
+}
+
+// GET /schedule.json: the current schedule, with its version token in
+// the ETag header
+// PUT /schedule.json: save a schedule. The If-Match header must give
+// the version token the editor started from, and the X-Schedule-Editor
+// header names the editor so its own locks do not block the save.
+func (locks *EditLocks) handleScheduleFile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		scheduleFileMutex.Lock()
+		raw, err := os.ReadFile(prefix + ".json")
+		scheduleFileMutex.Unlock()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"`+scheduleVersion(raw)+`"`)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			w.Write(raw)
+		}
+
+	case http.MethodPut:
+		locks.saveSchedule(w, r)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		apiError(w, http.StatusMethodNotAllowed, "use GET or PUT for %s", r.URL.Path)
+	}
+}
+
+func (locks *EditLocks) saveSchedule(w http.ResponseWriter, r *http.Request) {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		apiError(w, http.StatusPreconditionRequired, "a save must give the version it started from in an If-Match header")
+		return
+	}
+	editor := r.Header.Get("X-Schedule-Editor")
+	body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "reading request: %v", err)
+		return
+	}
+
+	// the input may have changed since the page loaded it
+	lines, err := fetchFile(prefix + ".txt")
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	data, err := engine.Parse(prefix+".txt", lines)
+	if err != nil {
+		apiError(w, http.StatusConflict, "the input no longer parses: %v", err)
+		return
+	}
+	placements, err := data.ReadJSON(bytes.NewReader(body))
+	if err != nil {
+		apiError(w, http.StatusBadRequest, "reading schedule: %v", err)
+		return
+	}
+
+	scheduleFileMutex.Lock()
+	defer scheduleFileMutex.Unlock()
+	raw, err := os.ReadFile(prefix + ".json")
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if version := scheduleVersion(raw); match != `"`+version+`"` && match != version {
+		apiError(w, http.StatusConflict, "the schedule has been changed since you loaded it; reload the page to see the changes")
+		return
+	}
+
+	// do not overwrite courses someone else is in the middle of moving
+	if old, err := data.ReadJSON(bytes.NewReader(raw)); err == nil {
+		where := make(map[string]engine.Placement)
+		for _, p := range old {
+			where[p.Course.SectionID()] = p
+		}
+		for _, p := range placements {
+			id := p.Course.SectionID()
+			if prev, present := where[id]; present && prev.Room == p.Room && prev.Time == p.Time {
+				continue
+			}
+			if _, held := locks.heldByOther(id, editor); held {
+				apiError(w, http.StatusConflict, "%s is being edited by someone else", id)
+				return
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := data.WriteJSON(buf, placements, nil); err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if err := replaceFile(prefix+".json", buf.Bytes()); err != nil {
+		apiError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	actor := editor
+	if actor == "" {
+		actor = "web " + r.RemoteAddr
+	}
+	auditScheduleFile(prefix+".json", raw, buf.Bytes(), actor, "web")
+	schedule := data.Score(placements)
+	apiReply(w, http.StatusOK, map[string]interface{}{
+		"version": scheduleVersion(buf.Bytes()),
+		"badness": schedule.Badness,
+	})
+}
+
+// replaceFile writes a file by way of a temporary file, so readers
+// never see it half written
+func replaceFile(filename string, contents []byte) error {
+	tmpFile := fmt.Sprintf("%s.%s.tmp", filename, hostname)
+	if err := os.WriteFile(tmpFile, contents, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
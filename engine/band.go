@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"strings"
+)
+
+// A Band is a part of the day, such as late morning, used to spread
+// the sections of a course across the day. It runs from its Start
+// (e.g., 1000) until the next band starts.
+type Band struct {
+	Name  string
+	Start string
+}
+
+// defaultBands splits the day into morning and afternoon, for inputs
+// that do not give their own bands
+var defaultBands = []Band{
+	{Name: "morning", Start: "0000"},
+	{Name: "afternoon", Start: "1200"},
+}
+
+// isClock reports whether s is a time of day in HHMM form
+func isClock(s string) bool {
+	if len(s) != 4 || strings.Trim(s, "0123456789") != "" {
+		return false
+	}
+	return s[:2] < "24" && s[2:] < "60"
+}
+
+// ParseBand reads a band of the day. Bands must be listed in order
+// from earliest to latest.
+func (data *InputData) ParseBand(fields []string) error {
+	if len(fields) != 3 {
+		return lineError(KindSyntax, "expected %q", "band: name HHMM")
+	}
+	band := Band{Name: fields[1], Start: fields[2]}
+	if !isClock(band.Start) {
+		return fieldError(KindSyntax, band.Start, "band start %q must be a time of day in HHMM form", band.Start)
+	}
+	for _, elt := range data.Bands {
+		if elt.Name == band.Name {
+			return fieldError(KindDuplicate, band.Name, "found duplicate band")
+		}
+	}
+	if n := len(data.Bands); n > 0 && data.Bands[n-1].Start >= band.Start {
+		return fieldError(KindOrder, band.Start, "bands must be listed from earliest to latest")
+	}
+	data.Bands = append(data.Bands, band)
+	return nil
+}
+
+// bands returns the bands of the day in use
+func (data *InputData) bands() []Band {
+	if len(data.Bands) == 0 {
+		return defaultBands
+	}
+	return data.Bands
+}
+
+// timeBands finds the band each time starts in, or -1 if it is in
+// none. With the default bands, only regular MWF and TR daytime slots
+// are counted, so evening classes do not count as afternoon ones.
+func (data *InputData) timeBands() []int {
+	bands := data.bands()
+	out := make([]int, len(data.Times))
+	for i, time := range data.Times {
+		out[i] = -1
+		if time.Start < 0 {
+			continue
+		}
+		if _, hour := time.Split(); len(data.Bands) == 0 && hour == "" {
+			continue
+		}
+		for band := range bands {
+			if start, err := parseClock(bands[band].Start); err == nil && start <= time.Start {
+				out[i] = band
+			}
+		}
+	}
+	return out
+}
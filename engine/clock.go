@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// newTime makes a time slot, reading its days and time of day from its
+// name
+func newTime(name string, position int) *Time {
+	time := &Time{Name: name, Position: position}
+	time.Days, time.Start, time.End, _ = parseTimeName(name)
+	return time
+}
+
+// parseTimeName splits a time name into its day letters (in upper
+// case) and the time of day it starts (and ends, if it gives a range)
+// in minutes after midnight, or -1 with an error if it cannot be read.
+// It understands names like TR0930, MW1300-1415, mwf_9:00, and
+// TR2:30pm. The days and the time of day can be separated by a space,
+// underscore, or period. Without am or pm, a four-digit time is on a
+// 24-hour clock, while a shorter one (e.g., 9:00 or 130) from 1 to 6
+// is taken to be in the afternoon.
+func parseTimeName(name string) (string, int, int, error) {
+	brk := strings.IndexFunc(name, unicode.IsDigit)
+	if brk < 0 {
+		return "", -1, -1, fmt.Errorf("no time of day found")
+	}
+	days := strings.ToUpper(strings.TrimRight(name[:brk], " _."))
+	clock := name[brk:]
+	end := -1
+	if dash := strings.Index(clock, "-"); dash >= 0 {
+		var err error
+		if end, err = parseClock(clock[dash+1:]); err != nil {
+			return days, -1, -1, err
+		}
+		clock = clock[:dash]
+	}
+	start, err := parseClock(clock)
+	if err != nil {
+		return days, -1, -1, err
+	}
+	if end >= 0 && end <= start {
+		return days, -1, -1, fmt.Errorf("%s ends before it starts", name[brk:])
+	}
+	return days, start, end, nil
+}
+
+// parseClock reads a time of day as minutes after midnight
+func parseClock(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	suffix := ""
+	for _, elt := range []string{"am", "pm", "a", "p"} {
+		if strings.HasSuffix(s, elt) {
+			suffix, s = elt[:1], strings.TrimSpace(strings.TrimSuffix(s, elt))
+			break
+		}
+	}
+
+	var hour, minute int
+	var err error
+	hhmm := !strings.Contains(s, ":") && len(s) == 4
+	switch {
+	case strings.Contains(s, ":"):
+		colon := strings.Index(s, ":")
+		if len(s)-colon-1 != 2 {
+			return 0, fmt.Errorf("expected two digits for the minutes in %q", s)
+		}
+		if hour, err = strconv.Atoi(s[:colon]); err != nil {
+			return 0, fmt.Errorf("cannot read the hour in %q", s)
+		}
+		if minute, err = strconv.Atoi(s[colon+1:]); err != nil {
+			return 0, fmt.Errorf("cannot read the minutes in %q", s)
+		}
+	case len(s) == 3 || len(s) == 4:
+		if hour, err = strconv.Atoi(s[:len(s)-2]); err != nil {
+			return 0, fmt.Errorf("cannot read the hour in %q", s)
+		}
+		if minute, err = strconv.Atoi(s[len(s)-2:]); err != nil {
+			return 0, fmt.Errorf("cannot read the minutes in %q", s)
+		}
+	case len(s) == 1 || len(s) == 2:
+		if hour, err = strconv.Atoi(s); err != nil {
+			return 0, fmt.Errorf("cannot read the hour in %q", s)
+		}
+	default:
+		return 0, fmt.Errorf("%q is not a time of day", s)
+	}
+	if hour < 0 || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("%q is not a time of day", s)
+	}
+
+	switch {
+	case suffix != "":
+		if hour < 1 || hour > 12 {
+			return 0, fmt.Errorf("%q is not a time of day", s)
+		}
+		hour %= 12
+		if suffix == "p" {
+			hour += 12
+		}
+	case hour > 23:
+		return 0, fmt.Errorf("%q is not a time of day", s)
+	case !hhmm && hour >= 1 && hour <= 6:
+		hour += 12
+	}
+	return hour*60 + minute, nil
+}
+
+// formatClock writes minutes after midnight in 24-hour HHMM form
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d%02d", minutes/60, minutes%60)
+}
+
+// Clock gives the time of day the slot starts in 24-hour HHMM form,
+// or "" if its name does not give one that can be read
+func (t *Time) Clock() string {
+	if t.Start < 0 {
+		return ""
+	}
+	return formatClock(t.Start)
+}
+
+// Clock styles for showing times to people. ClockRaw uses the time slot
+// names from the input, while Clock12 and Clock24 give the days and the
+// time of day the slot starts and ends, e.g., TR 9:30–10:45am or
+// TR 09:30–10:45.
+const (
+	ClockRaw = "raw"
+	Clock12  = "12"
+	Clock24  = "24"
+)
+
+// CheckClockStyle reports an error if a clock style is not known
+func CheckClockStyle(style string) error {
+	switch style {
+	case ClockRaw, Clock12, Clock24:
+		return nil
+	default:
+		return fmt.Errorf("times must be %s, %s, or %s, not %q", ClockRaw, Clock12, Clock24, style)
+	}
+}
+
+// Label gives the name of a time slot in a clock style. A slot with no
+// end in its name is taken to last as long as one meeting usually does
+// on its days. The name is used as is if it does not give a time of
+// day.
+func (t *Time) Label(style string) string {
+	if style == ClockRaw || t.Start < 0 {
+		return t.Name
+	}
+	end := t.End
+	if end < 0 {
+		minutes := DefaultMeetingMinutes()
+		length, present := minutes[t.Days]
+		if !present {
+			length = minutes["*"]
+		}
+		end = t.Start + length
+	}
+	return formatTimeRange(t.Days, t.Start, end, style)
+}
+
+// PlacementLabel gives the time of a placement in a clock style,
+// running to the end of the last slot it uses
+func (data *InputData) PlacementLabel(placement Placement, style string) string {
+	t := data.Times[placement.Time]
+	if style == ClockRaw || t.Start < 0 {
+		return t.Name
+	}
+	_, begin, end, err := data.MeetingTimes(placement, DefaultMeetingMinutes())
+	if err != nil {
+		return t.Label(style)
+	}
+	start, finish := clockMinutes(begin), clockMinutes(end)
+	if start < 0 || finish < 0 {
+		return t.Label(style)
+	}
+	return formatTimeRange(t.Days, start, finish, style)
+}
+
+// clockMinutes reads a 24-hour HHMM time as minutes after midnight, or
+// -1 if it is not one
+func clockMinutes(hhmm string) int {
+	n, err := strconv.Atoi(hhmm)
+	if err != nil || len(hhmm) != 4 {
+		return -1
+	}
+	return n/100*60 + n%100
+}
+
+// formatTimeRange writes days and a span of time in a clock style. On a
+// 12-hour clock, am or pm is only given once if both ends share it.
+func formatTimeRange(days string, start, end int, style string) string {
+	prefix := ""
+	if days != "" {
+		prefix = days + " "
+	}
+	if style == Clock24 {
+		return fmt.Sprintf("%s%02d:%02d–%02d:%02d", prefix, start/60, start%60, end/60%24, end%60)
+	}
+	hour12 := func(minutes int) (string, string) {
+		minutes %= 24 * 60
+		suffix := "am"
+		if minutes >= 12*60 {
+			suffix = "pm"
+		}
+		hour := minutes / 60 % 12
+		if hour == 0 {
+			hour = 12
+		}
+		return fmt.Sprintf("%d:%02d", hour, minutes%60), suffix
+	}
+	from, fromSuffix := hour12(start)
+	to, toSuffix := hour12(end)
+	if fromSuffix == toSuffix {
+		return prefix + from + "–" + to + toSuffix
+	}
+	return prefix + from + fromSuffix + "–" + to + toSuffix
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"strings"
+)
+
+// A DayPattern names a group of time prefixes (the letters before the
+// first digit of a time name, e.g., MWF) that count as the same days
+// for scoring. Instructors are expected to spread their classes
+// evenly across day patterns, and if Distribute is set, courses with
+// several sections should have one on each such pattern.
+type DayPattern struct {
+	Name       string
+	Prefixes   []string
+	Distribute bool
+}
+
+// ParseDays reads a day pattern
+func (data *InputData) ParseDays(fields []string) error {
+	if len(fields) < 3 {
+		return lineError(KindSyntax, "expected %q", "days: name prefix ... [distribute]")
+	}
+	pattern := DayPattern{Name: fields[1]}
+	prefixes := fields[2:]
+	if prefixes[len(prefixes)-1] == "distribute" {
+		pattern.Distribute = true
+		prefixes = prefixes[:len(prefixes)-1]
+	}
+	if len(prefixes) == 0 {
+		return lineError(KindSyntax, "expected %q", "days: name prefix ... [distribute]")
+	}
+	for _, elt := range data.DayPatterns {
+		if elt.Name == pattern.Name {
+			return fieldError(KindDuplicate, pattern.Name, "found duplicate day pattern")
+		}
+	}
+	for _, prefix := range prefixes {
+		if strings.ContainsAny(prefix, "0123456789") {
+			return fieldError(KindSyntax, prefix, "prefix %q must be the letters before the time of day", prefix)
+		}
+		prefix = strings.ToUpper(prefix)
+		if data.dayPattern(prefix) != nil {
+			return fieldError(KindDuplicate, prefix, "prefix %q is already in a day pattern", prefix)
+		}
+		for _, elt := range pattern.Prefixes {
+			if elt == prefix {
+				return fieldError(KindDuplicate, prefix, "prefix %q repeated", prefix)
+			}
+		}
+		pattern.Prefixes = append(pattern.Prefixes, prefix)
+	}
+	data.DayPatterns = append(data.DayPatterns, pattern)
+	return nil
+}
+
+// dayPattern finds the day pattern with the given prefix, or nil
+func (data *InputData) dayPattern(prefix string) *DayPattern {
+	for i := range data.DayPatterns {
+		for _, elt := range data.DayPatterns[i].Prefixes {
+			if elt == prefix {
+				return &data.DayPatterns[i]
+			}
+		}
+	}
+	return nil
+}
+
+// timeDays finds the days each time counts as for the per-day scoring,
+// or "" for none, and the days it counts as for the section
+// distribution rule, or "" if that rule ignores it. Without day
+// patterns, days come from Time.Prefix and only regular MW(F) and TR
+// daytime slots count for section distribution.
+func (data *InputData) timeDays() ([]string, []string) {
+	days := make([]string, len(data.Times))
+	distribution := make([]string, len(data.Times))
+	for i, time := range data.Times {
+		if len(data.DayPatterns) == 0 {
+			days[i] = time.Prefix()
+			switch prefix, hour := time.Split(); {
+			case hour == "":
+			case prefix == "mw":
+				distribution[i] = "MW(F)"
+			case prefix == "tr":
+				distribution[i] = "TR"
+			}
+			continue
+		}
+
+		prefix, _ := time.DaysAndHour()
+		prefix = strings.ToUpper(prefix)
+		if pattern := data.dayPattern(prefix); pattern != nil {
+			days[i] = pattern.Name
+			if pattern.Distribute {
+				distribution[i] = pattern.Name
+			}
+		} else {
+			days[i] = prefix
+		}
+	}
+	return days, distribution
+}
+
+// distributionDays lists the days compared by the section distribution
+// rule, in order
+func (data *InputData) distributionDays() []string {
+	if len(data.DayPatterns) == 0 {
+		return []string{"MW(F)", "TR"}
+	}
+	var out []string
+	for _, pattern := range data.DayPatterns {
+		if pattern.Distribute {
+			out = append(out, pattern.Name)
+		}
+	}
+	return out
+}
+
+// dayLetters gives the day letters (e.g., MWF) that a day from
+// timeDays meets on, for finding its dates in the term
+func (data *InputData) dayLetters(day string) string {
+	for _, pattern := range data.DayPatterns {
+		if pattern.Name == day {
+			return pattern.Prefixes[0]
+		}
+	}
+	return day
+}
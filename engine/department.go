@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Department gives the department a course belongs to, taken from the
+// letters at the start of its name in upper case, e.g., CS for CS1400.
+// For a course in a merged input the department name in front of it is
+// used instead, e.g., ENG for ENG.CS1400. It is "" if the name does
+// not start with a letter.
+func (c *Course) Department() string {
+	if dot := strings.Index(c.Name, "."); dot > 0 {
+		return c.Name[:dot]
+	}
+	end := strings.IndexFunc(c.Name, func(r rune) bool { return !unicode.IsLetter(r) })
+	if end < 0 {
+		end = len(c.Name)
+	}
+	return strings.ToUpper(c.Name[:end])
+}
+
+// Departments lists the departments of the courses in the input, in
+// the order they first appear
+func (data *InputData) Departments() []string {
+	var departments []string
+	for _, course := range data.Courses {
+		if department := course.Department(); !containsString(departments, department) {
+			departments = append(departments, department)
+		}
+	}
+	return departments
+}
+
+// Departments lists the departments of the sections a problem is tied
+// to, in the order the sections are given. A problem that is not tied
+// to any sections has no departments.
+func (p Problem) Departments() []string {
+	var departments []string
+	for _, course := range p.Courses {
+		if department := course.Department(); !containsString(departments, department) {
+			departments = append(departments, department)
+		}
+	}
+	return departments
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// An Expansion records a name in a conflict or anticonflict line that
+// stood for several courses: a cohort (see cohort: tags), an
+// instructor, who stands for every course they teach, or a pattern
+// like CS3* that matches course names. Line is the
+// 1-based line number of the text input, or zero.
+type Expansion struct {
+	Line    int
+	Kind    string
+	Name    string
+	Courses []string
+}
+
+func (e Expansion) String() string {
+	where := ""
+	if e.Line > 0 {
+		where = fmt.Sprintf("line %d: ", e.Line)
+	}
+	return fmt.Sprintf("%s%s: %s expands to %s", where, e.Kind, e.Name, strings.Join(e.Courses, " "))
+}
+
+// expandCourses finds the courses a name in a conflict or anticonflict
+// line refers to. A pattern (see isPattern) matches course names and
+// must match at least one. Otherwise the name can be a course, a
+// cohort, or an instructor (including one who co-teaches a course that
+// has not been linked to them yet), and must not be more than one of
+// these. A name that is not a course is recorded as an Expansion.
+func (data *InputData) expandCourses(kind, name string, coInstructors map[*Course][]string) ([]*Course, error) {
+	var byName, byCohort, byInstructor []*Course
+	if isPattern(name) {
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fieldError(KindSyntax, name, "bad pattern %q", name)
+		}
+		for _, instructor := range data.Instructors {
+			for _, course := range instructor.Courses {
+				if matched, _ := path.Match(name, course.Name); matched && !containsCourse(byName, course) {
+					byName = append(byName, course)
+				}
+			}
+		}
+		if len(byName) == 0 {
+			return nil, fieldError(KindUnresolved, name, "pattern %q matches no course in %s: line", name, kind)
+		}
+		data.recordExpansion(kind, name, byName)
+		return byName, nil
+	}
+	for _, instructor := range data.Instructors {
+		for _, course := range instructor.Courses {
+			if course.Name == name && !containsCourse(byName, course) {
+				byName = append(byName, course)
+			}
+			if course.InCohort(name) && !containsCourse(byCohort, course) {
+				byCohort = append(byCohort, course)
+			}
+			teaches := instructor.Name == name || containsString(coInstructors[course], name)
+			if teaches && !containsCourse(byInstructor, course) {
+				byInstructor = append(byInstructor, course)
+			}
+		}
+	}
+
+	hits := 0
+	var courses []*Course
+	for _, lst := range [][]*Course{byName, byCohort, byInstructor} {
+		if len(lst) > 0 {
+			courses = lst
+			hits++
+		}
+	}
+	switch {
+	case hits == 0:
+		return nil, fieldError(KindUnresolved, name, "course, cohort, or instructor not found in %s: line", kind)
+	case hits > 1:
+		return nil, fieldError(KindUnresolved, name, "%q in %s: line names more than one of a course, cohort, and instructor", name, kind)
+	case len(byName) > 0:
+		return courses, nil
+	}
+
+	data.recordExpansion(kind, name, courses)
+	return courses, nil
+}
+
+func (data *InputData) recordExpansion(kind, name string, courses []*Course) {
+	expansion := Expansion{Kind: kind, Name: name}
+	for _, course := range courses {
+		if !containsString(expansion.Courses, course.Name) {
+			expansion.Courses = append(expansion.Courses, course.Name)
+		}
+	}
+	data.Expansions = append(data.Expansions, expansion)
+}
+
+// isPattern reports whether a name in a course list is a pattern
+// to match course names against, e.g., CS3* or IT4???
+func isPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// isIgnored reports whether a name in a constraint line is on the
+// ignore list, either by name or by matching a pattern there
+func isIgnored(ignore map[string]struct{}, name string) bool {
+	if _, present := ignore[name]; present {
+		return true
+	}
+	for elt := range ignore {
+		if isPattern(elt) {
+			if matched, _ := path.Match(elt, name); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// patternUsed reports whether a pattern from an ignore list matches a
+// name used on any line of the input other than an ignore line
+func patternUsed(pattern string, lines [][]string) bool {
+	for _, line := range lines {
+		fields := inputFields(line)
+		if len(fields) == 0 || fields[0] == "ignore:" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if matched, _ := path.Match(pattern, field); matched && !isPattern(field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCourseName reports whether any course has the given name
+func (data *InputData) isCourseName(name string) bool {
+	for _, instructor := range data.Instructors {
+		for _, course := range instructor.Courses {
+			if course.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsCourse(lst []*Course, course *Course) bool {
+	for _, elt := range lst {
+		if elt == course {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(lst []string, s string) bool {
+	for _, elt := range lst {
+		if elt == s {
+			return true
+		}
+	}
+	return false
+}
+
+// numberExpansions sets the line number of the expansions recorded
+// since the first n
+func (data *InputData) numberExpansions(n, line int) {
+	for i := n; i < len(data.Expansions); i++ {
+		data.Expansions[i].Line = line
+	}
+}
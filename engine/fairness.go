@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"strings"
+)
+
+// A Fairness is how well a schedule honors the preferences one
+// instructor stated, and how much of the schedule's badness falls on
+// them. Stated counts the time slots their sections use, if they gave
+// any time a soft badness, plus one if they asked to teach on a number
+// of days. Honored counts the slots at times they gave no badness, plus
+// one if they teach on the number of days they asked for. Badness is
+// the total badness of the problems that name them.
+type Fairness struct {
+	Instructor *Instructor
+	Stated     int
+	Honored    int
+	Badness    int
+}
+
+// Fairness finds how well a schedule honors each instructor's
+// preferences, in input order
+func (data *InputData) Fairness(schedule Schedule) []Fairness {
+	var out []Fairness
+	for _, instructor := range data.Instructors {
+		elt := Fairness{Instructor: instructor}
+
+		soft := false
+		for _, badness := range instructor.Times {
+			if badness > 0 {
+				soft = true
+			}
+		}
+		if soft {
+			for t := range data.Times {
+				for r := range data.Rooms {
+					course := schedule.RoomTimes[r][t].Course
+					if course == nil || !course.TaughtBy(instructor) {
+						continue
+					}
+					elt.Stated++
+					if instructor.Times[t] == 0 {
+						elt.Honored++
+					}
+				}
+			}
+		}
+
+		daysHonored := true
+		for _, problem := range schedule.Problems {
+			if !namesInstructor(problem.Message, instructor.Name) {
+				continue
+			}
+			elt.Badness += problem.Badness
+			if problem.Category() == "instructor preference" {
+				daysHonored = false
+			}
+		}
+		if instructor.Days > 0 {
+			elt.Stated++
+			if daysHonored {
+				elt.Honored++
+			}
+		}
+		out = append(out, elt)
+	}
+	return out
+}
+
+// TaughtBy reports whether an instructor teaches the course
+func (c *Course) TaughtBy(instructor *Instructor) bool {
+	for _, elt := range c.Instructors {
+		if elt == instructor {
+			return true
+		}
+	}
+	return false
+}
+
+// namesInstructor reports whether a problem message names an
+// instructor as one of its words
+func namesInstructor(msg, name string) bool {
+	for _, word := range strings.Fields(msg) {
+		if strings.TrimRight(word, ",:;") == name {
+			return true
+		}
+	}
+	return false
+}
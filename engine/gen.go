@@ -0,0 +1,549 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// Worst is the badness of a schedule that has not been found yet
+	Worst int = 1e9
+
+	ModeWarmup int = iota
+	ModeLocalBest
+	ModeGlobalBest
+)
+
+// GenSettings are the parameters that control the gen search
+type GenSettings struct {
+	Workers              int
+	Pin                  float64
+	PinDev               float64
+	Duration             time.Duration
+	Warmup               time.Duration
+	RestartLocal         time.Duration
+	RestartGlobal        time.Duration
+	WeightedWarmup       bool
+	WeightedOptimization bool
+
+	// Unplaced is the most sections a schedule may leave out, each
+	// with a badness of UnplacedBadness, rather than abandoning the
+	// attempt
+	Unplaced int
+}
+
+// Check makes sure the settings are in range
+func (settings GenSettings) Check() error {
+	switch {
+	case settings.Workers < 1:
+		return fmt.Errorf("workers must be >= 1")
+	case settings.Pin < 0.0 || settings.Pin > 100.0:
+		return fmt.Errorf("pin must be between 0 and 100")
+	case settings.PinDev < 0.0:
+		return fmt.Errorf("pindev must be >= 0")
+	case settings.Duration <= 0:
+		return fmt.Errorf("time must be > 0")
+	case settings.Warmup <= 0:
+		return fmt.Errorf("warmup time must be > 0")
+	case settings.RestartLocal <= 0:
+		return fmt.Errorf("restartlocal time must be > 0")
+	case settings.RestartGlobal <= 0:
+		return fmt.Errorf("restartglobal time must be > 0")
+	case settings.Unplaced < 0:
+		return fmt.Errorf("unplaced must be >= 0")
+	}
+	return nil
+}
+
+// A GenProgress is a snapshot of a gen search, given to the
+// OnProgress hook before each attempt and once more at the end
+type GenProgress struct {
+	Time     time.Time
+	Attempts int
+	Failed   int
+	Badness  int
+	Mode     int
+	Final    bool
+
+	// set at the end if the search stopped early because a warmup
+	// found nothing valid
+	GaveUp bool
+}
+
+// Options control a Search: its settings, where it starts, and where
+// it reports its progress
+type Options struct {
+	GenSettings
+
+	// Start is the schedule to refine during the first warmup. If it
+	// has no placements, the search starts from scratch.
+	Start Schedule
+
+	// NoRestarts keeps refining the best schedule found, starting
+	// from Start, with no warmup and no restarts
+	NoRestarts bool
+
+	// Report logs progress as the search goes
+	Report bool
+
+	// ReportInterval is how often a search with Report set logs its
+	// progress. If it is zero, progress is logged once a minute.
+	ReportInterval time.Duration
+
+	// Verbose logs the reason each failed placement attempt was
+	// abandoned
+	Verbose bool
+
+	// Logger receives the messages from Report and Verbose. If it is
+	// nil, they go to the standard log package.
+	Logger Logger
+
+	// the hooks are all optional and are called with the search
+	// locked, so they should be quick. OnBest is called with each new
+	// global best, OnEvent with each new local or global best,
+	// OnProgress before each attempt and at the end, and OnReport with
+	// the global best each time a search with Report set logs its
+	// progress.
+	OnBest     func(Schedule)
+	OnEvent    func(SearchEvent)
+	OnProgress func(GenProgress)
+	OnReport   func(Schedule)
+
+	// Notify is called with everything the other hooks see as typed
+	// events, plus a ModeChange each time the search moves between
+	// warmup and refinement, so a single hook can follow the whole
+	// search. Like the others, it is called with the search locked.
+	Notify func(Event)
+
+	// set by SearchDeterministic
+	deterministic *DeterministicSettings
+}
+
+// DeterministicSettings are the parameters for SearchDeterministic.
+// They mirror GenSettings, but the length of the search, the warmup,
+// and the restarts are counted in attempts instead of time.
+type DeterministicSettings struct {
+	Seed                 int64
+	Attempts             int
+	Pin                  float64
+	PinDev               float64
+	Warmup               int
+	RestartLocal         int
+	RestartGlobal        int
+	WeightedWarmup       bool
+	WeightedOptimization bool
+	Unplaced             int
+}
+
+// Check makes sure the settings are in range
+func (settings DeterministicSettings) Check() error {
+	switch {
+	case settings.Attempts < 1:
+		return fmt.Errorf("attempts must be >= 1")
+	case settings.Pin < 0.0 || settings.Pin > 100.0:
+		return fmt.Errorf("pin must be between 0 and 100")
+	case settings.PinDev < 0.0:
+		return fmt.Errorf("pindev must be >= 0")
+	case settings.Warmup < 1:
+		return fmt.Errorf("warmup attempts must be >= 1")
+	case settings.RestartLocal < 1:
+		return fmt.Errorf("restartlocal attempts must be >= 1")
+	case settings.RestartGlobal < 1:
+		return fmt.Errorf("restartglobal attempts must be >= 1")
+	case settings.Unplaced < 0:
+		return fmt.Errorf("unplaced must be >= 0")
+	}
+	return nil
+}
+
+// Stats describe a finished Search: counts of attempts, whether the
+// search stopped early because a warmup found nothing valid, and
+// whether it was stopped because its context was done
+type Stats struct {
+	Successful int
+	Failed     int
+	GaveUp     bool
+	Stopped    bool
+	Elapsed    time.Duration
+}
+
+// Search looks for a schedule until the time runs out or ctx is done,
+// and returns the best schedule found. The returned schedule has no
+// placements if no valid schedule was found. It returns an error if
+// the options are out of range or some section has nowhere it can be
+// placed.
+func Search(ctx context.Context, data *InputData, options Options) (Schedule, Stats, error) {
+	if err := options.Check(); err != nil {
+		return Schedule{}, Stats{}, err
+	}
+	sections, err := data.buildSectionList(options.Unplaced > 0)
+	if err != nil {
+		return Schedule{}, Stats{}, err
+	}
+	if len(options.Start.Placements) == 0 {
+		options.Start = Schedule{Badness: Worst}
+	}
+	best, stats := data.search(ctx, sections, &options)
+	return best, stats, nil
+}
+
+// SearchDeterministic is like Search, but it gives the same schedule
+// every time for the same input, settings, and starting schedule. It
+// runs on one goroutine with its own random number generator seeded
+// from settings.Seed, and it restarts and ends after a number of
+// attempts rather than after a length of time. The GenSettings in
+// options are ignored. Stopping it early through ctx gives up the
+// guarantee, and stats.Stopped reports when that happened.
+func SearchDeterministic(ctx context.Context, data *InputData, settings DeterministicSettings, options Options) (Schedule, Stats, error) {
+	if err := settings.Check(); err != nil {
+		return Schedule{}, Stats{}, err
+	}
+	sections, err := data.buildSectionList(settings.Unplaced > 0)
+	if err != nil {
+		return Schedule{}, Stats{}, err
+	}
+	if len(options.Start.Placements) == 0 {
+		options.Start = Schedule{Badness: Worst}
+	}
+	options.GenSettings = GenSettings{
+		Workers:              1,
+		Pin:                  settings.Pin,
+		PinDev:               settings.PinDev,
+		WeightedWarmup:       settings.WeightedWarmup,
+		WeightedOptimization: settings.WeightedOptimization,
+		Unplaced:             settings.Unplaced,
+	}
+	options.deterministic = &settings
+	best, stats := data.search(ctx, sections, &options)
+	return best, stats, nil
+}
+
+func (data *InputData) search(parent context.Context, sections []*Section, run *Options) (Schedule, Stats) {
+	// a deterministic search counts attempts instead of watching the
+	// clock, and uses its own random numbers
+	det := run.deterministic
+	var random randomSource = sharedRandom{}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if det != nil {
+		random = rand.New(rand.NewSource(det.Seed))
+		ctx, cancel = context.WithCancel(parent)
+	} else {
+		ctx, cancel = context.WithTimeout(parent, run.Duration)
+	}
+	defer cancel()
+	logger := loggerOrDefault(run.Logger)
+	var verbose Logger
+	if run.Verbose {
+		verbose = logger
+	}
+	startTime := time.Now()
+	lastReport := startTime
+	reportInterval := run.ReportInterval
+	if reportInterval <= 0 {
+		reportInterval = time.Minute
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	mode := ModeWarmup
+	if run.NoRestarts && len(run.Start.Placements) > 0 {
+		mode = ModeGlobalBest
+	}
+	baseline := run.Start
+	localBest := run.Start
+	globalBest := run.Start
+	lastImprovement := time.Now()
+	successfullAttempts := 0
+	failedAttempts := 0
+	lastImprovementAttempt := 0
+	gaveUp := false
+
+	// these are only called with the mutex held
+	notify := func(event Event) {
+		if run.Notify != nil {
+			run.Notify(event)
+		}
+	}
+	setMode := func(now time.Time, next int, reason string) {
+		if next != mode {
+			notify(ModeChange{Time: now, From: mode, To: next, Reason: reason})
+			mode = next
+		}
+	}
+	progress := func(progress GenProgress) {
+		if run.OnProgress != nil {
+			run.OnProgress(progress)
+		}
+		notify(progress)
+	}
+	improved := func(now time.Time) {
+		lastImprovement = now
+		lastImprovementAttempt = successfullAttempts + failedAttempts
+	}
+
+	// has the given time (or number of attempts) passed since the last
+	// improvement?
+	waited := func(now time.Time, limit time.Duration, attempts int) bool {
+		if det != nil {
+			return successfullAttempts+failedAttempts-lastImprovementAttempt >= attempts
+		}
+		return now.Sub(lastImprovement) >= limit
+	}
+	var warmup, restartLocal, restartGlobal int
+	if det != nil {
+		warmup, restartLocal, restartGlobal = det.Warmup, det.RestartLocal, det.RestartGlobal
+	}
+
+	for worker := 0; worker < run.Workers; worker++ {
+		wg.Add(1)
+		go func(workerN int) {
+			for {
+				if expired(ctx) {
+					break
+				}
+				now := time.Now()
+
+				mutex.Lock()
+				if gaveUp || det != nil && successfullAttempts+failedAttempts >= det.Attempts {
+					mutex.Unlock()
+					break
+				}
+				if run.Report && time.Since(lastReport) >= reportInterval {
+					lastReport = lastReport.Add(reportInterval)
+					if run.OnReport != nil {
+						run.OnReport(globalBest)
+					}
+					logger.Printf("so far: %d runs in %v, badness score of %d",
+						successfullAttempts+failedAttempts,
+						lastReport.Sub(startTime),
+						globalBest.Badness)
+				}
+				progress(GenProgress{
+					Time:     now,
+					Attempts: successfullAttempts + failedAttempts,
+					Failed:   failedAttempts,
+					Badness:  globalBest.Badness,
+					Mode:     mode,
+				})
+
+				switch {
+				case run.NoRestarts && mode != ModeWarmup:
+					// keep refining the global best
+
+				case mode == ModeWarmup:
+					// is it time to move on to refinement?
+					if waited(now, run.Warmup, warmup) {
+						if len(localBest.Placements) == 0 {
+							// we did not find any valid schedules
+							if run.Report {
+								logger.Printf("no valid schedule found in warmup period")
+							}
+							gaveUp = true
+							mutex.Unlock()
+							continue
+						}
+						baseline = localBest
+						improved(now)
+						if run.Report {
+							logger.Printf("ending warmup")
+						}
+						setMode(now, ModeLocalBest, "warmup ended")
+					}
+
+				// is it time to restart from local or global best?
+				case mode == ModeLocalBest && waited(now, run.RestartLocal, restartLocal):
+					fallthrough
+				case mode == ModeGlobalBest && waited(now, run.RestartGlobal, restartGlobal):
+					baseline = Schedule{Badness: Worst}
+					localBest = Schedule{Badness: Worst}
+					improved(now)
+					if run.Report {
+						logger.Printf("restarting")
+					}
+					setMode(now, ModeWarmup, "restart")
+				}
+
+				base := baseline.Placements
+				currentMode := mode
+				mutex.Unlock()
+
+				// the pin value to use for this round
+				var localPin float64
+				switch {
+				case run.Pin >= 100.0:
+					localPin = 100.0
+				case run.Pin <= 0.0:
+					localPin = 0.0
+				default:
+					localPin = -1.0
+					for localPin >= 100.0 || localPin < 0.0 {
+						localPin = random.NormFloat64()*run.PinDev + run.Pin
+					}
+				}
+
+				// generate a schedule
+				weighted := currentMode == ModeWarmup && run.WeightedWarmup ||
+					(currentMode == ModeLocalBest || currentMode == ModeGlobalBest) && run.WeightedOptimization
+				candidate := data.placeSections(ctx, verbose, random, sections, base, localPin, weighted, run.Unplaced)
+				if len(candidate) == 0 {
+					mutex.Lock()
+					failedAttempts++
+					mutex.Unlock()
+					continue
+				}
+
+				// score it
+				schedule := data.Score(candidate)
+
+				// see how it compares
+				now = time.Now()
+				mutex.Lock()
+				successfullAttempts++
+				event := SearchEvent{
+					Time:    now,
+					Badness: schedule.Badness,
+					Mode:    ModeName(mode),
+					Pin:     localPin,
+				}
+
+				if schedule.Badness < globalBest.Badness {
+					event.Kind = "global"
+					// new global best? always keep it
+					globalBest = schedule
+					localBest = schedule
+
+					if mode == ModeWarmup {
+						// if we are in a warmup, just keep going
+						if run.Report {
+							logger.Printf("global best of %d found in warmup", schedule.Badness)
+						}
+					} else {
+						// if we are in a refinement period, reset the counter and the baseline
+						baseline = schedule
+						improved(now)
+						if run.Report {
+							logger.Printf("global best of %d found (pin %.1f)", schedule.Badness, localPin)
+						}
+						setMode(now, ModeGlobalBest, "global best")
+					}
+
+					if run.OnBest != nil {
+						run.OnBest(schedule)
+					}
+				} else if schedule.Badness < localBest.Badness {
+					// new local best?
+					switch {
+					case mode == ModeWarmup && len(base) > 0:
+						// it was a holdover from before a restart, so discard it
+
+					case mode == ModeWarmup:
+						event.Kind = "local"
+						localBest = schedule
+						if run.Report {
+							logger.Printf("warmup best of %d found (global best is %d)", schedule.Badness, globalBest.Badness)
+						}
+
+					default:
+						// refinement
+						event.Kind = "local"
+						baseline = schedule
+						localBest = schedule
+						improved(now)
+						if run.Report {
+							logger.Printf("local best of %d found (pin %.1f, global best is %d)", schedule.Badness, localPin, globalBest.Badness)
+						}
+					}
+				}
+				if event.Kind != "" {
+					event.Successful, event.Failed = successfullAttempts, failedAttempts
+					event.Schedule = schedule
+					if run.OnEvent != nil {
+						run.OnEvent(event)
+					}
+					notify(event)
+				}
+
+				mutex.Unlock()
+			}
+			wg.Done()
+		}(worker)
+	}
+	wg.Wait()
+
+	if run.Report {
+		logger.Printf("%d successful and %d failed attempts in %v", successfullAttempts, failedAttempts, time.Since(startTime))
+	}
+	progress(GenProgress{
+		Time:     time.Now(),
+		Attempts: successfullAttempts + failedAttempts,
+		Failed:   failedAttempts,
+		Badness:  globalBest.Badness,
+		Mode:     mode,
+		Final:    true,
+		GaveUp:   gaveUp,
+	})
+	stats := Stats{
+		Successful: successfullAttempts,
+		Failed:     failedAttempts,
+		GaveUp:     gaveUp,
+		Stopped:    parent.Err() != nil,
+		Elapsed:    time.Since(startTime),
+	}
+	return globalBest, stats
+}
+
+// An Event is something that happened during a search, as given to
+// the Notify hook: a ModeChange, a SearchEvent, or a GenProgress
+type Event interface {
+	EventTime() time.Time
+}
+
+// A ModeChange records the search moving from one mode to another:
+// from warmup to refinement when a warmup ends, to ModeGlobalBest
+// when refinement finds a new global best, and back to warmup on a
+// restart. Reason describes why, for display.
+type ModeChange struct {
+	Time   time.Time
+	From   int
+	To     int
+	Reason string
+}
+
+// A SearchEvent records one improvement found by gen. The events are
+// appended to <prefix>.events.jsonl, one JSON object per line, so the
+// behavior of the search can be analyzed after the fact.
+type SearchEvent struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	Badness    int       `json:"badness"`
+	Mode       string    `json:"mode"`
+	Pin        float64   `json:"pin"`
+	Successful int       `json:"successful"`
+	Failed     int       `json:"failed"`
+
+	// the schedule found, which is not written to the event log
+	Schedule Schedule `json:"-"`
+}
+
+func (event ModeChange) EventTime() time.Time  { return event.Time }
+func (event SearchEvent) EventTime() time.Time { return event.Time }
+func (event GenProgress) EventTime() time.Time { return event.Time }
+
+// ModeName describes a search mode for the event log
+func ModeName(mode int) string {
+	switch mode {
+	case ModeWarmup:
+		return "warmup"
+	case ModeLocalBest:
+		return "local"
+	case ModeGlobalBest:
+		return "global"
+	default:
+		return "unknown"
+	}
+}
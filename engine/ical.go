@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// the iCalendar name and Go weekday for each day letter in a time name
+var icalDays = map[rune]struct {
+	Code    string
+	Weekday time.Weekday
+}{
+	'M': {"MO", time.Monday},
+	'T': {"TU", time.Tuesday},
+	'W': {"WE", time.Wednesday},
+	'R': {"TH", time.Thursday},
+	'F': {"FR", time.Friday},
+	'S': {"SA", time.Saturday},
+	'U': {"SU", time.Sunday},
+}
+
+// ParseTermDates reads the first and last days of classes in
+// YYYY-MM-DD form
+func ParseTermDates(first, last string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", first)
+	if err != nil {
+		return start, start, fmt.Errorf("first day %q must be in YYYY-MM-DD form", first)
+	}
+	end, err := time.Parse("2006-01-02", last)
+	if err != nil {
+		return start, end, fmt.Errorf("last day %q must be in YYYY-MM-DD form", last)
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("the last day %s is before the first day %s", last, first)
+	}
+	return start, end, nil
+}
+
+// WriteICal writes an iCalendar file with a weekly event for each
+// section the instructors teach, repeating from the first day of the
+// term through the last, with the term's closed days left out as
+// exception dates. Times are floating (local to whoever opens the
+// file), since the input does not say where the school is.
+func (data *InputData) WriteICal(w io.Writer, placements []Placement, instructors []*Instructor, term *Term, now time.Time) error {
+	buf := new(bytes.Buffer)
+	line := func(format string, args ...interface{}) {
+		writeICalLine(buf, fmt.Sprintf(format, args...))
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//russross//schedule//EN")
+	line("CALSCALE:GREGORIAN")
+	stamp := now.UTC().Format("20060102T150405Z")
+
+	for _, instructor := range instructors {
+		for _, course := range instructor.Courses {
+			for _, placement := range placements {
+				if placement.Course != course {
+					continue
+				}
+				days, begin, end, err := data.MeetingTimes(placement, DefaultMeetingMinutes())
+				if err != nil {
+					return err
+				}
+				if len(begin) != 4 || len(end) != 4 {
+					return fmt.Errorf("time %s: start time %q is not in HHMM format", data.Times[placement.Time].Name, begin)
+				}
+
+				// the first meeting is on the first open class day of
+				// the term that falls on one of the section's days
+				var codes []string
+				for _, letter := range strings.ToUpper(days) {
+					if day, present := icalDays[letter]; present {
+						codes = append(codes, day.Code)
+					}
+				}
+				if len(codes) == 0 {
+					return fmt.Errorf("time %s: no meeting days found", data.Times[placement.Time].Name)
+				}
+				meetings := term.MeetingDates(days)
+				if len(meetings) == 0 {
+					continue
+				}
+				date := meetings[0]
+
+				var names []string
+				for _, elt := range course.Instructors {
+					names = append(names, elt.Name)
+				}
+				line("BEGIN:VEVENT")
+				line("UID:%s-%s@schedule", course.SectionID(), strings.ReplaceAll(instructor.Name, " ", "_"))
+				line("DTSTAMP:%s", stamp)
+				line("DTSTART:%sT%s00", date.Format("20060102"), begin)
+				line("DTEND:%sT%s00", date.Format("20060102"), end)
+				line("RRULE:FREQ=WEEKLY;BYDAY=%s;UNTIL=%sT235959", strings.Join(codes, ","), term.Last.Format("20060102"))
+				var exceptions []string
+				for _, closed := range term.ClosedDates(days) {
+					if closed.After(date) {
+						exceptions = append(exceptions, closed.Format("20060102")+"T"+begin+"00")
+					}
+				}
+				if len(exceptions) > 0 {
+					line("EXDATE:%s", strings.Join(exceptions, ","))
+				}
+				line("SUMMARY:%s", icalText(course.Name))
+				line("LOCATION:%s", icalText(data.Rooms[placement.Room].Name))
+				line("DESCRIPTION:%s", icalText(course.SectionID()+" taught by "+strings.Join(names, ", ")))
+				line("END:VEVENT")
+			}
+		}
+	}
+
+	line("END:VCALENDAR")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// icalText escapes a value for an iCalendar text property
+func icalText(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, ";", `\;`)
+	s = strings.ReplaceAll(s, ",", `\,`)
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// writeICalLine ends a line with CRLF, folding it so no line is longer
+// than 75 bytes, as iCalendar requires
+func writeICalLine(buf *bytes.Buffer, s string) {
+	limit := 75
+	for len(s) > limit {
+		// do not split a UTF-8 sequence
+		cut := limit
+		for cut > 0 && s[cut]&0xc0 == 0x80 {
+			cut--
+		}
+		buf.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+
+		// continuation lines start with a space
+		limit = 74
+	}
+	buf.WriteString(s + "\r\n")
+}
@@ -0,0 +1,1060 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JSONInputVersion is the version of the input format written by
+// InputData.MarshalJSON. Version 1 inputs, where a badness of 100 was
+// hard, are still read.
+const JSONInputVersion = 2
+
+// A JSONInput is the structured form of an input, for programs that
+// generate or edit inputs without going through the text format.
+// Everything is referred to by name. Badness values are as in the
+// text format, from 0 to MaxBadness, except that -1 stands for hard.
+// Rooms and times a course or instructor cannot use are left out.
+type JSONInput struct {
+	Version       int              `json:"version"`
+	Rooms         []JSONRoom       `json:"rooms"`
+	Times         []JSONTime       `json:"times"`
+	Days          []JSONDays       `json:"days,omitempty"`
+	Bands         []JSONBand       `json:"bands,omitempty"`
+	Instructors   []JSONInstructor `json:"instructors"`
+	Courses       []JSONCourse     `json:"courses"`
+	Conflicts     []JSONConflict   `json:"conflicts,omitempty"`
+	AntiConflicts []JSONConflict   `json:"anticonflicts,omitempty"`
+	Adjacencies   []JSONConflict   `json:"adjacencies,omitempty"`
+	Ceilings      []JSONCeiling    `json:"ceilings,omitempty"`
+	Concurrents   []JSONConflict   `json:"concurrents,omitempty"`
+	Orders        []JSONOrder      `json:"orders,omitempty"`
+	Aparts        []JSONGroup      `json:"aparts,omitempty"`
+	Togethers     []JSONGroup      `json:"togethers,omitempty"`
+	Term          *JSONTerm        `json:"term,omitempty"`
+	Costs         []JSONCost       `json:"costs,omitempty"`
+}
+
+// A JSONCost is extra badness for using any of the rooms at any of the
+// times, named individually
+type JSONCost struct {
+	Badness int      `json:"badness"`
+	Rooms   []string `json:"rooms"`
+	Times   []string `json:"times"`
+}
+
+// A JSONTerm is the term calendar, with dates in YYYY-MM-DD form.
+// Closed lists days with no classes, each a date or a range of dates
+// of the form first/last.
+type JSONTerm struct {
+	First    string   `json:"first"`
+	Last     string   `json:"last"`
+	Closed   []string `json:"closed,omitempty"`
+	Weighted bool     `json:"weighted,omitempty"`
+}
+
+// A JSONRoom is a room. Adjacent names the rooms next to it, which
+// are adjacent to it in turn whether or not they list it. Turnover
+// means it needs an empty slot between different courses.
+type JSONRoom struct {
+	Name     string   `json:"name"`
+	Tags     []string `json:"tags,omitempty"`
+	Adjacent []string `json:"adjacent,omitempty"`
+	Turnover bool     `json:"turnover,omitempty"`
+}
+
+// A JSONTime is a time slot. Next names the slot that follows it for
+// courses that take more than one slot, which must be the next time
+// in the list.
+type JSONTime struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
+	Next string   `json:"next,omitempty"`
+}
+
+// A JSONDays is a day pattern, grouping the time prefixes that count
+// as the same days for scoring
+type JSONDays struct {
+	Name       string   `json:"name"`
+	Prefixes   []string `json:"prefixes"`
+	Distribute bool     `json:"distribute,omitempty"`
+}
+
+// A JSONBand is a part of the day, starting at an HHMM time of day and
+// running until the next band starts
+type JSONBand struct {
+	Name  string `json:"name"`
+	Start string `json:"start"`
+}
+
+// A JSONInstructor lists the times an instructor can teach and the
+// badness of each. Days is 1 or 2 to prefer teaching on that many days.
+type JSONInstructor struct {
+	Name  string         `json:"name"`
+	Times map[string]int `json:"times"`
+	Days  int            `json:"days,omitempty"`
+}
+
+// A JSONCourse is one section of a course. The first instructor is
+// the one it is listed under in the text format. Times can be left
+// out to use the instructors' times alone. Slots is 2 or 3 for a
+// course that needs that many consecutive time slots, Studio marks a
+// studio course, which needs 3 slots on MWF and 2 on MW or TR, and
+// SlotsByDays gives the slots needed on each set of days (e.g., MWF)
+// for other patterns, with * for any other days.
+// Section fixes the section number, which is otherwise numbered from 1
+// in input order. Cohorts names the groups of students it serves,
+// Optional (if present) is the badness of dropping the section, and
+// Meetings lists any extra meetings it has.
+type JSONCourse struct {
+	Name        string         `json:"name"`
+	Section     int            `json:"section,omitempty"`
+	Instructors []string       `json:"instructors"`
+	Rooms       map[string]int `json:"rooms"`
+	Times       map[string]int `json:"times,omitempty"`
+	Slots       int            `json:"slots,omitempty"`
+	Studio      bool           `json:"studio,omitempty"`
+	SlotsByDays map[string]int `json:"slotsbydays,omitempty"`
+	Cohorts     []string       `json:"cohorts,omitempty"`
+	Optional    *int           `json:"optional,omitempty"`
+	Meetings    []JSONMeeting  `json:"meetings,omitempty"`
+}
+
+// A JSONMeeting is an extra meeting of a section with its own rooms
+// and times, for a section that meets on different days in different
+// rooms. It must start at the same time of day as the section's other
+// meetings, on different days.
+type JSONMeeting struct {
+	Rooms       map[string]int `json:"rooms"`
+	Times       map[string]int `json:"times,omitempty"`
+	Slots       int            `json:"slots,omitempty"`
+	Studio      bool           `json:"studio,omitempty"`
+	SlotsByDays map[string]int `json:"slotsbydays,omitempty"`
+}
+
+// A JSONConflict is a conflict, anticonflict, adjacency, or
+// concurrent setting for courses, which are named without a section
+// number and include every section. A concurrent setting with no
+// courses applies to every course.
+type JSONConflict struct {
+	Badness int      `json:"badness"`
+	Courses []string `json:"courses,omitempty"`
+}
+
+// A JSONCeiling limits how many sections of courses in a cohort can
+// meet at the same time
+type JSONCeiling struct {
+	Badness int    `json:"badness"`
+	Limit   int    `json:"limit"`
+	Cohort  string `json:"cohort"`
+}
+
+// A JSONOrder asks for every section of the second course to start
+// later on the same day as a section of the first, or right after it
+// if Immediately is set
+type JSONOrder struct {
+	Badness     int    `json:"badness"`
+	First       string `json:"first"`
+	Second      string `json:"second"`
+	Immediately bool   `json:"immediately,omitempty"`
+}
+
+// A JSONGroup is a group of instructors who should not teach at the
+// same time (an apart) or should teach on the same days (a together)
+type JSONGroup struct {
+	Badness     int      `json:"badness"`
+	Instructors []string `json:"instructors"`
+}
+
+// MarshalJSON writes the input as a JSONInput
+func (data *InputData) MarshalJSON() ([]byte, error) {
+	in := JSONInput{
+		Version:     JSONInputVersion,
+		Rooms:       []JSONRoom{},
+		Times:       []JSONTime{},
+		Instructors: []JSONInstructor{},
+		Courses:     []JSONCourse{},
+	}
+	for _, room := range data.Rooms {
+		elt := JSONRoom{Name: room.Name, Tags: room.Tags, Turnover: room.Turnover}
+		for _, other := range room.Adjacent {
+			elt.Adjacent = append(elt.Adjacent, other.Name)
+		}
+		in.Rooms = append(in.Rooms, elt)
+	}
+	for _, time := range data.Times {
+		elt := JSONTime{Name: time.Name, Tags: time.Tags}
+		if time.Next != nil {
+			elt.Next = time.Next.Name
+		}
+		in.Times = append(in.Times, elt)
+	}
+	for _, pattern := range data.DayPatterns {
+		in.Days = append(in.Days, JSONDays{Name: pattern.Name, Prefixes: pattern.Prefixes, Distribute: pattern.Distribute})
+	}
+	for _, band := range data.Bands {
+		in.Bands = append(in.Bands, JSONBand{Name: band.Name, Start: band.Start})
+	}
+	for _, instructor := range data.Instructors {
+		in.Instructors = append(in.Instructors, JSONInstructor{
+			Name:  instructor.Name,
+			Times: data.timeBadness(instructor.Times),
+			Days:  instructor.Days,
+		})
+	}
+	for _, course := range data.Courses {
+		if course.Main != nil {
+			continue
+		}
+		elt := JSONCourse{
+			Name:    course.Name,
+			Rooms:   data.roomBadness(course.Rooms),
+			Times:   data.timeBadness(course.Times),
+			Cohorts: course.Cohorts,
+		}
+		elt.Slots, elt.Studio, elt.SlotsByDays = jsonSlots(course)
+		if course.fixedSection {
+			elt.Section = course.Section
+		}
+		if course.Optional {
+			badness := course.DropBadness
+			elt.Optional = &badness
+		}
+		for _, instructor := range course.Instructors {
+			elt.Instructors = append(elt.Instructors, instructor.Name)
+		}
+		for _, extra := range course.Meetings() {
+			meeting := JSONMeeting{
+				Rooms: data.roomBadness(extra.Rooms),
+				Times: data.timeBadness(extra.Times),
+			}
+			meeting.Slots, meeting.Studio, meeting.SlotsByDays = jsonSlots(extra)
+			elt.Meetings = append(elt.Meetings, meeting)
+		}
+		in.Courses = append(in.Courses, elt)
+	}
+	for _, conflict := range data.Conflicts {
+		var names []string
+		seen := make(map[string]bool)
+		for _, course := range conflict.Courses {
+			if !seen[course.Name] {
+				seen[course.Name] = true
+				names = append(names, course.Name)
+			}
+		}
+		if len(names) > 0 {
+			in.Conflicts = append(in.Conflicts, JSONConflict{Badness: conflict.Badness, Courses: names})
+		}
+	}
+	for _, adjacency := range data.Adjacencies {
+		if len(adjacency.Courses) == 0 {
+			continue
+		}
+		in.Adjacencies = append(in.Adjacencies, JSONConflict{Badness: adjacency.Badness, Courses: adjacency.Courses})
+	}
+	for _, anti := range data.AntiConflicts {
+		if len(anti.Courses) == 0 {
+			continue
+		}
+		in.AntiConflicts = append(in.AntiConflicts, JSONConflict{Badness: anti.Badness, Courses: anti.Courses})
+	}
+	for _, ceiling := range data.Ceilings {
+		elt := JSONCeiling{Badness: ceiling.Badness, Limit: ceiling.Limit, Cohort: ceiling.Cohort}
+		in.Ceilings = append(in.Ceilings, elt)
+	}
+	for _, concurrent := range data.Concurrents {
+		elt := JSONConflict{Badness: concurrent.Badness, Courses: concurrent.Courses}
+		in.Concurrents = append(in.Concurrents, elt)
+	}
+	for _, order := range data.Orders {
+		elt := JSONOrder{Badness: order.Badness, First: order.First, Second: order.Second, Immediately: order.Immediately}
+		in.Orders = append(in.Orders, elt)
+	}
+	for _, apart := range data.Aparts {
+		in.Aparts = append(in.Aparts, jsonGroup(apart.Badness, apart.Instructors))
+	}
+	for _, together := range data.Togethers {
+		in.Togethers = append(in.Togethers, jsonGroup(together.Badness, together.Instructors))
+	}
+	if term := data.Term; term != nil {
+		in.Term = &JSONTerm{
+			First:    term.First.Format("2006-01-02"),
+			Last:     term.Last.Format("2006-01-02"),
+			Closed:   formatDates(term.Closed),
+			Weighted: term.WeightDays,
+		}
+	}
+	for _, cost := range data.Costs {
+		elt := JSONCost{Badness: cost.Badness}
+		for _, room := range cost.Rooms {
+			elt.Rooms = append(elt.Rooms, data.Rooms[room].Name)
+		}
+		for _, time := range cost.Times {
+			elt.Times = append(elt.Times, data.Times[time].Name)
+		}
+		in.Costs = append(in.Costs, elt)
+	}
+	return json.Marshal(in)
+}
+
+// upgrade turns a version 1 input, where a badness of 100 was hard,
+// into the current version, where -1 is
+func (in *JSONInput) upgrade() {
+	hard := func(badness *int) {
+		if *badness == 100 {
+			*badness = -1
+		}
+	}
+	hardList := func(list map[string]int) {
+		for name, badness := range list {
+			if badness == 100 {
+				list[name] = -1
+			}
+		}
+	}
+	for i := range in.Instructors {
+		hardList(in.Instructors[i].Times)
+	}
+	for i := range in.Courses {
+		course := &in.Courses[i]
+		hardList(course.Rooms)
+		hardList(course.Times)
+		for j := range course.Meetings {
+			hardList(course.Meetings[j].Rooms)
+			hardList(course.Meetings[j].Times)
+		}
+	}
+	for _, list := range [][]JSONConflict{in.Conflicts, in.AntiConflicts, in.Adjacencies, in.Concurrents} {
+		for i := range list {
+			hard(&list[i].Badness)
+		}
+	}
+	for i := range in.Ceilings {
+		hard(&in.Ceilings[i].Badness)
+	}
+	for i := range in.Orders {
+		hard(&in.Orders[i].Badness)
+	}
+	for _, list := range [][]JSONGroup{in.Aparts, in.Togethers} {
+		for i := range list {
+			hard(&list[i].Badness)
+		}
+	}
+	for i := range in.Costs {
+		hard(&in.Costs[i].Badness)
+	}
+	in.Version = JSONInputVersion
+}
+
+func jsonGroup(badness int, instructors []*Instructor) JSONGroup {
+	elt := JSONGroup{Badness: badness}
+	for _, instructor := range instructors {
+		elt.Instructors = append(elt.Instructors, instructor.Name)
+	}
+	return elt
+}
+
+// jsonSlots gives the slots, studio, and slots by days settings for a
+// course
+func jsonSlots(course *Course) (int, bool, map[string]int) {
+	switch {
+	case isStudio(course.SlotPattern):
+		return 0, true, nil
+	case len(course.SlotPattern) > 0:
+		return 0, false, slotPatternMap(course.SlotPattern)
+	}
+	return course.Slots, false, nil
+}
+
+// courseSlots checks the slots, studio, and slots by days settings of
+// a course and sets them
+func courseSlots(what string, course *Course, slots int, studio bool, slotsByDays map[string]int) error {
+	switch {
+	case (studio && slots != 0) || (studio && len(slotsByDays) > 0) || (slots != 0 && len(slotsByDays) > 0):
+		return fmt.Errorf("%s: only one of slots, studio, and slotsbydays can be given", what)
+	case studio:
+		course.SlotPattern = studioSlots
+	case len(slotsByDays) > 0:
+		pattern, err := slotPatternFromMap(slotsByDays)
+		if err != nil {
+			return fmt.Errorf("%s: %v", what, err)
+		}
+		course.SlotPattern = pattern
+	case slots < 0 || slots > 3:
+		return fmt.Errorf("%s: slots must be 2 or 3", what)
+	}
+	course.Slots = slots
+	return nil
+}
+
+// roomBadness maps room names to badness values, leaving out the rooms
+// that cannot be used
+func (data *InputData) roomBadness(list []int) map[string]int {
+	out := make(map[string]int)
+	for position, badness := range list {
+		if badness >= 0 {
+			out[data.Rooms[position].Name] = badness
+		}
+	}
+	return out
+}
+
+// timeBadness maps time names to badness values, leaving out the times
+// that cannot be used. It returns nil if there are none.
+func (data *InputData) timeBadness(list []int) map[string]int {
+	var out map[string]int
+	for position, badness := range list {
+		if badness >= 0 {
+			if out == nil {
+				out = make(map[string]int)
+			}
+			out[data.Times[position].Name] = badness
+		}
+	}
+	return out
+}
+
+// UnmarshalJSON reads an input written as a JSONInput, checking it the
+// same way Parse checks the text format. InputHash is a hash of the
+// JSON with whitespace removed.
+func (data *InputData) UnmarshalJSON(raw []byte) error {
+	var in JSONInput
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return err
+	}
+	switch in.Version {
+	case 1:
+		in.upgrade()
+	case JSONInputVersion:
+	default:
+		return fmt.Errorf("unsupported input version %d", in.Version)
+	}
+	*data = InputData{}
+
+	compact := new(bytes.Buffer)
+	if err := json.Compact(compact, raw); err != nil {
+		return err
+	}
+	data.InputHash = fmt.Sprintf("sha256:%x", sha256.Sum256(compact.Bytes()))
+
+	// every room, time, and tag name must be distinct, except that
+	// rooms can share tags, as can times
+	rooms := make(map[string]*Room)
+	times := make(map[string]*Time)
+	kinds := make(map[string]string)
+	claim := func(name, kind string) error {
+		if name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return fmt.Errorf("%s name %q must be non-empty with no spaces", kind, name)
+		}
+		if existing, present := kinds[name]; present && (existing != kind || !strings.HasSuffix(kind, "tag")) {
+			if existing == kind {
+				return fmt.Errorf("found duplicate %s %q", kind, name)
+			}
+			return fmt.Errorf("found %s %q with name matching %s name", kind, name, existing)
+		}
+		kinds[name] = kind
+		return nil
+	}
+	for _, elt := range in.Rooms {
+		if err := claim(elt.Name, "room"); err != nil {
+			return err
+		}
+		room := &Room{Name: elt.Name, Position: len(data.Rooms), Turnover: elt.Turnover}
+		for _, tag := range elt.Tags {
+			if err := claim(tag, "room tag"); err != nil {
+				return err
+			}
+			room.Tags = append(room.Tags, tag)
+		}
+		rooms[room.Name] = room
+		data.Rooms = append(data.Rooms, room)
+	}
+	for i, elt := range in.Rooms {
+		for _, name := range elt.Adjacent {
+			other := rooms[name]
+			if other == nil {
+				return fmt.Errorf("room %q: adjacent room %q not found", elt.Name, name)
+			}
+			if other == data.Rooms[i] {
+				return fmt.Errorf("room %q cannot be adjacent to itself", elt.Name)
+			}
+			data.Rooms[i].addAdjacent(other)
+		}
+	}
+	for _, elt := range in.Times {
+		if err := claim(elt.Name, "time"); err != nil {
+			return err
+		}
+		time := newTime(elt.Name, len(data.Times))
+		for _, tag := range elt.Tags {
+			if err := claim(tag, "time tag"); err != nil {
+				return err
+			}
+			time.Tags = append(time.Tags, tag)
+		}
+		times[time.Name] = time
+		data.Times = append(data.Times, time)
+	}
+	for i, elt := range in.Times {
+		if elt.Next == "" {
+			continue
+		}
+		if i+1 >= len(in.Times) || in.Times[i+1].Name != elt.Next {
+			return fmt.Errorf("time %q: next must be the time that follows it in the list", elt.Name)
+		}
+		data.Times[i].Next = data.Times[i+1]
+	}
+	for _, elt := range in.Days {
+		fields := append([]string{"days:", elt.Name}, elt.Prefixes...)
+		if elt.Distribute {
+			fields = append(fields, "distribute")
+		}
+		if err := data.ParseDays(fields); err != nil {
+			if parseErr, ok := err.(*ParseError); ok {
+				err = parseErr.Err
+			}
+			return fmt.Errorf("days: %v", err)
+		}
+	}
+	for _, elt := range in.Bands {
+		if err := data.ParseBand([]string{"band:", elt.Name, elt.Start}); err != nil {
+			if parseErr, ok := err.(*ParseError); ok {
+				err = parseErr.Err
+			}
+			return fmt.Errorf("band: %v", err)
+		}
+	}
+
+	// badness maps are indexed by position, with -1 for anything left out
+	resolve := func(what string, list map[string]int, n int, position func(string) (int, bool)) ([]int, error) {
+		out := make([]int, n)
+		for i := range out {
+			out[i] = -1
+		}
+		for name, badness := range list {
+			i, present := position(name)
+			if !present {
+				return nil, fmt.Errorf("%s: unknown name %q", what, name)
+			}
+			if badness < -1 || badness > MaxBadness {
+				return nil, fmt.Errorf("%s: badness must be between -1 and %d for %q", what, MaxBadness, name)
+			}
+			out[i] = badness
+		}
+		return out, nil
+	}
+	roomPosition := func(name string) (int, bool) {
+		room, present := rooms[name]
+		if !present {
+			return 0, false
+		}
+		return room.Position, true
+	}
+	timePosition := func(name string) (int, bool) {
+		time, present := times[name]
+		if !present {
+			return 0, false
+		}
+		return time.Position, true
+	}
+
+	instructors := make(map[string]*Instructor)
+	for _, elt := range in.Instructors {
+		what := fmt.Sprintf("instructor %q", elt.Name)
+		if elt.Name == "" || strings.ContainsAny(elt.Name, " \t\r\n") {
+			return fmt.Errorf("%s: name must be non-empty with no spaces", what)
+		}
+		if instructors[elt.Name] != nil {
+			return fmt.Errorf("cannot have two instructors with the same name %q", elt.Name)
+		}
+		if elt.Days < 0 || elt.Days > 2 {
+			return fmt.Errorf("%s: days must be 1 or 2", what)
+		}
+		if len(elt.Times) == 0 {
+			return fmt.Errorf("%s: no valid times found for instructor", what)
+		}
+		list, err := resolve(what, elt.Times, len(data.Times), timePosition)
+		if err != nil {
+			return err
+		}
+		instructor := &Instructor{Name: elt.Name, Times: list, Days: elt.Days}
+		instructors[instructor.Name] = instructor
+		data.Instructors = append(data.Instructors, instructor)
+	}
+
+	courseNames := make(map[string]bool)
+	for _, elt := range in.Courses {
+		what := fmt.Sprintf("course %q", elt.Name)
+		if elt.Name == "" || strings.ContainsAny(elt.Name, " \t\r\n") {
+			return fmt.Errorf("%s: name must be non-empty with no spaces", what)
+		}
+		if len(elt.Instructors) == 0 {
+			return fmt.Errorf("%s: must have at least one instructor", what)
+		}
+		if len(elt.Rooms) == 0 {
+			return fmt.Errorf("%s: no rooms found for course", what)
+		}
+		course := &Course{
+			Name:      elt.Name,
+			Conflicts: make(map[*Course]int),
+		}
+		var err error
+		if err = courseSlots(what, course, elt.Slots, elt.Studio, elt.SlotsByDays); err != nil {
+			return err
+		}
+		if elt.Section != 0 {
+			if course.Section, err = parseSectionNumber(fmt.Sprint(elt.Section)); err != nil {
+				return fmt.Errorf("%s: %v", what, err)
+			}
+			course.fixedSection = true
+			if err = data.checkSection(course); err != nil {
+				return fmt.Errorf("%s: %v", what, err)
+			}
+		}
+		for _, cohort := range elt.Cohorts {
+			if cohort == "" || strings.ContainsAny(cohort, " \t\r\n") {
+				return fmt.Errorf("%s: cohort name %q must be non-empty with no spaces", what, cohort)
+			}
+			if course.InCohort(cohort) {
+				return fmt.Errorf("%s: cohort %q repeated", what, cohort)
+			}
+			course.Cohorts = append(course.Cohorts, cohort)
+		}
+		if elt.Optional != nil {
+			if *elt.Optional < 0 || *elt.Optional > MaxBadness {
+				return fmt.Errorf("%s: the badness of dropping an optional course must be between 0 and %d", what, MaxBadness)
+			}
+			course.Optional = true
+			course.DropBadness = *elt.Optional
+		}
+		if course.Rooms, err = resolve(what, elt.Rooms, len(data.Rooms), roomPosition); err != nil {
+			return err
+		}
+		if len(elt.Times) > 0 {
+			if course.Times, err = resolve(what, elt.Times, len(data.Times), timePosition); err != nil {
+				return err
+			}
+		}
+		for _, name := range elt.Instructors {
+			instructor := instructors[name]
+			if instructor == nil {
+				return fmt.Errorf("%s: instructor %q not found", what, name)
+			}
+			for _, other := range course.Instructors {
+				if other == instructor {
+					return fmt.Errorf("%s: instructor %q assigned twice to the same course", what, name)
+				}
+			}
+			course.Instructors = append(course.Instructors, instructor)
+			instructor.Courses = append(instructor.Courses, course)
+		}
+
+		// extra meetings follow the section for each instructor
+		for i, meeting := range elt.Meetings {
+			what := fmt.Sprintf("course %q meeting %d", elt.Name, i+2)
+			extra := &Course{
+				Name:        course.Name,
+				Instructors: course.Instructors,
+				Conflicts:   make(map[*Course]int),
+				Cohorts:     course.Cohorts,
+				Main:        course,
+				Meeting:     i + 2,
+				Optional:    course.Optional,
+			}
+			if len(meeting.Rooms) == 0 {
+				return fmt.Errorf("%s: no rooms found for course", what)
+			}
+			if err = courseSlots(what, extra, meeting.Slots, meeting.Studio, meeting.SlotsByDays); err != nil {
+				return err
+			}
+			if extra.Rooms, err = resolve(what, meeting.Rooms, len(data.Rooms), roomPosition); err != nil {
+				return err
+			}
+			if len(meeting.Times) > 0 {
+				if extra.Times, err = resolve(what, meeting.Times, len(data.Times), timePosition); err != nil {
+					return err
+				}
+			}
+			for _, instructor := range extra.Instructors {
+				instructor.Courses = append(instructor.Courses, extra)
+			}
+		}
+		courseNames[course.Name] = true
+	}
+
+	// conflicts name courses, which stand for every section
+	constraint := func(kind string, elt JSONConflict) ([]*Course, error) {
+		if elt.Badness < -1 || elt.Badness > MaxBadness {
+			return nil, fmt.Errorf("badness of a %s must be between -1 and %d", kind, MaxBadness)
+		}
+		if len(elt.Courses) == 0 {
+			return nil, fmt.Errorf("a %s must name at least one course", kind)
+		}
+		var courses []*Course
+		repeat := make(map[string]bool)
+		for _, name := range elt.Courses {
+			if !courseNames[name] {
+				return nil, fmt.Errorf("course %q not found in %s", name, kind)
+			}
+			if repeat[name] {
+				return nil, fmt.Errorf("course %q repeated in %s", name, kind)
+			}
+			repeat[name] = true
+			for _, instructor := range data.Instructors {
+				for _, course := range instructor.Courses {
+					if course.Name == name && course.Instructors[0] == instructor {
+						courses = append(courses, course)
+					}
+				}
+			}
+		}
+		return courses, nil
+	}
+	for _, elt := range in.Conflicts {
+		courses, err := constraint("conflict", elt)
+		if err != nil {
+			return err
+		}
+		badness := elt.Badness
+		for _, course := range courses {
+			for _, other := range courses {
+				if course == other {
+					continue
+				}
+				if existing, present := course.Conflicts[other]; !present || badness > existing {
+					course.Conflicts[other] = badness
+				}
+			}
+		}
+		data.Conflicts = append(data.Conflicts, Conflict{Badness: badness, Courses: courses})
+	}
+	for _, elt := range in.AntiConflicts {
+		if _, err := constraint("anticonflict", elt); err != nil {
+			return err
+		}
+		badness := elt.Badness
+		data.AntiConflicts = append(data.AntiConflicts, AntiConflict{Badness: badness, Courses: elt.Courses})
+	}
+	for _, elt := range in.Adjacencies {
+		if _, err := constraint("adjacency", elt); err != nil {
+			return err
+		}
+		badness := elt.Badness
+		data.Adjacencies = append(data.Adjacencies, Adjacency{Badness: badness, Courses: elt.Courses})
+	}
+
+	// ceilings, concurrents, orders, aparts, togethers, and the term
+	// are checked the same way as the text lines
+	for _, elt := range in.Concurrents {
+		fields := append([]string{"concurrent:", fmt.Sprint(elt.Badness)}, elt.Courses...)
+		if err := data.ParseConcurrent(fields, nil); err != nil {
+			if parseErr, ok := err.(*ParseError); ok {
+				err = parseErr.Err
+			}
+			return fmt.Errorf("concurrent: %v", err)
+		}
+	}
+	for _, elt := range in.Ceilings {
+		fields := []string{"ceiling:", fmt.Sprint(elt.Badness), fmt.Sprint(elt.Limit), elt.Cohort}
+		if err := data.ParseCeiling(fields); err != nil {
+			if parseErr, ok := err.(*ParseError); ok {
+				err = parseErr.Err
+			}
+			return fmt.Errorf("ceiling: %v", err)
+		}
+	}
+	for _, elt := range in.Orders {
+		fields := []string{"order:", fmt.Sprint(elt.Badness), elt.First, elt.Second}
+		if elt.Immediately {
+			fields = append(fields, "immediately")
+		}
+		if err := data.ParseOrder(fields, nil); err != nil {
+			if parseErr, ok := err.(*ParseError); ok {
+				err = parseErr.Err
+			}
+			return fmt.Errorf("order: %v", err)
+		}
+	}
+	groups := func(kind string, list []JSONGroup, parse func([]string) error) error {
+		for _, elt := range list {
+			fields := append([]string{kind, fmt.Sprint(elt.Badness)}, elt.Instructors...)
+			if err := parse(fields); err != nil {
+				if parseErr, ok := err.(*ParseError); ok {
+					err = parseErr.Err
+				}
+				return fmt.Errorf("%s %v", kind, err)
+			}
+		}
+		return nil
+	}
+	if err := groups("apart:", in.Aparts, data.ParseApart); err != nil {
+		return err
+	}
+	if err := groups("together:", in.Togethers, data.ParseTogether); err != nil {
+		return err
+	}
+	if in.Term != nil {
+		fields := []string{"term:", in.Term.First, in.Term.Last}
+		if in.Term.Weighted {
+			fields = append(fields, "weighted")
+		}
+		err := data.ParseTerm(fields)
+		if err == nil && len(in.Term.Closed) > 0 {
+			err = data.ParseClosed(append([]string{"closed:"}, in.Term.Closed...))
+		}
+		if err != nil {
+			if parseErr, ok := err.(*ParseError); ok {
+				err = parseErr.Err
+			}
+			return fmt.Errorf("term: %v", err)
+		}
+	}
+
+	// costs name rooms and times individually, with no tags
+	for _, elt := range in.Costs {
+		fields := []string{"cost:", fmt.Sprint(elt.Badness)}
+		fields = append(fields, elt.Rooms...)
+		fields = append(fields, elt.Times...)
+		for _, name := range fields[2:] {
+			if rooms[name] == nil && times[name] == nil {
+				return fmt.Errorf("cost: unknown room or time %q", name)
+			}
+		}
+		if err := data.ParseCost(fields, rooms, times, nil, nil); err != nil {
+			if parseErr, ok := err.(*ParseError); ok {
+				err = parseErr.Err
+			}
+			return fmt.Errorf("cost: %v", err)
+		}
+	}
+
+	data.finish()
+	return nil
+}
+
+// WriteInput writes the input in the text format read by Parse. Rooms
+// and times are given to each course and instructor individually, so
+// the tags are kept but not used.
+func (data *InputData) WriteInput(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	badness := func(name string, badness int) string {
+		if badness == 0 {
+			return name
+		}
+		return fmt.Sprintf("%s:%d", name, badness)
+	}
+	list := func(fields []string, names func(int) string, badnessList []int) []string {
+		for position, value := range badnessList {
+			if value >= 0 {
+				fields = append(fields, badness(names(position), value))
+			}
+		}
+		return fields
+	}
+	roomName := func(position int) string { return data.Rooms[position].Name }
+	timeName := func(position int) string { return data.Times[position].Name }
+	line := func(fields ...string) {
+		fmt.Fprintln(out, strings.Join(fields, " "))
+	}
+
+	for _, room := range data.Rooms {
+		fields := append([]string{"room:", room.Name}, room.Tags...)
+		for _, other := range room.Adjacent {
+			if other.Position < room.Position {
+				fields = append(fields, "adjacent:"+other.Name)
+			}
+		}
+		line(fields...)
+	}
+	var turnover []string
+	for _, room := range data.Rooms {
+		if room.Turnover {
+			turnover = append(turnover, room.Name)
+		}
+	}
+	if len(turnover) > 0 {
+		line(append([]string{"turnover:"}, turnover...)...)
+	}
+	fmt.Fprintln(out)
+	for i, time := range data.Times {
+		if i > 0 && data.Times[i-1].Next != time {
+			line("time:")
+		}
+		line(append([]string{"time:", time.Name}, time.Tags...)...)
+	}
+	if len(data.DayPatterns)+len(data.Bands) > 0 {
+		fmt.Fprintln(out)
+	}
+	for _, pattern := range data.DayPatterns {
+		fields := append([]string{"days:", pattern.Name}, pattern.Prefixes...)
+		if pattern.Distribute {
+			fields = append(fields, "distribute")
+		}
+		line(fields...)
+	}
+	for _, band := range data.Bands {
+		line("band:", band.Name, band.Start)
+	}
+
+	for _, instructor := range data.Instructors {
+		fmt.Fprintln(out)
+		fields := list([]string{"instructor:", instructor.Name}, timeName, instructor.Times)
+		switch instructor.Days {
+		case 1:
+			fields = append(fields, "oneday")
+		case 2:
+			fields = append(fields, "twodays")
+		}
+		line(fields...)
+
+		courseFields := func(fields []string, course *Course) []string {
+			fields = list(fields, roomName, course.Rooms)
+			fields = list(fields, timeName, course.Times)
+			switch {
+			case isStudio(course.SlotPattern):
+				fields = append(fields, "studio")
+			case len(course.SlotPattern) > 0:
+				fields = append(fields, "slots:"+formatSlotPattern(course.SlotPattern))
+			case course.Slots == 2:
+				fields = append(fields, "twoslots")
+			case course.Slots == 3:
+				fields = append(fields, "threeslots")
+			}
+			return fields
+		}
+		for _, course := range instructor.Courses {
+			if course.Instructors[0] != instructor || course.Main != nil {
+				continue
+			}
+			fields := courseFields([]string{"course:", course.Name}, course)
+			if course.fixedSection {
+				fields = append(fields, fmt.Sprintf("section:%02d", course.Section))
+			}
+			for _, other := range course.Instructors[1:] {
+				fields = append(fields, "coteach:"+other.Name)
+			}
+			for _, cohort := range course.Cohorts {
+				fields = append(fields, "cohort:"+cohort)
+			}
+			if course.Optional {
+				fields = append(fields, fmt.Sprintf("optional:%d", course.DropBadness))
+			}
+			line(fields...)
+
+			// extra meetings inherit the section's cohorts unless they
+			// give their own
+			for _, extra := range course.Meetings() {
+				fields := courseFields([]string{"meeting:"}, extra)
+				if strings.Join(extra.Cohorts, " ") != strings.Join(course.Cohorts, " ") {
+					for _, cohort := range extra.Cohorts {
+						fields = append(fields, "cohort:"+cohort)
+					}
+				}
+				line(fields...)
+			}
+		}
+	}
+
+	if term := data.Term; term != nil {
+		fmt.Fprintln(out)
+		fields := []string{"term:", term.First.Format("2006-01-02"), term.Last.Format("2006-01-02")}
+		if term.WeightDays {
+			fields = append(fields, "weighted")
+		}
+		line(fields...)
+		if len(term.Closed) > 0 {
+			line(append([]string{"closed:"}, formatDates(term.Closed)...)...)
+		}
+	}
+
+	conflictBadness := func(badness int) string {
+		if badness < 0 {
+			return "hard"
+		}
+		return fmt.Sprint(badness)
+	}
+	if len(data.Costs) > 0 {
+		fmt.Fprintln(out)
+	}
+	for _, cost := range data.Costs {
+		fields := []string{"cost:", conflictBadness(cost.Badness)}
+		for _, room := range cost.Rooms {
+			fields = append(fields, roomName(room))
+		}
+		for _, time := range cost.Times {
+			fields = append(fields, timeName(time))
+		}
+		line(fields...)
+	}
+
+	if len(data.Conflicts)+len(data.AntiConflicts)+len(data.Adjacencies)+len(data.Ceilings)+len(data.Concurrents)+len(data.Orders)+len(data.Aparts)+len(data.Togethers) > 0 {
+		fmt.Fprintln(out)
+	}
+	// the text format needs at least two names on each line, but a
+	// conflict with one name can remain after the others are ignored
+	for _, conflict := range data.Conflicts {
+		fields := []string{"conflict:", conflictBadness(conflict.Badness)}
+		seen := make(map[string]bool)
+		for _, course := range conflict.Courses {
+			if !seen[course.Name] {
+				seen[course.Name] = true
+				fields = append(fields, course.Name)
+			}
+		}
+		switch {
+		case len(fields) == 3:
+			return fmt.Errorf("a conflict that only names %s cannot be written as text", fields[2])
+		case len(fields) > 3:
+			line(fields...)
+		}
+	}
+	for _, anti := range data.AntiConflicts {
+		switch {
+		case len(anti.Courses) == 1:
+			return fmt.Errorf("an anticonflict that only names %s cannot be written as text", anti.Courses[0])
+		case len(anti.Courses) > 1:
+			line(append([]string{"anticonflict:", conflictBadness(anti.Badness)}, anti.Courses...)...)
+		}
+	}
+	for _, adjacency := range data.Adjacencies {
+		switch {
+		case len(adjacency.Courses) == 1:
+			return fmt.Errorf("an adjacency that only names %s cannot be written as text", adjacency.Courses[0])
+		case len(adjacency.Courses) > 1:
+			line(append([]string{"adjacent:", conflictBadness(adjacency.Badness)}, adjacency.Courses...)...)
+		}
+	}
+	for _, ceiling := range data.Ceilings {
+		line("ceiling:", conflictBadness(ceiling.Badness), fmt.Sprint(ceiling.Limit), ceiling.Cohort)
+	}
+	for _, concurrent := range data.Concurrents {
+		line(append([]string{"concurrent:", conflictBadness(concurrent.Badness)}, concurrent.Courses...)...)
+	}
+	for _, order := range data.Orders {
+		fields := []string{"order:", conflictBadness(order.Badness), order.First, order.Second}
+		if order.Immediately {
+			fields = append(fields, "immediately")
+		}
+		line(fields...)
+	}
+	group := func(kind string, badness int, instructors []*Instructor) {
+		fields := []string{kind, conflictBadness(badness)}
+		for _, instructor := range instructors {
+			fields = append(fields, instructor.Name)
+		}
+		line(fields...)
+	}
+	for _, apart := range data.Aparts {
+		group("apart:", apart.Badness, apart.Instructors)
+	}
+	for _, together := range data.Togethers {
+		group("together:", together.Badness, together.Instructors)
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,316 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JSONVersion is the version of the schedule file format written by WriteJSON
+const JSONVersion = 2
+
+// A JSONSchedule is the version 2 schedule file format.
+// Placements are keyed by section ID so they do not depend on
+// the order of instructors and courses in the input. Unplaced lists
+// the IDs of the sections a partial schedule leaves out, and Dropped
+// the IDs of the optional sections it drops.
+type JSONSchedule struct {
+	Version    int             `json:"version"`
+	Generated  time.Time       `json:"generated"`
+	InputHash  string          `json:"input"`
+	Badness    int             `json:"badness"`
+	Run        *JSONRun        `json:"run,omitempty"`
+	Placements []JSONPlacement `json:"placements"`
+	Unplaced   []string        `json:"unplaced,omitempty"`
+	Dropped    []string        `json:"dropped,omitempty"`
+}
+
+// A JSONRun records how a schedule was produced, so a published
+// schedule can be traced back to the command and settings behind it
+type JSONRun struct {
+	Command string            `json:"command"`
+	Host    string            `json:"host,omitempty"`
+	Seed    int64             `json:"seed"`
+	Flags   map[string]string `json:"flags,omitempty"`
+	Started time.Time         `json:"started"`
+
+	// seconds from the start of the run until the schedule was written
+	Elapsed float64 `json:"elapsed"`
+
+	// the downloads the input came from, if it was downloaded
+	Sources []JSONSource `json:"sources,omitempty"`
+}
+
+// A JSONSource records one download of the input: where it came from,
+// which version it was, and where the copy that was used is cached
+type JSONSource struct {
+	URL      string    `json:"url"`
+	ETag     string    `json:"etag,omitempty"`
+	Fetched  time.Time `json:"fetched"`
+	Snapshot string    `json:"snapshot"`
+
+	// Cached is set when the cached copy was used without downloading
+	// it again, either because it was current or because the download
+	// failed or was not attempted
+	Cached bool `json:"cached,omitempty"`
+}
+
+type JSONPlacement struct {
+	ID          string   `json:"id"`
+	Course      string   `json:"course"`
+	Instructors []string `json:"instructors"`
+	Room        string   `json:"room"`
+	Time        string   `json:"time"`
+}
+
+// ReadJSON reads a schedule in either the current format or the
+// original format (a map from instructor names to course lists). It
+// returns an error if two sections share a room at the same time or a
+// section runs past the last time slot, so the placements it returns
+// can always be scored.
+func (data *InputData) ReadJSON(r io.Reader) ([]Placement, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// the original format has no version number
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &top); err != nil {
+		return nil, err
+	}
+	var version int
+	if elt, present := top["version"]; present && json.Unmarshal(elt, &version) == nil {
+		if version != JSONVersion {
+			return nil, fmt.Errorf("unsupported schedule file version %d", version)
+		}
+		placements, err := data.readJSONv2(raw)
+		if err != nil {
+			return nil, err
+		}
+		return placements, data.CheckPlacements(placements)
+	}
+	placements, err := data.readJSONv1(raw)
+	if err != nil {
+		return nil, err
+	}
+	return placements, data.CheckPlacements(placements)
+}
+
+func (data *InputData) readJSONv2(raw []byte) ([]Placement, error) {
+	var sched JSONSchedule
+	if err := json.Unmarshal(raw, &sched); err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string]*Course)
+	for _, course := range data.Courses {
+		sections[course.SectionID()] = course
+	}
+
+	var out []Placement
+	placed := make(map[*Course]bool)
+	for i, elt := range sched.Placements {
+		course, present := sections[elt.ID]
+		if !present {
+			return nil, fmt.Errorf("placement #%d has unknown section ID %q", i+1, elt.ID)
+		}
+		if placed[course] {
+			return nil, fmt.Errorf("section %s is placed more than once", elt.ID)
+		}
+		placed[course] = true
+		if elt.Course != course.Name {
+			return nil, fmt.Errorf("section %s should be course %s but I found %s instead",
+				elt.ID, course.Name, elt.Course)
+		}
+
+		// section numbers follow the input order, so a reordered input
+		// can give an ID to a different instructor's section
+		var instructors []string
+		for _, instructor := range course.Instructors {
+			instructors = append(instructors, instructor.Name)
+		}
+		if strings.Join(elt.Instructors, ", ") != strings.Join(instructors, ", ") {
+			return nil, fmt.Errorf("section %s should be taught by %s but I found %s instead",
+				elt.ID, strings.Join(instructors, ", "), strings.Join(elt.Instructors, ", "))
+		}
+
+		r, t, err := data.FindRoomTime(elt.Room, elt.Time)
+		if err != nil {
+			return nil, fmt.Errorf("section %s: %v", elt.ID, err)
+		}
+		out = append(out, Placement{Course: course, Room: r, Time: t})
+	}
+
+	// a section can only be missing if the schedule says it was left out
+	unplaced := make(map[string]bool)
+	for _, id := range sched.Unplaced {
+		unplaced[id] = true
+	}
+	for _, id := range sched.Dropped {
+		unplaced[id] = true
+	}
+	if len(out) != len(data.Courses) {
+		for _, course := range data.Courses {
+			if !placed[course] && !unplaced[course.SectionID()] {
+				return nil, fmt.Errorf("no placement found for section %s", course.SectionID())
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// FindRoomTime finds the room and time with the given names
+func (data *InputData) FindRoomTime(roomName, timeName string) (int, int, error) {
+	var r int
+	for r = 0; r < len(data.Rooms) && roomName != data.Rooms[r].Name; r++ {
+	}
+	if r >= len(data.Rooms) {
+		return 0, 0, fmt.Errorf("unrecognized room name %q", roomName)
+	}
+
+	var t int
+	for t = 0; t < len(data.Times) && timeName != data.Times[t].Name; t++ {
+	}
+	if t >= len(data.Times) {
+		return 0, 0, fmt.Errorf("unrecognized time name %q", timeName)
+	}
+	return r, t, nil
+}
+
+func (data *InputData) readJSONv1(raw []byte) ([]Placement, error) {
+	var sched map[string][][]string
+	if err := json.Unmarshal(raw, &sched); err != nil {
+		return nil, err
+	}
+
+	// build the list of placements
+	var out []Placement
+	for _, instructor := range data.Instructors {
+		courseList := sched[instructor.Name]
+		if len(courseList) != len(instructor.Courses) {
+			return nil, fmt.Errorf("found %d courses for %s, but expected to find %d",
+				len(courseList), instructor.Name, len(instructor.Courses))
+		}
+		for i := 0; i < len(courseList); i++ {
+			// for co-teaching, use the first listed instructor as the canonical entry
+			if instructor.Courses[i].Instructors[0] != instructor {
+				continue
+			}
+			course := courseList[i]
+			if len(course) != 3 {
+				return nil, fmt.Errorf("malformed entry for course #%d of instructor %s", i+1, instructor.Name)
+			}
+			if course[0] != instructor.Courses[i].Name {
+				return nil, fmt.Errorf("instructor %s course #%d should be %s but I found %s instead",
+					instructor.Name, i+1, instructor.Courses[i].Name, course[0])
+			}
+
+			var r int
+			for r = 0; r < len(data.Rooms) && course[1] != data.Rooms[r].Name; r++ {
+			}
+			if r >= len(data.Rooms) {
+				return nil, fmt.Errorf("instructor %s course %s (#%d) has unrecognized room name %q",
+					instructor.Name, instructor.Courses[i].Name, i+1, course[1])
+			}
+
+			var t int
+			for t = 0; t < len(data.Times) && course[2] != data.Times[t].Name; t++ {
+			}
+			if t >= len(data.Times) {
+				return nil, fmt.Errorf("instructor %s course %s (#%d) has unrecognized time name %q",
+					instructor.Name, instructor.Courses[i].Name, i+1, course[2])
+			}
+
+			out = append(out, Placement{Course: instructor.Courses[i], Room: r, Time: t})
+		}
+	}
+
+	if len(data.Instructors) != len(sched) {
+		return nil, fmt.Errorf("expected to find schedule for %d instructors, but found %d instead",
+			len(data.Instructors), len(sched))
+	}
+
+	return out, nil
+}
+
+// WriteJSON writes a schedule in the current file format,
+// one placement per line in the order the courses appear in the input,
+// followed by the sections it leaves out (if any).
+// run describes how the schedule was produced and may be nil.
+func (data *InputData) WriteJSON(w io.Writer, placements []Placement, run *JSONRun) error {
+	p := make(map[*Course]Placement)
+	for _, placement := range placements {
+		p[placement.Course] = placement
+	}
+	schedule := data.Score(placements)
+
+	quote := func(s string) string {
+		raw, _ := json.Marshal(s)
+		return string(raw)
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "{\n")
+	fmt.Fprintf(buf, "    \"version\": %d,\n", JSONVersion)
+	fmt.Fprintf(buf, "    \"generated\": %s,\n", quote(time.Now().UTC().Format(time.RFC3339)))
+	fmt.Fprintf(buf, "    \"input\": %s,\n", quote(data.InputHash))
+	fmt.Fprintf(buf, "    \"badness\": %d,\n", schedule.Badness)
+	if run != nil {
+		raw, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "    \"run\": %s,\n", raw)
+	}
+	var lines []string
+	for _, course := range data.Courses {
+		place, present := p[course]
+		if !present {
+			continue
+		}
+		var instructors []string
+		for _, instructor := range course.Instructors {
+			instructors = append(instructors, quote(instructor.Name))
+		}
+		lines = append(lines, fmt.Sprintf("        {\"id\": %s, \"course\": %s, \"instructors\": [%s], \"room\": %s, \"time\": %s}",
+			quote(course.SectionID()),
+			quote(course.Name),
+			strings.Join(instructors, ", "),
+			quote(data.Rooms[place.Room].Name),
+			quote(data.Times[place.Time].Name)))
+	}
+	fmt.Fprintf(buf, "    \"placements\": [\n")
+	if len(lines) > 0 {
+		fmt.Fprintf(buf, "%s\n", strings.Join(lines, ",\n"))
+	}
+	fmt.Fprintf(buf, "    ]")
+
+	// list the sections that were left out
+	for _, missing := range []struct {
+		key     string
+		courses []*Course
+	}{{"unplaced", schedule.Unplaced}, {"dropped", schedule.Dropped}} {
+		if len(missing.courses) == 0 {
+			continue
+		}
+		var ids []string
+		for _, course := range missing.courses {
+			ids = append(ids, quote(course.SectionID()))
+			if missing.key == "dropped" {
+				for _, meeting := range course.Meetings() {
+					ids = append(ids, quote(meeting.SectionID()))
+				}
+			}
+		}
+		fmt.Fprintf(buf, ",\n    %s: [%s]", quote(missing.key), strings.Join(ids, ", "))
+	}
+	fmt.Fprintf(buf, "\n}\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Finding is a constraint in the input that can have no effect on
+// which schedule is best, so it only slows the search down. Line is
+// the line of the text input it comes from, or zero if not known.
+type Finding struct {
+	Line    int
+	Kind    string
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", f.Line, f.Kind, f.Message)
+	}
+	return fmt.Sprintf("%s: %s", f.Kind, f.Message)
+}
+
+// an ignoreEntry is a name on an ignore: line
+type ignoreEntry struct {
+	Name string
+	Line int
+}
+
+// DeadConstraints looks for constraints that can have no effect:
+// conflicts between courses that always share an instructor (and so
+// can never meet at the same time anyway), anticonflicts between
+// courses with no time they could both start at, room lists that
+// allow every room equally, and ignored courses that are never named.
+func (data *InputData) DeadConstraints() []Finding {
+	var findings []Finding
+	byName := make(map[string][]*Course)
+	for _, course := range data.Courses {
+		byName[course.Name] = append(byName[course.Name], course)
+	}
+
+	// conflicts between courses that share an instructor
+	for _, conflict := range data.Conflicts {
+		var names []string
+		for _, course := range conflict.Courses {
+			if !containsString(names, course.Name) {
+				names = append(names, course.Name)
+			}
+		}
+		var pairs []string
+		total := 0
+		for i, a := range names {
+			for _, b := range names[i+1:] {
+				total++
+				if instructors := sharedInstructors(byName[a], byName[b]); instructors != "" {
+					pairs = append(pairs, fmt.Sprintf("%s/%s (%s)", a, b, instructors))
+				}
+			}
+		}
+		switch {
+		case len(pairs) == 0:
+		case len(pairs) == total:
+			findings = append(findings, Finding{
+				Line:    conflict.Line,
+				Kind:    "conflict",
+				Message: fmt.Sprintf("every pair is always taught by the same instructor, so none can meet at the same time anyway: %s", strings.Join(pairs, ", ")),
+			})
+		default:
+			findings = append(findings, Finding{
+				Line:    conflict.Line,
+				Kind:    "conflict",
+				Message: fmt.Sprintf("%d of %d pairs are always taught by the same instructor and cannot meet at the same time anyway: %s", len(pairs), total, strings.Join(pairs, ", ")),
+			})
+		}
+	}
+
+	// anticonflicts between courses that cannot meet at the same time
+	if len(data.AntiConflicts) > 0 {
+		feasible := data.feasibleTimes()
+		for _, anti := range data.AntiConflicts {
+			for i, a := range anti.Courses {
+				for _, b := range anti.Courses[i+1:] {
+					overlap := false
+					for t := range data.Times {
+						if feasible[a][t] && feasible[b][t] {
+							overlap = true
+							break
+						}
+					}
+					if !overlap {
+						findings = append(findings, Finding{
+							Line:    anti.Line,
+							Kind:    "anticonflict",
+							Message: fmt.Sprintf("%s and %s have no time they could both start at", a, b),
+						})
+					}
+				}
+			}
+		}
+	}
+
+	// room lists that exclude nothing
+	for _, course := range data.Courses {
+		same := true
+		for _, badness := range course.Rooms {
+			if badness < 0 || badness != course.Rooms[0] {
+				same = false
+				break
+			}
+		}
+		if same && len(data.Rooms) > 1 {
+			findings = append(findings, Finding{
+				Line:    course.line,
+				Kind:    "rooms",
+				Message: fmt.Sprintf("%s taught by %s can use every room equally", course.SectionID(), instructorNames(course.Instructors)),
+			})
+		}
+	}
+
+	// ignored courses that are never named
+	for _, entry := range data.unusedIgnores {
+		findings = append(findings, Finding{
+			Line:    entry.Line,
+			Kind:    "ignore",
+			Message: fmt.Sprintf("%s is not named anywhere else in the input", entry.Name),
+		})
+	}
+
+	sort.SliceStable(findings, func(a, b int) bool {
+		return findings[a].Line < findings[b].Line
+	})
+	return findings
+}
+
+// sharedInstructors gives the instructors that teach every section
+// of both courses, or "" if there are none
+func sharedInstructors(a, b []*Course) string {
+	if len(a) == 0 || len(b) == 0 {
+		return ""
+	}
+	teaches := func(instructor *Instructor, courses []*Course) bool {
+		for _, course := range courses {
+			found := false
+			for _, other := range course.Instructors {
+				if other == instructor {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	var shared []*Instructor
+	for _, instructor := range a[0].Instructors {
+		if teaches(instructor, a) && teaches(instructor, b) {
+			shared = append(shared, instructor)
+		}
+	}
+	return instructorNames(shared)
+}
+
+func instructorNames(instructors []*Instructor) string {
+	var names []string
+	for _, instructor := range instructors {
+		names = append(names, instructor.Name)
+	}
+	return strings.Join(names, " and ")
+}
+
+// feasibleTimes finds the times when some section of each course
+// could start, by course name
+func (data *InputData) feasibleTimes() map[string][]bool {
+	out := make(map[string][]bool)
+	sections, _ := data.buildSectionList(true)
+	for _, section := range sections {
+		name := section.Course.Name
+		if out[name] == nil {
+			out[name] = make([]bool, len(data.Times))
+		}
+		for _, times := range section.RoomTimes {
+			for t, badness := range times {
+				if badness >= 0 {
+					out[name][t] = true
+				}
+			}
+		}
+	}
+	return out
+}
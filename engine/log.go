@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"io"
+	"log"
+)
+
+// A Logger receives the messages the engine writes as it works. A
+// *log.Logger is a Logger.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// DiscardLogger drops every message
+var DiscardLogger Logger = log.New(io.Discard, "", 0)
+
+// standardLogger writes through the standard log package, so it uses
+// whatever output and flags the program has set there
+type standardLogger struct{}
+
+func (standardLogger) Printf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+}
+
+// loggerOrDefault returns logger, or the standard logger if it is nil
+func loggerOrDefault(logger Logger) Logger {
+	if logger == nil {
+		return standardLogger{}
+	}
+	return logger
+}
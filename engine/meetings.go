@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Weekdays are the columns of a weekly calendar
+var Weekdays = []struct {
+	Letter rune
+	Name   string
+}{
+	{'M', "Mon"}, {'T', "Tue"}, {'W', "Wed"}, {'R', "Thu"}, {'F', "Fri"},
+}
+
+// A Meeting is one weekly meeting of a section on one day,
+// with start and end times in minutes after midnight
+type Meeting struct {
+	Placement  Placement
+	Day        int
+	Start, End int
+}
+
+// Meetings breaks an instructor's placements into one entry per
+// weekday that each section meets, sorted by start time.
+// Days outside Monday through Friday are left out.
+func (data *InputData) Meetings(placements []Placement, instructor *Instructor) ([]Meeting, error) {
+	var meetings []Meeting
+	for _, course := range instructor.Courses {
+		for _, placement := range placements {
+			if placement.Course != course {
+				continue
+			}
+			days, begin, end, err := data.MeetingTimes(placement, DefaultMeetingMinutes())
+			if err != nil {
+				return nil, err
+			}
+			start, err := strconv.Atoi(begin)
+			if err != nil {
+				return nil, fmt.Errorf("time %s: start time %q is not in HHMM format", data.Times[placement.Time].Name, begin)
+			}
+			finish, _ := strconv.Atoi(end)
+			for _, letter := range strings.ToUpper(days) {
+				for day, weekday := range Weekdays {
+					if weekday.Letter == letter {
+						meetings = append(meetings, Meeting{
+							Placement: placement,
+							Day:       day,
+							Start:     start/100*60 + start%100,
+							End:       finish/100*60 + finish%100,
+						})
+					}
+				}
+			}
+		}
+	}
+	sort.SliceStable(meetings, func(a, b int) bool {
+		return meetings[a].Start < meetings[b].Start
+	})
+	return meetings, nil
+}
+
+// DefaultMeetingMinutes gives the usual length of one meeting in
+// minutes for each days pattern
+func DefaultMeetingMinutes() map[string]int {
+	return map[string]int{"MWF": 50, "MW": 75, "TR": 75, "*": 150}
+}
+
+// MeetingTimes gives the days, start time, and end time (both as
+// 24-hour HHMM) of a placement. Meeting lengths in minutes are looked
+// up by days pattern with "*" as the fallback unless the time name
+// gives its own end (e.g., MW1300-1415), and sections that use several
+// consecutive time slots end when the last slot ends.
+func (data *InputData) MeetingTimes(placement Placement, minutes map[string]int) (days, begin, end string, err error) {
+	days, begin = data.Times[placement.Time].DaysAndHour()
+	last := placement.Time + placement.Course.SlotsNeeded(data.Times[placement.Time]) - 1
+	lastDays, lastBegin := data.Times[last].DaysAndHour()
+	if data.Times[last].End >= 0 {
+		return days, begin, formatClock(data.Times[last].End), nil
+	}
+	length, present := minutes[lastDays]
+	if !present {
+		length, present = minutes["*"]
+	}
+	if !present {
+		return "", "", "", fmt.Errorf("no meeting length given for %s", lastDays)
+	}
+	if end, err = addMinutes(lastBegin, length); err != nil {
+		return "", "", "", fmt.Errorf("time %s: %v", data.Times[last].Name, err)
+	}
+	return days, begin, end, nil
+}
+
+// addMinutes adds a number of minutes to a 24-hour HHMM time
+func addMinutes(hhmm string, minutes int) (string, error) {
+	if len(hhmm) != 4 {
+		return "", fmt.Errorf("start time %q is not in HHMM format", hhmm)
+	}
+	n, err := strconv.Atoi(hhmm)
+	if err != nil {
+		return "", fmt.Errorf("start time %q is not in HHMM format", hhmm)
+	}
+	total := (n/100)*60 + n%100 + minutes
+	return fmt.Sprintf("%02d%02d", total/60, total%60), nil
+}
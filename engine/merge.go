@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Merge lists the departments whose inputs are scheduled together
+// in one pool of rooms and times. Pool is the prefix of the input that
+// gives the rooms, times, and anything else about them that every
+// department shares.
+type Merge struct {
+	Pool        string
+	Departments []Department
+}
+
+// A Department is one input in a merge. Name is put in front of the
+// names of its instructors, courses, and cohorts (e.g., CS.CS1400) to
+// keep them apart from those of other departments, and Prefix is the
+// prefix of its input, which holds its instructors, courses, and
+// constraints but no rooms or times.
+type Department struct {
+	Name   string
+	Prefix string
+	Line   int
+}
+
+// ParseMerge reads a merge file, which has lines of the form
+//
+//	pool: prefix
+//	department: name prefix
+//
+// with exactly one pool line and at least one department
+func ParseMerge(filename string, lines [][]string) (*Merge, error) {
+	merge := new(Merge)
+	var errs ParseErrors
+	names := make(map[string]bool)
+	for linenumber, line := range lines {
+		fields := inputFields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "pool:":
+			switch {
+			case merge.Pool != "":
+				err = lineError(KindDuplicate, "only one pool: line is allowed")
+			case len(fields) != 2:
+				err = lineError(KindSyntax, "expected %q", "pool: prefix")
+			default:
+				merge.Pool = fields[1]
+			}
+
+		case "department:":
+			switch {
+			case len(fields) != 3:
+				err = lineError(KindSyntax, "expected %q", "department: name prefix")
+			case strings.Contains(fields[1], "."):
+				err = fieldError(KindSyntax, fields[1], "department name cannot contain a period")
+			case names[fields[1]]:
+				err = fieldError(KindDuplicate, fields[1], "department listed twice")
+			default:
+				names[fields[1]] = true
+				merge.Departments = append(merge.Departments, Department{Name: fields[1], Prefix: fields[2], Line: linenumber + 1})
+			}
+
+		default:
+			err = fieldError(KindSyntax, fields[0], "unknown line type %q", fields[0])
+		}
+		if err != nil {
+			errs.add(filename, lines, linenumber+1, err)
+		}
+	}
+	if merge.Pool == "" {
+		errs.add(filename, lines, 0, lineError(KindSyntax, "no pool: line found"))
+	}
+	if len(merge.Departments) == 0 {
+		errs.add(filename, lines, 0, lineError(KindSyntax, "no department: lines found"))
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return merge, nil
+}
+
+// Merge combines the inputs of the departments, each parsed with the
+// pool's lines in front of its own and given in the same order as
+// merge.Departments, into one input. The rooms, times, and everything
+// else from the pool come from the first department. Each section
+// keeps its section number, so a section of the combined input is
+// the department's section with the department name and a period in
+// front of its course name.
+func (merge *Merge) Merge(departments []*InputData) (*InputData, error) {
+	if len(departments) != len(merge.Departments) {
+		return nil, fmt.Errorf("expected %d departments but found %d", len(merge.Departments), len(departments))
+	}
+	var combined *JSONInput
+	for n, data := range departments {
+		in, err := data.jsonInput()
+		if err != nil {
+			return nil, err
+		}
+		department := merge.Departments[n].Name
+		in.namespace(department, data)
+		if combined == nil {
+			combined = in
+			continue
+		}
+		combined.Instructors = append(combined.Instructors, in.Instructors...)
+		combined.Courses = append(combined.Courses, in.Courses...)
+		combined.Conflicts = append(combined.Conflicts, in.Conflicts...)
+		combined.AntiConflicts = append(combined.AntiConflicts, in.AntiConflicts...)
+		combined.Adjacencies = append(combined.Adjacencies, in.Adjacencies...)
+		combined.Ceilings = append(combined.Ceilings, in.Ceilings...)
+		combined.Concurrents = append(combined.Concurrents, in.Concurrents...)
+		combined.Orders = append(combined.Orders, in.Orders...)
+		combined.Aparts = append(combined.Aparts, in.Aparts...)
+		combined.Togethers = append(combined.Togethers, in.Togethers...)
+	}
+	return combined.inputData()
+}
+
+// Department gives the department a section of the combined input
+// belongs to, and its ID in that department's own input
+func (merge *Merge) Department(course *Course) (int, string) {
+	id := course.SectionID()
+	for n, department := range merge.Departments {
+		if strings.HasPrefix(id, department.Name+".") {
+			return n, id[len(department.Name)+1:]
+		}
+	}
+	return -1, id
+}
+
+// namespace puts the department name in front of every instructor,
+// course, and cohort name in a department's input. A concurrent
+// setting for every course becomes one for every course of the
+// department that has no setting of its own, so it does not spill
+// over into other departments.
+func (in *JSONInput) namespace(department string, data *InputData) {
+	rename := func(name string) string { return department + "." + name }
+	names := func(list []string) []string {
+		var out []string
+		for _, name := range list {
+			out = append(out, rename(name))
+		}
+		return out
+	}
+
+	for i := range in.Instructors {
+		in.Instructors[i].Name = rename(in.Instructors[i].Name)
+	}
+	i := 0
+	for _, course := range data.Courses {
+		if course.Main != nil {
+			continue
+		}
+		elt := &in.Courses[i]
+		i++
+		elt.Name = rename(elt.Name)
+		elt.Section = course.Section
+		elt.Instructors = names(elt.Instructors)
+		elt.Cohorts = names(elt.Cohorts)
+	}
+	for i := range in.Conflicts {
+		in.Conflicts[i].Courses = names(in.Conflicts[i].Courses)
+	}
+	for i := range in.AntiConflicts {
+		in.AntiConflicts[i].Courses = names(in.AntiConflicts[i].Courses)
+	}
+	for i := range in.Adjacencies {
+		in.Adjacencies[i].Courses = names(in.Adjacencies[i].Courses)
+	}
+	for i := range in.Ceilings {
+		in.Ceilings[i].Cohort = rename(in.Ceilings[i].Cohort)
+	}
+	for i := range in.Orders {
+		in.Orders[i].First = rename(in.Orders[i].First)
+		in.Orders[i].Second = rename(in.Orders[i].Second)
+	}
+	for i := range in.Aparts {
+		in.Aparts[i].Instructors = names(in.Aparts[i].Instructors)
+	}
+	for i := range in.Togethers {
+		in.Togethers[i].Instructors = names(in.Togethers[i].Instructors)
+	}
+
+	named := make(map[string]bool)
+	for _, concurrent := range in.Concurrents {
+		for _, name := range concurrent.Courses {
+			named[name] = true
+		}
+	}
+	var concurrents []JSONConflict
+	for _, concurrent := range in.Concurrents {
+		if len(concurrent.Courses) > 0 {
+			concurrent.Courses = names(concurrent.Courses)
+			concurrents = append(concurrents, concurrent)
+			continue
+		}
+		var rest []string
+		for _, course := range data.Courses {
+			if course.Main == nil && !named[course.Name] && !containsString(rest, course.Name) {
+				rest = append(rest, course.Name)
+			}
+		}
+		if len(rest) > 0 {
+			concurrent.Courses = names(rest)
+			concurrents = append(concurrents, concurrent)
+		}
+	}
+	in.Concurrents = concurrents
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// A Move records a course that is placed differently in two schedules
+type Move struct {
+	Course   *Course
+	From, To Placement
+}
+
+// A MoveOption is a legal destination for a course, along with the
+// badness of the schedule after moving it there
+type MoveOption struct {
+	Room    int
+	Time    int
+	Badness int
+}
+
+// FindCourse finds a course by section ID (e.g., CS1400-02), or by
+// course name if there is only one section of that course
+func (data *InputData) FindCourse(name string) (*Course, error) {
+	var matches []*Course
+	for _, course := range data.Courses {
+		if course.SectionID() == name {
+			return course, nil
+		}
+		if course.Name == name {
+			matches = append(matches, course)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown course %q", name)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("%s has %d sections; use a section ID such as %s", name, len(matches), matches[0].SectionID())
+	}
+}
+
+// SectionFor finds the section for a course in a section list
+func SectionFor(sections []*Section, course *Course) *Section {
+	for _, section := range sections {
+		if section.Course == course {
+			return section
+		}
+	}
+	return nil
+}
+
+// MoveCourse returns a copy of placements with the course moved to the
+// given room and time. It fails if the course cannot be placed there,
+// if another course is in the way, or if one of its instructors is
+// teaching another course at that time; nothing is displaced.
+func (data *InputData) MoveCourse(sections []*Section, placements []Placement, course *Course, room, time int) ([]Placement, error) {
+	return data.MoveCourses(sections, placements, []Placement{{Course: course, Room: room, Time: time}})
+}
+
+// MoveCourses is like MoveCourse, but moves several courses at once.
+// The moves are checked together, so two courses can trade places.
+func (data *InputData) MoveCourses(sections []*Section, placements []Placement, moves []Placement) ([]Placement, error) {
+	moving := make(map[*Course]bool)
+	for _, move := range moves {
+		course := move.Course
+		if moving[course] {
+			return nil, fmt.Errorf("%s is moved more than once", course.SectionID())
+		}
+		moving[course] = true
+		section := SectionFor(sections, course)
+		if section == nil {
+			return nil, fmt.Errorf("%s is not in the schedule", course.SectionID())
+		}
+		if section.RoomTimes[move.Room][move.Time] < 0 {
+			return nil, fmt.Errorf("%s cannot be scheduled in %s at %s because of its room, time, or instructor constraints",
+				course.SectionID(), data.Rooms[move.Room].Name, data.Times[move.Time].Name)
+		}
+	}
+
+	// apply the moves to a copy
+	index := make(map[*Course]int)
+	for i, placement := range placements {
+		index[placement.Course] = i
+	}
+	moved := make([]Placement, len(placements))
+	copy(moved, placements)
+	for _, move := range moves {
+		i, present := index[move.Course]
+		if !present {
+			return nil, fmt.Errorf("%s is not in the schedule", move.Course.SectionID())
+		}
+		moved[i] = move
+	}
+
+	// make sure nothing else is in the way: another course in the same
+	// room, or another course taught by one of the same instructors
+	for _, move := range moves {
+		slots := move.Course.SlotsNeeded(data.Times[move.Time])
+		for _, placement := range moved {
+			if placement.Course == move.Course {
+				continue
+			}
+			otherSlots := placement.Course.SlotsNeeded(data.Times[placement.Time])
+			if placement.Time >= move.Time+slots || move.Time >= placement.Time+otherSlots {
+				continue
+			}
+			if placement.Room == move.Room {
+				return nil, fmt.Errorf("%s cannot be scheduled in %s at %s because %s is already there at %s",
+					move.Course.SectionID(), data.Rooms[move.Room].Name, data.Times[move.Time].Name,
+					placement.Course.SectionID(), data.Times[placement.Time].Name)
+			}
+			if instructor := sharedInstructor(move.Course, placement.Course); instructor != nil {
+				return nil, fmt.Errorf("%s cannot be scheduled in %s at %s because %s also teaches %s in %s at %s",
+					move.Course.SectionID(), data.Rooms[move.Room].Name, data.Times[move.Time].Name,
+					instructor.Name, placement.Course.SectionID(),
+					data.Rooms[placement.Room].Name, data.Times[placement.Time].Name)
+			}
+		}
+	}
+
+	return moved, nil
+}
+
+// sharedInstructor finds an instructor who teaches both courses, or
+// returns nil if there is none
+func sharedInstructor(a, b *Course) *Instructor {
+	for _, instructorA := range a.Instructors {
+		for _, instructorB := range b.Instructors {
+			if instructorA == instructorB {
+				return instructorA
+			}
+		}
+	}
+	return nil
+}
+
+// MoveOptions lists every room and time a course could move to without
+// displacing anything else or breaking a hard rule the schedule did not
+// already break, ordered from the lowest resulting badness
+func (data *InputData) MoveOptions(sections []*Section, placements []Placement, course *Course) []MoveOption {
+	section := SectionFor(sections, course)
+	if section == nil {
+		return nil
+	}
+	var current Placement
+	for _, placement := range placements {
+		if placement.Course == course {
+			current = placement
+		}
+	}
+
+	scratch := new(ScoreScratch)
+	impossible := impossibleProblems(data.ScoreWith(scratch, placements).Problems)
+	var options []MoveOption
+	for r, times := range section.RoomTimes {
+		for t, badness := range times {
+			if badness < 0 || r == current.Room && t == current.Time {
+				continue
+			}
+			moved, err := data.MoveCourse(sections, placements, course, r, t)
+			if err != nil {
+				continue
+			}
+			scored := data.ScoreWith(scratch, moved)
+			if impossibleProblems(scored.Problems) > impossible {
+				continue
+			}
+			options = append(options, MoveOption{Room: r, Time: t, Badness: scored.Badness})
+		}
+	}
+	sort.SliceStable(options, func(a, b int) bool {
+		return options[a].Badness < options[b].Badness
+	})
+	return options
+}
+
+// impossibleProblems counts the problems that break a hard rule
+func impossibleProblems(problems []Problem) int {
+	count := 0
+	for _, problem := range problems {
+		if problem.Badness >= Impossible {
+			count++
+		}
+	}
+	return count
+}
+
+// CheckPlacements makes sure that every placement fits in the grid
+// and that no two placements use the same room at the same time
+func (data *InputData) CheckPlacements(placements []Placement) error {
+	used := make([][]*Course, len(data.Rooms))
+	for r := range used {
+		used[r] = make([]*Course, len(data.Times))
+	}
+	for _, placement := range placements {
+		slots := placement.Course.SlotsNeeded(data.Times[placement.Time])
+		if placement.Time+slots > len(data.Times) {
+			return fmt.Errorf("%s at %s runs past the last time slot",
+				placement.Course.SectionID(), data.Times[placement.Time].Name)
+		}
+		for i := 0; i < slots; i++ {
+			if other := used[placement.Room][placement.Time+i]; other != nil {
+				return fmt.Errorf("%s and %s are both in %s at %s",
+					other.SectionID(), placement.Course.SectionID(),
+					data.Rooms[placement.Room].Name, data.Times[placement.Time+i].Name)
+			}
+			used[placement.Room][placement.Time+i] = placement.Course
+		}
+	}
+	return nil
+}
+
+// Moves lists the courses that are placed differently in two schedules
+// for the same input, in the order the courses appear in the input
+func (data *InputData) Moves(from, to []Placement) []Move {
+	fromPlacement := make(map[*Course]Placement)
+	for _, placement := range from {
+		fromPlacement[placement.Course] = placement
+	}
+	toPlacement := make(map[*Course]Placement)
+	for _, placement := range to {
+		toPlacement[placement.Course] = placement
+	}
+
+	var moves []Move
+	for _, course := range data.Courses {
+		a, aPresent := fromPlacement[course]
+		b, bPresent := toPlacement[course]
+		if !aPresent || !bPresent {
+			continue
+		}
+		if a.Room != b.Room || a.Time != b.Time {
+			moves = append(moves, Move{Course: course, From: a, To: b})
+		}
+	}
+	return moves
+}
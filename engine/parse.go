@@ -0,0 +1,1717 @@
+// Package engine parses schedule input, scores schedules, reads and
+// writes schedule files, and searches for good schedules. The schedule
+// command-line tool and web assembly page are front ends to it.
+package engine
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// data types representing input parameters
+//
+// badness ranges from 0 (good) to MaxBadness (bad), or -1 for impossible
+//
+
+// MaxBadness is the highest badness of a soft constraint. Anything
+// that must not happen is given as "hard" instead and stored as -1.
+const MaxBadness = 9999
+
+type InputData struct {
+	Rooms         []*Room
+	Times         []*Time
+	Instructors   []*Instructor
+	Courses       []*Course
+	Conflicts     []Conflict
+	AntiConflicts []AntiConflict
+
+	// Term is the term calendar, or nil if the input does not give one
+	Term *Term
+
+	// DayPatterns group the time prefixes into days for scoring. If
+	// there are none, days come from Time.Prefix.
+	DayPatterns []DayPattern
+
+	// Bands divide the day into parts to spread the sections of a
+	// course across. If there are none, the day is split into morning
+	// and afternoon.
+	Bands []Band
+
+	// Costs add badness to particular combinations of rooms and times
+	Costs []Cost
+
+	// Aparts are groups of instructors who should not teach at the
+	// same time
+	Aparts []Apart
+
+	// Adjacencies are groups of courses whose sections should be in
+	// adjacent rooms when they meet at the same time
+	Adjacencies []Adjacency
+
+	// Ceilings limit how many courses of a cohort meet at once
+	Ceilings []Ceiling
+
+	// Orders are pairs of courses where one should meet later on the
+	// same day as the other
+	Orders []Order
+
+	// Togethers are groups of instructors who should teach on the
+	// same days
+	Togethers []Together
+
+	// Concurrents set the badness of sections of the same course
+	// meeting at the same time
+	Concurrents []Concurrent
+
+	// InputHash identifies the input that was parsed. It is a hash of
+	// the fields on each line, so it ignores changes in whitespace.
+	InputHash string
+
+	// Expansions list the cohorts and instructors named in conflict
+	// and anticonflict lines, with the courses each one stands for
+	Expansions []Expansion
+
+	// Warnings are problems in the input that do not stop it from
+	// being used, e.g., a time name with no time of day that can be
+	// read for scoring
+	Warnings ParseErrors
+
+	// the weight of each day prefix for the per-day load scoring, or
+	// nil if days are not weighted
+	dayWeight map[string]float64
+
+	// roomTimeCost[room][time] is the badness from Costs for a course
+	// using that room at that time, -1 if it cannot, or nil if there
+	// are no costs
+	roomTimeCost [][]int
+
+	// timeBand is the index of the band each time starts in, or -1
+	timeBand []int
+
+	// concurrent is the badness of two sections of each course meeting
+	// at the same time
+	concurrent map[string]int
+
+	// timeDay is the days each time counts as for the per-day scoring,
+	// and timeDistribution the days it counts as for the section
+	// distribution rule (see timeDays)
+	timeDay          []string
+	timeDistribution []string
+
+	// unusedIgnores are the names on ignore: lines that no other line
+	// uses
+	unusedIgnores []ignoreEntry
+}
+
+type Room struct {
+	Name     string
+	Tags     []string
+	Position int
+
+	// Adjacent lists the rooms next to this one, e.g., across a hallway
+	Adjacent []*Room
+
+	// Turnover means the room needs an empty slot between different
+	// courses, e.g., to tear down a lab
+	Turnover bool
+}
+
+// IsAdjacent reports whether the rooms are next to each other
+func (room *Room) IsAdjacent(other *Room) bool {
+	for _, elt := range room.Adjacent {
+		if elt == other {
+			return true
+		}
+	}
+	return false
+}
+
+// addAdjacent records that two rooms are next to each other
+func (room *Room) addAdjacent(other *Room) {
+	if room != other && !room.IsAdjacent(other) {
+		room.Adjacent = append(room.Adjacent, other)
+		other.Adjacent = append(other.Adjacent, room)
+	}
+}
+
+type Time struct {
+	Name     string
+	Tags     []string
+	Next     *Time
+	Position int
+
+	// Days are the day letters from the name in upper case, and Start
+	// is the time of day it gives in minutes after midnight, or -1 if
+	// none can be read. End is when the slot ends for a name that
+	// gives a range, e.g., MW1300-1415, or -1.
+	Days  string
+	Start int
+	End   int
+}
+
+type Instructor struct {
+	Name     string
+	Times    []int
+	Courses  []*Course
+	Days     int
+	MinRooms int
+}
+
+type Course struct {
+	ID          int
+	Name        string
+	Section     int
+	Instructors []*Instructor
+	Rooms       []int
+	Times       []int
+	Slots       int
+	Conflicts   map[*Course]int
+
+	// SlotPattern gives the slots the course needs on particular days,
+	// in place of Slots. A course with a pattern can only meet on the
+	// days it lists.
+	SlotPattern []DaySlots
+
+	// Cohorts names the groups of students the course serves, e.g.,
+	// freshman-core, for limiting how many of their courses meet at once
+	Cohorts []string
+
+	// Main is the course this is an extra meeting of, for a section
+	// that meets on different days in different rooms, or nil. Meeting
+	// numbers the extra meetings of a section from 2.
+	Main    *Course
+	Meeting int
+
+	// Optional marks a course that a schedule may drop entirely, at a
+	// cost of DropBadness. Extra meetings follow their section.
+	Optional    bool
+	DropBadness int
+
+	// ConflictBadness is indexed by course ID and is NoConflict
+	// for courses that do not conflict with this one.
+	// It holds the same information as Conflicts, but is much
+	// cheaper to consult in the inner loops of search and scoring.
+	ConflictBadness []int
+
+	// ApartBadness is indexed by course ID and is NoConflict for
+	// courses whose instructors are not kept apart from this one's.
+	// It is nil if the input has no apart: lines.
+	ApartBadness []int
+
+	// fixedSection is set if the input gives the section number
+	fixedSection bool
+
+	// line is the line of the text input that gives the course, or zero
+	line int
+}
+
+// NoConflict marks a pair of courses with no conflict between them
+const NoConflict int = -2
+
+// Line is the line of the text input that gives a conflict or
+// anticonflict, or zero
+type Conflict struct {
+	Badness int
+	Courses []*Course
+	Line    int
+}
+
+type AntiConflict struct {
+	Badness int
+	Courses []string
+	Line    int
+}
+
+// An Adjacency asks for sections of the courses that meet at the same
+// time to be in adjacent rooms, e.g., so they can share equipment
+type Adjacency struct {
+	Badness int
+	Courses []string
+}
+
+// A Ceiling limits how many sections of courses in a cohort can meet
+// at the same time. Each section over the Limit adds the Badness.
+type Ceiling struct {
+	Badness int
+	Limit   int
+	Cohort  string
+}
+
+// An Order asks for every section of the Second course to start later
+// on the same day as some section of the First, e.g., a lab that uses
+// that morning's lecture. If Immediately is set, it must start in the
+// slot right after that section ends.
+type Order struct {
+	Badness     int
+	First       string
+	Second      string
+	Immediately bool
+}
+
+// An Apart is a group of instructors who should not teach at the same
+// time as each other, e.g., because they share a teaching assistant
+type Apart struct {
+	Badness     int
+	Instructors []*Instructor
+}
+
+// A Together is a group of instructors who should teach on the same
+// days as each other, e.g., because they share a ride to campus
+type Together struct {
+	Badness     int
+	Instructors []*Instructor
+}
+
+// A Cost is extra badness for using any of the rooms at any of the
+// times, e.g., for a building that is expensive to keep open in the
+// evening. Rooms and Times are positions, and a badness of -1 means
+// the rooms cannot be used at those times at all.
+type Cost struct {
+	Badness int
+	Rooms   []int
+	Times   []int
+}
+
+// Prefix gives the days of a time for scoring when the input does not
+// declare day patterns
+func (t *Time) Prefix() string {
+	// hack alert: merging mwf and mw by only considering 1st two letters of prefix
+	if len(t.Days) > 2 {
+		return t.Days[:2]
+	}
+
+	return t.Days
+}
+
+// split the time into its prefix (either mw or tr) and hour
+// this should only be used for scoring purposes when the input does not
+// declare day patterns
+// returns empty strings if it doesn't find mw or tr or the time is evening
+func (t *Time) Split() (string, string) {
+	prefix := strings.ToLower(t.Prefix())
+	if prefix == "mwf" {
+		prefix = "mw"
+	}
+	if prefix != "mw" && prefix != "tr" {
+		return "", ""
+	}
+	if t.Start < 0 || t.Start > 16*60+30 {
+		return "", ""
+	}
+	return prefix, t.Clock()
+}
+
+// DaysAndHour splits a time name into its meeting days and its start
+// time in 24-hour HHMM form, e.g., "mwf 9:00" gives "mwf" and "0900".
+// If the name has no time of day that can be read, it gives the part
+// before the first digit and the rest as they are. Unlike Prefix, it
+// does not merge different day patterns.
+func (t *Time) DaysAndHour() (string, string) {
+	if t.Start >= 0 {
+		return t.Days, t.Clock()
+	}
+	brk := strings.IndexAny(t.Name, "0123456789")
+	if brk < 0 {
+		return t.Name, ""
+	}
+	return t.Name[:brk], t.Name[brk:]
+}
+
+// InCohort reports whether the course serves the given cohort
+func (c *Course) InCohort(cohort string) bool {
+	for _, elt := range c.Cohorts {
+		if elt == cohort {
+			return true
+		}
+	}
+	return false
+}
+
+// SectionID identifies a section of a course, e.g., CS1400-02, or
+// an extra meeting of a section, e.g., CS1400-02.2
+func (c *Course) SectionID() string {
+	if c.Main != nil {
+		return fmt.Sprintf("%s-%02d.%d", c.Name, c.Section, c.Meeting)
+	}
+	return fmt.Sprintf("%s-%02d", c.Name, c.Section)
+}
+
+// sameSection reports whether two courses are meetings of the same
+// section
+func (c *Course) sameSection(other *Course) bool {
+	if c == other {
+		return false
+	}
+	main, otherMain := c, other
+	if c.Main != nil {
+		main = c.Main
+	}
+	if other.Main != nil {
+		otherMain = other.Main
+	}
+	return main == otherMain
+}
+
+// mainCourse returns the course, or the course it is an extra meeting
+// of
+func (c *Course) mainCourse() *Course {
+	if c.Main != nil {
+		return c.Main
+	}
+	return c
+}
+
+// MeetingsAgree reports whether two meetings of a section can start at
+// the given times: the same time of day on different days
+func (data *InputData) MeetingsAgree(a, b int) bool {
+	timeA, timeB := data.Times[a], data.Times[b]
+	return timeA.Start >= 0 && timeA.Start == timeB.Start && !strings.ContainsAny(strings.ToUpper(timeA.Days), strings.ToUpper(timeB.Days))
+}
+
+// Meetings lists the extra meetings of a course, in input order
+func (c *Course) Meetings() []*Course {
+	var meetings []*Course
+	for _, course := range c.Instructors[0].Courses {
+		if course.Main == c {
+			meetings = append(meetings, course)
+		}
+	}
+	return meetings
+}
+
+// A ParseErrorKind says what sort of problem a ParseError is
+type ParseErrorKind string
+
+const (
+	// a line is missing fields or is not a kind of line at all
+	KindSyntax ParseErrorKind = "syntax"
+
+	// a name is defined twice, or listed twice where it should not be
+	KindDuplicate ParseErrorKind = "duplicate"
+
+	// a name is used for two different kinds of things
+	KindNameClash ParseErrorKind = "name clash"
+
+	// a badness value cannot be read or is out of range
+	KindBadness ParseErrorKind = "badness"
+
+	// a name or tag does not refer to anything, or to more than one thing
+	KindUnresolved ParseErrorKind = "unresolved"
+
+	// a line comes before a line it depends on
+	KindOrder ParseErrorKind = "order"
+
+	// an instructor or course is left with no times or rooms to use
+	KindNoChoices ParseErrorKind = "no choices"
+
+	// a course that is taught is also on the ignore list
+	KindIgnored ParseErrorKind = "ignored"
+
+	// a date cannot be read or is outside the term
+	KindDate ParseErrorKind = "date"
+
+	// a time name has no time of day that can be read for scoring
+	KindTime ParseErrorKind = "time"
+)
+
+// A ParseError is a problem found in the input. Line is the 1-based
+// line number (or zero if the problem is not tied to a line). Field is
+// the offending field as written when it is known, and Index is its
+// 1-based position in the line (or zero if not known).
+type ParseError struct {
+	Filename string
+	Line     int
+	Index    int
+	Field    string
+	Kind     ParseErrorKind
+	Err      error
+}
+
+func (e *ParseError) Error() string {
+	where := fmt.Sprintf("%q", e.Filename)
+	if e.Line > 0 {
+		where += fmt.Sprintf(" line %d", e.Line)
+	}
+	if e.Field != "" {
+		where += fmt.Sprintf(" field %q", e.Field)
+	}
+	return where + ": " + e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError reports a problem with a specific field of an input line.
+// Parse fills in the file name, line number, and field index.
+func fieldError(kind ParseErrorKind, field string, format string, args ...interface{}) error {
+	return &ParseError{Field: field, Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// lineError reports a problem with an input line as a whole
+func lineError(kind ParseErrorKind, format string, args ...interface{}) error {
+	return &ParseError{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// ParseErrors is every problem found in the input, in line order
+type ParseErrors []*ParseError
+
+func (lst ParseErrors) Error() string {
+	var msgs []string
+	for _, e := range lst {
+		msgs = append(msgs, e.Error())
+	}
+	if len(lst) > 1 {
+		msgs = append(msgs, fmt.Sprintf("%d errors found in the input", len(lst)))
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// add records an error found at a line. If err is already
+// a *ParseError, its field and kind are kept.
+func (lst *ParseErrors) add(filename string, lines [][]string, line int, err error) {
+	e, ok := err.(*ParseError)
+	if !ok {
+		e = &ParseError{Kind: KindSyntax, Err: err}
+	}
+	e.Filename = filename
+	e.Line = line
+	if e.Field != "" && line > 0 && line <= len(lines) {
+		for i, field := range inputFields(lines[line-1]) {
+			if field == e.Field {
+				e.Index = i + 1
+				break
+			}
+		}
+	}
+	*lst = append(*lst, e)
+}
+
+// inputFields drops the comment (if any) from a line of input
+func inputFields(line []string) []string {
+	var fields []string
+	for _, elt := range line {
+		comment := false
+		if i := strings.Index(elt, "//"); i >= 0 {
+			elt = elt[:i]
+			comment = true
+		}
+		s := strings.TrimSpace(elt)
+		if s != "" {
+			fields = append(fields, s)
+		}
+		if comment {
+			break
+		}
+	}
+	return fields
+}
+
+// SplitInput breaks the contents of an input file into fields, the way
+// a schedule.txt file is read from disk
+func SplitInput(text string) ([][]string, error) {
+	var lines [][]string
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		lines = append(lines, strings.Fields(scanner.Text()))
+	}
+	return lines, scanner.Err()
+}
+
+// Parse reads the input lines. Rather than stopping at the first
+// problem, it reports every one it finds as a ParseErrors value.
+func Parse(filename string, lines [][]string) (*InputData, error) {
+	data := new(InputData)
+
+	hash := sha256.New()
+	for _, line := range lines {
+		fmt.Fprintf(hash, "%s\n", strings.Join(line, "\t"))
+	}
+	data.InputHash = fmt.Sprintf("sha256:%x", hash.Sum(nil))
+
+	// recently-parsed objects for context-sensitive items
+	var instructor *Instructor
+	var time *Time
+	var lastCourse *Course
+
+	// parsing data that does not make it into the InputData struct
+	instructorNames := make(map[string]bool)
+	rooms := make(map[string]*Room)
+	times := make(map[string]*Time)
+	tagToRooms := make(map[string][]*Room)
+	tagToTimes := make(map[string][]*Time)
+	coInstructors := make(map[*Course][]string)
+	ignore := make(map[string]struct{})
+	ignoreLines := make(map[string]int)
+	courseLines := make(map[*Course]int)
+	var errs ParseErrors
+
+	// courses are skipped after an instructor line with errors
+	// instead of reporting each one as well
+	skipCourses := false
+
+	// likewise for meetings after a course line with errors
+	skipMeetings := false
+
+	for linenumber, line := range lines {
+		fields := inputFields(line)
+
+		// ignore blank/comment lines
+		if len(fields) == 0 {
+			continue
+		}
+
+		// process a line of input
+		var err error
+		switch fields[0] {
+		case "room:":
+			_, err = data.ParseRoom(fields, rooms, times, tagToRooms, tagToTimes)
+
+		case "turnover:":
+			err = data.ParseTurnover(fields, rooms, tagToRooms)
+
+		case "time:":
+			time, err = data.ParseTime(fields, time, rooms, times, tagToRooms, tagToTimes)
+			if err == nil && time != nil {
+				if _, _, _, clockErr := parseTimeName(time.Name); clockErr != nil {
+					data.Warnings.add(filename, lines, linenumber+1,
+						fieldError(KindTime, time.Name, "time %q will not count as morning, afternoon, or evening: %v", time.Name, clockErr))
+				}
+			}
+
+		case "instructor:":
+			instructor, err = data.ParseInstructor(fields, times, tagToTimes)
+			skipCourses = err != nil
+			lastCourse = nil
+			if err == nil {
+				if instructorNames[instructor.Name] {
+					err = fieldError(KindDuplicate, instructor.Name, "cannot have two instructors with the same name")
+				}
+				instructorNames[instructor.Name] = true
+			}
+
+		case "course:":
+			if skipCourses {
+				continue
+			}
+			var course *Course
+			if course, err = data.ParseCourse(fields, instructor, rooms, times, tagToRooms, tagToTimes, coInstructors); err == nil {
+				courseLines[course] = linenumber + 1
+				course.line = linenumber + 1
+			}
+			lastCourse = course
+			skipMeetings = err != nil
+
+		case "meeting:":
+			if skipCourses || skipMeetings {
+				continue
+			}
+			var course *Course
+			if course, err = data.ParseMeeting(fields, lastCourse, rooms, times, tagToRooms, tagToTimes); err == nil {
+				courseLines[course] = linenumber + 1
+				course.line = linenumber + 1
+			}
+
+		case "conflict:":
+			expansions := len(data.Expansions)
+			err = data.ParseConflict(fields, ignore, coInstructors)
+			data.numberExpansions(expansions, linenumber+1)
+			if err == nil {
+				data.Conflicts[len(data.Conflicts)-1].Line = linenumber + 1
+			}
+
+		case "anticonflict:":
+			expansions := len(data.Expansions)
+			err = data.ParseAntiConflict(fields, ignore, coInstructors)
+			data.numberExpansions(expansions, linenumber+1)
+			if err == nil {
+				data.AntiConflicts[len(data.AntiConflicts)-1].Line = linenumber + 1
+			}
+
+		case "adjacent:":
+			err = data.ParseAdjacent(fields, ignore)
+
+		case "ceiling:":
+			err = data.ParseCeiling(fields)
+
+		case "concurrent:":
+			err = data.ParseConcurrent(fields, ignore)
+
+		case "order:":
+			err = data.ParseOrder(fields, ignore)
+
+		case "apart:":
+			err = data.ParseApart(fields)
+
+		case "together:":
+			err = data.ParseTogether(fields)
+
+		case "ignore:":
+			err = data.ParseIgnore(fields, ignore)
+			for _, rawTag := range fields[1:] {
+				if isPattern(rawTag) {
+					ignoreLines[rawTag] = linenumber + 1
+				} else if !patternUsed(rawTag, lines) {
+					data.unusedIgnores = append(data.unusedIgnores, ignoreEntry{Name: rawTag, Line: linenumber + 1})
+				}
+			}
+
+		case "days:":
+			err = data.ParseDays(fields)
+
+		case "band:":
+			err = data.ParseBand(fields)
+
+		case "term:":
+			err = data.ParseTerm(fields)
+
+		case "closed:":
+			err = data.ParseClosed(fields)
+
+		case "cost:":
+			err = data.ParseCost(fields, rooms, times, tagToRooms, tagToTimes)
+
+		default:
+			err = fieldError(KindSyntax, fields[0], "unknown line")
+		}
+		if err != nil {
+			errs.add(filename, lines, linenumber+1, err)
+		} else if warning := hundredWarning(fields); warning != nil {
+			data.Warnings.add(filename, lines, linenumber+1, warning)
+		}
+	}
+
+	// make sure each pattern on an ignore list matches some course
+	// named in the input
+	var patterns []string
+	for pattern := range ignoreLines {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if !patternUsed(pattern, lines) {
+			errs.add(filename, lines, ignoreLines[pattern], fieldError(KindUnresolved, pattern, "pattern %q matches no course named in the input", pattern))
+		}
+	}
+
+	// make sure no ignored classes are actually being scheduled
+	for _, instructor := range data.Instructors {
+		for _, course := range instructor.Courses {
+			if isIgnored(ignore, course.Name) {
+				errs.add(filename, lines, courseLines[course], fieldError(KindIgnored, course.Name,
+					"instructor %q assigned to teach course %q, but that course is on the ignore list",
+					instructor.Name, course.Name))
+			}
+		}
+	}
+
+	// expand coinstructors in input order
+	var coTaught []*Course
+	for _, instructor := range data.Instructors {
+		for _, course := range instructor.Courses {
+			if _, present := coInstructors[course]; present {
+				coTaught = append(coTaught, course)
+			}
+		}
+	}
+	for _, course := range coTaught {
+	NEXTCOINSTRUCTOR:
+		for _, instructorName := range coInstructors[course] {
+			// watch out for dups
+			for _, elt := range course.Instructors {
+				if elt.Name == instructorName {
+					errs.add(filename, lines, courseLines[course], fieldError(KindDuplicate, "coteach:"+instructorName,
+						"instructor %q assigned twice (using coteach:) to the same course %q",
+						instructorName, course.Name))
+					continue NEXTCOINSTRUCTOR
+				}
+			}
+
+			// find the instructor
+			var instructor *Instructor
+			for _, elt := range data.Instructors {
+				if elt.Name == instructorName {
+					instructor = elt
+					break
+				}
+			}
+			if instructor == nil {
+				errs.add(filename, lines, courseLines[course], fieldError(KindUnresolved, "coteach:"+instructorName,
+					"instructor %q not found (listed as a coteach: for %q)",
+					instructorName, course.Name))
+				continue
+			}
+
+			// link the instructor and the course both ways
+			course.Instructors = append(course.Instructors, instructor)
+			instructor.Courses = append(instructor.Courses, course)
+		}
+	}
+
+	// extra meetings are taught by all of the section's instructors
+	for _, instructor := range data.Instructors {
+		for _, course := range instructor.Courses {
+			if course.Main == nil || course.Instructors[0] != instructor {
+				continue
+			}
+			for _, other := range course.Main.Instructors[1:] {
+				course.Instructors = append(course.Instructors, other)
+				other.Courses = append(other.Courses, course)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(a, b int) bool {
+			return errs[a].Line < errs[b].Line
+		})
+		return nil, errs
+	}
+
+	data.finish()
+	return data, nil
+}
+
+// finish fills in the fields that are derived from the rest of the
+// input: course IDs and section numbers, the conflict arrays, the
+// minimum number of rooms for each instructor, and the day weights
+func (data *InputData) finish() {
+	data.timeDay, data.timeDistribution = data.timeDays()
+	data.dayWeight = data.dayWeights()
+	data.roomTimeCost = data.costMatrix()
+	data.timeBand = data.timeBands()
+
+	// number the courses densely, using the first-listed instructor
+	// for co-taught courses so each course appears only once
+	for _, instructor := range data.Instructors {
+		for _, course := range instructor.Courses {
+			if course.Instructors[0] == instructor {
+				course.ID = len(data.Courses)
+				data.Courses = append(data.Courses, course)
+			}
+		}
+	}
+	data.numberSections()
+	data.concurrent = data.concurrentBadness()
+
+	// build the conflict arrays
+	for _, course := range data.Courses {
+		course.ConflictBadness = make([]int, len(data.Courses))
+		for i := range course.ConflictBadness {
+			course.ConflictBadness[i] = NoConflict
+		}
+		for other, badness := range course.Conflicts {
+			course.ConflictBadness[other.ID] = badness
+		}
+	}
+
+	// build the apart arrays, which are only needed if there are
+	// instructors to keep apart
+	if len(data.Aparts) > 0 {
+		for _, course := range data.Courses {
+			course.ApartBadness = make([]int, len(data.Courses))
+			for _, other := range data.Courses {
+				course.ApartBadness[other.ID] = data.apartBadness(course, other)
+			}
+		}
+	}
+
+	for _, instructor := range data.Instructors {
+		instructor.FindMinRooms()
+	}
+}
+
+func (data *InputData) ParseRoom(fields []string, rooms map[string]*Room, times map[string]*Time, tagToRooms map[string][]*Room, tagToTimes map[string][]*Time) (*Room, error) {
+	if len(fields) < 2 {
+		return nil, lineError(KindSyntax, "expected %q", "room: name tag tag tag ... adjacent:room ...")
+	}
+	room := &Room{
+		Name:     fields[1],
+		Position: len(rooms),
+	}
+	data.Rooms = append(data.Rooms, room)
+
+	if rooms[room.Name] != nil {
+		return nil, fieldError(KindDuplicate, room.Name, "found duplicate room")
+	}
+	if times[room.Name] != nil {
+		return nil, fieldError(KindNameClash, room.Name, "found room with name matching time name")
+	}
+	if tagToTimes[room.Name] != nil {
+		return nil, fieldError(KindNameClash, room.Name, "found room with name matching time tag")
+	}
+	if tagToRooms[room.Name] != nil {
+		return nil, fieldError(KindNameClash, room.Name, "found room with name matching room tag")
+	}
+	rooms[room.Name] = room
+	for _, tag := range fields[2:] {
+		// adjacent rooms must already be defined
+		if strings.HasPrefix(tag, "adjacent:") {
+			other := rooms[tag[len("adjacent:"):]]
+			if other == nil {
+				return nil, fieldError(KindUnresolved, tag, "room %q not found (adjacent rooms must be listed first)", tag[len("adjacent:"):])
+			}
+			if other == room {
+				return nil, fieldError(KindDuplicate, tag, "a room cannot be adjacent to itself")
+			}
+			room.addAdjacent(other)
+			continue
+		}
+
+		if rooms[tag] != nil {
+			return nil, fieldError(KindNameClash, tag, "found room tag with name matching room name")
+		}
+		if times[tag] != nil {
+			return nil, fieldError(KindNameClash, tag, "found room tag with name matching time name")
+		}
+		if tagToTimes[tag] != nil {
+			return nil, fieldError(KindNameClash, tag, "found room tag with name matching time tag")
+		}
+		room.Tags = append(room.Tags, tag)
+		tagToRooms[tag] = append(tagToRooms[tag], room)
+	}
+
+	return room, nil
+}
+
+func (data *InputData) ParseTurnover(fields []string, rooms map[string]*Room, tagToRooms map[string][]*Room) error {
+	if len(fields) < 2 {
+		return lineError(KindSyntax, "expected %q", "turnover: room-or-tag ...")
+	}
+	for _, tag := range fields[1:] {
+		switch {
+		case rooms[tag] != nil:
+			rooms[tag].Turnover = true
+		case tagToRooms[tag] != nil:
+			for _, room := range tagToRooms[tag] {
+				room.Turnover = true
+			}
+		default:
+			return fieldError(KindUnresolved, tag, "unresolved room or room tag %q", tag)
+		}
+	}
+	return nil
+}
+
+func (data *InputData) ParseTime(fields []string, prev *Time, rooms map[string]*Room, times map[string]*Time, tagToRooms map[string][]*Room, tagToTimes map[string][]*Time) (*Time, error) {
+	if len(fields) == 1 {
+		return nil, nil
+	}
+	time := newTime(fields[1], len(times))
+	data.Times = append(data.Times, time)
+
+	if times[time.Name] != nil {
+		return nil, fieldError(KindDuplicate, time.Name, "found duplicate time")
+	}
+	if rooms[time.Name] != nil {
+		return nil, fieldError(KindNameClash, time.Name, "found time with name matching room name")
+	}
+	if tagToTimes[time.Name] != nil {
+		return nil, fieldError(KindNameClash, time.Name, "found time with name matching time tag")
+	}
+	if tagToRooms[time.Name] != nil {
+		return nil, fieldError(KindNameClash, time.Name, "found time with name matching room tag")
+	}
+	times[time.Name] = time
+	if prev != nil {
+		prev.Next = time
+	}
+	for _, tag := range fields[2:] {
+		if rooms[tag] != nil {
+			return nil, fieldError(KindNameClash, tag, "found time tag with name matching room name")
+		}
+		if times[tag] != nil {
+			return nil, fieldError(KindNameClash, tag, "found time tag with name matching time name")
+		}
+		if tagToRooms[tag] != nil {
+			return nil, fieldError(KindNameClash, tag, "found time tag with name matching room tag")
+		}
+		time.Tags = append(time.Tags, tag)
+		tagToTimes[tag] = append(tagToTimes[tag], time)
+	}
+
+	return time, nil
+}
+
+func (data *InputData) ParseInstructor(fields []string, times map[string]*Time, tagToTimes map[string][]*Time) (*Instructor, error) {
+	if len(fields) < 3 {
+		return nil, lineError(KindSyntax, "expected %q", "instructor: name time time ... [oneday|twodays]")
+	}
+	instructor := &Instructor{
+		Name:  fields[1],
+		Times: make([]int, len(times)),
+	}
+	for i := 0; i < len(instructor.Times); i++ {
+		// all time slots default to impossible
+		instructor.Times[i] = -1
+	}
+	data.Instructors = append(data.Instructors, instructor)
+
+	// parse available times
+	hardTimes := make(map[int]bool)
+	for _, rawTag := range fields[2:] {
+		// handle days preferences
+		if rawTag == "oneday" {
+			instructor.Days = 1
+			continue
+		}
+		if rawTag == "twodays" {
+			instructor.Days = 2
+			continue
+		}
+
+		tag, badness, err := parseBadness(rawTag)
+		if err != nil {
+			return nil, fieldError(KindBadness, rawTag, "expected a time of the form %q: %v", "time:badness", err)
+		}
+
+		hits := 0
+		if time, present := times[tag]; present {
+			mergeBadness(instructor.Times, time.Position, badness, hardTimes)
+			hits++
+		}
+		if times, present := tagToTimes[tag]; present {
+			for _, time := range times {
+				mergeBadness(instructor.Times, time.Position, badness, hardTimes)
+			}
+			hits++
+		}
+		if hits == 0 {
+			return nil, fieldError(KindUnresolved, rawTag, "unresolved tag %q", tag)
+		} else if hits > 1 {
+			return nil, fieldError(KindUnresolved, rawTag, "tag %q has multiple resolutions", tag)
+		}
+	}
+
+	valid := 0
+	for _, elt := range instructor.Times {
+		if elt >= 0 {
+			valid++
+		}
+	}
+	if valid == 0 {
+		return nil, fieldError(KindNoChoices, instructor.Name, "no valid times found for instructor")
+	}
+
+	return instructor, nil
+}
+
+func (data *InputData) ParseCourse(fields []string, instructor *Instructor, rooms map[string]*Room, times map[string]*Time, tagToRooms map[string][]*Room, tagToTimes map[string][]*Time, coInstructors map[*Course][]string) (*Course, error) {
+	if len(fields) < 2 {
+		return nil, lineError(KindSyntax, "expected %q", "course: name tag tag tag ...")
+	}
+	if instructor == nil {
+		return nil, lineError(KindOrder, "course: must come after instructor")
+	}
+	course := &Course{
+		Name:        fields[1],
+		Instructors: []*Instructor{instructor},
+		Rooms:       make([]int, len(rooms)),
+		Times:       make([]int, len(times)),
+		Conflicts:   make(map[*Course]int),
+	}
+	for i := 0; i < len(course.Rooms); i++ {
+		// all rooms default to impossible
+		course.Rooms[i] = -1
+	}
+	for i := 0; i < len(course.Times); i++ {
+		// all times default to impossible
+		course.Times[i] = -1
+	}
+	instructor.Courses = append(instructor.Courses, course)
+
+	hardRooms, hardTimes := make(map[int]bool), make(map[int]bool)
+	for _, rawTag := range fields[2:] {
+		// handle multiple slots
+		if rawTag == "twoslots" {
+			course.Slots = 2
+			continue
+		}
+		if rawTag == "threeslots" {
+			course.Slots = 3
+			continue
+		}
+		if rawTag == "studio" {
+			// 3 for MWF, 2 for MW or TR
+			course.SlotPattern = studioSlots
+			continue
+		}
+		if strings.HasPrefix(rawTag, "slots:") {
+			pattern, err := parseSlotPattern(rawTag[len("slots:"):])
+			if err != nil {
+				return nil, fieldError(KindSyntax, rawTag, "%v", err)
+			}
+			course.SlotPattern = pattern
+			continue
+		}
+		if strings.HasPrefix(rawTag, "coteach:") {
+			coInstructors[course] = append(coInstructors[course], rawTag[len("coteach:"):])
+			continue
+		}
+		if strings.HasPrefix(rawTag, "section:") {
+			n, err := parseSectionNumber(rawTag[len("section:"):])
+			if err != nil {
+				return nil, fieldError(KindSyntax, rawTag, "%v", err)
+			}
+			course.Section = n
+			course.fixedSection = true
+			if err := data.checkSection(course); err != nil {
+				return nil, fieldError(KindDuplicate, rawTag, "%v", err)
+			}
+			continue
+		}
+		if strings.HasPrefix(rawTag, "optional:") {
+			_, badness, err := parseBadness(rawTag)
+			if err != nil {
+				return nil, fieldError(KindBadness, rawTag, "%v", err)
+			}
+			if badness < 0 {
+				return nil, fieldError(KindBadness, rawTag, "dropping an optional course cannot be hard")
+			}
+			course.Optional = true
+			course.DropBadness = badness
+			continue
+		}
+		if strings.HasPrefix(rawTag, "cohort:") {
+			cohort := rawTag[len("cohort:"):]
+			if cohort == "" {
+				return nil, fieldError(KindSyntax, rawTag, "missing cohort name")
+			}
+			if course.InCohort(cohort) {
+				return nil, fieldError(KindDuplicate, rawTag, "cohort %q repeated", cohort)
+			}
+			course.Cohorts = append(course.Cohorts, cohort)
+			continue
+		}
+
+		// handle tags
+		tag, badness, err := parseBadness(rawTag)
+		if err != nil {
+			return nil, fieldError(KindBadness, rawTag, "%v", err)
+		}
+
+		hits := 0
+		if room, present := rooms[tag]; present {
+			mergeBadness(course.Rooms, room.Position, badness, hardRooms)
+			hits++
+		}
+		if time, present := times[tag]; present {
+			mergeBadness(course.Times, time.Position, badness, hardTimes)
+			hits++
+		}
+		if rooms, present := tagToRooms[tag]; present {
+			for _, room := range rooms {
+				mergeBadness(course.Rooms, room.Position, badness, hardRooms)
+			}
+			hits++
+		}
+		if times, present := tagToTimes[tag]; present {
+			for _, time := range times {
+				mergeBadness(course.Times, time.Position, badness, hardTimes)
+			}
+			hits++
+		}
+		if hits == 0 {
+			return nil, fieldError(KindUnresolved, rawTag, "unresolved tag %q", tag)
+		} else if hits > 1 {
+			return nil, fieldError(KindUnresolved, rawTag, "tag %q has multiple resolutions", tag)
+		}
+	}
+
+	valid := 0
+	for _, badness := range course.Rooms {
+		if badness >= 0 {
+			valid++
+		}
+	}
+	if valid == 0 {
+		return nil, fieldError(KindNoChoices, course.Name, "no rooms found for course %s", course.Name)
+	}
+
+	// if the course does not specify any times, then we leave its list as nil
+	// in which case the instructor times are all that matter
+	hasTimes := false
+	for _, badness := range course.Times {
+		if badness >= 0 {
+			hasTimes = true
+			break
+		}
+	}
+	switch {
+	case !hasTimes && len(hardTimes) > 0:
+		// only times ruled out, so the rest are all fine
+		for i := range course.Times {
+			if !hardTimes[i] {
+				course.Times[i] = 0
+			}
+		}
+	case !hasTimes:
+		course.Times = nil
+	}
+
+	return course, nil
+}
+
+// ParseMeeting reads an extra meeting of the most recent course, which
+// is placed separately with its own rooms and times but must start at
+// the same time of day on different days
+func (data *InputData) ParseMeeting(fields []string, main *Course, rooms map[string]*Room, times map[string]*Time, tagToRooms map[string][]*Room, tagToTimes map[string][]*Time) (*Course, error) {
+	if main == nil {
+		return nil, lineError(KindOrder, "meeting: must come after course")
+	}
+	if main.Main != nil {
+		main = main.Main
+	}
+	coInstructors := make(map[*Course][]string)
+	meeting := len(main.Meetings()) + 2
+	course, err := data.ParseCourse(append([]string{"course:", main.Name}, fields[1:]...), main.Instructors[0], rooms, times, tagToRooms, tagToTimes, coInstructors)
+	if err != nil {
+		return nil, err
+	}
+	if len(coInstructors) > 0 {
+		return nil, lineError(KindSyntax, "a meeting: line cannot have coteach: tags; it uses the course's instructors")
+	}
+	if course.Optional {
+		return nil, lineError(KindSyntax, "a meeting: line cannot have an optional: tag; it follows the course")
+	}
+	if course.fixedSection {
+		return nil, lineError(KindSyntax, "a meeting: line cannot have a section: tag; it follows the course")
+	}
+	course.Main = main
+	course.Meeting = meeting
+	course.Optional = main.Optional
+	if len(course.Cohorts) == 0 {
+		course.Cohorts = main.Cohorts
+	}
+	return course, nil
+}
+
+func (data *InputData) ParseConflict(fields []string, ignore map[string]struct{}, coInstructors map[*Course][]string) error {
+	// a single cohort, instructor, or pattern can stand for several
+	// courses
+	if len(fields) < 3 || len(fields) == 3 && !isPattern(fields[2]) && data.isCourseName(fields[2]) {
+		return lineError(KindSyntax, "expected %q", "conflict: badness course1 course2 ...")
+	}
+
+	badness, err := parseBadnessValue(fields[1])
+	if err != nil {
+		return fieldError(KindBadness, fields[1], "badness of a conflict %v", err)
+	}
+
+	// cohorts and instructors expand to their courses, which may
+	// overlap with each other and with courses named directly
+	var courses []*Course
+	repeat := make(map[string]bool)
+	for _, tag := range fields[2:] {
+		if isIgnored(ignore, tag) {
+			continue
+		}
+		if repeat[tag] {
+			return fieldError(KindDuplicate, tag, "course repeated")
+		}
+		repeat[tag] = true
+
+		expanded, err := data.expandCourses("conflict", tag, coInstructors)
+		if err != nil {
+			return err
+		}
+		for _, course := range expanded {
+			if !containsCourse(courses, course) {
+				courses = append(courses, course)
+			}
+		}
+	}
+
+	for _, course := range courses {
+		for _, elt := range courses {
+			if course == elt {
+				continue
+			}
+
+			if existing, present := course.Conflicts[elt]; !present || badness > existing {
+				course.Conflicts[elt] = badness
+			}
+		}
+	}
+
+	data.Conflicts = append(data.Conflicts, Conflict{Badness: badness, Courses: courses})
+
+	return nil
+}
+
+func (data *InputData) ParseAntiConflict(fields []string, ignore map[string]struct{}, coInstructors map[*Course][]string) error {
+	// a single cohort, instructor, or pattern can stand for several
+	// courses
+	if len(fields) < 3 || len(fields) == 3 && !isPattern(fields[2]) && data.isCourseName(fields[2]) {
+		return lineError(KindSyntax, "expected %q", "anticonflict: badness course1 course2 ...")
+	}
+
+	badness, err := parseBadnessValue(fields[1])
+	if err != nil {
+		return fieldError(KindBadness, fields[1], "badness of an anticonflict %v", err)
+	}
+
+	var courses []string
+	repeat := make(map[string]bool)
+	for _, tag := range fields[2:] {
+		if isIgnored(ignore, tag) {
+			continue
+		}
+		if repeat[tag] {
+			return fieldError(KindDuplicate, tag, "course repeated")
+		}
+		repeat[tag] = true
+
+		expanded, err := data.expandCourses("anticonflict", tag, coInstructors)
+		if err != nil {
+			return err
+		}
+		for _, course := range expanded {
+			if !containsString(courses, course.Name) {
+				courses = append(courses, course.Name)
+			}
+		}
+	}
+
+	data.AntiConflicts = append(data.AntiConflicts, AntiConflict{Badness: badness, Courses: courses})
+
+	return nil
+}
+
+func (data *InputData) ParseAdjacent(fields []string, ignore map[string]struct{}) error {
+	if len(fields) < 4 {
+		return lineError(KindSyntax, "expected %q", "adjacent: badness course1 course2 ...")
+	}
+
+	badness, err := parseBadnessValue(fields[1])
+	if err != nil {
+		return fieldError(KindBadness, fields[1], "badness of an adjacency %v", err)
+	}
+
+	var courses []string
+	repeat := make(map[string]bool)
+NEXTFIELD:
+	for _, tag := range fields[2:] {
+		if isIgnored(ignore, tag) {
+			continue
+		}
+
+		for _, instructor := range data.Instructors {
+			for _, course := range instructor.Courses {
+				if course.Name == tag {
+					if repeat[tag] {
+						return fieldError(KindDuplicate, tag, "course repeated")
+					}
+					repeat[tag] = true
+					courses = append(courses, tag)
+					continue NEXTFIELD
+				}
+			}
+		}
+		return fieldError(KindUnresolved, tag, "course not found in adjacent: line")
+	}
+
+	data.Adjacencies = append(data.Adjacencies, Adjacency{Badness: badness, Courses: courses})
+
+	return nil
+}
+
+func (data *InputData) ParseCeiling(fields []string) error {
+	if len(fields) != 4 {
+		return lineError(KindSyntax, "expected %q", "ceiling: badness limit cohort")
+	}
+
+	badness, err := parseBadnessValue(fields[1])
+	if err != nil {
+		return fieldError(KindBadness, fields[1], "badness of a ceiling %v", err)
+	}
+
+	limit, err := strconv.Atoi(fields[2])
+	if err != nil || limit < 1 {
+		return fieldError(KindSyntax, fields[2], "limit must be a positive number")
+	}
+
+	cohort := fields[3]
+	found := false
+	for _, instructor := range data.Instructors {
+		for _, course := range instructor.Courses {
+			if course.InCohort(cohort) {
+				found = true
+			}
+		}
+	}
+	if !found {
+		return fieldError(KindUnresolved, cohort, "no course is in cohort %q", cohort)
+	}
+
+	data.Ceilings = append(data.Ceilings, Ceiling{Badness: badness, Limit: limit, Cohort: cohort})
+	return nil
+}
+
+func (data *InputData) ParseOrder(fields []string, ignore map[string]struct{}) error {
+	if len(fields) < 4 || len(fields) > 5 {
+		return lineError(KindSyntax, "expected %q", "order: badness first-course second-course [immediately]")
+	}
+
+	badness, err := parseBadnessValue(fields[1])
+	if err != nil {
+		return fieldError(KindBadness, fields[1], "badness of an order %v", err)
+	}
+
+	order := Order{Badness: badness, First: fields[2], Second: fields[3]}
+	if len(fields) == 5 {
+		if fields[4] != "immediately" {
+			return fieldError(KindSyntax, fields[4], "expected %q", "immediately")
+		}
+		order.Immediately = true
+	}
+	if order.First == order.Second {
+		return fieldError(KindDuplicate, order.Second, "course repeated")
+	}
+
+	skip := false
+	for _, tag := range []string{order.First, order.Second} {
+		if isIgnored(ignore, tag) {
+			skip = true
+			continue
+		}
+		found := false
+		for _, instructor := range data.Instructors {
+			for _, course := range instructor.Courses {
+				if course.Name == tag {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return fieldError(KindUnresolved, tag, "course not found in order: line")
+		}
+	}
+
+	// an order involving an ignored course has nothing to check
+	if !skip {
+		data.Orders = append(data.Orders, order)
+	}
+	return nil
+}
+
+func (data *InputData) ParseCost(fields []string, rooms map[string]*Room, times map[string]*Time, tagToRooms map[string][]*Room, tagToTimes map[string][]*Time) error {
+	if len(fields) < 4 {
+		return lineError(KindSyntax, "expected %q", "cost: badness room-or-tag ... time-or-tag ...")
+	}
+
+	badness, err := parseBadnessValue(fields[1])
+	if err != nil {
+		return fieldError(KindBadness, fields[1], "badness of a cost %v", err)
+	}
+
+	cost := Cost{Badness: badness}
+	roomSeen := make(map[int]bool)
+	timeSeen := make(map[int]bool)
+	addRoom := func(room *Room) {
+		if !roomSeen[room.Position] {
+			roomSeen[room.Position] = true
+			cost.Rooms = append(cost.Rooms, room.Position)
+		}
+	}
+	addTime := func(time *Time) {
+		if !timeSeen[time.Position] {
+			timeSeen[time.Position] = true
+			cost.Times = append(cost.Times, time.Position)
+		}
+	}
+	for _, tag := range fields[2:] {
+		switch {
+		case rooms[tag] != nil:
+			addRoom(rooms[tag])
+		case times[tag] != nil:
+			addTime(times[tag])
+		case tagToRooms[tag] != nil:
+			for _, room := range tagToRooms[tag] {
+				addRoom(room)
+			}
+		case tagToTimes[tag] != nil:
+			for _, time := range tagToTimes[tag] {
+				addTime(time)
+			}
+		default:
+			return fieldError(KindUnresolved, tag, "unresolved tag %q", tag)
+		}
+	}
+	if len(cost.Rooms) == 0 || len(cost.Times) == 0 {
+		return lineError(KindSyntax, "a cost: line needs at least one room and one time")
+	}
+	sort.Ints(cost.Rooms)
+	sort.Ints(cost.Times)
+
+	data.Costs = append(data.Costs, cost)
+	return nil
+}
+
+// costMatrix combines the costs into a room/time grid, using the worst
+// badness where costs overlap. It returns nil if there are no costs.
+func (data *InputData) costMatrix() [][]int {
+	if len(data.Costs) == 0 {
+		return nil
+	}
+	matrix := make([][]int, len(data.Rooms))
+	for i := range matrix {
+		matrix[i] = make([]int, len(data.Times))
+	}
+	for _, cost := range data.Costs {
+		for _, room := range cost.Rooms {
+			for _, time := range cost.Times {
+				if existing := matrix[room][time]; existing >= 0 && (cost.Badness < 0 || cost.Badness > existing) {
+					matrix[room][time] = cost.Badness
+				}
+			}
+		}
+	}
+	return matrix
+}
+
+func (data *InputData) ParseApart(fields []string) error {
+	badness, instructors, err := data.parseInstructorGroup(fields, "apart:")
+	if err != nil {
+		return err
+	}
+	data.Aparts = append(data.Aparts, Apart{Badness: badness, Instructors: instructors})
+	return nil
+}
+
+func (data *InputData) ParseTogether(fields []string) error {
+	badness, instructors, err := data.parseInstructorGroup(fields, "together:")
+	if err != nil {
+		return err
+	}
+	data.Togethers = append(data.Togethers, Together{Badness: badness, Instructors: instructors})
+	return nil
+}
+
+// parseInstructorGroup reads the badness and instructors from an
+// apart: or together: line
+func (data *InputData) parseInstructorGroup(fields []string, kind string) (int, []*Instructor, error) {
+	if len(fields) < 4 {
+		return 0, nil, lineError(KindSyntax, "expected %q", kind+" badness instructor1 instructor2 ...")
+	}
+
+	badness, err := parseBadnessValue(fields[1])
+	if err != nil {
+		return 0, nil, fieldError(KindBadness, fields[1], "badness on a %s line %v", kind, err)
+	}
+
+	var instructors []*Instructor
+	repeat := make(map[*Instructor]bool)
+NEXTFIELD:
+	for _, name := range fields[2:] {
+		for _, instructor := range data.Instructors {
+			if instructor.Name == name {
+				if repeat[instructor] {
+					return 0, nil, fieldError(KindDuplicate, name, "instructor repeated")
+				}
+				repeat[instructor] = true
+				instructors = append(instructors, instructor)
+				continue NEXTFIELD
+			}
+		}
+		return 0, nil, fieldError(KindUnresolved, name, "instructor not found in %s line", kind)
+	}
+	return badness, instructors, nil
+}
+
+// apartInstructors finds the instructors of two courses who should be
+// kept apart, returning them with the worst badness of the rules that
+// apply, or NoConflict if there are none. Instructors the courses share
+// are not considered, as they are double booked anyway.
+func (data *InputData) apartInstructors(a, b *Course) (*Instructor, *Instructor, int) {
+	var worstA, worstB *Instructor
+	worst := NoConflict
+	for _, apart := range data.Aparts {
+		members := make(map[*Instructor]bool)
+		for _, instructor := range apart.Instructors {
+			members[instructor] = true
+		}
+		for _, instructorA := range a.Instructors {
+			for _, instructorB := range b.Instructors {
+				if instructorA == instructorB || !members[instructorA] || !members[instructorB] {
+					continue
+				}
+
+				// -1 (impossible) beats everything
+				if worst == NoConflict || apart.Badness < 0 || (worst >= 0 && apart.Badness > worst) {
+					worstA, worstB, worst = instructorA, instructorB, apart.Badness
+				}
+			}
+		}
+	}
+	return worstA, worstB, worst
+}
+
+func (data *InputData) apartBadness(a, b *Course) int {
+	_, _, badness := data.apartInstructors(a, b)
+	return badness
+}
+
+func (data *InputData) ParseIgnore(fields []string, ignore map[string]struct{}) error {
+	if len(fields) < 2 {
+		return lineError(KindSyntax, "expected %q", "ignore: tag ...")
+	}
+
+	for _, rawTag := range fields[1:] {
+		if _, err := path.Match(rawTag, ""); err != nil {
+			return fieldError(KindSyntax, rawTag, "bad pattern %q", rawTag)
+		}
+		ignore[rawTag] = struct{}{}
+	}
+
+	return nil
+}
+
+// parseBadnessValue reads a badness: "hard" for something that must
+// not happen (or must happen), which gives -1, or a number from 0 to
+// MaxBadness. -1 is still read as hard, as older inputs use it.
+func parseBadnessValue(s string) (int, error) {
+	if s == "hard" || s == "-1" {
+		return -1, nil
+	}
+	badness, err := strconv.Atoi(s)
+	if err != nil || badness < 0 || badness > MaxBadness {
+		return 0, fmt.Errorf("must be %q or a number from 0 to %d", "hard", MaxBadness)
+	}
+	return badness, nil
+}
+
+// hundredWarning flags a badness of 100 on a line, which once meant
+// hard but is now an ordinary soft badness
+func hundredWarning(fields []string) error {
+	var field string
+	switch fields[0] {
+	case "conflict:", "anticonflict:", "adjacent:", "ceiling:", "concurrent:", "order:", "apart:", "together:", "cost:":
+		field = fields[1]
+	case "instructor:", "course:", "meeting:":
+		for _, elt := range fields[1:] {
+			if strings.HasSuffix(elt, ":100") {
+				field = elt
+			}
+		}
+	}
+	if field != "100" && !strings.HasSuffix(field, ":100") {
+		return nil
+	}
+	return fieldError(KindBadness, field, "a badness of 100 is no longer hard; use %q if this must not happen", "hard")
+}
+
+// parseBadness splits a tag of the form name or name:badness
+func parseBadness(tag string) (string, int, error) {
+	parts := strings.Split(tag, ":")
+	switch len(parts) {
+	case 1:
+		return parts[0], 0, nil
+	case 2:
+		badness, err := parseBadnessValue(parts[1])
+		if err != nil {
+			return "", 0, fmt.Errorf("badness in %q %v", tag, err)
+		}
+		return parts[0], badness, nil
+	default:
+		return "", 0, fmt.Errorf("error parsing badness value in %q", tag)
+	}
+}
+
+// mergeBadness records the badness of a room or time listed on a line,
+// keeping the worst when it is listed more than once (e.g., by name and
+// by tag). A hard listing rules it out no matter what else is listed,
+// and hard tracks the positions ruled out so far.
+func mergeBadness(list []int, position, badness int, hard map[int]bool) {
+	switch {
+	case badness < 0:
+		hard[position] = true
+		list[position] = -1
+	case hard[position]:
+	case list[position] < 0 || badness > list[position]:
+		list[position] = badness
+	}
+}
+
+// find the minimum set of rooms necessary for an instructor
+// to cover all assigned courses.
+// note: this is the hitting set problem, which is np-complete.
+// our n is the number of courses a single instructor teaches, so
+// we just brute force it
+func (instructor *Instructor) FindMinRooms() {
+	// get a complete list of rooms the instructor can use
+	allPossibleRooms := make(map[int]struct{})
+	for _, course := range instructor.Courses {
+		for position, badness := range course.Rooms {
+			if badness >= 0 {
+				allPossibleRooms[position] = struct{}{}
+			}
+		}
+	}
+	var roomPositions []int
+	for position := range allPossibleRooms {
+		roomPositions = append(roomPositions, position)
+	}
+
+	// note: if the loop ends without finding a solution with
+	// fewer than the max number of rooms, it will leave the
+	// result at the max number without bothering to prove it
+minRoomsLoop:
+	for instructor.MinRooms = 1; instructor.MinRooms < len(instructor.Courses); instructor.MinRooms++ {
+		n, k := len(roomPositions), instructor.MinRooms
+		set := nChooseKInit(n, k)
+
+	setLoop:
+		for nChooseKNext(set, n, k) {
+		courseLoop:
+			for _, course := range instructor.Courses {
+				for _, index := range set {
+					if course.Rooms[roomPositions[index]] >= 0 {
+						continue courseLoop
+					}
+				}
+				continue setLoop
+			}
+
+			// success!
+			break minRoomsLoop
+		}
+	}
+}
+
+func nChooseKInit(n, k int) []int {
+	if k > n || n < 1 {
+		panic("n choose k got bad inputs")
+	}
+	lst := make([]int, k)
+	for i := range lst {
+		lst[i] = -1
+	}
+	return lst
+}
+
+func nChooseKNext(lst []int, n, k int) bool {
+	if lst[0] == -1 {
+		for i := 0; i < k; i++ {
+			lst[i] = i
+		}
+		return true
+	}
+	for i := 0; i < k; i++ {
+		elt := lst[k-1-i]
+		if elt < n-1-i {
+			for j := k - 1 - i; j < k; j++ {
+				elt++
+				lst[j] = elt
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// A PathCheck is the result of checking whether a student can take a
+// list of courses in the same term. Sections is a choice of one section
+// of each course, none meeting at the same time as another, or nil if
+// there is no such choice, in which case Blocking lists the smallest
+// groups of the courses that cannot all be taken together.
+type PathCheck struct {
+	Sections []*Course
+	Blocking [][]string
+}
+
+// CheckPath looks for a way to take every course in the list with no
+// two meeting at the same time in the schedule. Sections that are not
+// placed cannot be chosen. A section with extra meetings is taken with
+// all of them.
+func (data *InputData) CheckPath(schedule Schedule, courses []string) (PathCheck, error) {
+	// the time slots each placed section uses
+	slots := make(map[*Course]map[int]bool)
+	for r := range schedule.RoomTimes {
+		for t, cell := range schedule.RoomTimes[r] {
+			if cell.Course == nil {
+				continue
+			}
+			main := cell.Course.mainCourse()
+			if slots[main] == nil {
+				slots[main] = make(map[int]bool)
+			}
+			slots[main][t] = true
+		}
+	}
+
+	var options [][]*Course
+	for i, name := range courses {
+		if containsString(courses[:i], name) {
+			return PathCheck{}, fmt.Errorf("course %s is listed twice", name)
+		}
+		if !data.isCourseName(name) {
+			return PathCheck{}, fmt.Errorf("course %s not found", name)
+		}
+		var sections []*Course
+		for _, course := range data.Courses {
+			if course.Main == nil && course.Name == name && slots[course] != nil {
+				sections = append(sections, course)
+			}
+		}
+		options = append(options, sections)
+	}
+
+	clash := func(a, b *Course) bool {
+		for t := range slots[a] {
+			if slots[b][t] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// pick a section of each course in turn, backing up when the
+	// only sections left clash with ones already picked
+	var choose func(subset []int, chosen []*Course) []*Course
+	choose = func(subset []int, chosen []*Course) []*Course {
+		if len(chosen) == len(subset) {
+			return append([]*Course{}, chosen...)
+		}
+	next:
+		for _, section := range options[subset[len(chosen)]] {
+			for _, other := range chosen {
+				if clash(section, other) {
+					continue next
+				}
+			}
+			if found := choose(subset, append(chosen, section)); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+
+	all := make([]int, len(courses))
+	for i := range all {
+		all[i] = i
+	}
+	if found := choose(all, nil); found != nil || len(courses) == 0 {
+		return PathCheck{Sections: found}, nil
+	}
+
+	// find the smallest groups that cannot be taken together, starting
+	// with courses that have no placed sections at all
+	var check PathCheck
+	for size := 1; size <= len(courses) && check.Blocking == nil; size++ {
+		var subset []int
+		var walk func(start int)
+		walk = func(start int) {
+			if len(subset) == size {
+				if choose(subset, nil) == nil {
+					var names []string
+					for _, i := range subset {
+						names = append(names, courses[i])
+					}
+					check.Blocking = append(check.Blocking, names)
+				}
+				return
+			}
+			for i := start; i < len(courses); i++ {
+				subset = append(subset, i)
+				walk(i + 1)
+				subset = subset[:len(subset)-1]
+			}
+		}
+		walk(0)
+	}
+	return check, nil
+}
@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// StaffName is the name given to the instructors that take over the
+// sections of an instructor removed by WithoutInstructor, numbered
+// STAFF1, STAFF2, and so on
+const StaffName = "STAFF"
+
+// WithoutInstructor makes a copy of the input as it would be if the
+// named instructor were no longer available. Each section they teach
+// alone goes to a STAFF instructor of its own who can teach at any
+// time, or is left out entirely if drop is set, along with any
+// constraints that no longer name enough courses. Sections they
+// co-teach stay with their other instructors. Every section keeps its
+// section number, so sections of the copy can be matched to the
+// original by SectionID. It also returns the IDs of the sections that
+// were handed to STAFF or dropped.
+func (data *InputData) WithoutInstructor(name string, drop bool) (*InputData, []string, error) {
+	var removed *Instructor
+	for _, instructor := range data.Instructors {
+		if instructor.Name == name {
+			removed = instructor
+		}
+	}
+	if removed == nil {
+		return nil, nil, fmt.Errorf("instructor %q not found", name)
+	}
+
+	in, err := data.jsonInput()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the courses are written in the same order as data.Courses,
+	// leaving out extra meetings
+	var mains []*Course
+	for _, course := range data.Courses {
+		if course.Main == nil {
+			mains = append(mains, course)
+		}
+	}
+
+	taken := make(map[string]bool)
+	for _, instructor := range in.Instructors {
+		taken[instructor.Name] = true
+	}
+	staff := 0
+	allTimes := make(map[string]int)
+	for _, time := range data.Times {
+		allTimes[time.Name] = 0
+	}
+
+	var changed []string
+	var courses []JSONCourse
+	for i, elt := range in.Courses {
+		course := mains[i]
+		elt.Section = course.Section
+		var others []string
+		for _, instructor := range elt.Instructors {
+			if instructor != name {
+				others = append(others, instructor)
+			}
+		}
+		switch {
+		case len(others) == len(elt.Instructors):
+		case len(others) > 0:
+			elt.Instructors = others
+		case drop:
+			changed = append(changed, course.SectionID())
+			continue
+		default:
+			var staffName string
+			for staffName == "" || taken[staffName] {
+				staff++
+				staffName = fmt.Sprintf("%s%d", StaffName, staff)
+			}
+			taken[staffName] = true
+			in.Instructors = append(in.Instructors, JSONInstructor{Name: staffName, Times: allTimes})
+			elt.Instructors = []string{staffName}
+			changed = append(changed, course.SectionID())
+		}
+		courses = append(courses, elt)
+	}
+	in.Courses = courses
+
+	var instructors []JSONInstructor
+	for _, instructor := range in.Instructors {
+		if instructor.Name != name {
+			instructors = append(instructors, instructor)
+		}
+	}
+	in.Instructors = instructors
+
+	// drop the instructor from groups, and groups with only one left
+	groups := func(list []JSONGroup) []JSONGroup {
+		var out []JSONGroup
+		for _, group := range list {
+			var names []string
+			for _, instructor := range group.Instructors {
+				if instructor != name {
+					names = append(names, instructor)
+				}
+			}
+			if len(names) >= 2 {
+				group.Instructors = names
+				out = append(out, group)
+			}
+		}
+		return out
+	}
+	in.Aparts = groups(in.Aparts)
+	in.Togethers = groups(in.Togethers)
+
+	if drop {
+		in.dropMissingCourses()
+	}
+
+	out, err := in.inputData()
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, changed, nil
+}
+
+// WithoutRoom makes a copy of the input as it would be if the named
+// room could not be used. It fails if some section cannot meet in any
+// other room.
+func (data *InputData) WithoutRoom(name string) (*InputData, error) {
+	found := false
+	for _, room := range data.Rooms {
+		if room.Name == name {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("room %q not found", name)
+	}
+
+	in, err := data.jsonInput()
+	if err != nil {
+		return nil, err
+	}
+	var rooms []JSONRoom
+	for _, room := range in.Rooms {
+		if room.Name == name {
+			continue
+		}
+		var adjacent []string
+		for _, other := range room.Adjacent {
+			if other != name {
+				adjacent = append(adjacent, other)
+			}
+		}
+		room.Adjacent = adjacent
+		rooms = append(rooms, room)
+	}
+	in.Rooms = rooms
+
+	i := 0
+	for _, course := range data.Courses {
+		if course.Main != nil {
+			continue
+		}
+		elt := in.Courses[i]
+		i++
+		delete(elt.Rooms, name)
+		if len(elt.Rooms) == 0 {
+			return nil, fmt.Errorf("%s cannot meet in any other room", course.SectionID())
+		}
+		for n, meeting := range elt.Meetings {
+			delete(meeting.Rooms, name)
+			if len(meeting.Rooms) == 0 {
+				return nil, fmt.Errorf("%s cannot meet in any other room", course.Meetings()[n].SectionID())
+			}
+		}
+	}
+
+	var costs []JSONCost
+	for _, cost := range in.Costs {
+		var names []string
+		for _, room := range cost.Rooms {
+			if room != name {
+				names = append(names, room)
+			}
+		}
+		if len(names) > 0 {
+			cost.Rooms = names
+			costs = append(costs, cost)
+		}
+	}
+	in.Costs = costs
+
+	return in.inputData()
+}
+
+// jsonInput gives the input as a JSONInput, ready to be changed
+func (data *InputData) jsonInput() (*JSONInput, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	in := new(JSONInput)
+	if err := json.Unmarshal(raw, in); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// inputData reads a JSONInput back into a new InputData
+func (in *JSONInput) inputData() (*InputData, error) {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	data := new(InputData)
+	if err := data.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// dropMissingCourses removes the names of courses that no longer have
+// any sections from the constraints that name them, and drops the
+// constraints that are left naming too few courses to mean anything
+func (in *JSONInput) dropMissingCourses() {
+	present := make(map[string]bool)
+	cohorts := make(map[string]bool)
+	for _, course := range in.Courses {
+		present[course.Name] = true
+		for _, cohort := range course.Cohorts {
+			cohorts[cohort] = true
+		}
+	}
+	filter := func(list []JSONConflict, min int) []JSONConflict {
+		var out []JSONConflict
+		for _, elt := range list {
+			var names []string
+			for _, name := range elt.Courses {
+				if present[name] {
+					names = append(names, name)
+				}
+			}
+			if len(names) >= min {
+				elt.Courses = names
+				out = append(out, elt)
+			}
+		}
+		return out
+	}
+	in.Conflicts = filter(in.Conflicts, 1)
+	in.AntiConflicts = filter(in.AntiConflicts, 2)
+	in.Adjacencies = filter(in.Adjacencies, 2)
+
+	// a concurrent setting with no courses applies to every course, so
+	// one that loses all of its courses must go rather than be emptied
+	var concurrents []JSONConflict
+	for _, elt := range in.Concurrents {
+		if len(elt.Courses) == 0 {
+			concurrents = append(concurrents, elt)
+		} else if kept := filter([]JSONConflict{elt}, 1); len(kept) > 0 {
+			concurrents = append(concurrents, kept[0])
+		}
+	}
+	in.Concurrents = concurrents
+
+	var orders []JSONOrder
+	for _, elt := range in.Orders {
+		if present[elt.First] && present[elt.Second] {
+			orders = append(orders, elt)
+		}
+	}
+	in.Orders = orders
+
+	var ceilings []JSONCeiling
+	for _, elt := range in.Ceilings {
+		if cohorts[elt.Cohort] {
+			ceilings = append(ceilings, elt)
+		}
+	}
+	in.Ceilings = ceilings
+}
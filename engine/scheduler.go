@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A Scheduler holds an input, the current schedule for it, and the
+// search (if any) working to improve that schedule. Its methods are
+// safe for concurrent use, so a server or user interface can start and
+// stop searches, read the current schedule, and move courses by hand
+// from different goroutines.
+type Scheduler struct {
+	data     *InputData
+	sections []*Section
+
+	mutex sync.Mutex
+	best  Schedule
+	run   *schedulerRun
+	stats Stats
+}
+
+// a schedulerRun is one search started by a Scheduler
+type schedulerRun struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	options  Options
+	sections []*Section
+	started  time.Time
+	done     chan struct{}
+}
+
+// NewScheduler returns a Scheduler for the input, starting from the
+// given placements, or from scratch if there are none. It returns an
+// error if the placements are not valid for the input. A section with
+// nowhere it can be placed is only an error when a search is started
+// that cannot leave it out.
+func NewScheduler(data *InputData, placements []Placement) (*Scheduler, error) {
+	sections, err := data.buildSectionList(true)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheduler{
+		data:     data,
+		sections: sections,
+		best:     Schedule{Badness: Worst},
+	}
+	if len(placements) > 0 {
+		if err := data.CheckPlacements(placements); err != nil {
+			return nil, err
+		}
+		s.best = data.Score(placements)
+	}
+	return s, nil
+}
+
+// Data returns the input the Scheduler was created with
+func (s *Scheduler) Data() *InputData {
+	return s.data
+}
+
+// Sections returns the section list built from the input, including
+// any sections with nowhere they can be placed. It must not be
+// modified.
+func (s *Scheduler) Sections() []*Section {
+	return s.sections
+}
+
+// Start begins a search in the background. If options.Start has no
+// placements, the search starts from the current schedule. Each new
+// best schedule it finds becomes the current schedule before the
+// OnBest hook (if any) is called. It returns an error if a search is
+// already running, the settings are out of range, or some section has
+// nowhere it can be placed and options.Unplaced does not allow it to
+// be left out.
+func (s *Scheduler) Start(ctx context.Context, options Options) error {
+	if err := options.Check(); err != nil {
+		return err
+	}
+	sections, err := s.data.buildSectionList(options.Unplaced > 0)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.run != nil {
+		return fmt.Errorf("a search is already running")
+	}
+	s.start(ctx, options, sections)
+	return nil
+}
+
+// start launches a search; it is called with the mutex held
+func (s *Scheduler) start(ctx context.Context, options Options, sections []*Section) {
+	searchCtx, cancel := context.WithCancel(ctx)
+	run := &schedulerRun{
+		ctx:      ctx,
+		cancel:   cancel,
+		options:  options,
+		sections: sections,
+		started:  time.Now(),
+		done:     make(chan struct{}),
+	}
+	s.run = run
+
+	search := options
+	if len(search.Start.Placements) == 0 {
+		search.Start = s.best
+	}
+	if len(search.Start.Placements) == 0 {
+		search.Start = Schedule{Badness: Worst}
+	}
+	search.OnBest = func(schedule Schedule) {
+		s.mutex.Lock()
+		if s.run == run && (schedule.Badness < s.best.Badness || len(s.best.Placements) == 0) {
+			s.best = schedule
+		}
+		s.mutex.Unlock()
+		if options.OnBest != nil {
+			options.OnBest(schedule)
+		}
+	}
+
+	go func() {
+		_, stats := s.data.search(searchCtx, sections, &search)
+		cancel()
+		s.mutex.Lock()
+		s.stats = stats
+		if s.run == run {
+			s.run = nil
+		}
+		s.mutex.Unlock()
+		close(run.done)
+	}()
+}
+
+// Running reports whether a search is running
+func (s *Scheduler) Running() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.run != nil
+}
+
+// Cancel asks the running search (if any) to stop, without waiting for
+// it to finish
+func (s *Scheduler) Cancel() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.run != nil {
+		s.run.cancel()
+	}
+}
+
+// Stop stops the running search (if any) and waits for it to finish.
+// It returns the stats of the most recent search.
+func (s *Scheduler) Stop() Stats {
+	s.Cancel()
+	return s.Wait()
+}
+
+// Wait waits for the running search (if any) to finish. It returns the
+// stats of the most recent search.
+func (s *Scheduler) Wait() Stats {
+	s.mutex.Lock()
+	run := s.run
+	s.mutex.Unlock()
+	if run != nil {
+		<-run.done
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stats
+}
+
+// Best returns the current schedule: the best one found so far, or the
+// result of the most recent move if that came later. It has no
+// placements if no valid schedule has been found.
+func (s *Scheduler) Best() Schedule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.best.Clone()
+}
+
+// ApplyMove moves a course to the given room and time in the current
+// schedule, which must have placements, and returns the result, which
+// becomes the current schedule. As with MoveCourse, it fails if the
+// course cannot be placed there or another course is in the way. If a
+// search is running, it is stopped and then restarted from the new
+// schedule with whatever time it had left.
+func (s *Scheduler) ApplyMove(course *Course, room, t int) (Schedule, error) {
+	// stop any running search, remembering the first one to restart it
+	var stopped *schedulerRun
+	s.mutex.Lock()
+	for s.run != nil {
+		run := s.run
+		if stopped == nil {
+			stopped = run
+		}
+		run.cancel()
+		s.mutex.Unlock()
+		<-run.done
+		s.mutex.Lock()
+	}
+	defer s.mutex.Unlock()
+
+	restart := func() {
+		if stopped == nil || stopped.ctx.Err() != nil {
+			return
+		}
+		options := stopped.options
+		options.Duration -= time.Since(stopped.started)
+		if options.Duration <= 0 {
+			return
+		}
+		options.Start = Schedule{}
+		s.start(stopped.ctx, options, stopped.sections)
+	}
+
+	if len(s.best.Placements) == 0 {
+		restart()
+		return Schedule{}, fmt.Errorf("there is no schedule to move a course in")
+	}
+	placements, err := s.data.MoveCourse(s.sections, s.best.Placements, course, room, t)
+	if err != nil {
+		restart()
+		return Schedule{}, err
+	}
+	s.best = s.data.Score(placements)
+	restart()
+	return s.best.Clone(), nil
+}
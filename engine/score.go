@@ -0,0 +1,878 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A Schedule is a two-dimensional view of the placed sections,
+// ready to be scored and displayed.
+type Schedule struct {
+	Placements []Placement
+	RoomTimes  [][]Cell
+	Problems   []Problem
+	Badness    int
+
+	// Unplaced lists the sections the schedule leaves out, in the
+	// order they appear in the input, and Dropped lists the optional
+	// sections it leaves out on purpose
+	Unplaced []*Course
+	Dropped  []*Course
+}
+
+type Problem struct {
+	Message string
+	Badness int
+
+	// the sections involved, when the problem is tied to them
+	Courses []*Course
+}
+
+// Category is the kind of problem, taken from the start of the message,
+// e.g., "curriculum conflict" or "instructor time preference"
+func (p Problem) Category() string {
+	if i := strings.Index(p.Message, ": "); i >= 0 {
+		return p.Message[:i]
+	}
+	return p.Message
+}
+
+type CoursePair struct {
+	A, B string
+}
+
+func (s *Schedule) AddBadness(badness int) {
+	if badness >= 0 && badness <= MaxBadness || badness == UnplacedBadness {
+		s.Badness += badness
+	} else {
+		s.Badness += Impossible
+	}
+}
+
+const Impossible int = 1000000
+
+// UnplacedBadness is the badness of each section a partial schedule
+// leaves out. It is high enough that a search will place every section
+// it can, but a partial schedule still beats one that is impossible.
+const UnplacedBadness int = 10000
+
+// ScoreScratch holds buffers that can be reused between calls to ScoreWith.
+// A single ScoreScratch must not be used by more than one goroutine at a time.
+type ScoreScratch struct {
+	grid                   [][]Cell
+	problems               []Problem
+	anticonflicts          map[CoursePair]int
+	timesPerDay            map[string]int
+	instructorToPlacements map[*Instructor][]Placement
+	courseToPlacements     map[string][]Placement
+	inRoom                 map[int]int
+	onDay                  map[string][]Placement
+	placed                 []bool
+}
+
+func (scratch *ScoreScratch) reset() {
+	if scratch.anticonflicts == nil {
+		scratch.anticonflicts = make(map[CoursePair]int)
+		scratch.timesPerDay = make(map[string]int)
+		scratch.instructorToPlacements = make(map[*Instructor][]Placement)
+		scratch.courseToPlacements = make(map[string][]Placement)
+		scratch.inRoom = make(map[int]int)
+		scratch.onDay = make(map[string][]Placement)
+	}
+	scratch.problems = scratch.problems[:0]
+	for i := range scratch.placed {
+		scratch.placed[i] = false
+	}
+	for key := range scratch.anticonflicts {
+		delete(scratch.anticonflicts, key)
+	}
+	for key := range scratch.timesPerDay {
+		delete(scratch.timesPerDay, key)
+	}
+
+	// keep the slices around for reuse; empty lists are skipped when scoring
+	for key, lst := range scratch.instructorToPlacements {
+		scratch.instructorToPlacements[key] = lst[:0]
+	}
+	for key, lst := range scratch.courseToPlacements {
+		scratch.courseToPlacements[key] = lst[:0]
+	}
+}
+
+func (data *InputData) Score(placements []Placement) Schedule {
+	return data.ScoreWith(new(ScoreScratch), placements)
+}
+
+// ScoreWith is the same as Score, but it reuses the buffers in scratch
+// instead of allocating new ones. The schedule it returns shares memory
+// with scratch, so it is only valid until the next call using the same
+// scratch. Use Clone to keep a copy.
+func (data *InputData) ScoreWith(scratch *ScoreScratch, placements []Placement) Schedule {
+	scratch.reset()
+	grid := data.FillGrid(scratch.grid, placements)
+	scratch.grid = grid
+	schedule := Schedule{Placements: placements, RoomTimes: grid}
+	problems := scratch.problems
+
+	// map pairs of courses that should be taught at the same time
+	// to the badness score for a miss, then check them off the list
+	// as we find them
+	anticonflicts := scratch.anticonflicts
+	for _, conflict := range data.AntiConflicts {
+		for _, a := range conflict.Courses {
+			for _, b := range conflict.Courses {
+				if a == b {
+					continue
+				}
+				if a > b {
+					a, b = b, a
+				}
+
+				// use the worst badness score in case of overlapping rules
+				if other, exists := anticonflicts[CoursePair{a, b}]; !exists || conflict.Badness > other {
+					anticonflicts[CoursePair{a, b}] = conflict.Badness
+				}
+			}
+		}
+	}
+
+	// map pairs of courses that should be in adjacent rooms when they
+	// meet at the same time to the badness score for a miss
+	var adjacencies map[CoursePair]int
+	if len(data.Adjacencies) > 0 {
+		adjacencies = make(map[CoursePair]int)
+	}
+	for _, adjacency := range data.Adjacencies {
+		for _, a := range adjacency.Courses {
+			for _, b := range adjacency.Courses {
+				if a >= b {
+					continue
+				}
+				if other, exists := adjacencies[CoursePair{a, b}]; !exists || adjacency.Badness < 0 || (other >= 0 && adjacency.Badness > other) {
+					adjacencies[CoursePair{a, b}] = adjacency.Badness
+				}
+			}
+		}
+	}
+
+	// check each time slot
+	for t := range data.Times {
+		// consider each course in this time slot
+		for roomA := 0; roomA < len(data.Rooms); roomA++ {
+			courseA := grid[roomA][t].Course
+			if courseA == nil {
+				continue
+			}
+			isSpilloverA := grid[roomA][t].IsSpillover
+
+			// is this a bad time for this instructor?
+			for _, instructor := range courseA.Instructors {
+				if badness := instructor.Times[t]; badness > 0 {
+					msg := fmt.Sprintf("instructor time preference: %s has %s scheduled at %s (badness %d)",
+						instructor.Name, courseA.Name, data.Times[t].Name, badness)
+					problems = append(problems, Problem{Message: msg, Badness: badness, Courses: []*Course{courseA}})
+				} else if badness < 0 {
+					msg := fmt.Sprintf("instructor not available: %s has %s scheduled at %s (badness %d)",
+						instructor.Name, courseA.Name, data.Times[t].Name, Impossible)
+					problems = append(problems, Problem{Message: msg, Badness: Impossible, Courses: []*Course{courseA}})
+				}
+			}
+
+			// is this a bad time for this course?
+			if len(courseA.Times) > 0 && !isSpilloverA {
+				if badness := courseA.Times[t]; badness != 0 {
+					if badness < 0 {
+						badness = Impossible
+					}
+					msg := fmt.Sprintf("course time preference: %s should not be scheduled at %s (badness %d)",
+						courseA.Name, data.Times[t].Name, badness)
+					problems = append(problems, Problem{Message: msg, Badness: badness, Courses: []*Course{courseA}})
+				}
+			}
+
+			// can this course meet on these days?
+			if !isSpilloverA && !courseA.MeetsOn(data.Times[t]) {
+				msg := fmt.Sprintf("course slot pattern: %s cannot meet at %s (badness %d)",
+					courseA.Name, data.Times[t].Name, Impossible)
+				problems = append(problems, Problem{Message: msg, Badness: Impossible, Courses: []*Course{courseA}})
+			}
+
+			// is this a bad room for this course? (only counts once per course)
+			if badness := courseA.Rooms[roomA]; !isSpilloverA && badness != 0 {
+				if badness < 0 {
+					badness = Impossible
+				}
+				msg := fmt.Sprintf("course room preference: %s should not be scheduled in %s (badness %d)",
+					courseA.Name, data.Rooms[roomA].Name, badness)
+				problems = append(problems, Problem{Message: msg, Badness: badness, Courses: []*Course{courseA}})
+			}
+
+			// does this room cost extra at this time?
+			if data.roomTimeCost != nil {
+				if badness := data.roomTimeCost[roomA][t]; badness != 0 {
+					if badness < 0 {
+						badness = Impossible
+					}
+					msg := fmt.Sprintf("room/time cost: %s is scheduled in %s at %s (badness %d)",
+						courseA.Name, data.Rooms[roomA].Name, data.Times[t].Name, badness)
+					problems = append(problems, Problem{Message: msg, Badness: badness, Courses: []*Course{courseA}})
+				}
+			}
+
+			// does a different course follow with no time to turn over?
+			if data.Rooms[roomA].Turnover && t+1 < len(data.Times) && data.Times[t].Next == data.Times[t+1] {
+				next := grid[roomA][t+1]
+				if next.Course != nil && !next.IsSpillover && next.Course.Name != courseA.Name {
+					msg := fmt.Sprintf("room turnover: %s follows %s in %s at %s with no time between (badness %d)",
+						next.Course.Name, courseA.Name, data.Rooms[roomA].Name, data.Times[t+1].Name, Impossible)
+					problems = append(problems, Problem{Message: msg, Badness: Impossible, Courses: []*Course{courseA, next.Course}})
+				}
+			}
+
+			// compare pairs of courses in different rooms at the same time
+			for roomB := roomA + 1; roomB < len(data.Rooms); roomB++ {
+				courseB := grid[roomB][t].Course
+				if courseB == nil {
+					continue
+				}
+
+				// are these taught by the same instructor?
+				// (note: we will never generate a schedule like this,
+				// but a user might propose one)
+				for _, instructorA := range courseA.Instructors {
+					for _, instructorB := range courseB.Instructors {
+						if instructorA == instructorB {
+							if !grid[roomA][t].IsSpillover || !grid[roomB][t].IsSpillover {
+								courses := []string{courseA.Name, courseB.Name}
+								sort.Strings(courses)
+								msg := fmt.Sprintf("instructor double booked: %s has courses %s and %s at %s (badness %d)",
+									instructorA.Name, courses[0], courses[1], data.Times[t].Name, Impossible)
+								problems = append(problems, Problem{Message: msg, Badness: Impossible, Courses: []*Course{courseA, courseB}})
+							}
+						}
+					}
+				}
+
+				// are these two courses in conflict?
+				if badness := courseA.ConflictBadness[courseB.ID]; badness != NoConflict {
+					if !grid[roomA][t].IsSpillover || !grid[roomB][t].IsSpillover {
+						if badness < 0 {
+							badness = Impossible
+						}
+						courses := []string{courseA.Name, courseB.Name}
+						sort.Strings(courses)
+						msg := fmt.Sprintf("curriculum conflict: %s and %s both meet at %s (badness %d)",
+							courses[0], courses[1], data.Times[t].Name, badness)
+						problems = append(problems, Problem{Message: msg, Badness: badness, Courses: []*Course{courseA, courseB}})
+					}
+				}
+
+				// should these instructors be kept apart?
+				if courseA.ApartBadness != nil && courseA.ApartBadness[courseB.ID] != NoConflict {
+					if !grid[roomA][t].IsSpillover || !grid[roomB][t].IsSpillover {
+						instructorA, instructorB, badness := data.apartInstructors(courseA, courseB)
+						if badness < 0 {
+							badness = Impossible
+						}
+						msg := fmt.Sprintf("instructors apart: %s (%s) and %s (%s) both teach at %s (badness %d)",
+							instructorA.Name, courseA.Name, instructorB.Name, courseB.Name, data.Times[t].Name, badness)
+						problems = append(problems, Problem{Message: msg, Badness: badness, Courses: []*Course{courseA, courseB}})
+					}
+				}
+
+				// are we trying to schedule these two at the same time?
+				a, b := courseA.Name, courseB.Name
+				if a > b {
+					a, b = b, a
+				}
+				if _, present := anticonflicts[CoursePair{a, b}]; present {
+					// only consider it satisfied if they start at the same time
+					if !grid[roomA][t].IsSpillover && !grid[roomB][t].IsSpillover {
+						delete(anticonflicts, CoursePair{a, b})
+					}
+				}
+
+				// should these be in adjacent rooms?
+				if badness, present := adjacencies[CoursePair{a, b}]; present && !isSpilloverA && !grid[roomB][t].IsSpillover {
+					if !data.Rooms[roomA].IsAdjacent(data.Rooms[roomB]) {
+						if badness < 0 {
+							badness = Impossible
+						}
+						msg := fmt.Sprintf("room adjacency: %s in %s and %s in %s both meet at %s but the rooms are not adjacent (badness %d)",
+							courseA.Name, data.Rooms[roomA].Name, courseB.Name, data.Rooms[roomB].Name, data.Times[t].Name, badness)
+						problems = append(problems, Problem{Message: msg, Badness: badness, Courses: []*Course{courseA, courseB}})
+					}
+				}
+
+				// are these different sections of the same course?
+				if courseA.Name == courseB.Name && !courseA.sameSection(courseB) {
+					if badness := data.concurrent[courseA.Name]; badness != 0 {
+						if badness < 0 {
+							badness = Impossible
+						}
+						sections := []string{courseA.SectionID(), courseB.SectionID()}
+						sort.Strings(sections)
+						msg := fmt.Sprintf("curriculum conflict: %s has sections %s and %s meeting at %s (badness %d)",
+							courseA.Name, sections[0], sections[1], data.Times[t].Name, badness)
+						problems = append(problems, Problem{Message: msg, Badness: badness, Courses: []*Course{courseA, courseB}})
+					}
+				}
+			}
+		}
+	}
+
+	// limit how many sections of a cohort meet at once
+	for _, ceiling := range data.Ceilings {
+		for t := range data.Times {
+			var courses []*Course
+			for room := range data.Rooms {
+				if course := grid[room][t].Course; course != nil && course.InCohort(ceiling.Cohort) {
+					courses = append(courses, course)
+				}
+			}
+			excess := len(courses) - ceiling.Limit
+			if excess <= 0 {
+				continue
+			}
+			// badness caps at MaxBadness, so a soft limit stays soft
+			badness := ceiling.Badness * excess
+			if badness > MaxBadness {
+				badness = MaxBadness
+			}
+			if ceiling.Badness < 0 {
+				badness = Impossible
+			}
+			msg := fmt.Sprintf("cohort ceiling: %d %s sections meet at %s but the limit is %d (badness %d)",
+				len(courses), ceiling.Cohort, data.Times[t].Name, ceiling.Limit, badness)
+			problems = append(problems, Problem{Message: msg, Badness: badness, Courses: courses})
+		}
+	}
+
+	// apply penalties for sections that were left out
+	if len(scratch.placed) != len(data.Courses) {
+		scratch.placed = make([]bool, len(data.Courses))
+	}
+	placed := scratch.placed
+	for _, placement := range placements {
+		placed[placement.Course.ID] = true
+	}
+	for _, course := range data.Courses {
+		if placed[course.ID] {
+			continue
+		}
+		var names []string
+		for _, instructor := range course.Instructors {
+			names = append(names, instructor.Name)
+		}
+
+		// an optional section is dropped if none of its meetings are placed
+		if course.Optional && sectionDropped(course, placed) {
+			if course.Main == nil {
+				msg := fmt.Sprintf("optional course dropped: %s taught by %s was left out (badness %d)",
+					course.SectionID(), strings.Join(names, " and "), course.DropBadness)
+				problems = append(problems, Problem{Message: msg, Badness: course.DropBadness, Courses: []*Course{course}})
+				schedule.Dropped = append(schedule.Dropped, course)
+			}
+			continue
+		}
+
+		msg := fmt.Sprintf("unplaced section: %s taught by %s could not be placed (badness %d)",
+			course.SectionID(), strings.Join(names, " and "), UnplacedBadness)
+		problems = append(problems, Problem{Message: msg, Badness: UnplacedBadness, Courses: []*Course{course}})
+		schedule.Unplaced = append(schedule.Unplaced, course)
+	}
+
+	// apply penalties for anticonflicts that were not satisfied
+	for pair, badness := range anticonflicts {
+		if badness < 0 {
+			badness = Impossible
+		}
+		msg := fmt.Sprintf("curriculum conflict: %s and %s must have sections that meet at the same time (badness %d)",
+			pair.A, pair.B, badness)
+		var courses []*Course
+		for _, placement := range placements {
+			if placement.Course.Name == pair.A || placement.Course.Name == pair.B {
+				courses = append(courses, placement.Course)
+			}
+		}
+		problems = append(problems, Problem{Message: msg, Badness: badness, Courses: courses})
+	}
+
+	// find what count as days (multiple time slots with the same days)
+	timesPerDay := scratch.timesPerDay
+	for _, day := range data.timeDay {
+		if day != "" {
+			timesPerDay[day]++
+		}
+	}
+
+	// check how many rooms the instructor is assigned to
+	// check how spread out the instructor's schedule is
+	// check the split of an instructor's classes across days
+	// group all of the placements for courses with multiple sections
+	instructorToPlacements := scratch.instructorToPlacements
+	courseToPlacements := scratch.courseToPlacements
+	for _, placement := range placements {
+		for _, instructor := range placement.Course.Instructors {
+			lst := instructorToPlacements[instructor]
+			instructorToPlacements[instructor] = append(lst, placement)
+		}
+		// extra meetings are not extra sections
+		if placement.Course.Main == nil {
+			lst := courseToPlacements[placement.Course.Name]
+			courseToPlacements[placement.Course.Name] = append(lst, placement)
+		}
+	}
+
+	// check that each extra meeting agrees with the earlier meetings of
+	// its section
+	for _, b := range placements {
+		if b.Course.Main == nil {
+			continue
+		}
+		for _, a := range placements {
+			earlier := a.Course == b.Course.Main || (a.Course.Main == b.Course.Main && a.Course.Meeting < b.Course.Meeting)
+			if earlier && !data.MeetingsAgree(a.Time, b.Time) {
+				msg := fmt.Sprintf("split meeting: %s meets at %s and %s, which must be the same time on different days (badness %d)",
+					a.Course.Name, data.Times[a.Time].Name, data.Times[b.Time].Name, Impossible)
+				problems = append(problems, Problem{Message: msg, Badness: Impossible, Courses: []*Course{a.Course, b.Course}})
+			}
+		}
+	}
+
+	// check each instructor's schedule for niceness
+	for instructor, list := range instructorToPlacements {
+		if len(list) == 0 {
+			continue
+		}
+		sort.Slice(list, func(a, b int) bool {
+			return list[a].Time < list[b].Time
+		})
+
+		// gather info about how many classes are in each room and on each day
+		inRoom := scratch.inRoom
+		for key := range inRoom {
+			delete(inRoom, key)
+		}
+		onDay := scratch.onDay
+		for key := range onDay {
+			delete(onDay, key)
+		}
+		for _, elt := range list {
+			inRoom[elt.Room]++
+			if day := data.timeDay[elt.Time]; timesPerDay[day] > 1 {
+				onDay[day] = append(onDay[day], elt)
+			}
+		}
+
+		// penalize instructors with courses in too many rooms
+		if extra := len(inRoom) - instructor.MinRooms; extra > 0 {
+			badness := extra * extra
+			msg := fmt.Sprintf("instructor convenience: %s is spread across more rooms than necessary (badness %d)",
+				instructor.Name, badness)
+			problems = append(problems, Problem{Message: msg, Badness: badness, Courses: placementCourses(list)})
+		}
+
+		// penalize workloads that are unevenly split across days
+		if len(onDay) > 1 {
+			// with weighted days, a class counts for the meetings it
+			// has in the term relative to classes on other days
+			max, min := -1.0, -1.0
+			i := 0
+			for day, classes := range onDay {
+				count := float64(len(classes))
+				if data.dayWeight != nil {
+					count *= data.dayWeight[day]
+				}
+				if i == 0 || count > max {
+					max = count
+				}
+				if i == 0 || count < min {
+					min = count
+				}
+				i++
+			}
+
+			// add a penalty if there is more than one class difference between
+			// the most and fewest on a day
+			if gap := max - min; gap > 1 {
+				badness := int(math.Round(gap * gap * 4))
+				msg := fmt.Sprintf("instructor convenience: %s has more classes on some days than others (badness %d)",
+					instructor.Name, badness)
+				problems = append(problems, Problem{Message: msg, Badness: badness, Courses: placementCourses(list)})
+			}
+		}
+
+		// try to honor instructor preference for number of days teaching
+		if instructor.Days > 0 && len(onDay) != instructor.Days {
+			gap := instructor.Days - len(onDay)
+			if gap < 0 {
+				gap = -gap
+			}
+			badness := 10 * gap
+			if instructor.Days > len(onDay) {
+				badness *= 2
+			}
+			wanted := "s"
+			if instructor.Days == 1 {
+				wanted = ""
+			}
+			got := "s"
+			if len(onDay) == 1 {
+				got = ""
+			}
+			msg := fmt.Sprintf("instructor preference: %s has classes on %d day%s but wanted them on %d day%s (badness %d)",
+				instructor.Name, len(onDay), got, instructor.Days, wanted, badness)
+			problems = append(problems, Problem{Message: msg, Badness: badness, Courses: placementCourses(list)})
+		}
+
+		if len(instructor.Courses) > 1 {
+			badness := 0
+
+			// penalize schedules that are too spread out or too clustered on a given day
+			for _, classes := range onDay {
+				// one singleton class per day is okay. if there are two, the 2nd will incur penalties
+				singletonOkay := true
+				i := 0
+				for i < len(classes) {
+					// find the beginning of the next cluster of classes (if any)
+					slotsNeeded := classes[i].Course.SlotsNeeded(data.Times[classes[i].Time])
+					var next int
+					for next = i + 1; next < len(classes); next++ {
+						if classes[next].Time-classes[next-1].Time > slotsNeeded {
+							size := classes[next].Time - classes[next-1].Time - slotsNeeded
+
+							// is this gap too long?
+							if size > 1 {
+								// 2 => 6, 3 => 12, 4 => 20
+								badness += size * (size + 1)
+							}
+
+							break
+						}
+						slotsNeeded = classes[next].Course.SlotsNeeded(data.Times[classes[next].Time])
+					}
+
+					clusterSize := next - i
+					i = next
+
+					// was this cluster of classes too long or too short?
+					if clusterSize == 1 && singletonOkay {
+						// this is the odd class on this day
+						singletonOkay = false
+					} else {
+						// clusters of size two are perfect, anything else gets a penalty
+						mismatch := clusterSize - 2
+						if mismatch < 0 {
+							mismatch = -mismatch
+						}
+						if mismatch != 0 {
+							// 1 => 9, 3 => 9, 4 => 16, 5 => 81
+							badness += (mismatch + 2) * (mismatch + 2)
+						}
+					}
+				}
+			}
+
+			if badness > 0 {
+				msg := fmt.Sprintf("instructor convenience: %s has classes that are poorly spread out (badness %d)",
+					instructor.Name, badness)
+				problems = append(problems, Problem{Message: msg, Badness: badness, Courses: placementCourses(list)})
+			}
+		}
+	}
+
+	// check that courses meet in the requested order
+	for _, order := range data.Orders {
+		firsts := courseToPlacements[order.First]
+		if len(firsts) == 0 {
+			continue
+		}
+		for _, second := range courseToPlacements[order.Second] {
+			secondTime := data.Times[second.Time]
+			secondDays, _ := secondTime.DaysAndHour()
+			satisfied := false
+			for _, first := range firsts {
+				firstTime := data.Times[first.Time]
+				firstDays, _ := firstTime.DaysAndHour()
+				if firstDays != secondDays {
+					continue
+				}
+				if order.Immediately {
+					if first.Time+first.Course.SlotsNeeded(data.Times[first.Time]) == second.Time {
+						satisfied = true
+					}
+				} else if firstTime.Start >= 0 && firstTime.Start < secondTime.Start {
+					satisfied = true
+				}
+			}
+			if satisfied {
+				continue
+			}
+
+			badness := order.Badness
+			if badness < 0 {
+				badness = Impossible
+			}
+			when := "later on the same day as"
+			if order.Immediately {
+				when = "right after"
+			}
+			msg := fmt.Sprintf("course order: %s at %s should meet %s %s (badness %d)",
+				order.Second, data.Times[second.Time].Name, when, order.First, badness)
+			problems = append(problems, Problem{Message: msg, Badness: badness, Courses: append(placementCourses(firsts), second.Course)})
+		}
+	}
+
+	// check that instructors who should teach on the same days do
+	for _, together := range data.Togethers {
+		for i, a := range together.Instructors {
+			for _, b := range together.Instructors[i+1:] {
+				listA, listB := instructorToPlacements[a], instructorToPlacements[b]
+				if len(listA) == 0 || len(listB) == 0 {
+					continue
+				}
+
+				// find the days only one of them teaches
+				days := make(map[string]int)
+				for _, elt := range listA {
+					if day := data.timeDay[elt.Time]; timesPerDay[day] > 1 {
+						days[day] |= 1
+					}
+				}
+				for _, elt := range listB {
+					if day := data.timeDay[elt.Time]; timesPerDay[day] > 1 {
+						days[day] |= 2
+					}
+				}
+				var unshared []string
+				for day, who := range days {
+					if who != 3 {
+						unshared = append(unshared, day)
+					}
+				}
+				if len(unshared) == 0 {
+					continue
+				}
+				sort.Strings(unshared)
+
+				// badness caps at MaxBadness, so a soft rule stays soft
+				badness := together.Badness * len(unshared)
+				if badness > MaxBadness {
+					badness = MaxBadness
+				}
+				if together.Badness < 0 {
+					badness = Impossible
+				}
+				msg := fmt.Sprintf("instructors together: %s and %s do not both teach on %s (badness %d)",
+					a.Name, b.Name, strings.Join(unshared, ", "), badness)
+				courses := append(placementCourses(listA), placementCourses(listB)...)
+				problems = append(problems, Problem{Message: msg, Badness: badness, Courses: courses})
+			}
+		}
+	}
+
+	// check for sections being spread out
+	for courseName, placements := range courseToPlacements {
+		if len(placements) < 2 {
+			continue
+		}
+
+		// having at least one section on each day pattern is important,
+		// covering as many as there are sections (or allowed patterns)
+		usedDays := make(map[string]bool)
+		daySections := 0
+		for _, placement := range placements {
+			if day := data.timeDistribution[placement.Time]; day != "" {
+				usedDays[day] = true
+				daySections++
+			}
+		}
+		if daySections >= 2 {
+			// which day patterns does the input data allow?
+			allowedDays := make(map[string]bool)
+			for _, instructor := range data.Instructors {
+				for _, course := range instructor.Courses {
+					if course.Name != courseName {
+						continue
+					}
+					for time, badness := range course.Times {
+						if badness < 0 {
+							continue
+						}
+						if day := data.timeDistribution[time]; day != "" {
+							allowedDays[day] = true
+						}
+					}
+				}
+			}
+
+			target := daySections
+			if len(allowedDays) < target {
+				target = len(allowedDays)
+			}
+			if short := target - len(usedDays); short > 0 {
+				var missing []string
+				for _, day := range data.distributionDays() {
+					if allowedDays[day] && !usedDays[day] {
+						missing = append(missing, day)
+					}
+				}
+				badness := 15 * short
+				msg := fmt.Sprintf("section distribution: %s has multiple sections but none on %s (badness %d)",
+					courseName, orList(missing), badness)
+				problems = append(problems, Problem{Message: msg, Badness: badness, Courses: placementCourses(placements)})
+			}
+		}
+
+		// spread the sections across the bands of the day, covering as
+		// many bands as there are sections (or allowed bands)
+		usedBands := make(map[int]bool)
+		banded := 0
+		for _, placement := range placements {
+			if band := data.timeBand[placement.Time]; band >= 0 {
+				usedBands[band] = true
+				banded++
+			}
+		}
+		if banded < 2 {
+			continue
+		}
+		allowedBands := make(map[int]bool)
+		for _, instructor := range data.Instructors {
+			for _, course := range instructor.Courses {
+				if course.Name != courseName {
+					continue
+				}
+				for time, badness := range course.Times {
+					if badness < 0 {
+						continue
+					}
+					if band := data.timeBand[time]; band >= 0 {
+						allowedBands[band] = true
+					}
+				}
+			}
+		}
+		target := banded
+		if len(allowedBands) < target {
+			target = len(allowedBands)
+		}
+		if short := target - len(usedBands); short > 0 {
+			var missing []string
+			for band, elt := range data.bands() {
+				if allowedBands[band] && !usedBands[band] {
+					missing = append(missing, elt.Name)
+				}
+			}
+			badness := 10 * short
+			msg := fmt.Sprintf("section distribution: %s has multiple sections but none in the %s (badness %d)",
+				courseName, orList(missing), badness)
+			problems = append(problems, Problem{Message: msg, Badness: badness, Courses: placementCourses(placements)})
+		}
+	}
+
+	sort.Slice(problems, func(a, b int) bool {
+		if problems[a].Badness != problems[b].Badness {
+			return problems[a].Badness > problems[b].Badness
+		}
+		return problems[a].Message < problems[b].Message
+	})
+	for _, problem := range problems {
+		schedule.AddBadness(problem.Badness)
+	}
+	schedule.Problems = problems
+	scratch.problems = problems
+	return schedule
+}
+
+// sectionDropped reports whether no meeting of the course's section is
+// placed
+func sectionDropped(course *Course, placed []bool) bool {
+	main := course.mainCourse()
+	if placed[main.ID] {
+		return false
+	}
+	for _, meeting := range main.Meetings() {
+		if placed[meeting.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// placementCourses lists the courses in a list of placements
+func placementCourses(list []Placement) []*Course {
+	courses := make([]*Course, len(list))
+	for i, placement := range list {
+		courses[i] = placement.Course
+	}
+	return courses
+}
+
+func (old Schedule) Clone() Schedule {
+	placements := make([]Placement, len(old.Placements))
+	copy(placements, old.Placements)
+	roomTimes := make([][]Cell, len(old.RoomTimes))
+	for i, lst := range old.RoomTimes {
+		cells := make([]Cell, len(lst))
+		copy(cells, lst)
+		roomTimes[i] = cells
+	}
+	problems := make([]Problem, len(old.Problems))
+	copy(problems, old.Problems)
+	var unplaced, dropped []*Course
+	if len(old.Unplaced) > 0 {
+		unplaced = make([]*Course, len(old.Unplaced))
+		copy(unplaced, old.Unplaced)
+	}
+	if len(old.Dropped) > 0 {
+		dropped = make([]*Course, len(old.Dropped))
+		copy(dropped, old.Dropped)
+	}
+	return Schedule{
+		Placements: placements,
+		RoomTimes:  roomTimes,
+		Problems:   problems,
+		Badness:    old.Badness,
+		Unplaced:   unplaced,
+		Dropped:    dropped,
+	}
+}
+
+// DiffProblems returns the problems that are only in the old list
+// and the problems that are only in the new list. Problems with
+// identical messages are matched up one for one.
+func DiffProblems(oldProblems, newProblems []Problem) (gone, added []Problem) {
+	count := make(map[string]int)
+	for _, problem := range newProblems {
+		count[problem.Message]++
+	}
+	for _, problem := range oldProblems {
+		if count[problem.Message] > 0 {
+			count[problem.Message]--
+		} else {
+			gone = append(gone, problem)
+		}
+	}
+	for _, problem := range newProblems {
+		if count[problem.Message] > 0 {
+			count[problem.Message]--
+			added = append(added, problem)
+		}
+	}
+	return gone, added
+}
+
+// orList joins names for a message, e.g., "a, b, or c"
+func orList(names []string) string {
+	switch len(names) {
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " or " + names[1]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + ", or " + names[len(names)-1]
+}
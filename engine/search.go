@@ -1,9 +1,11 @@
-package main
+package engine
 
 import (
-	"log"
+	"context"
+	"fmt"
 	"math/rand"
 	"sort"
+	"time"
 )
 
 // A Section is used during schedule creation
@@ -27,9 +29,19 @@ type Cell struct {
 	IsSpillover bool
 }
 
-// MakeSectionList forms a list of sections in order from most- to least-constrained.
-// The list it returns is read-only and only its clones can be modified.
-func (data *InputData) MakeSectionList() []*Section {
+// BuildSectionList forms a list of sections in order from most- to
+// least-constrained. The list it returns is read-only and only its
+// clones can be modified. It returns an error if some section has
+// nowhere it can be placed.
+func (data *InputData) BuildSectionList() ([]*Section, error) {
+	return data.buildSectionList(false)
+}
+
+// buildSectionList is BuildSectionList, but if partial is set, a
+// section with nowhere it can be placed is kept (at the front of the
+// list) instead of being an error, so a partial schedule can leave it
+// out
+func (data *InputData) buildSectionList(partial bool) ([]*Section, error) {
 	var sections []*Section
 	for _, instructor := range data.Instructors {
 		for _, course := range instructor.Courses {
@@ -54,7 +66,7 @@ func (data *InputData) MakeSectionList() []*Section {
 			// * the start slot must be okay for the course OR the course must not specify times
 			// * all slots the course occupies must be okay for all instructors
 			//
-			// the badness is the sum of the worst badness value from each slot used (capped at 99)
+			// the badness is the sum of the worst badness value from each slot used (capped at MaxBadness)
 			var courseTimes []int
 		timeLoop:
 			for i := range data.Times {
@@ -64,6 +76,12 @@ func (data *InputData) MakeSectionList() []*Section {
 					continue timeLoop
 				}
 
+				// the course must be able to meet on these days
+				if !course.MeetsOn(data.Times[i]) {
+					courseTimes = append(courseTimes, -1)
+					continue timeLoop
+				}
+
 				// there must be enough slots starting at this time
 				// and the instructors must be available for all of them
 				slotsNeeded := course.SlotsNeeded(data.Times[i])
@@ -89,9 +107,9 @@ func (data *InputData) MakeSectionList() []*Section {
 					}
 				}
 
-				// badness caps at 99
-				if badness > 99 {
-					badness = 99
+				// badness caps at MaxBadness
+				if badness > MaxBadness {
+					badness = MaxBadness
 				}
 
 				// which is worse: the course preferences for starting this course at this time
@@ -116,17 +134,20 @@ func (data *InputData) MakeSectionList() []*Section {
 					default:
 						badness = courseTimes[timeIndex]
 					}
+					if badness >= 0 && data.roomTimeCost != nil {
+						badness = data.addRoomTimeCost(course, roomIndex, timeIndex, badness)
+					}
 					section.RoomTimes[roomIndex][timeIndex] = badness
 					if badness >= 0 {
-						section.Tickets += 100 - badness
+						section.Tickets += lotteryTickets(badness)
 						section.Count++
 					}
 				}
 			}
 
 			// it must be possible to place the section somewhere
-			if section.Tickets == 0 || section.Count == 0 {
-				log.Fatalf("no valid room/time combinations found for %s taught by %s", course.Name, instructor.Name)
+			if !partial && !course.Optional && (section.Tickets == 0 || section.Count == 0) {
+				return nil, fmt.Errorf("no valid room/time combinations found for %s taught by %s", course.Name, instructor.Name)
 			}
 		}
 	}
@@ -136,7 +157,36 @@ func (data *InputData) MakeSectionList() []*Section {
 		return sections[a].Count < sections[b].Count
 	})
 
-	return sections
+	return sections, nil
+}
+
+// addRoomTimeCost adds the costs of every slot the course would
+// occupy in the given room starting at the given time to its badness
+// there, capped at MaxBadness, or returns -1 if any of those slots is closed
+func (data *InputData) addRoomTimeCost(course *Course, room, time, badness int) int {
+	for j := 0; j < course.SlotsNeeded(data.Times[time]); j++ {
+		cost := data.roomTimeCost[room][time+j]
+		if cost < 0 {
+			return -1
+		}
+		badness += cost
+	}
+	if badness > MaxBadness {
+		badness = MaxBadness
+	}
+	return badness
+}
+
+// lotteryTickets gives the weight of a room and time with the given
+// badness in a weighted lottery. Up to 99 it falls off in a straight
+// line, as 100 - badness did when 99 was the highest badness, and
+// above that it keeps falling in proportion to the badness, so every
+// soft choice keeps at least one ticket.
+func lotteryTickets(badness int) int {
+	if badness < 100 {
+		return 100 * (100 - badness)
+	}
+	return 10000 / badness
 }
 
 func CloneSectionList(original []*Section) []*Section {
@@ -158,7 +208,39 @@ func CloneSectionList(original []*Section) []*Section {
 	return clone
 }
 
-func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacementList []Placement, localPin float64, weightedLottery bool) []Placement {
+// PlaceSections places each section in turn, keeping each placement
+// from oldPlacementList with a probability of localPin percent and
+// drawing the rest by lottery. A section left with nowhere to go is
+// left out, up to maxUnplaced of them. It returns nil if the
+// placements made left more sections than that with nowhere to go, or
+// if ctx is done first.
+func (data *InputData) PlaceSections(ctx context.Context, readOnlySectionList []*Section, oldPlacementList []Placement, localPin float64, weightedLottery bool, maxUnplaced int) []Placement {
+	return data.placeSections(ctx, nil, sharedRandom{}, readOnlySectionList, oldPlacementList, localPin, weightedLottery, maxUnplaced)
+}
+
+// a randomSource supplies the random numbers for a search: either the
+// shared source in math/rand, or a *rand.Rand for a search that must
+// give the same result each time
+type randomSource interface {
+	Float64() float64
+	Intn(n int) int
+	NormFloat64() float64
+}
+
+// sharedRandom uses the top-level functions in math/rand, which are
+// safe for concurrent use
+type sharedRandom struct{}
+
+func (sharedRandom) Float64() float64     { return rand.Float64() }
+func (sharedRandom) Intn(n int) int       { return rand.Intn(n) }
+func (sharedRandom) NormFloat64() float64 { return rand.NormFloat64() }
+
+// placeSections is PlaceSections, drawing from random and also logging
+// to verbose (if it is not nil) why an attempt was abandoned
+func (data *InputData) placeSections(ctx context.Context, verbose Logger, random randomSource, readOnlySectionList []*Section, oldPlacementList []Placement, localPin float64, weightedLottery bool, maxUnplaced int) []Placement {
+	done := ctx.Done()
+	unplaced := 0
+
 	// the schedule we are creating
 	var schedule []Placement
 
@@ -177,17 +259,53 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 		oldSchedule[placement.Course] = placement
 	}
 
+	// whether each optional section (keyed by its main course) is
+	// being kept (true) or dropped (false), once that is decided
+	keep := make(map[*Course]bool)
+	placedSection := make(map[*Course]bool)
+
 	// place the sections one at a time, starting with the most constrained
 	for sectionIndex := 0; sectionIndex < len(sections); sectionIndex++ {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
 		section := sections[sectionIndex]
 		r, t := -1, -1
 
+		// an optional section may be dropped: keep it dropped with the
+		// same odds as keeping a placement, and always drop it if
+		// there is nowhere left to put it
+		main := section.Course.mainCourse()
+		if section.Course.Optional {
+			kept, decided := keep[main]
+			if !decided {
+				_, present := oldSchedule[main]
+				kept = !(len(oldPlacementList) > 0 && !present && random.Float64()*100.0 < localPin)
+				kept = kept && section.Tickets > 0 && section.Count > 0
+			}
+			keep[main] = kept
+			if !kept {
+				continue
+			}
+		}
+
+		// leave it out if there is nowhere left to put it
+		if section.Tickets <= 0 || section.Count <= 0 {
+			unplaced++
+			if unplaced > maxUnplaced {
+				return nil
+			}
+			continue
+		}
+
 		// should we place this section where it was in the old schedule?
 		if oldPlacement, present := oldSchedule[section.Course]; present {
 			// we have an old placement to work with
 			if section.RoomTimes[oldPlacement.Room][oldPlacement.Time] >= 0 {
 				// its old placement is at an available time
-				if rand.Float64()*100.0 < localPin {
+				if random.Float64()*100.0 < localPin {
 					// the dice roll says we should keep it here
 					r, t = oldPlacement.Room, oldPlacement.Time
 				}
@@ -200,7 +318,21 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 			if !weightedLottery {
 				ticketMax = section.Count
 			}
-			ticket := rand.Intn(ticketMax)
+
+			// an optional section that has not been placed yet also
+			// has tickets for being dropped
+			dropTickets := 0
+			if section.Course.Optional && !placedSection[main] {
+				dropTickets = 1
+				if weightedLottery {
+					dropTickets = lotteryTickets(main.DropBadness)
+				}
+			}
+			ticket := random.Intn(ticketMax + dropTickets)
+			if ticket >= ticketMax {
+				keep[main] = false
+				continue
+			}
 		lotteryLoop:
 			for room, times := range section.RoomTimes {
 				for time, badness := range times {
@@ -208,7 +340,7 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 						continue
 					}
 					if weightedLottery {
-						ticket -= (100 - badness)
+						ticket -= lotteryTickets(badness)
 					} else {
 						ticket--
 					}
@@ -226,12 +358,13 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 			if len(section.Course.Instructors) > 1 {
 				name += ", et al"
 			}
-			log.Fatalf("search failed to find a placement for %s taught by %s",
-				section.Course.Name, name)
+			panic(fmt.Sprintf("search failed to find a placement for %s taught by %s",
+				section.Course.Name, name))
 		}
 
 		// record the placement
 		schedule = append(schedule, Placement{Course: section.Course, Room: r, Time: t})
+		placedSection[main] = true
 
 		// update all remaining unplaced sections
 		slots := section.Course.SlotsNeeded(data.Times[t])
@@ -243,6 +376,39 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 				other.BlockRoomTime(r, t+i, -1, data.Times)
 			}
 
+			// sections of a course that must not meet at the same time
+			if other.Course.Name == section.Course.Name && !other.Course.sameSection(section.Course) &&
+				data.concurrent[section.Course.Name] < 0 {
+				for room := range data.Rooms {
+					for i := 0; i < slots; i++ {
+						other.BlockRoomTime(room, t+i, -1, data.Times)
+					}
+				}
+			}
+
+			// meetings of the same section must start at the same time of
+			// day on different days
+			if other.Course.sameSection(section.Course) {
+				for time := range data.Times {
+					if !data.MeetingsAgree(t, time) {
+						for room := range data.Rooms {
+							other.BlockRoomTime(room, time, -1, data.Times)
+						}
+					}
+				}
+			}
+
+			// leave an empty slot on either side in a room that needs
+			// time to turn over between different courses
+			if data.Rooms[r].Turnover && other.Course.Name != section.Course.Name {
+				if t > 0 && data.Times[t-1].Next == data.Times[t] {
+					other.BlockRoomTime(r, t-1, -1, data.Times)
+				}
+				if end := t + slots - 1; end+1 < len(data.Times) && data.Times[end].Next == data.Times[end+1] {
+					other.BlockRoomTime(r, end+1, -1, data.Times)
+				}
+			}
+
 			// block out this time in all rooms for the same instructor
 			for _, thisInstructor := range section.Course.Instructors {
 				for _, otherInstructor := range other.Course.Instructors {
@@ -257,7 +423,7 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 			}
 
 			// update badness in all rooms at this time for sections with conflicts
-			if badness, present := section.Course.Conflicts[other.Course]; present {
+			if badness := section.Course.ConflictBadness[other.Course.ID]; badness != NoConflict {
 				for room := range data.Rooms {
 					for i := 0; i < slots; i++ {
 						other.BlockRoomTime(room, t+i, badness, data.Times)
@@ -265,9 +431,23 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 				}
 			}
 
-			// did this make the schedule impossible?
-			if other.Tickets <= 0 || other.Count <= 0 {
-				if verbose {
+			// and for sections whose instructors should be kept apart
+			if section.Course.ApartBadness != nil {
+				if badness := section.Course.ApartBadness[other.Course.ID]; badness != NoConflict {
+					for room := range data.Rooms {
+						for i := 0; i < slots; i++ {
+							other.BlockRoomTime(room, t+i, badness, data.Times)
+						}
+					}
+				}
+			}
+
+			// did this make the schedule impossible? (a partial schedule
+			// leaves the other section out when its turn comes, as it
+			// does an optional section that has not been placed yet)
+			if maxUnplaced == 0 && (other.Tickets <= 0 || other.Count <= 0) &&
+				!(other.Course.Optional && !placedSection[other.Course.mainCourse()]) {
+				if verbose != nil {
 					thisName := section.Course.Instructors[0].Name
 					if len(section.Course.Instructors) > 1 {
 						thisName += ", et al"
@@ -276,7 +456,7 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 					if len(other.Course.Instructors) > 1 {
 						otherName += ", et al"
 					}
-					log.Printf("placing %s %s at %s in %s made placing %s %s impossible",
+					verbose.Printf("placing %s %s at %s in %s made placing %s %s impossible",
 						thisName, section.Course.Name,
 						data.Times[t].Name, data.Rooms[r].Name,
 						otherName, other.Course.Name)
@@ -295,13 +475,9 @@ func (data *InputData) PlaceSections(readOnlySectionList []*Section, oldPlacemen
 }
 
 func (section *Section) BlockRoomTime(r, t, badness int, times []*Time) {
-	slots := section.Course.SlotsNeeded(times[t])
-
-	// hack alert: for studio classes (the only kind that need multiple slots)
-	// assume 3 is the max needed
-	if slots == 2 {
-		slots = 3
-	}
+	// a course with a slot pattern may need more slots starting at an
+	// earlier time than it would starting at this one
+	slots := section.Course.maxSlots(times[t])
 
 	for i := 0; i < slots && t-i >= 0; i++ {
 		if i > 0 && times[t-i].Next != times[t-i+1] {
@@ -318,10 +494,10 @@ func (section *Section) BlockRoomTime(r, t, badness int, times []*Time) {
 		old := section.RoomTimes[r][t-i]
 		if old >= 0 && (badness < 0 || badness > old) {
 			section.RoomTimes[r][t-i] = badness
-			section.Tickets -= 100 - old
+			section.Tickets -= lotteryTickets(old)
 			section.Count--
 			if badness >= 0 {
-				section.Tickets += 100 - badness
+				section.Tickets += lotteryTickets(badness)
 				section.Count++
 			}
 		}
@@ -329,9 +505,25 @@ func (section *Section) BlockRoomTime(r, t, badness int, times []*Time) {
 }
 
 func (data *InputData) MakeGrid(placements []Placement) [][]Cell {
-	roomTimes := make([][]Cell, len(data.Rooms))
-	for i := range roomTimes {
-		roomTimes[i] = make([]Cell, len(data.Times))
+	return data.FillGrid(nil, placements)
+}
+
+// FillGrid is the same as MakeGrid, but it clears and reuses roomTimes
+// if it is the right size instead of allocating a new grid. Placements
+// from outside the search must be checked with CheckPlacements first
+// (ReadJSON does this), since overlapping placements are a bug here.
+func (data *InputData) FillGrid(roomTimes [][]Cell, placements []Placement) [][]Cell {
+	if len(roomTimes) == len(data.Rooms) && (len(roomTimes) == 0 || len(roomTimes[0]) == len(data.Times)) {
+		for _, cells := range roomTimes {
+			for i := range cells {
+				cells[i] = Cell{}
+			}
+		}
+	} else {
+		roomTimes = make([][]Cell, len(data.Rooms))
+		for i := range roomTimes {
+			roomTimes[i] = make([]Cell, len(data.Times))
+		}
 	}
 
 	for _, placement := range placements {
@@ -347,10 +539,10 @@ func (data *InputData) MakeGrid(placements []Placement) [][]Cell {
 				if len(otherCourse.Instructors) > 1 {
 					otherName += ", et al"
 				}
-				log.Fatalf("%s %s cannot be scheduled at %s in %s because that slot is already used by %s %s",
+				panic(fmt.Sprintf("%s %s cannot be scheduled at %s in %s because that slot is already used by %s %s",
 					thisName, placement.Course.Name,
 					data.Times[placement.Time].Name, data.Rooms[placement.Room].Name,
-					otherName, otherCourse.Name)
+					otherName, otherCourse.Name))
 			}
 			roomTimes[placement.Room][placement.Time+i].Course = placement.Course
 			if i > 0 {
@@ -362,7 +554,81 @@ func (data *InputData) MakeGrid(placements []Placement) [][]Cell {
 	return roomTimes
 }
 
-func (data *InputData) SearchSwaps(sections []*Section, baseline Schedule, maxDepth int, placementIndex int) Schedule {
+// A SwapTask is one unit of work for a parallel swap search:
+// displace the placement at PlacementIndex and move it first to
+// the given room and time, then search everything below that.
+type SwapTask struct {
+	PlacementIndex int
+	Room           int
+	Time           int
+}
+
+// SwapTasks splits a swap search into independent tasks at the first
+// branching level, one per legal room/time for each displaced placement.
+// Tasks are ordered with the earliest placements first, since those
+// have the most placements after them to swap with and tend to be
+// the most expensive.
+func (data *InputData) SwapTasks(sections []*Section, baseline Schedule) []SwapTask {
+	courseToSection := make(map[*Course]*Section)
+	for _, section := range sections {
+		courseToSection[section.Course] = section
+	}
+
+	var tasks []SwapTask
+	for i, placement := range baseline.Placements {
+		section := courseToSection[placement.Course]
+		for r, times := range section.RoomTimes {
+			for t, badness := range times {
+				if badness < 0 || r == placement.Room && t == placement.Time {
+					continue
+				}
+				tasks = append(tasks, SwapTask{PlacementIndex: i, Room: r, Time: t})
+			}
+		}
+	}
+	return tasks
+}
+
+// BestSwaps looks for the best schedule reachable from baseline by up
+// to maxDepth swaps, working through the swap tasks one at a time
+// until they run out or ctx is done. It also reports whether every
+// task was searched in full. The schedule it returns has no placements
+// if nothing better than baseline was found.
+func (data *InputData) BestSwaps(ctx context.Context, sections []*Section, baseline Schedule, maxDepth int) (Schedule, bool) {
+	scratch := new(ScoreScratch)
+	best := Schedule{Badness: Impossible}
+	for _, task := range data.SwapTasks(sections, baseline) {
+		found := data.SearchSwaps(ctx, sections, baseline, maxDepth, task, scratch)
+		if len(found.Placements) > 0 && found.Badness < best.Badness {
+			best = found
+		}
+		if expired(ctx) {
+			return best, false
+		}
+	}
+	return best, true
+}
+
+// expired reports whether ctx is done or its deadline has passed. The
+// deadline is checked directly because a search running on the only
+// thread (as in the browser) never gives the timer a chance to fire.
+func expired(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// SearchSwaps looks for the best schedule reachable from baseline by
+// performing the first move given in task followed by up to maxDepth
+// additional swaps. scratch is used for scoring and may be reused by
+// the caller between searches, but not shared between goroutines. If
+// ctx is done or its deadline passes, it stops early and returns the
+// best schedule found so far.
+func (data *InputData) SearchSwaps(ctx context.Context, sections []*Section, baseline Schedule, maxDepth int, task SwapTask, scratch *ScoreScratch) Schedule {
+	placementIndex := task.PlacementIndex
+
 	// clone the schedule so we can modify it as we search
 	working := baseline.Clone()
 	best := Schedule{Badness: Impossible}
@@ -404,17 +670,27 @@ func (data *InputData) SearchSwaps(sections []*Section, baseline Schedule, maxDe
 	// it returns with, working, displaced, and replaced are restored to
 	// the state they were in when it was called
 	// best will have a clone of any improved schedule it finds
+	// the deadline is checked every so many steps, since checking the
+	// clock at every one would slow the search down
+	steps, stopped := 0, false
 	var search func(int)
 	search = func(depth int) {
+		if stopped {
+			return
+		}
+		if steps++; steps%1024 == 0 && expired(ctx) {
+			stopped = true
+			return
+		}
+
 		// base case: successful search
 		if len(displaced) == 0 {
 			// score it
-			scored := data.Score(working.Placements)
+			scored := data.ScoreWith(scratch, working.Placements)
 
 			// if we have a new best, clone the schedule and keep it
 			if scored.Badness < working.Badness && scored.Badness < best.Badness {
 				best = scored.Clone()
-				//log.Printf("found a %d-swap improvement with score %d", depth, scored.Badness)
 			}
 
 			// continue swapping if there is still some depth left
@@ -458,6 +734,11 @@ func (data *InputData) SearchSwaps(sections []*Section, baseline Schedule, maxDe
 					continue
 				}
 
+				// the first move is fixed by the task
+				if depth == 0 && (r != task.Room || t != task.Time) {
+					continue
+				}
+
 				// which sections are in the way?
 				var inTheWay []Placement
 				slots := course.SlotsNeeded(data.Times[t])
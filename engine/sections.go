@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// DefaultConcurrentBadness is the badness of two sections of the same
+// course meeting at the same time when no concurrent: line applies
+const DefaultConcurrentBadness = 40
+
+// A Concurrent sets the badness of two sections of the same course
+// meeting at the same time, for the listed courses or (if there are
+// none) for every course. A line that names a course beats one that
+// does not.
+type Concurrent struct {
+	Badness int
+	Courses []string
+}
+
+// ParseConcurrent reads a badness for sections of the same course
+// meeting at the same time
+func (data *InputData) ParseConcurrent(fields []string, ignore map[string]struct{}) error {
+	if len(fields) < 2 {
+		return lineError(KindSyntax, "expected %q", "concurrent: badness [course ...]")
+	}
+
+	badness, err := parseBadnessValue(fields[1])
+	if err != nil {
+		return fieldError(KindBadness, fields[1], "badness of concurrent sections %v", err)
+	}
+
+	concurrent := Concurrent{Badness: badness}
+	repeat := make(map[string]bool)
+	for _, tag := range fields[2:] {
+		if isIgnored(ignore, tag) {
+			continue
+		}
+		if repeat[tag] {
+			return fieldError(KindDuplicate, tag, "course repeated")
+		}
+		repeat[tag] = true
+		found := false
+		for _, instructor := range data.Instructors {
+			for _, course := range instructor.Courses {
+				if course.Name == tag {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return fieldError(KindUnresolved, tag, "course not found in concurrent: line")
+		}
+		concurrent.Courses = append(concurrent.Courses, tag)
+	}
+
+	// every course named was ignored
+	if len(fields) > 2 && len(concurrent.Courses) == 0 {
+		return nil
+	}
+
+	data.Concurrents = append(data.Concurrents, concurrent)
+	return nil
+}
+
+// concurrentBadness gives the badness of two sections of each course
+// meeting at the same time, which is -1 if they cannot
+func (data *InputData) concurrentBadness() map[string]int {
+	out := make(map[string]int)
+	for _, course := range data.Courses {
+		if _, present := out[course.Name]; present {
+			continue
+		}
+
+		// lines naming the course beat lines for every course, and
+		// the worst badness applies among them
+		badness := DefaultConcurrentBadness
+		for _, named := range []bool{true, false} {
+			found := false
+			for _, concurrent := range data.Concurrents {
+				if named != (len(concurrent.Courses) > 0) {
+					continue
+				}
+				applies := !named
+				for _, name := range concurrent.Courses {
+					if name == course.Name {
+						applies = true
+					}
+				}
+				if applies && (!found || concurrent.Badness < 0 || (badness >= 0 && concurrent.Badness > badness)) {
+					badness, found = concurrent.Badness, true
+				}
+			}
+			if found {
+				break
+			}
+		}
+		out[course.Name] = badness
+	}
+	return out
+}
+
+// parseSectionNumber reads the number in a section: tag
+func parseSectionNumber(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > 99 {
+		return 0, fmt.Errorf("section number must be between 1 and 99")
+	}
+	return n, nil
+}
+
+// checkSection makes sure no other section of the course already has
+// the course's section number
+func (data *InputData) checkSection(course *Course) error {
+	for _, instructor := range data.Instructors {
+		for _, other := range instructor.Courses {
+			if other != course && other.Main == nil && other.fixedSection &&
+				other.Name == course.Name && other.Section == course.Section {
+				return fmt.Errorf("%s already has a section %02d", course.Name, course.Section)
+			}
+		}
+	}
+	return nil
+}
+
+// numberSections numbers the sections of each course from 1 in input
+// order, skipping the numbers that sections give for themselves.
+// Extra meetings share the number of their section.
+func (data *InputData) numberSections() {
+	used := make(map[string]map[int]bool)
+	for _, course := range data.Courses {
+		if course.Main == nil && course.fixedSection {
+			if used[course.Name] == nil {
+				used[course.Name] = make(map[int]bool)
+			}
+			used[course.Name][course.Section] = true
+		}
+	}
+	next := make(map[string]int)
+	for _, course := range data.Courses {
+		if course.Main != nil || course.fixedSection {
+			continue
+		}
+		n := next[course.Name] + 1
+		for used[course.Name][n] {
+			n++
+		}
+		course.Section = n
+		next[course.Name] = n
+	}
+	for _, course := range data.Courses {
+		if course.Main != nil {
+			course.Section = course.Main.Section
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A DaySlots gives the number of consecutive slots a course needs when
+// it meets on the given days (the letters before the first digit of a
+// time name, e.g., MWF). Days of "*" stands for any days not listed.
+type DaySlots struct {
+	Days  string
+	Slots int
+}
+
+// studioSlots is the pattern for a studio course: 3 slots on MWF and
+// 2 on MW or TR
+var studioSlots = []DaySlots{{Days: "MWF", Slots: 3}, {Days: "MW", Slots: 2}, {Days: "TR", Slots: 2}}
+
+// parseSlotPattern reads a list like MWF=3,TR=2,MW=2
+func parseSlotPattern(s string) ([]DaySlots, error) {
+	var pattern []DaySlots
+	for _, elt := range strings.Split(s, ",") {
+		eq := strings.Index(elt, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("expected days=slots but found %q", elt)
+		}
+		days := strings.ToUpper(elt[:eq])
+		if days != "*" && strings.Trim(days, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return nil, fmt.Errorf("days %q must be letters or *", elt[:eq])
+		}
+		slots, err := strconv.Atoi(elt[eq+1:])
+		if err != nil || slots < 1 {
+			return nil, fmt.Errorf("slots for %s must be a positive number", days)
+		}
+		for _, other := range pattern {
+			if other.Days == days {
+				return nil, fmt.Errorf("days %s repeated", days)
+			}
+		}
+		pattern = append(pattern, DaySlots{Days: days, Slots: slots})
+	}
+	return pattern, nil
+}
+
+// formatSlotPattern writes a pattern as parseSlotPattern reads it
+func formatSlotPattern(pattern []DaySlots) string {
+	var out []string
+	for _, elt := range pattern {
+		out = append(out, fmt.Sprintf("%s=%d", elt.Days, elt.Slots))
+	}
+	return strings.Join(out, ",")
+}
+
+// slotPatternMap gives a pattern as a map from days to slots, or nil
+func slotPatternMap(pattern []DaySlots) map[string]int {
+	if len(pattern) == 0 {
+		return nil
+	}
+	out := make(map[string]int)
+	for _, elt := range pattern {
+		out[elt.Days] = elt.Slots
+	}
+	return out
+}
+
+// slotPatternFromMap is the reverse of slotPatternMap, with the days
+// sorted and * last
+func slotPatternFromMap(m map[string]int) ([]DaySlots, error) {
+	var days []string
+	for key := range m {
+		days = append(days, key)
+	}
+	sort.Slice(days, func(a, b int) bool {
+		if days[a] == "*" || days[b] == "*" {
+			return days[b] == "*" && days[a] != "*"
+		}
+		return days[a] < days[b]
+	})
+	var out []string
+	for _, key := range days {
+		out = append(out, fmt.Sprintf("%s=%d", key, m[key]))
+	}
+	return parseSlotPattern(strings.Join(out, ","))
+}
+
+// isStudio reports whether a pattern is the studio pattern
+func isStudio(pattern []DaySlots) bool {
+	return formatSlotPattern(pattern) == formatSlotPattern(studioSlots)
+}
+
+// daySlots finds the entry in the course's slot pattern for a time, or
+// false if the course has a pattern that does not include its days
+func (c *Course) daySlots(t *Time) (int, bool) {
+	days, _ := t.DaysAndHour()
+	days = strings.ToUpper(days)
+	fallback, found := 0, false
+	for _, elt := range c.SlotPattern {
+		if elt.Days == days {
+			return elt.Slots, true
+		}
+		if elt.Days == "*" {
+			fallback, found = elt.Slots, true
+		}
+	}
+	return fallback, found
+}
+
+// MeetsOn reports whether the course can start at the given time as far
+// as its slot pattern is concerned
+func (c *Course) MeetsOn(t *Time) bool {
+	if len(c.SlotPattern) == 0 {
+		return true
+	}
+	_, ok := c.daySlots(t)
+	return ok
+}
+
+// maxSlots is the most slots the course could need starting at the
+// given time or earlier
+func (c *Course) maxSlots(t *Time) int {
+	slots := c.SlotsNeeded(t)
+	for _, elt := range c.SlotPattern {
+		if elt.Slots > slots {
+			slots = elt.Slots
+		}
+	}
+	return slots
+}
+
+// how many slots does this course
+// require if it starts at this time?
+func (c *Course) SlotsNeeded(t *Time) int {
+	if len(c.SlotPattern) > 0 {
+		// days outside the pattern are not allowed, but count as a
+		// single slot so a schedule that uses them can still be shown
+		if slots, ok := c.daySlots(t); ok {
+			return slots
+		}
+		return 1
+	}
+	if c.Slots < 1 {
+		return 1
+	}
+	return c.Slots
+}
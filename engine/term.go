@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Term is the calendar of a term: the first and last days of
+// classes and the days in between when the school is closed. Dates
+// are midnight UTC.
+type Term struct {
+	First  time.Time
+	Last   time.Time
+	Closed []time.Time
+
+	// WeightDays makes the per-day load scoring count the meetings
+	// each day pattern actually has in the term instead of treating
+	// every day the same
+	WeightDays bool
+}
+
+// TermDates returns the input's term with its first and last days
+// replaced, keeping its closed days, or a term with no closed days if
+// the input does not give one
+func (data *InputData) TermDates(first, last time.Time) *Term {
+	term := &Term{First: first, Last: last}
+	if data.Term != nil {
+		term.Closed = data.Term.Closed
+		term.WeightDays = data.Term.WeightDays
+	}
+	return term
+}
+
+// IsClosed reports whether classes are cancelled on the given day
+func (term *Term) IsClosed(day time.Time) bool {
+	i := sort.Search(len(term.Closed), func(i int) bool { return !term.Closed[i].Before(day) })
+	return i < len(term.Closed) && term.Closed[i].Equal(day)
+}
+
+// termWeekdays returns the days of the week given by the day letters in
+// a time name (e.g., MWF), skipping anything else, and false if there
+// are none
+func termWeekdays(days string) (map[time.Weekday]bool, bool) {
+	meets := make(map[time.Weekday]bool)
+	for _, letter := range strings.ToUpper(days) {
+		if day, present := icalDays[letter]; present {
+			meets[day.Weekday] = true
+		}
+	}
+	return meets, len(meets) > 0
+}
+
+// MeetingDates lists the days in the term that fall on the given day
+// letters (e.g., MWF) and are not closed
+func (term *Term) MeetingDates(days string) []time.Time {
+	meets, ok := termWeekdays(days)
+	if !ok {
+		return nil
+	}
+	var dates []time.Time
+	for date := term.First; !date.After(term.Last); date = date.AddDate(0, 0, 1) {
+		if meets[date.Weekday()] && !term.IsClosed(date) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// ClosedDates lists the closed days in the term that fall on the given
+// day letters, i.e., the meetings that are cancelled
+func (term *Term) ClosedDates(days string) []time.Time {
+	meets, ok := termWeekdays(days)
+	if !ok {
+		return nil
+	}
+	var dates []time.Time
+	for _, date := range term.Closed {
+		if meets[date.Weekday()] && !date.Before(term.First) && !date.After(term.Last) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// parseDate reads a date in YYYY-MM-DD form
+func parseDate(s string) (time.Time, error) {
+	return time.Parse("2006-01-02", s)
+}
+
+// ParseTerm reads the first and last days of classes, and whether the
+// per-day load scoring should weight days by their meetings
+func (data *InputData) ParseTerm(fields []string) error {
+	if len(fields) < 3 || len(fields) > 4 {
+		return lineError(KindSyntax, "expected %q", "term: first-day last-day [weighted]")
+	}
+	if data.Term != nil {
+		return lineError(KindDuplicate, "found a second term: line")
+	}
+	first, err := parseDate(fields[1])
+	if err != nil {
+		return fieldError(KindDate, fields[1], "first day %q must be in YYYY-MM-DD form", fields[1])
+	}
+	last, err := parseDate(fields[2])
+	if err != nil {
+		return fieldError(KindDate, fields[2], "last day %q must be in YYYY-MM-DD form", fields[2])
+	}
+	if last.Before(first) {
+		return fieldError(KindDate, fields[2], "the last day %s is before the first day %s", fields[2], fields[1])
+	}
+	term := &Term{First: first, Last: last}
+	if len(fields) == 4 {
+		if fields[3] != "weighted" {
+			return fieldError(KindSyntax, fields[3], "expected %q", "weighted")
+		}
+		term.WeightDays = true
+	}
+	data.Term = term
+	return nil
+}
+
+// ParseClosed reads a list of closed days, each a date or a range of
+// dates of the form first/last
+func (data *InputData) ParseClosed(fields []string) error {
+	if len(fields) < 2 {
+		return lineError(KindSyntax, "expected %q", "closed: date date/date ...")
+	}
+	if data.Term == nil {
+		return lineError(KindOrder, "closed: must come after term:")
+	}
+	term := data.Term
+	for _, field := range fields[1:] {
+		from, to := field, field
+		if slash := strings.Index(field, "/"); slash >= 0 {
+			from, to = field[:slash], field[slash+1:]
+		}
+		first, err := parseDate(from)
+		if err != nil {
+			return fieldError(KindDate, field, "closed day %q must be in YYYY-MM-DD form", from)
+		}
+		last, err := parseDate(to)
+		if err != nil {
+			return fieldError(KindDate, field, "closed day %q must be in YYYY-MM-DD form", to)
+		}
+		if last.Before(first) {
+			return fieldError(KindDate, field, "closed range ends before it starts")
+		}
+		if first.Before(term.First) || last.After(term.Last) {
+			return fieldError(KindDate, field, "closed days must be within the term")
+		}
+		for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+			if !term.IsClosed(date) {
+				term.Closed = append(term.Closed, date)
+				sort.Slice(term.Closed, func(a, b int) bool { return term.Closed[a].Before(term.Closed[b]) })
+			}
+		}
+	}
+	return nil
+}
+
+// dayWeights gives the weight of each day (see timeDays) for the
+// per-day load scoring: the number of meetings a class on that
+// day pattern has in the term, relative to the average over all day
+// patterns. It returns nil unless the term asks for weighted days.
+func (data *InputData) dayWeights() map[string]float64 {
+	if data.Term == nil || !data.Term.WeightDays {
+		return nil
+	}
+
+	// only days shared by several times count
+	timesPerDay := make(map[string]int)
+	for _, day := range data.timeDay {
+		if day != "" {
+			timesPerDay[day]++
+		}
+	}
+	counts := make(map[string]int)
+	for day, n := range timesPerDay {
+		if n > 1 {
+			counts[day] = len(data.Term.MeetingDates(data.dayLetters(day)))
+		}
+	}
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return nil
+	}
+	average := float64(total) / float64(len(counts))
+	weights := make(map[string]float64)
+	for day, count := range counts {
+		weights[day] = float64(count) / average
+	}
+	return weights
+}
+
+// formatDates writes closed days as a closed: line would, merging runs
+// of consecutive days into ranges
+func formatDates(dates []time.Time) []string {
+	var out []string
+	for i := 0; i < len(dates); {
+		j := i
+		for j+1 < len(dates) && dates[j+1].Equal(dates[j].AddDate(0, 0, 1)) {
+			j++
+		}
+		if i == j {
+			out = append(out, dates[i].Format("2006-01-02"))
+		} else {
+			out = append(out, fmt.Sprintf("%s/%s", dates[i].Format("2006-01-02"), dates[j].Format("2006-01-02")))
+		}
+		i = j + 1
+	}
+	return out
+}
@@ -0,0 +1,288 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// A Linkage ties together the inputs for the terms of a year, e.g.,
+// fall and spring, so each can be scheduled with an eye on the others.
+// Terms lists the file prefixes of the inputs in order.
+type Linkage struct {
+	Terms     []string
+	Patterns  []PatternLink
+	Sequences []SequenceLink
+}
+
+// A PatternLink asks instructors to teach on the same days from one
+// term to the next. Each section that meets on days its instructor
+// does not teach on in a neighboring term adds the Badness. With no
+// Instructors, it applies to everyone. Line is the line of the linkage
+// file that gives it.
+type PatternLink struct {
+	Badness     int
+	Instructors []string
+	Line        int
+}
+
+// A SequenceLink asks for the sections of Second, taught in the term
+// after First as the second half of a year-long sequence, to start at
+// the same time of day as a section of First. Each section of either
+// course with no partner at its time of day adds the Badness.
+type SequenceLink struct {
+	Badness int
+	First   string
+	Second  string
+	Line    int
+
+	// the term First is taught in, found by Check
+	term int
+}
+
+// ParseLinkage reads a linkage file, which has lines of the form
+//
+//	terms: fall spring
+//	pattern: badness [instructor ...]
+//	sequence: badness first-course second-course
+//
+// The terms line is required and names the prefix of each term's
+// input, in order.
+func ParseLinkage(filename string, lines [][]string) (*Linkage, error) {
+	link := new(Linkage)
+	var errs ParseErrors
+	for linenumber, line := range lines {
+		fields := inputFields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "terms:":
+			switch {
+			case link.Terms != nil:
+				err = lineError(KindDuplicate, "only one terms: line is allowed")
+			case len(fields) < 3:
+				err = lineError(KindSyntax, "expected %q", "terms: first-term second-term ...")
+			default:
+				link.Terms = fields[1:]
+			}
+
+		case "pattern:":
+			var badness int
+			if len(fields) < 2 {
+				err = lineError(KindSyntax, "expected %q", "pattern: badness [instructor ...]")
+			} else if badness, err = parseLinkBadness(fields[1]); err == nil {
+				link.Patterns = append(link.Patterns, PatternLink{Badness: badness, Instructors: fields[2:], Line: linenumber + 1})
+			}
+
+		case "sequence:":
+			var badness int
+			if len(fields) != 4 {
+				err = lineError(KindSyntax, "expected %q", "sequence: badness first-course second-course")
+			} else if badness, err = parseLinkBadness(fields[1]); err == nil {
+				link.Sequences = append(link.Sequences, SequenceLink{Badness: badness, First: fields[2], Second: fields[3], Line: linenumber + 1})
+			}
+
+		default:
+			err = fieldError(KindSyntax, fields[0], "unknown line type %q", fields[0])
+		}
+		if err != nil {
+			errs.add(filename, lines, linenumber+1, err)
+		}
+	}
+	if link.Terms == nil {
+		errs.add(filename, lines, 0, lineError(KindSyntax, "no terms: line found"))
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return link, nil
+}
+
+// parseLinkBadness reads the badness of a linkage line, which is
+// always soft
+func parseLinkBadness(s string) (int, error) {
+	badness, err := strconv.Atoi(s)
+	if err != nil || badness < 0 || badness > MaxBadness {
+		return 0, fieldError(KindBadness, s, "badness must be a number from 0 to %d", MaxBadness)
+	}
+	return badness, nil
+}
+
+// Check makes sure the linkage makes sense for the inputs of its terms,
+// given in the same order: every instructor it names teaches in some
+// term, and the courses of each sequence are taught in back-to-back
+// terms
+func (link *Linkage) Check(terms []*InputData) error {
+	if len(terms) != len(link.Terms) {
+		return fmt.Errorf("expected %d terms but found %d", len(link.Terms), len(terms))
+	}
+	for _, pattern := range link.Patterns {
+		for _, name := range pattern.Instructors {
+			found := false
+			for _, data := range terms {
+				for _, instructor := range data.Instructors {
+					if instructor.Name == name {
+						found = true
+					}
+				}
+			}
+			if !found {
+				return fmt.Errorf("line %d: instructor %q is not in any term", pattern.Line, name)
+			}
+		}
+	}
+	for i := range link.Sequences {
+		sequence := &link.Sequences[i]
+		sequence.term = -1
+		for n := 0; n+1 < len(terms); n++ {
+			if terms[n].isCourseName(sequence.First) && terms[n+1].isCourseName(sequence.Second) {
+				sequence.term = n
+				break
+			}
+		}
+		if sequence.term < 0 {
+			return fmt.Errorf("line %d: %s and %s are not taught in back-to-back terms", sequence.Line, sequence.First, sequence.Second)
+		}
+	}
+	return nil
+}
+
+// Score finds the badness of the links between the schedules of the
+// terms, which must be in the same order as link.Terms and checked
+// with Check
+func (link *Linkage) Score(terms []*InputData, schedules []Schedule) (int, []Problem) {
+	total := 0
+	var problems []Problem
+	for n, data := range terms {
+		for _, placement := range schedules[n].Placements {
+			for _, penalty := range link.penalties(terms, schedules, n, placement.Course, data.Times[placement.Time]) {
+				total += penalty.Badness
+				penalty.Courses = []*Course{placement.Course}
+				problems = append(problems, penalty)
+			}
+		}
+	}
+	return total, problems
+}
+
+// Bias makes a copy of the input for one term with the badness of the
+// links to the current schedules of the other terms added to the
+// badness of each course's times, so that a search of the copy is
+// steered toward a schedule that fits with the others. Each section
+// keeps its section number, so sections of the copy can be matched
+// to the original by SectionID.
+func (link *Linkage) Bias(terms []*InputData, schedules []Schedule, n int) (*InputData, error) {
+	data := terms[n]
+	in, err := data.jsonInput()
+	if err != nil {
+		return nil, err
+	}
+	i := 0
+	for _, course := range data.Courses {
+		if course.Main != nil {
+			continue
+		}
+		elt := &in.Courses[i]
+		elt.Section = course.Section
+		i++
+		extra := make(map[string]int)
+		for _, time := range data.Times {
+			for _, penalty := range link.penalties(terms, schedules, n, course, time) {
+				extra[time.Name] += penalty.Badness
+			}
+		}
+		if len(extra) == 0 {
+			continue
+		}
+		if elt.Times == nil {
+			elt.Times = make(map[string]int)
+			for _, time := range data.Times {
+				elt.Times[time.Name] = 0
+			}
+		}
+		for name, badness := range elt.Times {
+			if badness >= 0 {
+				elt.Times[name] = badness + extra[name]
+				if elt.Times[name] > MaxBadness {
+					elt.Times[name] = MaxBadness
+				}
+			}
+		}
+	}
+	return in.inputData()
+}
+
+// penalties gives the problems a section of a course in term n would
+// have with the other terms if it met at the given time
+func (link *Linkage) penalties(terms []*InputData, schedules []Schedule, n int, course *Course, time *Time) []Problem {
+	var problems []Problem
+	name := link.Terms[n]
+	for _, pattern := range link.Patterns {
+		for _, instructor := range course.Instructors {
+			if len(pattern.Instructors) > 0 && !containsString(pattern.Instructors, instructor.Name) {
+				continue
+			}
+			for _, other := range []int{n - 1, n + 1} {
+				if other < 0 || other >= len(terms) {
+					continue
+				}
+				days := teachingDays(terms[other], schedules[other], instructor.Name)
+				if len(days) > 0 && !days[time.Days] {
+					problems = append(problems, Problem{
+						Message: fmt.Sprintf("term pattern: %s teaches %s on %s in %s but not in %s (badness %d)",
+							instructor.Name, course.SectionID(), time.Days, name, link.Terms[other], pattern.Badness),
+						Badness: pattern.Badness,
+					})
+				}
+			}
+		}
+	}
+	for _, sequence := range link.Sequences {
+		var other int
+		var partner string
+		switch {
+		case sequence.term == n && course.Name == sequence.First:
+			other, partner = n+1, sequence.Second
+		case sequence.term+1 == n && course.Name == sequence.Second:
+			other, partner = n-1, sequence.First
+		default:
+			continue
+		}
+		starts := courseStarts(terms[other], schedules[other], partner)
+		if len(starts) > 0 && !starts[time.Start] {
+			problems = append(problems, Problem{
+				Message: fmt.Sprintf("term sequence: %s starts at %s in %s but no section of %s does in %s (badness %d)",
+					course.SectionID(), time.Clock(), name, partner, link.Terms[other], sequence.Badness),
+				Badness: sequence.Badness,
+			})
+		}
+	}
+	return problems
+}
+
+// teachingDays gives the days of the times an instructor teaches at
+// in a schedule
+func teachingDays(data *InputData, schedule Schedule, name string) map[string]bool {
+	days := make(map[string]bool)
+	for _, placement := range schedule.Placements {
+		for _, instructor := range placement.Course.Instructors {
+			if instructor.Name == name {
+				days[data.Times[placement.Time].Days] = true
+			}
+		}
+	}
+	return days
+}
+
+// courseStarts gives the times of day the sections of a course start
+// at in a schedule
+func courseStarts(data *InputData, schedule Schedule, name string) map[int]bool {
+	starts := make(map[int]bool)
+	for _, placement := range schedule.Placements {
+		if placement.Course.Name == name {
+			starts[data.Times[placement.Time].Start] = true
+		}
+	}
+	return starts
+}
@@ -0,0 +1,108 @@
+// +build !wasm
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/russross/schedule/engine"
+)
+
+// An EventLog appends search events to a file
+type EventLog struct {
+	fp      *os.File
+	encoder *json.Encoder
+}
+
+// openEventLog opens an event log for appending, creating it if necessary
+func openEventLog(filename string) *EventLog {
+	fp, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		log.Fatalf("opening event log: %v", err)
+	}
+	return &EventLog{fp: fp, encoder: json.NewEncoder(fp)}
+}
+
+// Write appends one event. Failures are logged but do not stop the search.
+func (events *EventLog) Write(event engine.SearchEvent) {
+	if err := events.encoder.Encode(event); err != nil {
+		log.Printf("writing event log: %v", err)
+	}
+}
+
+func (events *EventLog) Close() {
+	if err := events.fp.Close(); err != nil {
+		log.Printf("closing event log: %v", err)
+	}
+}
+
+// A HistoryLog records the progress of a search at regular intervals
+// as CSV, suitable for plotting how quickly it converges
+type HistoryLog struct {
+	fp     *os.File
+	writer *csv.Writer
+	start  time.Time
+
+	// when the last periodic row was due
+	last time.Time
+}
+
+// createHistoryLog creates (or truncates) a history file and writes its header
+func createHistoryLog(filename string, start time.Time) *HistoryLog {
+	fp, err := os.Create(filename)
+	if err != nil {
+		log.Fatalf("creating history file: %v", err)
+	}
+	history := &HistoryLog{fp: fp, writer: csv.NewWriter(fp), start: start, last: start}
+	history.write([]string{"time", "elapsed", "attempts", "failed", "badness", "mode"})
+	return history
+}
+
+// Progress records a row each time the history interval passes, and
+// a final row when the search ends
+func (history *HistoryLog) Progress(progress engine.GenProgress) {
+	when := progress.Time
+	if !progress.Final {
+		if progress.Time.Sub(history.last) < historyInterval {
+			return
+		}
+		history.last = history.last.Add(historyInterval)
+		when = history.last
+	}
+	history.Write(when, progress.Attempts, progress.Failed, progress.Badness, progress.Mode)
+}
+
+// Write records one row. badness is left blank if no schedule has been found yet.
+func (history *HistoryLog) Write(now time.Time, attempts, failed, badness, mode int) {
+	score := ""
+	if badness < engine.Worst {
+		score = strconv.Itoa(badness)
+	}
+	history.write([]string{
+		now.Format(time.RFC3339),
+		strconv.FormatFloat(now.Sub(history.start).Seconds(), 'f', 1, 64),
+		strconv.Itoa(attempts),
+		strconv.Itoa(failed),
+		score,
+		engine.ModeName(mode),
+	})
+}
+
+func (history *HistoryLog) write(row []string) {
+	history.writer.Write(row)
+	history.writer.Flush()
+	if err := history.writer.Error(); err != nil {
+		log.Printf("writing history file: %v", err)
+	}
+}
+
+func (history *HistoryLog) Close() {
+	if err := history.fp.Close(); err != nil {
+		log.Printf("closing history file: %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+// Exit codes, so scripts can tell why a command failed. log.Fatalf
+// exits with ExitError for anything not covered here.
+const (
+	ExitOK          = 0
+	ExitError       = 1
+	ExitParse       = 2
+	ExitInfeasible  = 3
+	ExitOverBudget  = 4
+	ExitPartial     = 5
+	ExitBlocked     = 6
+	ExitInterrupted = 130
+)
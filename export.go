@@ -0,0 +1,182 @@
+// +build !wasm
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat = "csv"
+)
+
+func CommandExport(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	format := outputFormat(cmd, exportFormat, map[string]string{".csv": "csv", ".dot": "dot", ".gv": "dot"})
+	if sisMappingFile != "" && !cmd.Flags().Changed("format") {
+		format = "sis"
+	}
+
+	var err error
+	switch format {
+	case "csv":
+		placements := readPlacements(data, prefix+".json")
+		out := openOutput()
+		defer out.Close()
+		err = WriteCSV(data, out, placements)
+	case "sis":
+		mapping := DefaultSISMapping()
+		if sisMappingFile != "" {
+			if mapping, err = ReadSISMapping(sisMappingFile); err != nil {
+				log.Fatalf("reading SIS mapping: %v", err)
+			}
+		}
+		placements := readPlacements(data, prefix+".json")
+		out := openOutput()
+		defer out.Close()
+		err = WriteSIS(data, out, placements, mapping)
+	case "25live":
+		placements := readPlacements(data, prefix+".json")
+		out := openOutput()
+		defer out.Close()
+		err = WriteRoomBooking(data, out, placements)
+	case "dot":
+		// the conflict graph only depends on the input
+		out := openOutput()
+		defer out.Close()
+		err = WriteDot(data, out)
+	default:
+		log.Fatalf("unknown export format %q", format)
+	}
+	if err != nil {
+		log.Fatalf("exporting: %v", err)
+	}
+}
+
+// WriteCSV writes one row per placed course with its section number,
+// instructors, room, days, and start time, in the order the courses
+// appear in the input.
+func WriteCSV(data *engine.InputData, w io.Writer, placements []engine.Placement) error {
+	p := make(map[*engine.Course]engine.Placement)
+	for _, placement := range placements {
+		p[placement.Course] = placement
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"course", "section", "instructor", "room", "days", "time"}); err != nil {
+		return err
+	}
+	for _, course := range data.Courses {
+		placement, present := p[course]
+		if !present {
+			continue
+		}
+		var instructors []string
+		for _, instructor := range course.Instructors {
+			instructors = append(instructors, instructor.Name)
+		}
+		days, hour := data.Times[placement.Time].DaysAndHour()
+		record := []string{
+			course.Name,
+			fmt.Sprintf("%02d", course.Section),
+			strings.Join(instructors, ", "),
+			data.Rooms[placement.Room].Name,
+			days,
+			hour,
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteRoomBooking writes one row per placed course in the CSV layout
+// that campus room reservation systems such as 25Live import: the
+// event name, the room, and a weekly recurring day/time pattern.
+func WriteRoomBooking(data *engine.InputData, w io.Writer, placements []engine.Placement) error {
+	p := make(map[*engine.Course]engine.Placement)
+	for _, placement := range placements {
+		p[placement.Course] = placement
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"Event Name", "Space", "Recurrence", "Days", "Start Time", "End Time"}); err != nil {
+		return err
+	}
+	minutes := engine.DefaultMeetingMinutes()
+	for _, course := range data.Courses {
+		placement, present := p[course]
+		if !present {
+			continue
+		}
+		days, begin, end, err := data.MeetingTimes(placement, minutes)
+		if err != nil {
+			return err
+		}
+		record := []string{
+			course.SectionID(),
+			data.Rooms[placement.Room].Name,
+			"Weekly",
+			weekdayNames(days),
+			clockTime(begin),
+			clockTime(end),
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// weekdayNames spells out single-letter days, e.g., TR => Tue Thu
+func weekdayNames(days string) string {
+	names := map[rune]string{
+		'M': "Mon", 'T': "Tue", 'W': "Wed", 'R': "Thu", 'F': "Fri", 'S': "Sat", 'U': "Sun",
+	}
+	var lst []string
+	for _, day := range strings.ToUpper(days) {
+		name, present := names[day]
+		if !present {
+			return days
+		}
+		lst = append(lst, name)
+	}
+	return strings.Join(lst, " ")
+}
+
+// clockTime converts a 24-hour HHMM time to 12-hour form, e.g.,
+// 1330 => 1:30 PM. Anything else is returned unchanged.
+func clockTime(hhmm string) string {
+	n, err := strconv.Atoi(hhmm)
+	if len(hhmm) != 4 || err != nil {
+		return hhmm
+	}
+	hour, minute := n/100, n%100
+	suffix := "AM"
+	if hour >= 12 {
+		suffix = "PM"
+	}
+	if hour > 12 {
+		hour -= 12
+	} else if hour == 0 {
+		hour = 12
+	}
+	return fmt.Sprintf("%d:%02d %s", hour, minute, suffix)
+}
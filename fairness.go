@@ -0,0 +1,130 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+// CommandFairness reports, for each instructor, how many of their
+// stated preferences each schedule honors and what share of its
+// badness falls on them. Each argument is either a .json schedule for
+// the <prefix>.txt input or the prefix of another input and schedule,
+// e.g., last year's; with none, the current schedule is used.
+func CommandFairness(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		args = []string{prefix + ".json"}
+	}
+
+	// score each schedule against its own input
+	var names []string
+	var reports [][]engine.Fairness
+	var badness []int
+	var current *engine.InputData
+	savedPrefix := prefix
+	for _, arg := range args {
+		var data *engine.InputData
+		filename := arg
+		if strings.HasSuffix(arg, ".json") {
+			if current == nil {
+				current = readInputData()
+			}
+			data = current
+		} else {
+			prefix = arg
+			data = readInputData()
+			prefix = savedPrefix
+			filename = arg + ".json"
+		}
+		schedule := data.Score(readPlacements(data, filename))
+		names = append(names, strings.TrimSuffix(arg, ".json"))
+		reports = append(reports, data.Fairness(schedule))
+		badness = append(badness, schedule.Badness)
+	}
+
+	// instructors in the order they first appear
+	var instructors []string
+	for _, report := range reports {
+		for _, elt := range report {
+			if !containsName(instructors, elt.Instructor.Name) {
+				instructors = append(instructors, elt.Instructor.Name)
+			}
+		}
+	}
+	if len(reports) > 1 {
+		names = append(names, "all")
+	}
+
+	nameLen := len("Instructor")
+	for _, name := range instructors {
+		if displayWidth(name) > nameLen {
+			nameLen = displayWidth(name)
+		}
+	}
+	header := fmt.Sprintf("%11s  %5s", "honored", "share")
+	colLen := len(header)
+	for _, name := range names {
+		if displayWidth(name) > colLen {
+			colLen = displayWidth(name)
+		}
+	}
+
+	out := openOutput()
+	defer out.Close()
+	fmt.Fprintf(out, "%-*s", nameLen, "")
+	for _, name := range names {
+		fmt.Fprintf(out, "  %s", padLeft(name, colLen))
+	}
+	fmt.Fprintf(out, "\n%-*s", nameLen, "Instructor")
+	for range names {
+		fmt.Fprintf(out, "  %*s", colLen, header)
+	}
+	fmt.Fprintln(out)
+	for _, name := range instructors {
+		fmt.Fprint(out, padRight(name, nameLen))
+		var all engine.Fairness
+		allBadness := 0
+		for n, report := range reports {
+			found := false
+			for _, elt := range report {
+				if elt.Instructor.Name == name {
+					fmt.Fprintf(out, "  %*s", colLen, fairnessCell(elt, badness[n]))
+					all.Stated += elt.Stated
+					all.Honored += elt.Honored
+					all.Badness += elt.Badness
+					allBadness += badness[n]
+					found = true
+				}
+			}
+			if !found {
+				fmt.Fprintf(out, "  %*s", colLen, "-")
+			}
+		}
+		if len(reports) > 1 {
+			fmt.Fprintf(out, "  %*s", colLen, fairnessCell(all, allBadness))
+		}
+		fmt.Fprintln(out)
+	}
+	if len(instructors) == 0 {
+		log.Printf("no instructors found")
+	}
+}
+
+// fairnessCell gives the share of preferences honored and the share of
+// the total badness for one instructor
+func fairnessCell(elt engine.Fairness, total int) string {
+	honored := "-"
+	if elt.Stated > 0 {
+		honored = fmt.Sprintf("%d/%d %3d%%", elt.Honored, elt.Stated, elt.Honored*100/elt.Stated)
+	}
+	share := "-"
+	if total > 0 {
+		share = fmt.Sprintf("%d%%", elt.Badness*100/total)
+	}
+	return fmt.Sprintf("%11s  %5s", honored, share)
+}
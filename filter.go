@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/russross/schedule/engine"
+)
+
+// A reportFilter narrows a report down to some instructors, courses,
+// or rooms. A section is shown if it matches at least one name of each
+// kind that is given, so --instructor and --room together show that
+// instructor's sections in that room.
+type reportFilter struct {
+	Instructors []string
+	Courses     []string
+	Rooms       []string
+}
+
+// the filter given on the command line
+var filter reportFilter
+
+func (f reportFilter) active() bool {
+	return len(f.Instructors) > 0 || len(f.Courses) > 0 || len(f.Rooms) > 0
+}
+
+// check reports an error if the filter names an instructor, course, or
+// room that is not in the input. A course can be given by name (e.g.,
+// CS1400) or as a section (e.g., CS1400-01).
+func (f reportFilter) check(data *engine.InputData) error {
+	for _, name := range f.Instructors {
+		found := false
+		for _, instructor := range data.Instructors {
+			found = found || instructor.Name == name
+		}
+		if !found {
+			return fmt.Errorf("unknown instructor %q", name)
+		}
+	}
+	for _, name := range f.Courses {
+		found := false
+		for _, course := range data.Courses {
+			found = found || course.Name == name || course.SectionID() == name
+		}
+		if !found {
+			return fmt.Errorf("unknown course %q", name)
+		}
+	}
+	for _, name := range f.Rooms {
+		found := false
+		for _, room := range data.Rooms {
+			found = found || room.Name == name
+		}
+		if !found {
+			return fmt.Errorf("unknown room %q", name)
+		}
+	}
+	return nil
+}
+
+// course reports whether a section matches the instructor and course
+// names in the filter
+func (f reportFilter) course(c *engine.Course) bool {
+	if c.Main != nil {
+		c = c.Main
+	}
+	if len(f.Instructors) > 0 {
+		found := false
+		for _, instructor := range c.Instructors {
+			found = found || containsName(f.Instructors, instructor.Name)
+		}
+		if !found {
+			return false
+		}
+	}
+	return len(f.Courses) == 0 || containsName(f.Courses, c.Name) || containsName(f.Courses, c.SectionID())
+}
+
+// room reports whether a room matches the room names in the filter
+func (f reportFilter) room(data *engine.InputData, r int) bool {
+	return len(f.Rooms) == 0 || containsName(f.Rooms, data.Rooms[r].Name)
+}
+
+// placement reports whether a placement matches the filter
+func (f reportFilter) placement(data *engine.InputData, placement engine.Placement) bool {
+	return f.course(placement.Course) && f.room(data, placement.Room)
+}
+
+// placements gives the placements that match the filter
+func (f reportFilter) placements(data *engine.InputData, placements []engine.Placement) []engine.Placement {
+	var out []engine.Placement
+	for _, placement := range placements {
+		if f.placement(data, placement) {
+			out = append(out, placement)
+		}
+	}
+	return out
+}
+
+// schedule gives a copy of a schedule with only the sections that match
+// the filter left in the grid, and only the problems and unplaced
+// sections that involve one of them. The badness is left unchanged.
+func (f reportFilter) schedule(data *engine.InputData, schedule engine.Schedule) engine.Schedule {
+	out := engine.Schedule{Badness: schedule.Badness}
+	out.Placements = f.placements(data, schedule.Placements)
+	shown := make(map[*engine.Course]bool)
+	for _, placement := range out.Placements {
+		shown[placement.Course] = true
+	}
+	out.RoomTimes = make([][]engine.Cell, len(schedule.RoomTimes))
+	for r := range schedule.RoomTimes {
+		out.RoomTimes[r] = make([]engine.Cell, len(schedule.RoomTimes[r]))
+		for t, cell := range schedule.RoomTimes[r] {
+			if cell.Course != nil && f.room(data, r) && f.course(cell.Course) {
+				out.RoomTimes[r][t] = cell
+			}
+		}
+	}
+
+	// a section that is not placed has no room, so it only matches
+	// when no room is given
+	for _, problem := range schedule.Problems {
+		for _, course := range problem.Courses {
+			if shown[course] || len(f.Rooms) == 0 && f.course(course) {
+				out.Problems = append(out.Problems, problem)
+				break
+			}
+		}
+	}
+	for _, course := range schedule.Unplaced {
+		if len(f.Rooms) == 0 && f.course(course) {
+			out.Unplaced = append(out.Unplaced, course)
+		}
+	}
+	for _, course := range schedule.Dropped {
+		if len(f.Rooms) == 0 && f.course(course) {
+			out.Dropped = append(out.Dropped, course)
+		}
+	}
+	return out
+}
+
+func containsName(list []string, name string) bool {
+	for _, elt := range list {
+		if elt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// problemBadness adds up the badness of a list of problems
+func problemBadness(problems []engine.Problem) int {
+	total := 0
+	for _, problem := range problems {
+		total += problem.Badness
+	}
+	return total
+}
@@ -0,0 +1,68 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	gitCommit = false
+)
+
+// gitCommitFiles commits the schedule files just written to the git
+// repository that holds them. written are the files that were
+// (re)written and removed are earlier files that were deleted in
+// favor of them (when the score is part of the file name).
+// Nothing else that happens to be staged is included in the commit.
+func gitCommitFiles(badness int, written, removed []string) error {
+	if len(written) == 0 {
+		return nil
+	}
+	dir := filepath.Dir(written[0])
+	var paths []string
+	for _, name := range written {
+		paths = append(paths, filepath.Base(name))
+	}
+	if _, err := runGit(dir, append([]string{"add", "--"}, paths...)...); err != nil {
+		return err
+	}
+	for _, name := range removed {
+		// only files git knew about need their deletion committed
+		base := filepath.Base(name)
+		if _, err := runGit(dir, "ls-files", "--error-unmatch", "--", base); err != nil {
+			continue
+		}
+		if _, err := runGit(dir, "rm", "--cached", "--quiet", "--", base); err != nil {
+			return err
+		}
+		paths = append(paths, base)
+	}
+
+	msg := fmt.Sprintf("Update %s: badness %d at %s",
+		filepath.Base(written[0]), badness, time.Now().Format("2006-01-02 15:04:05"))
+	_, err := runGit(dir, append([]string{"commit", "--quiet", "-m", msg, "--"}, paths...)...)
+	return err
+}
+
+// runGit runs a git command in a directory and returns its output.
+// On failure the error includes whatever git wrote to standard error.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return string(out), nil
+}
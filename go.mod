@@ -2,4 +2,9 @@ module github.com/russross/schedule
 
 go 1.16
 
-require github.com/spf13/cobra v1.2.1
+require (
+	github.com/spf13/cobra v1.2.1
+	github.com/spf13/pflag v1.0.5
+	google.golang.org/grpc v1.41.0
+	google.golang.org/protobuf v1.27.1
+)
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+)
+
+// colors used to distinguish instructors in the conflict graph
+var graphColors = []string{
+	"lightblue", "lightpink", "palegreen", "khaki", "plum",
+	"lightsalmon", "aquamarine", "thistle", "wheat", "lightcyan",
+	"peachpuff", "lightgray", "powderblue", "mistyrose", "honeydew",
+}
+
+// WriteDot writes the course conflict graph in Graphviz DOT format.
+// There is one node per course name, filled with a color for its
+// (first) instructor. Conflicts are solid edges labeled with their
+// badness and anticonflicts are dashed edges.
+func WriteDot(data *engine.InputData, w io.Writer) error {
+	// one node per course name
+	var names []string
+	instructors := make(map[string][]string)
+	for _, course := range data.Courses {
+		if _, present := instructors[course.Name]; !present {
+			names = append(names, course.Name)
+		}
+		for _, instructor := range course.Instructors {
+			found := false
+			for _, elt := range instructors[course.Name] {
+				if elt == instructor.Name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				instructors[course.Name] = append(instructors[course.Name], instructor.Name)
+			}
+		}
+	}
+	color := make(map[string]string)
+	for i, instructor := range data.Instructors {
+		color[instructor.Name] = graphColors[i%len(graphColors)]
+	}
+
+	// collapse conflicts between sections into one edge per pair of names,
+	// keeping the worst badness
+	conflicts := make(map[engine.CoursePair]int)
+	for _, course := range data.Courses {
+		for other, badness := range course.Conflicts {
+			a, b := course.Name, other.Name
+			if a == b {
+				continue
+			}
+			if a > b {
+				a, b = b, a
+			}
+			if existing, present := conflicts[engine.CoursePair{A: a, B: b}]; !present || worseBadness(badness, existing) {
+				conflicts[engine.CoursePair{A: a, B: b}] = badness
+			}
+		}
+	}
+	anticonflicts := make(map[engine.CoursePair]int)
+	for _, anticonflict := range data.AntiConflicts {
+		for i, a := range anticonflict.Courses {
+			for _, b := range anticonflict.Courses[i+1:] {
+				if a > b {
+					a, b = b, a
+				}
+				if existing, present := anticonflicts[engine.CoursePair{A: a, B: b}]; !present || worseBadness(anticonflict.Badness, existing) {
+					anticonflicts[engine.CoursePair{A: a, B: b}] = anticonflict.Badness
+				}
+			}
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "graph conflicts {\n")
+	fmt.Fprintf(buf, "    node [style=filled];\n")
+	for _, name := range names {
+		fmt.Fprintf(buf, "    %q [label=%q, fillcolor=%q];\n",
+			name, name+"\n"+strings.Join(instructors[name], ", "), color[instructors[name][0]])
+	}
+	for _, pair := range sortedPairs(conflicts) {
+		badness := conflicts[pair]
+		fmt.Fprintf(buf, "    %q -- %q [label=%q, penwidth=%.1f];\n",
+			pair.A, pair.B, badnessLabel(badness), edgeWidth(badness))
+	}
+	for _, pair := range sortedPairs(anticonflicts) {
+		badness := anticonflicts[pair]
+		fmt.Fprintf(buf, "    %q -- %q [label=%q, penwidth=%.1f, style=dashed, color=blue];\n",
+			pair.A, pair.B, badnessLabel(badness), edgeWidth(badness))
+	}
+	fmt.Fprintf(buf, "}\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// is badness a worse than b, given that -1 is the worst possible?
+func worseBadness(a, b int) bool {
+	return a < 0 && b >= 0 || b >= 0 && a > b
+}
+
+func badnessLabel(badness int) string {
+	if badness < 0 {
+		return "hard"
+	}
+	return fmt.Sprintf("%d", badness)
+}
+
+func edgeWidth(badness int) float64 {
+	if badness < 0 || badness > 100 {
+		badness = 100
+	}
+	return 1.0 + float64(badness)/25.0
+}
+
+func sortedPairs(m map[engine.CoursePair]int) []engine.CoursePair {
+	var pairs []engine.CoursePair
+	for pair := range m {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(a, b int) bool {
+		if pairs[a].A != pairs[b].A {
+			return pairs[a].A < pairs[b].A
+		}
+		return pairs[a].B < pairs[b].B
+	})
+	return pairs
+}
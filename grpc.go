@@ -0,0 +1,177 @@
+// +build !wasm
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/russross/schedule/schedulepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var grpcAddr = ""
+
+// GRPCServer offers the JSON API over gRPC. Its searches are jobs of
+// the API server, so they count toward --maxjobs and show up in
+// /api/jobs alongside the others.
+type GRPCServer struct {
+	schedulepb.UnimplementedSchedulerServer
+	api *APIServer
+}
+
+// serveGRPC listens for gRPC requests until the program exits
+func serveGRPC(addr string, api *APIServer) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	server := grpc.NewServer()
+	schedulepb.RegisterSchedulerServer(server, &GRPCServer{api: api})
+	log.Printf("serving gRPC at %s", addr)
+	if err := server.Serve(listener); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// apiRequest converts the fields shared by every request
+func apiRequest(input, schedule string) *APIRequest {
+	req := &APIRequest{Input: input}
+	if schedule != "" {
+		req.Schedule = json.RawMessage(schedule)
+	}
+	return req
+}
+
+// scoreReply describes a scored schedule
+func scoreReply(data *engine.InputData, schedule engine.Schedule) (*schedulepb.ScoreReply, error) {
+	score, err := scoreResponse(data, schedule)
+	if err != nil {
+		return nil, err
+	}
+	out := &schedulepb.ScoreReply{
+		Badness:  int32(score.Badness),
+		Schedule: string(score.Schedule),
+	}
+	for _, problem := range score.Problems {
+		out.Problems = append(out.Problems, &schedulepb.Problem{
+			Category: problem.Category,
+			Message:  problem.Message,
+			Badness:  int32(problem.Badness),
+		})
+	}
+	return out, nil
+}
+
+func (s *GRPCServer) Parse(ctx context.Context, req *schedulepb.ParseRequest) (*schedulepb.ParseReply, error) {
+	validation := validateRequest(apiRequest(req.Input, req.Schedule))
+	out := &schedulepb.ParseReply{
+		Valid:       validation.Valid,
+		Rooms:       int32(validation.Rooms),
+		Times:       int32(validation.Times),
+		Instructors: int32(validation.Instructors),
+		Sections:    int32(validation.Sections),
+	}
+	for _, e := range validation.Errors {
+		out.Errors = append(out.Errors, &schedulepb.InputError{
+			Line:    int32(e.Line),
+			Index:   int32(e.Index),
+			Field:   e.Field,
+			Kind:    e.Kind,
+			Message: e.Message,
+		})
+	}
+	return out, nil
+}
+
+func (s *GRPCServer) Score(ctx context.Context, req *schedulepb.ScoreRequest) (*schedulepb.ScoreReply, error) {
+	data, placements, err := parseRequest(apiRequest(req.Input, req.Schedule), true)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	out, err := scoreReply(data, data.Score(placements))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+	return out, nil
+}
+
+func (s *GRPCServer) Solve(req *schedulepb.SolveRequest, stream schedulepb.Scheduler_SolveServer) error {
+	apiReq := apiRequest(req.Input, req.Schedule)
+	apiReq.Method = req.Method
+	apiReq.Time = req.Time
+	apiReq.Workers = int(req.Workers)
+	apiReq.Pin = req.Pin
+	apiReq.PinDev = req.Pindev
+	apiReq.MaxSwaps = int(req.MaxSwaps)
+	data, placements, err := parseRequest(apiReq, false)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	sections, err := data.BuildSectionList()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	settings, method, err := solveSettings(apiReq, placements)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	// the search does not wait for the client: if it falls behind,
+	// only the latest schedule is sent
+	latest := make(chan engine.Schedule, 1)
+	onBest := func(schedule engine.Schedule) {
+		schedule.Placements = append([]engine.Placement(nil), schedule.Placements...)
+		select {
+		case <-latest:
+		default:
+		}
+		latest <- schedule
+	}
+	job, done, err := s.api.startSolve(data, sections, placements, method, apiReq.MaxSwaps, settings, onBest)
+	if err != nil {
+		return status.Errorf(codes.ResourceExhausted, "%v", err)
+	}
+
+	send := func(best *engine.Schedule) error {
+		snapshot := s.api.snapshot(job)
+		progress := &schedulepb.SolveProgress{
+			Job:     snapshot.ID,
+			State:   snapshot.State,
+			Elapsed: time.Since(snapshot.Started).Seconds(),
+			Error:   snapshot.Error,
+		}
+		if best != nil && len(best.Placements) > 0 {
+			reply, err := scoreReply(data, data.Score(best.Placements))
+			if err != nil {
+				return status.Errorf(codes.Internal, "%v", err)
+			}
+			progress.Best = reply
+		}
+		return stream.Send(progress)
+	}
+	if err := send(nil); err != nil {
+		s.api.cancelJob(job)
+		return err
+	}
+	for {
+		select {
+		case schedule := <-latest:
+			if err := send(&schedule); err != nil {
+				s.api.cancelJob(job)
+				return err
+			}
+		case best := <-done:
+			return send(&best)
+		case <-stream.Context().Done():
+			s.api.cancelJob(job)
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+
+	"github.com/russross/schedule/engine"
+)
+
+// WriteHTML renders a scored schedule as a standalone web page with the
+// room/time grid, the total badness, and the list of known problems.
+// Unlike index.html it needs no other files, so it can be emailed around.
+func WriteHTML(data *engine.InputData, w io.Writer, schedule engine.Schedule) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "<!DOCTYPE html>\n")
+	fmt.Fprintf(buf, "<html lang=\"en\">\n")
+	fmt.Fprintf(buf, "<head>\n")
+	fmt.Fprintf(buf, "  <meta charset=\"utf-8\">\n")
+	fmt.Fprintf(buf, "  <title>Schedule of rooms by time (badness %d)</title>\n", schedule.Badness)
+	fmt.Fprintf(buf, "  <style>\n")
+	fmt.Fprintf(buf, "    table { border-collapse: collapse; }\n")
+	fmt.Fprintf(buf, "    table, td { border: 1px solid darkgray; }\n")
+	fmt.Fprintf(buf, "    td { padding: 0.2em 0.5em; vertical-align: top; }\n")
+	fmt.Fprintf(buf, "    td.course { background-color: #eef; }\n")
+	fmt.Fprintf(buf, "  </style>\n")
+	fmt.Fprintf(buf, "</head>\n")
+	fmt.Fprintf(buf, "<body>\n")
+	fmt.Fprintf(buf, "  <table>\n")
+
+	// header row with room names
+	fmt.Fprintf(buf, "    <tr>\n      <td>&nbsp;</td>\n")
+	for r, room := range data.Rooms {
+		if filter.room(data, r) {
+			fmt.Fprintf(buf, "      <td>%s</td>\n", html.EscapeString(room.Name))
+		}
+	}
+	fmt.Fprintf(buf, "    </tr>\n")
+
+	// one row per time slot
+	for t, time := range data.Times {
+		fmt.Fprintf(buf, "    <tr>\n      <td>%s</td>\n", html.EscapeString(timeLabel(data, t)))
+		for r := range data.Rooms {
+			cell := schedule.RoomTimes[r][t]
+			switch {
+			case !filter.room(data, r):
+				// left out by --room
+			case cell.IsSpillover:
+				// covered by the rowspan of the cell above
+			case cell.Course == nil:
+				fmt.Fprintf(buf, "      <td>&nbsp;</td>\n")
+			default:
+				rowspan := ""
+				if slots := cell.Course.SlotsNeeded(time); slots > 1 {
+					rowspan = fmt.Sprintf(" rowspan=\"%d\"", slots)
+				}
+				instructorName := cell.Course.Instructors[0].Name
+				if len(cell.Course.Instructors) > 1 {
+					instructorName += "+"
+				}
+				fmt.Fprintf(buf, "      <td class=\"course\"%s>%s<br>%s</td>\n",
+					rowspan, html.EscapeString(instructorName), html.EscapeString(cell.Course.Name))
+			}
+		}
+		fmt.Fprintf(buf, "    </tr>\n")
+	}
+	fmt.Fprintf(buf, "  </table>\n")
+
+	// score and problems
+	if filter.active() {
+		fmt.Fprintf(buf, "  <p>Total badness %d, of which %d is from the following known problems:</p>\n", schedule.Badness, problemBadness(schedule.Problems))
+	} else {
+		fmt.Fprintf(buf, "  <p>Total badness %d with the following known problems:</p>\n", schedule.Badness)
+	}
+	if byDepartment {
+		for _, group := range problemsByDepartment(data, schedule.Problems) {
+			fmt.Fprintf(buf, "  <h3>%s: badness %d from %d problem(s)</h3>\n",
+				html.EscapeString(group.Department), group.Badness, len(group.Problems))
+			writeHTMLProblems(buf, group.Problems)
+		}
+	} else {
+		writeHTMLProblems(buf, schedule.Problems)
+	}
+	fmt.Fprintf(buf, "</body>\n")
+	fmt.Fprintf(buf, "</html>\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeHTMLProblems writes a list of problems
+func writeHTMLProblems(buf *bytes.Buffer, problems []engine.Problem) {
+	fmt.Fprintf(buf, "  <ul>\n")
+	for _, problem := range problems {
+		fmt.Fprintf(buf, "    <li>%s</li>\n", html.EscapeString(problem.Message))
+	}
+	fmt.Fprintf(buf, "  </ul>\n")
+}
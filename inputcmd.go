@@ -0,0 +1,52 @@
+// +build !wasm
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+// CommandInput writes <prefix>.txt as JSON, or with an argument, reads
+// an input written as JSON and writes it in the text format
+func CommandInput(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		log.Fatalf("unknown option: %s", strings.Join(args[1:], " "))
+	}
+
+	if len(args) == 0 {
+		data := readInputData()
+		for _, expansion := range data.Expansions {
+			log.Printf("%v", expansion)
+		}
+		raw, err := json.MarshalIndent(data, "", "    ")
+		if err != nil {
+			log.Fatalf("encoding the input: %v", err)
+		}
+		out := openOutput()
+		defer out.Close()
+		out.Write(append(raw, '\n'))
+		return
+	}
+
+	raw, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	data := new(engine.InputData)
+	if err := json.Unmarshal(raw, data); err != nil {
+		log.Printf("reading %s: %v", args[0], err)
+		os.Exit(ExitParse)
+	}
+	out := openOutput()
+	defer out.Close()
+	if err := data.WriteInput(out); err != nil {
+		log.Fatalf("writing the input: %v", err)
+	}
+}
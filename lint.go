@@ -0,0 +1,33 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// CommandLint lists the constraints in <prefix>.txt that can have no
+// effect on the schedule, so they can be cleaned out of the input
+func CommandLint(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	data := readInputData()
+	findings := data.DeadConstraints()
+
+	out := openOutput()
+	defer out.Close()
+	for _, finding := range findings {
+		fmt.Fprintf(out, "%v\n", finding)
+	}
+	if len(findings) == 0 {
+		fmt.Fprintf(out, "no constraints without effect found\n")
+	} else {
+		fmt.Fprintf(out, "%d constraint(s) with little or no effect found\n", len(findings))
+	}
+}
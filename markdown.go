@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+)
+
+// WriteMarkdown renders a scored schedule as a Markdown table of rooms
+// by times followed by the total badness and the list of known problems.
+// Markdown tables cannot span rows, so the extra slots used by
+// multi-slot courses are marked as continuations.
+func WriteMarkdown(data *engine.InputData, w io.Writer, schedule engine.Schedule) error {
+	escape := strings.NewReplacer("|", "\\|", "*", "\\*", "_", "\\_")
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "| |")
+	for r, room := range data.Rooms {
+		if filter.room(data, r) {
+			fmt.Fprintf(buf, " %s |", escape.Replace(room.Name))
+		}
+	}
+	fmt.Fprintf(buf, "\n|---|")
+	for r := range data.Rooms {
+		if filter.room(data, r) {
+			fmt.Fprintf(buf, "---|")
+		}
+	}
+	fmt.Fprintf(buf, "\n")
+
+	for t := range data.Times {
+		fmt.Fprintf(buf, "| %s |", escape.Replace(timeLabel(data, t)))
+		for r := range data.Rooms {
+			cell := schedule.RoomTimes[r][t]
+			switch {
+			case !filter.room(data, r):
+				// left out by --room
+			case cell.Course == nil:
+				fmt.Fprintf(buf, " |")
+			case cell.IsSpillover:
+				fmt.Fprintf(buf, " *(cont.)* |")
+			default:
+				instructorName := cell.Course.Instructors[0].Name
+				if len(cell.Course.Instructors) > 1 {
+					instructorName += "+"
+				}
+				fmt.Fprintf(buf, " %s<br>%s |", escape.Replace(instructorName), escape.Replace(cell.Course.Name))
+			}
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+
+	if filter.active() {
+		fmt.Fprintf(buf, "\nTotal badness %d, of which %d is from the following known problems:\n\n", schedule.Badness, problemBadness(schedule.Problems))
+	} else {
+		fmt.Fprintf(buf, "\nTotal badness %d with the following known problems:\n\n", schedule.Badness)
+	}
+	if byDepartment {
+		for _, group := range problemsByDepartment(data, schedule.Problems) {
+			fmt.Fprintf(buf, "### %s: badness %d from %d problem(s)\n\n", escape.Replace(group.Department), group.Badness, len(group.Problems))
+			for _, problem := range group.Problems {
+				fmt.Fprintf(buf, "* %s\n", escape.Replace(problem.Message))
+			}
+			fmt.Fprintf(buf, "\n")
+		}
+	} else {
+		for _, problem := range schedule.Problems {
+			fmt.Fprintf(buf, "* %s\n", escape.Replace(problem.Message))
+		}
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
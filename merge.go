@@ -0,0 +1,168 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergePrefix = "merged"
+)
+
+// CommandMerge schedules several departments together in a shared pool
+// of rooms and times. The merge file (departments.txt by default) names
+// the pool and each department's input. The combined input, schedule,
+// and web page are written to merged.txt, merged.json, and merged.html
+// (or the prefix given with --prefix), and the view of each department
+// on its own to merged-NAME.txt, merged-NAME.json, and merged-NAME.html.
+func CommandMerge(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		log.Fatalf("usage: schedule merge [departments.txt]")
+	}
+	settings := genSettingsFromFlags()
+	if err := settings.Check(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	filename := "departments.txt"
+	if len(args) == 1 {
+		filename = args[0]
+	}
+	lines, err := fetchFile(filename)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	merge, err := engine.ParseMerge(filename, lines)
+	if err != nil {
+		log.Printf("%v", err)
+		os.Exit(ExitParse)
+	}
+
+	// each department is read with the pool in front of it
+	poolFile := merge.Pool + ".txt"
+	pool, err := fetchFile(poolFile)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	var departments []*engine.InputData
+	for _, department := range merge.Departments {
+		departmentFile := department.Prefix + ".txt"
+		lines, err := fetchFile(departmentFile)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		data, err := engine.Parse(departmentFile, append(append([][]string{}, pool...), lines...))
+		if err != nil {
+			if errs, ok := err.(engine.ParseErrors); ok {
+				poolLines(errs, poolFile, len(pool))
+			}
+			log.Printf("%v", err)
+			os.Exit(ExitParse)
+		}
+		poolLines(data.Warnings, poolFile, len(pool))
+		for _, warning := range data.Warnings {
+			log.Printf("warning: %v", warning)
+		}
+		departments = append(departments, data)
+	}
+	combined, err := merge.Merge(departments)
+	if err != nil {
+		log.Fatalf("merging departments: %v", err)
+	}
+
+	// the combined input is written out so the other commands can be
+	// used on the combined schedule
+	savedPrefix := prefix
+	defer func() { prefix = savedPrefix }()
+	prefix = mergePrefix
+	writeMergedInput(combined, mergePrefix+".txt")
+
+	log.Printf("scheduling %d department(s) with %d section(s) together", len(departments), len(combined.Courses))
+	options := engine.Options{
+		GenSettings: settings,
+		Report:      true,
+		OnBest: func(schedule engine.Schedule) {
+			writeOutputFiles(combined, schedule)
+		},
+	}
+	catchInterrupt()
+	best, _, err := engine.Search(interruptContext, combined, options)
+	if err != nil {
+		log.Printf("%v", err)
+		os.Exit(ExitInfeasible)
+	}
+	if len(best.Placements) == 0 {
+		log.Printf("no schedule found")
+		os.Exit(ExitInfeasible)
+	}
+
+	// split the combined schedule into a view for each department
+	split := make([][]engine.Placement, len(departments))
+	for _, placement := range best.Placements {
+		n, id := merge.Department(placement.Course)
+		if n < 0 {
+			log.Fatalf("section %s is not in any department", id)
+		}
+		course := findSection(departments[n], id)
+		if course == nil {
+			log.Fatalf("section %s not found in %s", id, merge.Departments[n].Prefix)
+		}
+		split[n] = append(split[n], engine.Placement{Course: course, Room: placement.Room, Time: placement.Time})
+	}
+	fmt.Printf("combined: badness %d\n", best.Badness)
+	for n, department := range merge.Departments {
+		view := departments[n].Score(split[n])
+		fmt.Printf("%s: badness %d on its own\n", department.Name, view.Badness)
+		prefix = mergePrefix + "-" + department.Name
+		prevFile, prevHtmlFile = "", ""
+		writeMergedInput(departments[n], prefix+".txt")
+		writeOutputFiles(departments[n], view)
+	}
+	if isInterrupted() {
+		os.Exit(ExitInterrupted)
+	}
+}
+
+// poolLines corrects the errors found in a department's input read
+// with the pool in front of it, so they name the file and line they
+// came from
+func poolLines(errs engine.ParseErrors, poolFile string, n int) {
+	for _, e := range errs {
+		switch {
+		case e.Line > n:
+			e.Line -= n
+		case e.Line > 0:
+			e.Filename = poolFile
+		}
+	}
+}
+
+// findSection finds the section with the given ID
+func findSection(data *engine.InputData, id string) *engine.Course {
+	for _, course := range data.Courses {
+		if course.SectionID() == id {
+			return course
+		}
+	}
+	return nil
+}
+
+// writeMergedInput writes an input in the text format
+func writeMergedInput(data *engine.InputData, filename string) {
+	fp, err := os.Create(filename)
+	if err != nil {
+		log.Fatalf("creating %s: %v", filename, err)
+	}
+	if err = data.WriteInput(fp); err != nil {
+		log.Fatalf("writing %s: %v", filename, err)
+	}
+	if err = fp.Close(); err != nil {
+		log.Fatalf("closing %s: %v", filename, err)
+	}
+}
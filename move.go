@@ -0,0 +1,55 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+func CommandMove(cmd *cobra.Command, args []string) {
+	if len(args) != 3 {
+		log.Fatalf("usage: schedule move COURSE ROOM TIME")
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	oldSchedule := data.Score(readPlacements(data, prefix+".json"))
+
+	course, err := data.FindCourse(args[0])
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	room, time, err := data.FindRoomTime(args[1], args[2])
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	placements, err := data.MoveCourse(makeSectionList(data), oldSchedule.Placements, course, room, time)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	newSchedule := data.Score(placements)
+
+	// a move that breaks a hard rule is never written
+	gone, added := engine.DiffProblems(oldSchedule.Problems, newSchedule.Problems)
+	for _, problem := range added {
+		if problem.Badness >= engine.Impossible {
+			log.Fatalf("%s cannot be moved to %s at %s: %s", course.SectionID(), data.Rooms[room].Name, data.Times[time].Name, problem.Message)
+		}
+	}
+	writeOutputFiles(data, newSchedule)
+
+	fmt.Printf("moved %s to %s at %s\n", course.SectionID(), data.Rooms[room].Name, data.Times[time].Name)
+	fmt.Printf("badness %d -> %d (%+d)\n", oldSchedule.Badness, newSchedule.Badness, newSchedule.Badness-oldSchedule.Badness)
+	for _, problem := range gone {
+		fmt.Println("- " + problem.Message)
+	}
+	for _, problem := range added {
+		fmt.Println(colorize(badnessColor(problem.Badness), "+ "+problem.Message))
+	}
+}
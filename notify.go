@@ -0,0 +1,121 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/russross/schedule/engine"
+)
+
+var (
+	notifyURL = ""
+
+	// notifications still being sent
+	notifyPending sync.WaitGroup
+)
+
+// A Notification is posted as JSON to the --notify URL. Text repeats
+// the rest in a sentence, which is what Slack and similar chat
+// webhooks display.
+type Notification struct {
+	Event   string  `json:"event"`
+	Command string  `json:"command"`
+	Host    string  `json:"host"`
+	Badness int     `json:"badness"`
+	Elapsed float64 `json:"elapsed"`
+	Output  string  `json:"output,omitempty"`
+	Status  string  `json:"status,omitempty"`
+	Text    string  `json:"text"`
+}
+
+func newNotification(event string, schedule engine.Schedule) *Notification {
+	note := &Notification{
+		Event:   event,
+		Host:    hostname,
+		Badness: schedule.Badness,
+	}
+	if runInfo != nil {
+		note.Command = runInfo.Command
+		note.Elapsed = time.Since(runInfo.Started).Round(time.Second).Seconds()
+	}
+	if prevFile != "" {
+		if path, err := filepath.Abs(prevFile); err == nil {
+			note.Output = path
+		} else {
+			note.Output = prevFile
+		}
+	}
+	return note
+}
+
+// notifyBest reports a new best schedule without holding up the search
+func notifyBest(schedule engine.Schedule) {
+	if notifyURL == "" {
+		return
+	}
+	note := newNotification("best", schedule)
+	note.Text = fmt.Sprintf("schedule %s on %s: new best badness %d after %v",
+		note.Command, note.Host, note.Badness, time.Duration(note.Elapsed)*time.Second)
+	notifyPending.Add(1)
+	go func() {
+		defer notifyPending.Done()
+		postNotification(note)
+	}()
+}
+
+// notifyStatus describes each status for the end of a run
+var notifyStatus = map[string]string{
+	"ok":          "finished",
+	"interrupted": "was interrupted",
+	"infeasible":  "finished without a feasible schedule",
+	"partial":     "finished with some sections left out",
+	"over-budget": "finished over the maximum badness",
+}
+
+// notifyFinished reports the end of a run and waits until every
+// notification has been sent (or has failed). status is ok,
+// interrupted, infeasible, partial, or over-budget.
+func notifyFinished(schedule engine.Schedule, status string) {
+	if notifyURL == "" {
+		return
+	}
+	note := newNotification("finished", schedule)
+	note.Status = status
+	if len(schedule.Placements) == 0 {
+		note.Text = fmt.Sprintf("schedule %s on %s %s after %v",
+			note.Command, note.Host, notifyStatus[status], time.Duration(note.Elapsed)*time.Second)
+	} else {
+		note.Text = fmt.Sprintf("schedule %s on %s %s after %v with badness %d",
+			note.Command, note.Host, notifyStatus[status], time.Duration(note.Elapsed)*time.Second, note.Badness)
+	}
+	notifyPending.Wait()
+	postNotification(note)
+}
+
+// postNotification sends one notification. Failures are logged but
+// never stop the program.
+func postNotification(note *Notification) {
+	raw, err := json.Marshal(note)
+	if err != nil {
+		log.Printf("notify: %v", err)
+		return
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(notifyURL, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		log.Printf("notify: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("notify: %s returned %s", notifyURL, resp.Status)
+	}
+}
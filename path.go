@@ -0,0 +1,120 @@
+// +build !wasm
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pathsFile = ""
+)
+
+// A studentPath is a list of courses a typical student takes in the
+// same term
+type studentPath struct {
+	Name    string
+	Courses []string
+}
+
+// CommandPath checks that a student could take every course in a list
+// with no two meeting at the same time in the current schedule. The
+// courses are given on the command line, or with --paths as a file
+// with one list per line in the form
+//
+//     NAME: COURSE COURSE ...
+//
+// where blank lines and anything after a # are ignored.
+func CommandPath(cmd *cobra.Command, args []string) {
+	var paths []studentPath
+	switch {
+	case pathsFile != "" && len(args) > 0:
+		log.Fatalf("give courses on the command line or with --paths, not both")
+	case pathsFile != "":
+		var err error
+		if paths, err = readPaths(pathsFile); err != nil {
+			log.Fatalf("%v", err)
+		}
+		if len(paths) == 0 {
+			log.Fatalf("no paths found in %s", pathsFile)
+		}
+	case len(args) > 0:
+		paths = []studentPath{{Name: "courses", Courses: args}}
+	default:
+		log.Fatalf("usage: schedule path COURSE COURSE ... or schedule path --paths FILE")
+	}
+
+	data := readInputData()
+	schedule := data.Score(readPlacements(data, prefix+".json"))
+
+	out := openOutput()
+	defer out.Close()
+	blocked := 0
+	for _, path := range paths {
+		check, err := data.CheckPath(schedule, path.Courses)
+		if err != nil {
+			log.Fatalf("%s: %v", path.Name, err)
+		}
+		if check.Sections != nil {
+			var ids []string
+			for _, section := range check.Sections {
+				ids = append(ids, section.SectionID())
+			}
+			fmt.Fprintf(out, "%s: ok, e.g., %s\n", path.Name, strings.Join(ids, " "))
+			continue
+		}
+		blocked++
+		fmt.Fprintf(out, "%s: no combination of sections without a time conflict\n", path.Name)
+		for _, group := range check.Blocking {
+			if len(group) == 1 {
+				fmt.Fprintf(out, "    %s has no section in the schedule\n", group[0])
+			} else {
+				fmt.Fprintf(out, "    %s cannot all be taken together\n", strings.Join(group, " "))
+			}
+		}
+	}
+	if blocked > 0 {
+		out.Close()
+		log.Printf("%d of %d path(s) blocked", blocked, len(paths))
+		os.Exit(ExitBlocked)
+	}
+}
+
+// readPaths reads a list of student paths
+func readPaths(filename string) ([]studentPath, error) {
+	fp, err := openFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	var paths []studentPath
+	scanner := bufio.NewScanner(fp)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("%s line %d: expected NAME: COURSE COURSE ...", filename, lineNumber)
+		}
+		path := studentPath{Name: strings.TrimSpace(line[:colon]), Courses: strings.Fields(line[colon+1:])}
+		if path.Name == "" || len(path.Courses) == 0 {
+			return nil, fmt.Errorf("%s line %d: expected NAME: COURSE COURSE ...", filename, lineNumber)
+		}
+		paths = append(paths, path)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,190 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pressureFormat = "text"
+)
+
+// Pressure measures contention for each room/time cell
+type Pressure struct {
+	// Cells[room][time] is the number of sections that could legally
+	// occupy the cell, counting every slot a multi-slot section would use
+	Cells [][]int
+
+	// Times[time] is the number of distinct sections that could
+	// meet at that time in any room
+	Times []int
+
+	// Max is the largest value in Cells
+	Max int
+}
+
+func CommandPressure(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// the pressure only depends on the input, not a specific schedule
+	pressure := MeasurePressure(data, makeSectionList(data))
+
+	format := outputFormat(cmd, pressureFormat, map[string]string{".html": "html", ".htm": "html"})
+	if format != "text" && format != "html" {
+		log.Fatalf("unknown format %q", format)
+	}
+
+	out := openOutput()
+	defer out.Close()
+	var err error
+	switch format {
+	case "text":
+		err = WritePressureText(data, out, pressure)
+	case "html":
+		err = WritePressureHTML(data, out, pressure)
+	}
+	if err != nil {
+		log.Fatalf("writing pressure report: %v", err)
+	}
+}
+
+// MeasurePressure counts how many sections could legally occupy each
+// room/time cell, based on their feasible placements before any
+// section has been placed.
+func MeasurePressure(data *engine.InputData, sections []*engine.Section) Pressure {
+	pressure := Pressure{
+		Cells: make([][]int, len(data.Rooms)),
+		Times: make([]int, len(data.Times)),
+	}
+	for r := range pressure.Cells {
+		pressure.Cells[r] = make([]int, len(data.Times))
+	}
+
+	for _, section := range sections {
+		covered := make([][]bool, len(data.Rooms))
+		atTime := make([]bool, len(data.Times))
+		for r, times := range section.RoomTimes {
+			covered[r] = make([]bool, len(data.Times))
+			for t, badness := range times {
+				if badness < 0 {
+					continue
+				}
+				slots := section.Course.SlotsNeeded(data.Times[t])
+				for i := 0; i < slots; i++ {
+					covered[r][t+i] = true
+					atTime[t+i] = true
+				}
+			}
+		}
+		for r := range covered {
+			for t, hit := range covered[r] {
+				if hit {
+					pressure.Cells[r][t]++
+					if pressure.Cells[r][t] > pressure.Max {
+						pressure.Max = pressure.Cells[r][t]
+					}
+				}
+			}
+		}
+		for t, hit := range atTime {
+			if hit {
+				pressure.Times[t]++
+			}
+		}
+	}
+
+	return pressure
+}
+
+// WritePressureText writes the pressure grid with times down the side
+// and rooms across the top. The last column is the number of sections
+// that could meet at that time compared to the number of rooms.
+func WritePressureText(data *engine.InputData, w io.Writer, pressure Pressure) error {
+	timeLen := 0
+	for _, t := range data.Times {
+		if displayWidth(t.Name) > timeLen {
+			timeLen = displayWidth(t.Name)
+		}
+	}
+	colLen := len(fmt.Sprintf("%d", pressure.Max))
+	for _, r := range data.Rooms {
+		if displayWidth(r.Name) > colLen {
+			colLen = displayWidth(r.Name)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%*s", timeLen, "")
+	for _, r := range data.Rooms {
+		fmt.Fprintf(buf, "  %s", padLeft(r.Name, colLen))
+	}
+	fmt.Fprintf(buf, "  sections/rooms\n")
+	for t, time := range data.Times {
+		fmt.Fprint(buf, padLeft(time.Name, timeLen))
+		for r := range data.Rooms {
+			fmt.Fprintf(buf, "  %*d", colLen, pressure.Cells[r][t])
+		}
+		fmt.Fprintf(buf, "  %d/%d\n", pressure.Times[t], len(data.Rooms))
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// WritePressureHTML writes the pressure grid as a standalone web page,
+// with cells shaded from white (no contention) to red (the most).
+func WritePressureHTML(data *engine.InputData, w io.Writer, pressure Pressure) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "<!DOCTYPE html>\n")
+	fmt.Fprintf(buf, "<html lang=\"en\">\n")
+	fmt.Fprintf(buf, "<head>\n")
+	fmt.Fprintf(buf, "  <meta charset=\"utf-8\">\n")
+	fmt.Fprintf(buf, "  <title>Constraint pressure by room and time</title>\n")
+	fmt.Fprintf(buf, "  <style>\n")
+	fmt.Fprintf(buf, "    table { border-collapse: collapse; }\n")
+	fmt.Fprintf(buf, "    table, td { border: 1px solid darkgray; }\n")
+	fmt.Fprintf(buf, "    td { padding: 0.2em 0.5em; text-align: right; }\n")
+	fmt.Fprintf(buf, "  </style>\n")
+	fmt.Fprintf(buf, "</head>\n")
+	fmt.Fprintf(buf, "<body>\n")
+	fmt.Fprintf(buf, "  <table>\n")
+	fmt.Fprintf(buf, "    <tr>\n      <td>&nbsp;</td>\n")
+	for _, room := range data.Rooms {
+		fmt.Fprintf(buf, "      <td>%s</td>\n", html.EscapeString(room.Name))
+	}
+	fmt.Fprintf(buf, "      <td>sections/rooms</td>\n")
+	fmt.Fprintf(buf, "    </tr>\n")
+	for t, time := range data.Times {
+		fmt.Fprintf(buf, "    <tr>\n      <td>%s</td>\n", html.EscapeString(time.Name))
+		for r := range data.Rooms {
+			count := pressure.Cells[r][t]
+			shade := 255
+			if pressure.Max > 0 {
+				shade = 255 - 200*count/pressure.Max
+			}
+			fmt.Fprintf(buf, "      <td style=\"background-color: rgb(255, %d, %d)\">%d</td>\n", shade, shade, count)
+		}
+		fmt.Fprintf(buf, "      <td>%d/%d</td>\n", pressure.Times[t], len(data.Rooms))
+		fmt.Fprintf(buf, "    </tr>\n")
+	}
+	fmt.Fprintf(buf, "  </table>\n")
+	fmt.Fprintf(buf, "</body>\n")
+	fmt.Fprintf(buf, "</html>\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,264 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	publishDir = ""
+)
+
+func CommandPublish(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	schedule := data.Score(readPlacements(data, prefix+".json"))
+
+	dir := publishDir
+	if dir == "" {
+		dir = prefix + "-site"
+	}
+	if err := Publish(data, dir, schedule); err != nil {
+		log.Fatalf("publishing: %v", err)
+	}
+	log.Printf("site written to %s", dir)
+}
+
+// Publish renders a small static web site for a schedule into dir:
+// the room/time grid, one page per instructor, one page per room,
+// and the list of problems, all linked to each other
+func Publish(data *engine.InputData, dir string, schedule engine.Schedule) error {
+	for _, sub := range []string{"instructor", "room"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return err
+		}
+	}
+
+	pages := map[string]*bytes.Buffer{
+		"index.html":    publishGrid(data, schedule),
+		"problems.html": publishProblems(data, schedule),
+	}
+	for _, instructor := range data.Instructors {
+		pages[instructorPage(instructor.Name)] = publishInstructor(data, schedule, instructor)
+	}
+	for r, room := range data.Rooms {
+		pages[roomPage(room.Name)] = publishRoom(data, schedule, r)
+	}
+
+	for name, buf := range pages {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageSlug turns a name into something safe to use as a file name
+func pageSlug(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name) + ".html"
+}
+
+func instructorPage(name string) string { return "instructor/" + pageSlug(name) }
+func roomPage(name string) string       { return "room/" + pageSlug(name) }
+
+// publishHeader starts a page. root is the relative path back
+// to the top of the site, e.g., "../" for pages in a subdirectory.
+func publishHeader(buf *bytes.Buffer, root, title string) {
+	fmt.Fprintf(buf, "<!DOCTYPE html>\n")
+	fmt.Fprintf(buf, "<html lang=\"en\">\n")
+	fmt.Fprintf(buf, "<head>\n")
+	fmt.Fprintf(buf, "  <meta charset=\"utf-8\">\n")
+	fmt.Fprintf(buf, "  <title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(buf, "  <style>\n")
+	fmt.Fprintf(buf, "    table { border-collapse: collapse; }\n")
+	fmt.Fprintf(buf, "    table, td, th { border: 1px solid darkgray; }\n")
+	fmt.Fprintf(buf, "    td, th { padding: 0.2em 0.5em; vertical-align: top; text-align: left; }\n")
+	fmt.Fprintf(buf, "    td.course { background-color: #eef; }\n")
+	fmt.Fprintf(buf, "    nav { margin-bottom: 1em; }\n")
+	fmt.Fprintf(buf, "  </style>\n")
+	fmt.Fprintf(buf, "</head>\n")
+	fmt.Fprintf(buf, "<body>\n")
+	fmt.Fprintf(buf, "  <nav><a href=\"%sindex.html\">Grid</a> | <a href=\"%sproblems.html\">Problems</a></nav>\n", root, root)
+	fmt.Fprintf(buf, "  <h1>%s</h1>\n", html.EscapeString(title))
+}
+
+func publishFooter(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "</body>\n")
+	fmt.Fprintf(buf, "</html>\n")
+}
+
+// instructorLinks lists the instructors of a course, each linked to their page
+func instructorLinks(root string, course *engine.Course) string {
+	var links []string
+	for _, instructor := range course.Instructors {
+		links = append(links, fmt.Sprintf("<a href=\"%s%s\">%s</a>",
+			root, instructorPage(instructor.Name), html.EscapeString(instructor.Name)))
+	}
+	return strings.Join(links, ", ")
+}
+
+func publishGrid(data *engine.InputData, schedule engine.Schedule) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	publishHeader(buf, "", fmt.Sprintf("Schedule of rooms by time (badness %d)", schedule.Badness))
+
+	fmt.Fprintf(buf, "  <table>\n")
+	fmt.Fprintf(buf, "    <tr>\n      <th>&nbsp;</th>\n")
+	for _, room := range data.Rooms {
+		fmt.Fprintf(buf, "      <th><a href=\"%s\">%s</a></th>\n", roomPage(room.Name), html.EscapeString(room.Name))
+	}
+	fmt.Fprintf(buf, "    </tr>\n")
+	for t, time := range data.Times {
+		fmt.Fprintf(buf, "    <tr>\n      <th>%s</th>\n", html.EscapeString(timeLabel(data, t)))
+		for r := range data.Rooms {
+			cell := schedule.RoomTimes[r][t]
+			switch {
+			case cell.IsSpillover:
+				// covered by the rowspan of the cell above
+			case cell.Course == nil:
+				fmt.Fprintf(buf, "      <td>&nbsp;</td>\n")
+			default:
+				rowspan := ""
+				if slots := cell.Course.SlotsNeeded(time); slots > 1 {
+					rowspan = fmt.Sprintf(" rowspan=\"%d\"", slots)
+				}
+				fmt.Fprintf(buf, "      <td class=\"course\"%s>%s<br>%s</td>\n",
+					rowspan, instructorLinks("", cell.Course), html.EscapeString(cell.Course.Name))
+			}
+		}
+		fmt.Fprintf(buf, "    </tr>\n")
+	}
+	fmt.Fprintf(buf, "  </table>\n")
+	fmt.Fprintf(buf, "  <p>Total badness %d with <a href=\"problems.html\">%d known problems</a>.</p>\n",
+		schedule.Badness, len(schedule.Problems))
+
+	publishFooter(buf)
+	return buf
+}
+
+func publishProblems(data *engine.InputData, schedule engine.Schedule) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	publishHeader(buf, "", fmt.Sprintf("Known problems (badness %d)", schedule.Badness))
+
+	if len(schedule.Problems) == 0 {
+		fmt.Fprintf(buf, "  <p>There are no known problems.</p>\n")
+	} else {
+		fmt.Fprintf(buf, "  <ul>\n")
+		for _, problem := range schedule.Problems {
+			fmt.Fprintf(buf, "    <li>%s</li>\n", html.EscapeString(problem.Message))
+		}
+		fmt.Fprintf(buf, "  </ul>\n")
+	}
+
+	// link to everyone so the site can be browsed from here too
+	fmt.Fprintf(buf, "  <h2>Instructors</h2>\n  <ul>\n")
+	for _, instructor := range data.Instructors {
+		fmt.Fprintf(buf, "    <li><a href=\"%s\">%s</a></li>\n", instructorPage(instructor.Name), html.EscapeString(instructor.Name))
+	}
+	fmt.Fprintf(buf, "  </ul>\n")
+	fmt.Fprintf(buf, "  <h2>Rooms</h2>\n  <ul>\n")
+	for _, room := range data.Rooms {
+		fmt.Fprintf(buf, "    <li><a href=\"%s\">%s</a></li>\n", roomPage(room.Name), html.EscapeString(room.Name))
+	}
+	fmt.Fprintf(buf, "  </ul>\n")
+
+	publishFooter(buf)
+	return buf
+}
+
+func publishInstructor(data *engine.InputData, schedule engine.Schedule, instructor *engine.Instructor) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	publishHeader(buf, "../", instructor.Name)
+
+	fmt.Fprintf(buf, "  <table>\n")
+	fmt.Fprintf(buf, "    <tr><th>Course</th><th>Section</th><th>Room</th><th>Time</th><th>Instructors</th></tr>\n")
+	for _, course := range instructor.Courses {
+		for _, placement := range schedule.Placements {
+			if placement.Course != course {
+				continue
+			}
+			room := data.Rooms[placement.Room].Name
+			fmt.Fprintf(buf, "    <tr><td>%s</td><td>%02d</td><td><a href=\"../%s\">%s</a></td><td>%s</td><td>%s</td></tr>\n",
+				html.EscapeString(course.Name), course.Section,
+				roomPage(room), html.EscapeString(room),
+				html.EscapeString(placementTime(data, placement)),
+				instructorLinks("../", course))
+		}
+	}
+	fmt.Fprintf(buf, "  </table>\n")
+
+	// problems that mention this instructor by name
+	var problems []string
+	for _, problem := range schedule.Problems {
+		if strings.Contains(problem.Message, instructor.Name) {
+			problems = append(problems, problem.Message)
+		}
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(buf, "  <h2>Problems</h2>\n  <ul>\n")
+		for _, msg := range problems {
+			fmt.Fprintf(buf, "    <li>%s</li>\n", html.EscapeString(msg))
+		}
+		fmt.Fprintf(buf, "  </ul>\n")
+	}
+
+	publishFooter(buf)
+	return buf
+}
+
+func publishRoom(data *engine.InputData, schedule engine.Schedule, r int) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	room := data.Rooms[r]
+	title := "Room " + room.Name
+	if len(room.Tags) > 0 {
+		title += " (" + strings.Join(room.Tags, ", ") + ")"
+	}
+	publishHeader(buf, "../", title)
+
+	fmt.Fprintf(buf, "  <table>\n")
+	fmt.Fprintf(buf, "    <tr><th>Time</th><th>Course</th><th>Instructors</th></tr>\n")
+	for t, time := range data.Times {
+		cell := schedule.RoomTimes[r][t]
+		switch {
+		case cell.IsSpillover:
+			// the course and instructor cells are covered by the rowspan above
+			fmt.Fprintf(buf, "    <tr><th>%s</th></tr>\n", html.EscapeString(timeLabel(data, t)))
+		case cell.Course == nil:
+			fmt.Fprintf(buf, "    <tr><th>%s</th><td>&nbsp;</td><td>&nbsp;</td></tr>\n", html.EscapeString(timeLabel(data, t)))
+		default:
+			rowspan := ""
+			if slots := cell.Course.SlotsNeeded(time); slots > 1 {
+				rowspan = fmt.Sprintf(" rowspan=\"%d\"", slots)
+			}
+			fmt.Fprintf(buf, "    <tr><th>%s</th><td class=\"course\"%s>%s</td><td%s>%s</td></tr>\n",
+				html.EscapeString(timeLabel(data, t)), rowspan, html.EscapeString(cell.Course.SectionID()),
+				rowspan, instructorLinks("../", cell.Course))
+		}
+	}
+	fmt.Fprintf(buf, "  </table>\n")
+
+	publishFooter(buf)
+	return buf
+}
@@ -0,0 +1,154 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	roomLossBudget = 30 * time.Second
+)
+
+// CommandRoomLoss checks how the current schedule would fare if each
+// room (or each room named on the command line) were taken away: it
+// moves the sections out of the room with a short search and reports
+// whether they all still fit and how much the badness goes up
+func CommandRoomLoss(cmd *cobra.Command, args []string) {
+	if roomLossBudget <= 0 {
+		log.Fatalf("time must be > 0")
+	}
+	settings := genSettingsFromFlags()
+	settings.Duration = roomLossBudget
+	if err := settings.Check(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	data := readInputData()
+	baseline := data.Score(readPlacements(data, prefix+".json"))
+
+	rooms := args
+	if len(rooms) == 0 {
+		for _, room := range data.Rooms {
+			rooms = append(rooms, room.Name)
+		}
+	}
+	for _, name := range rooms {
+		if !roomKnown(data, name) {
+			log.Fatalf("room %q not found", name)
+		}
+	}
+
+	out := openOutput()
+	defer out.Close()
+	catchInterrupt()
+
+	// a short search can often improve on the schedule even with every
+	// room, so the same search with every room is the fair comparison
+	log.Printf("searching for %v with every room for comparison", roomLossBudget)
+	reference, _, err := engine.Search(interruptContext, data, engine.Options{
+		GenSettings: settings,
+		Start:       baseline,
+		NoRestarts:  true,
+	})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(reference.Placements) == 0 || reference.Badness > baseline.Badness {
+		reference = baseline
+	}
+	fmt.Fprintf(out, "Current badness: %d\n", baseline.Badness)
+	fmt.Fprintf(out, "Badness after a %v search with every room: %d\n", roomLossBudget, reference.Badness)
+
+	where := make(map[string]string)
+	for _, placement := range baseline.Placements {
+		where[placement.Course.SectionID()] = data.Rooms[placement.Room].Name + " " + data.Times[placement.Time].Name
+	}
+	for _, name := range rooms {
+		if isInterrupted() {
+			log.Printf("interrupted before trying the remaining rooms")
+			os.Exit(ExitInterrupted)
+		}
+		fmt.Fprintf(out, "\nWithout %s: ", name)
+		changed, err := data.WithoutRoom(name)
+		if err != nil {
+			fmt.Fprintf(out, "no schedule possible: %v\n", err)
+			continue
+		}
+
+		// keep every section that was not in the room where it was
+		sections := make(map[string]*engine.Course)
+		for _, course := range changed.Courses {
+			sections[course.SectionID()] = course
+		}
+		var placements []engine.Placement
+		var evicted []string
+		for _, placement := range baseline.Placements {
+			room, t, err := changed.FindRoomTime(data.Rooms[placement.Room].Name, data.Times[placement.Time].Name)
+			if err != nil {
+				evicted = append(evicted, placement.Course.SectionID())
+				continue
+			}
+			placements = append(placements, engine.Placement{Course: sections[placement.Course.SectionID()], Room: room, Time: t})
+		}
+		start := changed.Score(placements)
+		start.Badness = engine.Worst
+
+		log.Printf("moving %d section(s) out of %s", len(evicted), name)
+		best, _, err := engine.Search(interruptContext, changed, engine.Options{
+			GenSettings: settings,
+			Start:       start,
+			NoRestarts:  true,
+		})
+		if err != nil {
+			fmt.Fprintf(out, "no schedule possible: %v\n", err)
+			continue
+		}
+		if best.Badness >= engine.Worst || len(best.Unplaced) > 0 {
+			fmt.Fprintf(out, "no schedule found in %v with %d section(s) moved out\n", roomLossBudget, len(evicted))
+			continue
+		}
+		fmt.Fprintf(out, "badness %d (%+d)\n", best.Badness, best.Badness-reference.Badness)
+
+		// show where the sections in the room went, and how many
+		// others moved to make room for them
+		others := 0
+		for _, placement := range best.Placements {
+			id := placement.Course.SectionID()
+			now := changed.Rooms[placement.Room].Name + " " + changed.Times[placement.Time].Name
+			switch {
+			case containsID(evicted, id):
+				fmt.Fprintf(out, "    %s: %s -> %s\n", id, where[id], now)
+			case where[id] != now:
+				others++
+			}
+		}
+		if others > 0 {
+			fmt.Fprintf(out, "    %d other section(s) moved\n", others)
+		}
+	}
+}
+
+func roomKnown(data *engine.InputData, name string) bool {
+	for _, room := range data.Rooms {
+		if room.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsID(list []string, id string) bool {
+	for _, elt := range list {
+		if elt == id {
+			return true
+		}
+	}
+	return false
+}
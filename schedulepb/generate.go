@@ -0,0 +1,5 @@
+// Package schedulepb holds the gRPC interface offered by schedule serve
+// --grpc, generated from schedule.proto.
+package schedulepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative schedule.proto
@@ -0,0 +1,211 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package schedulepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SchedulerClient is the client API for Scheduler service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SchedulerClient interface {
+	// Parse checks an input (and the schedule, if one is given) for errors
+	Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*ParseReply, error)
+	// Score scores a schedule
+	Score(ctx context.Context, in *ScoreRequest, opts ...grpc.CallOption) (*ScoreReply, error)
+	// Solve runs a search. It sends the job when the search starts,
+	// each new best schedule as it is found, and the final state when
+	// the search ends. Cancelling the call stops the search.
+	Solve(ctx context.Context, in *SolveRequest, opts ...grpc.CallOption) (Scheduler_SolveClient, error)
+}
+
+type schedulerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSchedulerClient(cc grpc.ClientConnInterface) SchedulerClient {
+	return &schedulerClient{cc}
+}
+
+func (c *schedulerClient) Parse(ctx context.Context, in *ParseRequest, opts ...grpc.CallOption) (*ParseReply, error) {
+	out := new(ParseReply)
+	err := c.cc.Invoke(ctx, "/schedule.Scheduler/Parse", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *schedulerClient) Score(ctx context.Context, in *ScoreRequest, opts ...grpc.CallOption) (*ScoreReply, error) {
+	out := new(ScoreReply)
+	err := c.cc.Invoke(ctx, "/schedule.Scheduler/Score", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *schedulerClient) Solve(ctx context.Context, in *SolveRequest, opts ...grpc.CallOption) (Scheduler_SolveClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Scheduler_ServiceDesc.Streams[0], "/schedule.Scheduler/Solve", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &schedulerSolveClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Scheduler_SolveClient interface {
+	Recv() (*SolveProgress, error)
+	grpc.ClientStream
+}
+
+type schedulerSolveClient struct {
+	grpc.ClientStream
+}
+
+func (x *schedulerSolveClient) Recv() (*SolveProgress, error) {
+	m := new(SolveProgress)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SchedulerServer is the server API for Scheduler service.
+// All implementations must embed UnimplementedSchedulerServer
+// for forward compatibility
+type SchedulerServer interface {
+	// Parse checks an input (and the schedule, if one is given) for errors
+	Parse(context.Context, *ParseRequest) (*ParseReply, error)
+	// Score scores a schedule
+	Score(context.Context, *ScoreRequest) (*ScoreReply, error)
+	// Solve runs a search. It sends the job when the search starts,
+	// each new best schedule as it is found, and the final state when
+	// the search ends. Cancelling the call stops the search.
+	Solve(*SolveRequest, Scheduler_SolveServer) error
+	mustEmbedUnimplementedSchedulerServer()
+}
+
+// UnimplementedSchedulerServer must be embedded to have forward compatible implementations.
+type UnimplementedSchedulerServer struct {
+}
+
+func (UnimplementedSchedulerServer) Parse(context.Context, *ParseRequest) (*ParseReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Parse not implemented")
+}
+func (UnimplementedSchedulerServer) Score(context.Context, *ScoreRequest) (*ScoreReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Score not implemented")
+}
+func (UnimplementedSchedulerServer) Solve(*SolveRequest, Scheduler_SolveServer) error {
+	return status.Errorf(codes.Unimplemented, "method Solve not implemented")
+}
+func (UnimplementedSchedulerServer) mustEmbedUnimplementedSchedulerServer() {}
+
+// UnsafeSchedulerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SchedulerServer will
+// result in compilation errors.
+type UnsafeSchedulerServer interface {
+	mustEmbedUnimplementedSchedulerServer()
+}
+
+func RegisterSchedulerServer(s grpc.ServiceRegistrar, srv SchedulerServer) {
+	s.RegisterService(&Scheduler_ServiceDesc, srv)
+}
+
+func _Scheduler_Parse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ParseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchedulerServer).Parse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/schedule.Scheduler/Parse",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchedulerServer).Parse(ctx, req.(*ParseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scheduler_Score_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SchedulerServer).Score(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/schedule.Scheduler/Score",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SchedulerServer).Score(ctx, req.(*ScoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scheduler_Solve_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SolveRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SchedulerServer).Solve(m, &schedulerSolveServer{stream})
+}
+
+type Scheduler_SolveServer interface {
+	Send(*SolveProgress) error
+	grpc.ServerStream
+}
+
+type schedulerSolveServer struct {
+	grpc.ServerStream
+}
+
+func (x *schedulerSolveServer) Send(m *SolveProgress) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Scheduler_ServiceDesc is the grpc.ServiceDesc for Scheduler service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Scheduler_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "schedule.Scheduler",
+	HandlerType: (*SchedulerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Parse",
+			Handler:    _Scheduler_Parse_Handler,
+		},
+		{
+			MethodName: "Score",
+			Handler:    _Scheduler_Score_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Solve",
+			Handler:       _Scheduler_Solve_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "schedule.proto",
+}
@@ -0,0 +1,253 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+//go:generate sh -c "GOOS=js GOARCH=wasm go build -o web/schedule.wasm ."
+//go:generate sh -c "cp \"$(go env GOROOT)/lib/wasm/wasm_exec.js\" web/ 2>/dev/null || cp \"$(go env GOROOT)/misc/wasm/wasm_exec.js\" web/"
+
+// the web front end: index.html and its web worker, plus the web
+// assembly build and its support file, which go generate puts in web/
+//go:embed index.html worker.js web
+var webFiles embed.FS
+
+var (
+	serveAddr = "localhost:8080"
+	serveGen  = false
+)
+
+func CommandServe(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	web, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	for _, name := range []string{"schedule.wasm", "wasm_exec.js"} {
+		if _, err := fs.Stat(web, name); err != nil {
+			log.Fatalf("this program was built without the web front end (%s is missing); run go generate and build it again", name)
+		}
+	}
+
+	var settings engine.GenSettings
+	if serveGen {
+		settings = genSettingsFromFlags()
+		if err := settings.Check(); err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	// make sure the input and schedule can be read before starting
+	data := readInputData()
+	var start engine.Schedule
+	if serveGen && !continueSearch {
+		start = engine.Schedule{Badness: engine.Worst}
+	} else {
+		start = data.Score(readPlacements(data, prefix+".json"))
+	}
+
+	mux := http.NewServeMux()
+	static := http.FileServer(http.FS(webFiles))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		static.ServeHTTP(w, r)
+	})
+	api := NewAPIServer()
+	api.Register(mux)
+	NewEditLocks().Register(mux)
+	live := NewLiveHub()
+	mux.Handle("/live", live)
+	assets := http.FileServer(http.FS(web))
+	mux.Handle("/schedule.wasm", assets)
+	mux.Handle("/wasm_exec.js", assets)
+	mux.Handle("/worker.js", static)
+
+	// the input and schedule are read from disk on each request,
+	// so the page always shows the latest versions
+	mux.HandleFunc("/schedule.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeFile(w, r, prefix+".txt")
+	})
+
+	log.Printf("serving %s.txt and %s.json at http://%s/", prefix, prefix, serveAddr)
+	if serveGen {
+		go serveSearch(data, settings, start, live)
+	}
+	if grpcAddr != "" {
+		go serveGRPC(grpcAddr, api)
+	}
+	server := &http.Server{
+		Addr:              serveAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+	}
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// serveSearch runs a gen search alongside the server, writing each new
+// best schedule to the output files and streaming it to live viewers
+func serveSearch(data *engine.InputData, settings engine.GenSettings, start engine.Schedule, live *LiveHub) {
+	started := time.Now()
+	scheduler, err := engine.NewScheduler(data, start.Placements)
+	if err != nil {
+		log.Printf("stopping the search: %v", err)
+		return
+	}
+	options := engine.Options{
+		GenSettings: settings,
+		OnBest: func(schedule engine.Schedule) {
+			log.Print(colorize(ansiBold+ansiGreen, fmt.Sprintf("global best of %d found", schedule.Badness)))
+			scheduleFileMutex.Lock()
+			writeOutputFiles(data, schedule)
+			version, err := currentScheduleVersion()
+			scheduleFileMutex.Unlock()
+			if err != nil {
+				log.Printf("%v", err)
+			}
+			live.PublishBest(data, schedule, version, time.Since(started))
+		},
+	}
+	log.Printf("starting search")
+	if err := scheduler.Start(context.Background(), options); err != nil {
+		log.Printf("stopping the search: %v", err)
+		return
+	}
+	stats := scheduler.Wait()
+	best := scheduler.Best()
+	log.Printf("%d successful and %d failed attempts", stats.Successful, stats.Failed)
+
+	status := "ok"
+	if stats.GaveUp || len(best.Placements) == 0 {
+		status = "infeasible"
+	}
+	live.Publish(LiveEvent{
+		Event:      "finished",
+		Time:       time.Now(),
+		Elapsed:    time.Since(started).Round(time.Millisecond).Seconds(),
+		Badness:    best.Badness,
+		Successful: stats.Successful,
+		Failed:     stats.Failed,
+		Status:     status,
+	})
+}
+
+// A LiveEvent is one message streamed to live viewers
+type LiveEvent struct {
+	Event      string          `json:"event"`
+	Time       time.Time       `json:"time"`
+	Elapsed    float64         `json:"elapsed"`
+	Badness    int             `json:"badness"`
+	Successful int             `json:"successful,omitempty"`
+	Failed     int             `json:"failed,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Version    string          `json:"version,omitempty"`
+	Schedule   json.RawMessage `json:"schedule,omitempty"`
+}
+
+// A LiveHub streams search events to every connected WebSocket.
+// A new viewer is sent the most recent best schedule first, and a
+// viewer that falls behind misses events rather than slowing the
+// search down.
+type LiveHub struct {
+	mutex   sync.Mutex
+	viewers map[chan []byte]bool
+	last    []byte
+}
+
+func NewLiveHub() *LiveHub {
+	return &LiveHub{viewers: make(map[chan []byte]bool)}
+}
+
+// PublishBest sends a new best schedule to all viewers
+func (hub *LiveHub) PublishBest(data *engine.InputData, schedule engine.Schedule, version string, elapsed time.Duration) {
+	buf := new(bytes.Buffer)
+	if err := data.WriteJSON(buf, schedule.Placements, nil); err != nil {
+		log.Printf("live update: %v", err)
+		return
+	}
+	hub.Publish(LiveEvent{
+		Event:    "best",
+		Time:     time.Now(),
+		Elapsed:  elapsed.Round(time.Millisecond).Seconds(),
+		Badness:  schedule.Badness,
+		Version:  version,
+		Schedule: json.RawMessage(buf.Bytes()),
+	})
+}
+
+// Publish sends an event to all viewers
+func (hub *LiveHub) Publish(event LiveEvent) {
+	msg, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("live update: %v", err)
+		return
+	}
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	if event.Schedule != nil {
+		hub.last = msg
+	}
+	for viewer := range hub.viewers {
+		select {
+		case viewer <- msg:
+		default:
+		}
+	}
+}
+
+func (hub *LiveHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := AcceptWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	viewer := make(chan []byte, 16)
+	hub.mutex.Lock()
+	hub.viewers[viewer] = true
+	if hub.last != nil {
+		viewer <- hub.last
+	}
+	hub.mutex.Unlock()
+	defer func() {
+		hub.mutex.Lock()
+		delete(hub.viewers, viewer)
+		hub.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		case msg := <-viewer:
+			if ws.WriteText(msg) != nil {
+				return
+			}
+		case <-ws.Closed():
+			return
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// +build !wasm
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/russross/schedule/engine"
+)
+
+var (
+	sisMappingFile = ""
+)
+
+// An SISMapping describes the flat file a student information system
+// (such as Banner) imports, so that each institution can adapt the
+// export to its own layout without code changes.
+type SISMapping struct {
+	// Delimiter separates fields; it must be a single character.
+	// The default is a comma.
+	Delimiter string `json:"delimiter"`
+
+	// Header controls whether the first row lists the column names
+	Header bool `json:"header"`
+
+	// Columns lists the fields of each row in order
+	Columns []SISColumn `json:"columns"`
+
+	// CRN maps section IDs (e.g., CS1400-01) to course reference numbers
+	CRN map[string]string `json:"crn"`
+
+	// Building is used for rooms that do not appear in Rooms
+	Building string `json:"building"`
+
+	// Rooms maps room names from the input to the building and room
+	// number the SIS expects
+	Rooms map[string]SISRoom `json:"rooms"`
+
+	// Days maps the days part of a time name (e.g., MWF) to the
+	// meeting days the SIS expects. Unmapped days are passed through.
+	Days map[string]string `json:"days"`
+
+	// Minutes gives the length of one meeting in minutes for each
+	// days pattern, with "*" as the fallback
+	Minutes map[string]int `json:"minutes"`
+
+	// DateFormat is the layout for dates, written as Go's reference
+	// date (e.g., 01/02/2006). The default is 2006-01-02.
+	DateFormat string `json:"dateformat"`
+}
+
+// An SISColumn is one field in the output. Field is one of crn,
+// course, subject, number, section, id, instructor, days, begin, end,
+// building, room, startdate, enddate, meetings, or exceptions; the
+// last four come from the term in the input (the first and last
+// meetings, the number of meetings, and the closed days that cancel a
+// meeting, separated by commas) and are empty if it has none. If Field
+// is empty, Value is written as a constant.
+type SISColumn struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+type SISRoom struct {
+	Building string `json:"building"`
+	Room     string `json:"room"`
+}
+
+// DefaultSISMapping is used when no mapping file is given
+func DefaultSISMapping() *SISMapping {
+	return &SISMapping{
+		Delimiter: ",",
+		Header:    true,
+		Columns: []SISColumn{
+			{Name: "CRN", Field: "crn"},
+			{Name: "Subject", Field: "subject"},
+			{Name: "Course", Field: "number"},
+			{Name: "Section", Field: "section"},
+			{Name: "Days", Field: "days"},
+			{Name: "Begin", Field: "begin"},
+			{Name: "End", Field: "end"},
+			{Name: "Building", Field: "building"},
+			{Name: "Room", Field: "room"},
+		},
+		Minutes:    engine.DefaultMeetingMinutes(),
+		DateFormat: "2006-01-02",
+	}
+}
+
+// ReadSISMapping reads a mapping config in JSON format. Anything
+// not given in the file is taken from the default mapping.
+func ReadSISMapping(filename string) (*SISMapping, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	mapping := DefaultSISMapping()
+
+	// decoding into the default columns would merge with them
+	// element by element, so only keep them if none are given
+	columns := mapping.Columns
+	mapping.Columns = nil
+	if err := json.Unmarshal(raw, mapping); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", filename, err)
+	}
+	if mapping.Columns == nil {
+		mapping.Columns = columns
+	}
+	if utf8.RuneCountInString(mapping.Delimiter) != 1 {
+		return nil, fmt.Errorf("%s: delimiter must be a single character, found %q", filename, mapping.Delimiter)
+	}
+	known := map[string]bool{
+		"crn": true, "course": true, "subject": true, "number": true, "section": true, "id": true,
+		"instructor": true, "days": true, "begin": true, "end": true, "building": true, "room": true,
+		"startdate": true, "enddate": true, "meetings": true, "exceptions": true,
+	}
+	for _, column := range mapping.Columns {
+		if column.Field != "" && !known[column.Field] {
+			return nil, fmt.Errorf("%s: unknown field %q in column %q", filename, column.Field, column.Name)
+		}
+	}
+	return mapping, nil
+}
+
+// WriteSIS writes one row per placed course in the layout described
+// by the mapping, in the order the courses appear in the input
+func WriteSIS(data *engine.InputData, w io.Writer, placements []engine.Placement, mapping *SISMapping) error {
+	p := make(map[*engine.Course]engine.Placement)
+	for _, placement := range placements {
+		p[placement.Course] = placement
+	}
+
+	out := csv.NewWriter(w)
+	out.Comma, _ = utf8.DecodeRuneInString(mapping.Delimiter)
+	if mapping.Header {
+		var record []string
+		for _, column := range mapping.Columns {
+			record = append(record, column.Name)
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	for _, course := range data.Courses {
+		placement, present := p[course]
+		if !present {
+			continue
+		}
+		fields, err := sisFields(data, course, placement, mapping)
+		if err != nil {
+			return err
+		}
+		var record []string
+		for _, column := range mapping.Columns {
+			if column.Field == "" {
+				record = append(record, column.Value)
+			} else {
+				record = append(record, fields[column.Field])
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func sisFields(data *engine.InputData, course *engine.Course, placement engine.Placement, mapping *SISMapping) (map[string]string, error) {
+	var instructors []string
+	for _, instructor := range course.Instructors {
+		instructors = append(instructors, instructor.Name)
+	}
+
+	// split the course name into subject and number, e.g., CS1400
+	subject, number := course.Name, ""
+	if brk := strings.IndexAny(course.Name, "0123456789"); brk >= 0 {
+		subject, number = strings.TrimSpace(course.Name[:brk]), course.Name[brk:]
+	}
+
+	// the room
+	roomName := data.Rooms[placement.Room].Name
+	room, present := mapping.Rooms[roomName]
+	if !present {
+		room = SISRoom{Building: mapping.Building, Room: roomName}
+	}
+
+	// the meeting days and times
+	days, begin, end, err := data.MeetingTimes(placement, mapping.Minutes)
+	if err != nil {
+		return nil, err
+	}
+
+	// the meetings in the term, before the days are mapped
+	var startDate, endDate, meetings, exceptions string
+	if term := data.Term; term != nil {
+		dates := term.MeetingDates(days)
+		if len(dates) > 0 {
+			startDate = dates[0].Format(mapping.DateFormat)
+			endDate = dates[len(dates)-1].Format(mapping.DateFormat)
+		}
+		meetings = fmt.Sprint(len(dates))
+		var closed []string
+		for _, date := range term.ClosedDates(days) {
+			closed = append(closed, date.Format(mapping.DateFormat))
+		}
+		exceptions = strings.Join(closed, ",")
+	}
+
+	if mapped, present := mapping.Days[days]; present {
+		days = mapped
+	}
+
+	return map[string]string{
+		"crn":        mapping.CRN[course.SectionID()],
+		"course":     course.Name,
+		"subject":    subject,
+		"number":     number,
+		"section":    fmt.Sprintf("%02d", course.Section),
+		"id":         course.SectionID(),
+		"instructor": strings.Join(instructors, ", "),
+		"days":       days,
+		"begin":      begin,
+		"end":        end,
+		"building":   room.Building,
+		"room":       room.Room,
+		"startdate":  startDate,
+		"enddate":    endDate,
+		"meetings":   meetings,
+		"exceptions": exceptions,
+	}, nil
+}
@@ -0,0 +1,195 @@
+// +build !wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotNote  = ""
+	snapshotForce = false
+)
+
+// A snapshotInfo describes a saved copy of a schedule. It is kept next
+// to the copy as NAME.info.json.
+type snapshotInfo struct {
+	Name    string    `json:"name"`
+	Saved   time.Time `json:"saved"`
+	Actor   string    `json:"actor"`
+	Note    string    `json:"note,omitempty"`
+	Badness int       `json:"badness"`
+}
+
+// snapshotDir is where the snapshots of the schedule are kept
+func snapshotDir() string {
+	return prefix + "-snapshots"
+}
+
+// snapshotName checks that a snapshot name is safe to use as a file name
+func snapshotName(name string) string {
+	valid := name != "" && !strings.HasPrefix(name, ".")
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+		default:
+			valid = false
+		}
+	}
+	if !valid {
+		log.Fatalf("snapshot names may only use letters, digits, '.', '-', and '_', and may not start with '.': %q", name)
+	}
+	return name
+}
+
+// CommandSnapshotSave saves a copy of the current schedule under a
+// name so it can be restored later
+func CommandSnapshotSave(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		log.Fatalf("usage: schedule snapshot save NAME")
+	}
+	if isRemote(prefix) {
+		log.Fatalf("snapshots can only be kept for local files")
+	}
+	name := snapshotName(args[0])
+	dir := snapshotDir()
+	filename := filepath.Join(dir, name+".json")
+	if _, err := os.Stat(filename); err == nil && !snapshotForce {
+		log.Fatalf("snapshot %s already exists; use --force to replace it", name)
+	}
+
+	// make sure the schedule is readable before keeping a copy of it
+	data := readInputData()
+	schedule := data.Score(readPlacements(data, prefix+".json"))
+	raw, err := os.ReadFile(prefix + ".json")
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	info := snapshotInfo{
+		Name:    name,
+		Saved:   time.Now().UTC(),
+		Actor:   currentActor(),
+		Note:    snapshotNote,
+		Badness: schedule.Badness,
+	}
+	meta, err := json.MarshalIndent(info, "", "    ")
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatalf("creating %s: %v", dir, err)
+	}
+	if err := replaceFile(filename, raw); err != nil {
+		log.Fatalf("saving snapshot: %v", err)
+	}
+	if err := replaceFile(filepath.Join(dir, name+".info.json"), append(meta, '\n')); err != nil {
+		log.Fatalf("saving snapshot: %v", err)
+	}
+	fmt.Printf("saved %s.json as snapshot %s (badness %d)\n", prefix, name, schedule.Badness)
+}
+
+// CommandSnapshotRestore replaces the current schedule with a saved
+// snapshot. The snapshot must still match the input.
+func CommandSnapshotRestore(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		log.Fatalf("usage: schedule snapshot restore NAME")
+	}
+	if isRemote(prefix) {
+		log.Fatalf("snapshots can only be kept for local files")
+	}
+	name := snapshotName(args[0])
+	filename := filepath.Join(snapshotDir(), name+".json")
+	if _, err := os.Stat(filename); err != nil {
+		log.Fatalf("no snapshot named %s found in %s", name, snapshotDir())
+	}
+
+	data := readInputData()
+	schedule := data.Score(readPlacements(data, filename))
+	old, found := scoreSnapshot(data, prefix+".json")
+	writeOutputFiles(data, schedule)
+	if found {
+		fmt.Printf("restored snapshot %s: badness %d -> %d\n", name, old, schedule.Badness)
+	} else {
+		fmt.Printf("restored snapshot %s: badness %d\n", name, schedule.Badness)
+	}
+}
+
+// CommandSnapshotList lists the saved snapshots, oldest first, with
+// their badness when saved and scored against the current input
+func CommandSnapshotList(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("usage: schedule snapshot list")
+	}
+	matches, err := filepath.Glob(filepath.Join(snapshotDir(), "*.info.json"))
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	var snapshots []snapshotInfo
+	for _, match := range matches {
+		raw, err := os.ReadFile(match)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		var info snapshotInfo
+		if err := json.Unmarshal(raw, &info); err != nil {
+			log.Fatalf("reading %s: %v", match, err)
+		}
+		snapshots = append(snapshots, info)
+	}
+	sort.Slice(snapshots, func(a, b int) bool { return snapshots[a].Saved.Before(snapshots[b].Saved) })
+
+	out := openOutput()
+	defer out.Close()
+	if len(snapshots) == 0 {
+		fmt.Fprintf(out, "no snapshots saved in %s\n", snapshotDir())
+		return
+	}
+
+	// rescore each one in case the input has changed since
+	data := readInputData()
+	nameLen := len("Name")
+	for _, info := range snapshots {
+		if len(info.Name) > nameLen {
+			nameLen = len(info.Name)
+		}
+	}
+	fmt.Fprintf(out, "%-*s  %-16s  %7s  %7s  %s\n", nameLen, "Name", "Saved", "Badness", "Now", "By")
+	for _, info := range snapshots {
+		now := "-"
+		if badness, found := scoreSnapshot(data, filepath.Join(snapshotDir(), info.Name+".json")); found {
+			now = strconv.Itoa(badness)
+		}
+		fmt.Fprintf(out, "%-*s  %-16s  %7d  %7s  %s\n", nameLen, info.Name,
+			info.Saved.Local().Format("2006-01-02 15:04"), info.Badness, now, info.Actor)
+		if info.Note != "" {
+			fmt.Fprintf(out, "%-*s  %s\n", nameLen, "", info.Note)
+		}
+	}
+}
+
+// scoreSnapshot scores a schedule file against the input, if it can
+// be read and still matches it
+func scoreSnapshot(data *engine.InputData, filename string) (int, bool) {
+	fp, err := os.Open(filename)
+	if err != nil {
+		return 0, false
+	}
+	defer fp.Close()
+	placements, err := data.ReadJSON(fp)
+	if err != nil {
+		return 0, false
+	}
+	return data.Score(placements).Badness, true
+}
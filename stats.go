@@ -0,0 +1,275 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+func CommandStats(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	placements := readPlacements(data, prefix+".json")
+	schedule := data.Score(placements)
+
+	out := openOutput()
+	defer out.Close()
+
+	// the instance
+	fmt.Fprintf(out, "Input: %d rooms, %d times, %d instructors, %d sections, %d conflict lines, %d anticonflict lines\n",
+		len(data.Rooms), len(data.Times), len(data.Instructors), len(data.Courses),
+		len(data.Conflicts), len(data.AntiConflicts))
+	fmt.Fprintf(out, "Total badness: %d with %d problems\n", schedule.Badness, len(schedule.Problems))
+
+	// cohorts and instructors named in conflict lines
+	if len(data.Expansions) > 0 {
+		fmt.Fprintf(out, "\nNames expanded in conflict and anticonflict lines:\n")
+		for _, expansion := range data.Expansions {
+			fmt.Fprintf(out, "  %v\n", expansion)
+		}
+	}
+
+	// sections per instructor
+	nameLen := 0
+	for _, instructor := range data.Instructors {
+		if displayWidth(instructor.Name) > nameLen {
+			nameLen = displayWidth(instructor.Name)
+		}
+	}
+	fmt.Fprintf(out, "\nSections per instructor:\n")
+	for _, instructor := range data.Instructors {
+		shared := 0
+		for _, course := range instructor.Courses {
+			if len(course.Instructors) > 1 {
+				shared++
+			}
+		}
+		if shared > 0 {
+			fmt.Fprintf(out, "  %s  %2d (%d co-taught)\n", padRight(instructor.Name, nameLen), len(instructor.Courses), shared)
+		} else {
+			fmt.Fprintf(out, "  %s  %2d\n", padRight(instructor.Name, nameLen), len(instructor.Courses))
+		}
+	}
+
+	// room utilization
+	roomLen := 0
+	for _, room := range data.Rooms {
+		if displayWidth(room.Name) > roomLen {
+			roomLen = displayWidth(room.Name)
+		}
+	}
+	fmt.Fprintf(out, "\nRoom utilization:\n")
+	used := 0
+	for r, room := range data.Rooms {
+		count := 0
+		for t := range data.Times {
+			if schedule.RoomTimes[r][t].Course != nil {
+				count++
+			}
+		}
+		used += count
+		fmt.Fprintf(out, "  %s  %3d/%d slots  %5.1f%%\n",
+			padRight(room.Name, roomLen), count, len(data.Times), 100.0*float64(count)/float64(len(data.Times)))
+	}
+	total := len(data.Rooms) * len(data.Times)
+	fmt.Fprintf(out, "  %-*s  %3d/%d slots  %5.1f%%\n", roomLen, "all", used, total, 100.0*float64(used)/float64(total))
+
+	// slots in use by day band
+	fmt.Fprintf(out, "\nSlots in use by day and time of day:\n")
+	bands := make(map[string]int)
+	var bandNames []string
+	for t, time := range data.Times {
+		band := "other"
+		if prefix, hour := time.Split(); prefix != "" && hour != "" {
+			band = strings.ToUpper(prefix) + " afternoon"
+			if time.Start < 12*60 {
+				band = strings.ToUpper(prefix) + " morning"
+			}
+		}
+		if _, present := bands[band]; !present {
+			bandNames = append(bandNames, band)
+			bands[band] = 0
+		}
+		for r := range data.Rooms {
+			if schedule.RoomTimes[r][t].Course != nil {
+				bands[band]++
+			}
+		}
+	}
+	for _, band := range bandNames {
+		fmt.Fprintf(out, "  %-14s  %3d\n", band, bands[band])
+	}
+
+	// conflicts are violated when two sections share a time slot
+	// and at least one of them starts there, the same as in Score
+	const (
+		slotFree = iota
+		slotStart
+		slotSpillover
+	)
+	slots := make([][]int, len(data.Courses))
+	for i := range slots {
+		slots[i] = make([]int, len(data.Times))
+	}
+	starts := make(map[string][]int)
+	for _, placement := range placements {
+		lst := slots[placement.Course.ID]
+		lst[placement.Time] = slotStart
+		for i := 1; i < placement.Course.SlotsNeeded(data.Times[placement.Time]); i++ {
+			lst[placement.Time+i] = slotSpillover
+		}
+		starts[placement.Course.Name] = append(starts[placement.Course.Name], placement.Time)
+	}
+	hardOK, hardBad, softOK, softBad := 0, 0, 0, 0
+	for _, a := range data.Courses {
+		for _, b := range data.Courses[a.ID+1:] {
+			badness := a.ConflictBadness[b.ID]
+			if badness == engine.NoConflict {
+				continue
+			}
+			overlap := false
+			for t := range data.Times {
+				x, y := slots[a.ID][t], slots[b.ID][t]
+				if x != slotFree && y != slotFree && (x == slotStart || y == slotStart) {
+					overlap = true
+					break
+				}
+			}
+			switch {
+			case badness < 0 && overlap:
+				hardBad++
+			case badness < 0:
+				hardOK++
+			case overlap:
+				softBad++
+			default:
+				softOK++
+			}
+		}
+	}
+	antiOK, antiBad := 0, 0
+	// anticonflicts are satisfied when some pair of sections start together
+	seen := make(map[engine.CoursePair]bool)
+	for _, anticonflict := range data.AntiConflicts {
+		for i, a := range anticonflict.Courses {
+			for _, b := range anticonflict.Courses[i+1:] {
+				if a > b {
+					a, b = b, a
+				}
+				if seen[engine.CoursePair{A: a, B: b}] {
+					continue
+				}
+				seen[engine.CoursePair{A: a, B: b}] = true
+				satisfied := false
+				for _, ta := range starts[a] {
+					for _, tb := range starts[b] {
+						if ta == tb {
+							satisfied = true
+						}
+					}
+				}
+				if satisfied {
+					antiOK++
+				} else {
+					antiBad++
+				}
+			}
+		}
+	}
+	fmt.Fprintf(out, "\nConflicts between pairs of sections:\n")
+	fmt.Fprintf(out, "  hard conflicts:  %3d satisfied, %3d violated\n", hardOK, hardBad)
+	fmt.Fprintf(out, "  soft conflicts:  %3d satisfied, %3d violated\n", softOK, softBad)
+	fmt.Fprintf(out, "  anticonflicts:   %3d satisfied, %3d violated\n", antiOK, antiBad)
+
+	// badness by category
+	count := make(map[string]int)
+	badness := make(map[string]int)
+	var categories []string
+	for _, problem := range schedule.Problems {
+		category := problem.Category()
+		if _, present := count[category]; !present {
+			categories = append(categories, category)
+		}
+		count[category]++
+		badness[category] += problem.Badness
+	}
+	sort.Slice(categories, func(a, b int) bool {
+		if badness[categories[a]] != badness[categories[b]] {
+			return badness[categories[a]] > badness[categories[b]]
+		}
+		return categories[a] < categories[b]
+	})
+	categoryLen := 0
+	for _, category := range categories {
+		if len(category) > categoryLen {
+			categoryLen = len(category)
+		}
+	}
+	fmt.Fprintf(out, "\nBadness by category:\n")
+	for _, category := range categories {
+		share := 0.0
+		if schedule.Badness > 0 {
+			share = 100.0 * float64(badness[category]) / float64(schedule.Badness)
+		}
+		fmt.Fprintf(out, "  %-*s  %3d problems  badness %7d  %5.1f%%\n",
+			categoryLen, category, count[category], badness[category], share)
+	}
+
+	if byDepartment {
+		writeDepartmentStats(out, data, schedule)
+	}
+}
+
+// writeDepartmentStats writes the number of sections and the badness
+// of the problems tied to each department. A problem that involves more
+// than one department counts for each of them, so the shares can add
+// up to more than 100%.
+func writeDepartmentStats(out io.Writer, data *engine.InputData, schedule engine.Schedule) {
+	departments := data.Departments()
+	nameLen := len("other")
+	for _, department := range departments {
+		if displayWidth(department) > nameLen {
+			nameLen = displayWidth(department)
+		}
+	}
+	fmt.Fprintf(out, "\nSections by department:\n")
+	for _, department := range departments {
+		sections, placed := 0, 0
+		for _, course := range data.Courses {
+			if course.Main != nil || course.Department() != department {
+				continue
+			}
+			sections++
+			for _, placement := range schedule.Placements {
+				if placement.Course == course {
+					placed++
+					break
+				}
+			}
+		}
+		fmt.Fprintf(out, "  %s  %3d sections  %3d placed\n", padRight(departmentName(department), nameLen), sections, placed)
+	}
+
+	fmt.Fprintf(out, "\nBadness by department:\n")
+	for _, group := range problemsByDepartment(data, schedule.Problems) {
+		share := 0.0
+		if schedule.Badness > 0 {
+			share = 100.0 * float64(group.Badness) / float64(schedule.Badness)
+		}
+		fmt.Fprintf(out, "  %s  %3d problems  badness %7d  %5.1f%%\n",
+			padRight(group.Department, nameLen), len(group.Problems), group.Badness, share)
+	}
+}
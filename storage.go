@@ -0,0 +1,264 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Files named s3://bucket/key or gs://bucket/key are kept in Amazon S3
+// or Google Cloud Storage instead of on the local disk. S3 credentials
+// come from the usual AWS_* environment variables. Google credentials
+// come from --credentials or GOOGLE_APPLICATION_CREDENTIALS, or from
+// the metadata server when running in Google Cloud.
+
+func isRemote(name string) bool {
+	return strings.HasPrefix(name, "s3://") || strings.HasPrefix(name, "gs://")
+}
+
+// openFile opens a local or remote file for reading. A remote file
+// that does not exist gives an error that satisfies os.IsNotExist.
+func openFile(name string) (io.ReadCloser, error) {
+	if !isRemote(name) {
+		return os.Open(name)
+	}
+	res, err := remoteRequest(http.MethodGet, name, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	default:
+		res.Body.Close()
+		return nil, fmt.Errorf("reading %s: %s", name, res.Status)
+	}
+}
+
+// putRemote stores a remote file, replacing it whole
+func putRemote(name string, contents []byte, contentType string) error {
+	res, err := remoteRequest(http.MethodPut, name, contents, contentType)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("writing %s: %s", name, res.Status)
+	}
+	return nil
+}
+
+// removeRemote deletes a remote file
+func removeRemote(name string) error {
+	res, err := remoteRequest(http.MethodDelete, name, nil, "")
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("deleting %s: %s", name, res.Status)
+	}
+	return nil
+}
+
+func remoteRequest(method, name string, body []byte, contentType string) (*http.Response, error) {
+	scheme := name[:strings.Index(name, "://")]
+	path := name[len(scheme)+3:]
+	slash := strings.Index(path, "/")
+	if slash < 1 || slash == len(path)-1 {
+		return nil, fmt.Errorf("%s must be in the form %s://bucket/name", name, scheme)
+	}
+	bucket, key := path[:slash], path[slash+1:]
+
+	var req *http.Request
+	var err error
+	switch scheme {
+	case "s3":
+		req, err = newS3Request(method, bucket, key, body, contentType, time.Now())
+	default:
+		req, err = newGCSRequest(method, bucket, key, body, contentType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return storageClient.Do(req)
+}
+
+// storageClient gives up on a request that stalls. Schedules are
+// written while the search is waiting on them, so a hung upload would
+// otherwise stop every worker.
+var storageClient = &http.Client{Timeout: time.Minute}
+
+// Google Cloud Storage, through its XML API
+
+const storageScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+var storageToken = tokenCache{scope: storageScope}
+
+func newGCSRequest(method, bucket, key string, body []byte, contentType string) (*http.Request, error) {
+	target := "https://storage.googleapis.com/" + bucket + "/" + uriEncode(key, false)
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	keyFile := credentialsFile
+	if keyFile == "" {
+		keyFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	token, err := storageToken.get(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("getting an access token: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// Amazon S3, with requests signed using AWS Signature Version 4
+
+// An awsCredentials holds the keys used to sign S3 requests
+type awsCredentials struct {
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	Region       string
+}
+
+func awsCredentialsFromEnv() (awsCredentials, error) {
+	creds := awsCredentials{
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Region:       os.Getenv("AWS_REGION"),
+	}
+	if creds.Region == "" {
+		creds.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if creds.Region == "" {
+		creds.Region = "us-east-1"
+	}
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return creds, fmt.Errorf("set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to use S3")
+	}
+	return creds, nil
+}
+
+func newS3Request(method, bucket, key string, body []byte, contentType string, now time.Time) (*http.Request, error) {
+	creds, err := awsCredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// AWS_ENDPOINT_URL points at an S3-compatible service instead,
+	// using path-style addressing
+	target := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, creds.Region, uriEncode(key, false))
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		target = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + uriEncode(key, false)
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	creds.sign(req, body, now)
+	return req, nil
+}
+
+// sign adds the headers for AWS Signature Version 4 to a request. It
+// signs the host, any headers already set, and the x-amz-* headers it
+// adds.
+func (creds awsCredentials) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+	scope := day + "/" + creds.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	mac := func(key []byte, msg string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(msg))
+		return h.Sum(nil)
+	}
+	signingKey := mac(mac(mac(mac([]byte("AWS4"+creds.SecretKey), day), creds.Region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(mac(signingKey, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKey, scope, signedHeaders, signature))
+}
+
+// uriEncode percent-encodes everything but the unreserved characters,
+// and also slashes if encodeSlash is set, as AWS signing requires
+func uriEncode(s string, encodeSlash bool) string {
+	var out strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			out.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			out.WriteByte(b)
+		default:
+			fmt.Fprintf(&out, "%%%02X", b)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+)
+
+// timeStyle is how times are shown to people: engine.ClockRaw for the
+// slot names from the input, or engine.Clock12 or engine.Clock24
+var timeStyle = engine.ClockRaw
+
+// timeLabel gives the name of a time slot as it is shown to people
+func timeLabel(data *engine.InputData, t int) string {
+	return data.Times[t].Label(timeStyle)
+}
+
+// placementTime gives the time of a placement as it is shown to
+// people, which covers every slot it uses
+func placementTime(data *engine.InputData, placement engine.Placement) string {
+	return data.PlacementLabel(placement, timeStyle)
+}
+
+// printSchedule writes the schedule to standard output
+func printSchedule(data *engine.InputData, schedule engine.Schedule) {
+	writeSchedule(os.Stdout, data, schedule)
+}
+
+// writeSchedule writes the schedule grid followed by the total badness
+// and the list of known problems
+func writeSchedule(w io.Writer, data *engine.InputData, schedule engine.Schedule) {
+	nameLen := 0
+	for _, instructor := range data.Instructors {
+		for _, course := range instructor.Courses {
+			if displayWidth(course.Name) > nameLen {
+				nameLen = displayWidth(course.Name)
+			}
+			if course.Instructors[0] == instructor {
+				if len(course.Instructors) > 1 {
+					if displayWidth(instructor.Name)+1 > nameLen {
+						nameLen = displayWidth(instructor.Name) + 1
+					}
+				} else {
+					if displayWidth(instructor.Name) > nameLen {
+						nameLen = displayWidth(instructor.Name)
+					}
+				}
+			}
+		}
+	}
+	roomLen := 0
+	var rooms []int
+	for r, room := range data.Rooms {
+		if !filter.room(data, r) {
+			continue
+		}
+		rooms = append(rooms, r)
+		if displayWidth(room.Name) > roomLen {
+			roomLen = displayWidth(room.Name)
+		}
+	}
+	if roomLen > nameLen {
+		nameLen = roomLen
+	}
+	timeLen := 0
+	for t := range data.Times {
+		if n := displayWidth(timeLabel(data, t)); n > timeLen {
+			timeLen = n
+		}
+	}
+
+	hyphens := ""
+	dots := ""
+	for i := 0; i < nameLen; i++ {
+		hyphens += "-"
+		dots += "."
+	}
+	fmt.Fprintf(w, "%*s ", timeLen, "")
+	for _, r := range rooms {
+		pad := (nameLen - roomLen) / 2
+		fmt.Fprintf(w, "  %*s%s ", pad, "", padRight(data.Rooms[r].Name, nameLen-pad))
+	}
+	fmt.Fprintln(w)
+	for t := range data.Times {
+		fmt.Fprintf(w, "%*s ", timeLen, "")
+		for _, r := range rooms {
+			cell := schedule.RoomTimes[r][t]
+			switch {
+			case cell.IsSpillover:
+				fmt.Fprintf(w, "+ %-*s ", nameLen, "")
+			default:
+				fmt.Fprintf(w, "+-%s-", hyphens)
+			}
+		}
+		fmt.Fprintln(w, "+")
+		fmt.Fprintf(w, "%s ", padLeft(timeLabel(data, t), timeLen))
+		for _, r := range rooms {
+			cell := schedule.RoomTimes[r][t]
+			switch {
+			case cell.Course != nil && !cell.IsSpillover:
+				instructorName := cell.Course.Instructors[0].Name
+				if len(cell.Course.Instructors) > 1 {
+					instructorName += "+"
+				}
+				fmt.Fprintf(w, "| %s ", padRight(instructorName, nameLen))
+			default:
+				fmt.Fprintf(w, "| %-*s ", nameLen, "")
+			}
+		}
+		fmt.Fprintln(w, "|")
+		fmt.Fprintf(w, "%*s ", timeLen, "")
+		for _, r := range rooms {
+			cell := schedule.RoomTimes[r][t]
+			switch {
+			case cell.Course != nil && !cell.IsSpillover:
+				fmt.Fprintf(w, "| %s ", padRight(cell.Course.Name, nameLen))
+			case cell.Course != nil && useColor:
+				// dim the name in the extra slots of multi-slot courses
+				fmt.Fprintf(w, "| %s ", colorize(ansiDim, padRight(cell.Course.Name, nameLen)))
+			default:
+				fmt.Fprintf(w, "| %-*s ", nameLen, "")
+			}
+		}
+		fmt.Fprintln(w, "|")
+	}
+	fmt.Fprintf(w, "%*s ", timeLen, "")
+	for range rooms {
+		fmt.Fprintf(w, "+-%s-", hyphens)
+	}
+	fmt.Fprintln(w, "+")
+	fmt.Fprintln(w)
+	writeSectionList(w, schedule.Unplaced, "%d section(s) could not be placed and must be fixed by hand:")
+	writeSectionList(w, schedule.Dropped, "%d optional section(s) were dropped:")
+	if filter.active() {
+		fmt.Fprintf(w, "Total badness %d, of which %d is from the following known problems:\n", schedule.Badness, problemBadness(schedule.Problems))
+	} else {
+		fmt.Fprintf(w, "Total badness %d with the following known problems:\n", schedule.Badness)
+	}
+	if !byDepartment {
+		writeProblems(w, schedule.Problems, "* ")
+		return
+	}
+	for _, group := range problemsByDepartment(data, schedule.Problems) {
+		fmt.Fprintf(w, "%s: badness %d from %d problem(s)\n", group.Department, group.Badness, len(group.Problems))
+		writeProblems(w, group.Problems, "  * ")
+	}
+}
+
+// writeProblems writes one line per problem, colored by its badness
+func writeProblems(w io.Writer, problems []engine.Problem, bullet string) {
+	for _, problem := range problems {
+		if color := badnessColor(problem.Badness); color != "" {
+			fmt.Fprintln(w, bullet+colorize(color, problem.Message))
+		} else {
+			fmt.Fprintln(w, bullet+problem.Message)
+		}
+	}
+}
+
+// writeSectionList writes a heading with the number of sections
+// followed by a line for each section, or nothing if there are none
+func writeSectionList(w io.Writer, courses []*engine.Course, heading string) {
+	if len(courses) == 0 {
+		return
+	}
+	fmt.Fprintf(w, heading+"\n", len(courses))
+	for _, course := range courses {
+		var names []string
+		for _, instructor := range course.Instructors {
+			names = append(names, instructor.Name)
+		}
+		fmt.Fprintf(w, "* %s taught by %s\n", course.SectionID(), strings.Join(names, " and "))
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,323 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+// terminal control for the full-screen editor, which always uses
+// highlighting no matter how --color is set
+const (
+	ansiClearScreen = "\x1b[H\x1b[2J"
+	ansiAltScreen   = "\x1b[?1049h\x1b[?25l"
+	ansiMainScreen  = "\x1b[?25h\x1b[?1049l"
+
+	// the number of alternatives listed for the selected course
+	tuiOptions = 9
+)
+
+// tuiState is everything the interactive editor needs between keystrokes
+type tuiState struct {
+	data     *engine.InputData
+	sections []*engine.Section
+	schedule engine.Schedule
+	undo     [][]engine.Placement
+
+	// the cursor position in the grid
+	room, time int
+
+	// the course whose alternatives are listed, if any
+	selected *engine.Course
+	options  []engine.MoveOption
+
+	message   string
+	dirty     bool
+	quitArmed bool
+}
+
+func CommandTUI(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	placements := readPlacements(data, prefix+".json")
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		log.Fatalf("the tui command needs a terminal: %v", err)
+	}
+	defer tty.Close()
+
+	// put the terminal in raw mode so we get each key as it is pressed
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		log.Fatalf("reading terminal settings: %v", err)
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		log.Fatalf("setting terminal to raw mode: %v", err)
+	}
+	fmt.Fprint(tty, ansiAltScreen)
+	defer func() {
+		fmt.Fprint(tty, ansiMainScreen)
+		stty(tty, strings.TrimSpace(saved))
+	}()
+
+	state := &tuiState{
+		data:     data,
+		sections: makeSectionList(data),
+		schedule: data.Score(placements),
+		message:  "arrows: move  enter: select/move here  1-9: take an alternative  u: undo  w: write  q: quit",
+	}
+
+	key := make([]byte, 3)
+	for {
+		state.render(tty)
+		n, err := tty.Read(key[:1])
+		if err != nil || n == 0 {
+			return
+		}
+
+		// arrow keys arrive as ESC [ A-D
+		if key[0] == 0x1b {
+			if n, err := tty.Read(key[1:3]); err != nil || n < 2 || key[1] != '[' {
+				continue
+			}
+			switch key[2] {
+			case 'A':
+				key[0] = 'k'
+			case 'B':
+				key[0] = 'j'
+			case 'C':
+				key[0] = 'l'
+			case 'D':
+				key[0] = 'h'
+			default:
+				continue
+			}
+		}
+		if state.handle(key[0]) {
+			return
+		}
+	}
+}
+
+// stty runs the stty command on the terminal and returns its output
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// handle processes one key and reports whether it is time to quit
+func (state *tuiState) handle(key byte) bool {
+	if key != 'q' {
+		state.quitArmed = false
+	}
+	switch key {
+	case 'k':
+		if state.time > 0 {
+			state.time--
+		}
+	case 'j':
+		if state.time+1 < len(state.data.Times) {
+			state.time++
+		}
+	case 'h':
+		if state.room > 0 {
+			state.room--
+		}
+	case 'l':
+		if state.room+1 < len(state.data.Rooms) {
+			state.room++
+		}
+
+	case '\r', '\n', ' ':
+		cell := state.schedule.RoomTimes[state.room][state.time]
+		if option := state.optionAt(state.room, state.time); option >= 0 {
+			state.apply(state.options[option])
+		} else if cell.Course != nil {
+			state.selected = cell.Course
+			state.options = state.data.MoveOptions(state.sections, state.schedule.Placements, cell.Course)
+			state.message = fmt.Sprintf("%s has %d alternatives", cell.Course.SectionID(), len(state.options))
+		} else {
+			state.selected, state.options = nil, nil
+			state.message = ""
+		}
+
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		option := int(key - '1')
+		if option < len(state.options) && option < tuiOptions {
+			state.apply(state.options[option])
+		}
+
+	case 'u':
+		if len(state.undo) == 0 {
+			state.message = "nothing to undo"
+			break
+		}
+		placements := state.undo[len(state.undo)-1]
+		state.undo = state.undo[:len(state.undo)-1]
+		state.schedule = state.data.Score(placements)
+		state.dirty = true
+		state.reselect()
+		state.message = "undone"
+
+	case 'w':
+		writeOutputFiles(state.data, state.schedule)
+		state.dirty = false
+		state.message = fmt.Sprintf("wrote %s.json with badness %d", prefix, state.schedule.Badness)
+
+	case 'q', 3:
+		if key == 'q' && state.dirty && !state.quitArmed {
+			state.quitArmed = true
+			state.message = "there are unwritten changes: press q again to quit without writing or w to write them"
+			break
+		}
+		return true
+	}
+	return false
+}
+
+// apply moves the selected course and remembers how to undo it
+func (state *tuiState) apply(option engine.MoveOption) {
+	old := state.schedule
+	moved, err := state.data.MoveCourse(state.sections, old.Placements, state.selected, option.Room, option.Time)
+	if err != nil {
+		state.message = err.Error()
+		return
+	}
+	state.undo = append(state.undo, old.Placements)
+	state.schedule = state.data.Score(moved)
+	state.dirty = true
+	state.room, state.time = option.Room, option.Time
+	state.reselect()
+	state.message = fmt.Sprintf("moved %s to %s %s: badness %d (%+d)",
+		state.selected.SectionID(), state.data.Rooms[option.Room].Name, state.data.Times[option.Time].Name,
+		state.schedule.Badness, state.schedule.Badness-old.Badness)
+}
+
+// reselect refreshes the alternatives after the schedule changes
+func (state *tuiState) reselect() {
+	if state.selected != nil {
+		state.options = state.data.MoveOptions(state.sections, state.schedule.Placements, state.selected)
+	}
+}
+
+// optionAt finds which listed alternative starts at a cell, or -1
+func (state *tuiState) optionAt(room, time int) int {
+	for i, option := range state.options {
+		if i >= tuiOptions {
+			break
+		}
+		if option.Room == room && option.Time == time {
+			return i
+		}
+	}
+	return -1
+}
+
+func (state *tuiState) render(tty *os.File) {
+	data := state.data
+	timeLen, colLen := 0, 3
+	for _, time := range data.Times {
+		if displayWidth(time.Name) > timeLen {
+			timeLen = displayWidth(time.Name)
+		}
+	}
+	for _, room := range data.Rooms {
+		if displayWidth(room.Name) > colLen {
+			colLen = displayWidth(room.Name)
+		}
+	}
+	for _, course := range data.Courses {
+		if displayWidth(course.Name) > colLen {
+			colLen = displayWidth(course.Name)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(ansiClearScreen)
+	fmt.Fprintf(buf, "%*s", timeLen, "")
+	for _, room := range data.Rooms {
+		fmt.Fprintf(buf, "  %s", padRight(room.Name, colLen))
+	}
+	buf.WriteString("\r\n")
+	for t, time := range data.Times {
+		fmt.Fprint(buf, padLeft(time.Name, timeLen))
+		for r := range data.Rooms {
+			cell := state.schedule.RoomTimes[r][t]
+			text, style := "", ""
+			switch {
+			case cell.Course != nil && cell.IsSpillover:
+				text, style = cell.Course.Name, ansiDim
+			case cell.Course != nil:
+				text = cell.Course.Name
+				if cell.Course == state.selected {
+					style = ansiBold
+				}
+			case state.optionAt(r, t) >= 0:
+				text, style = fmt.Sprintf("<%d>", state.optionAt(r, t)+1), ansiGreen
+			}
+			if r == state.room && t == state.time {
+				style += ansiInvert
+			}
+			buf.WriteString("  ")
+			if style != "" {
+				buf.WriteString(style)
+			}
+			fmt.Fprint(buf, padRight(text, colLen))
+			if style != "" {
+				buf.WriteString(ansiReset)
+			}
+		}
+		buf.WriteString("\r\n")
+	}
+
+	fmt.Fprintf(buf, "\r\nBadness %d with %d problems", state.schedule.Badness, len(state.schedule.Problems))
+	if state.dirty {
+		buf.WriteString(" (not written)")
+	}
+	buf.WriteString("\r\n")
+	if state.selected != nil {
+		var current engine.Placement
+		for _, placement := range state.schedule.Placements {
+			if placement.Course == state.selected {
+				current = placement
+			}
+		}
+		var instructors []string
+		for _, instructor := range state.selected.Instructors {
+			instructors = append(instructors, instructor.Name)
+		}
+		fmt.Fprintf(buf, "\r\n%s (%s) in %s at %s; best alternatives:\r\n",
+			state.selected.SectionID(), strings.Join(instructors, ", "),
+			data.Rooms[current.Room].Name, data.Times[current.Time].Name)
+		for i, option := range state.options {
+			if i >= tuiOptions {
+				break
+			}
+			fmt.Fprintf(buf, "  %d) %s %s  badness %d (%+d)\r\n", i+1,
+				padRight(data.Rooms[option.Room].Name, colLen), padRight(data.Times[option.Time].Name, timeLen),
+				option.Badness, option.Badness-state.schedule.Badness)
+		}
+		if len(state.options) == 0 {
+			buf.WriteString("  none without moving something else first\r\n")
+		}
+	}
+	fmt.Fprintf(buf, "\r\n%s", state.message)
+	buf.WriteTo(tty)
+}
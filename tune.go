@@ -0,0 +1,217 @@
+// +build !wasm
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tuneBudget         = time.Minute
+	tuneTrials         = 12
+	tuneRepeat         = 1
+	tunePins           = []float64{90, 95, 98}
+	tunePinDevs        = []float64{2, 5}
+	tuneWarmups        = []time.Duration{5 * time.Second, 15 * time.Second}
+	tuneRestartLocals  = []time.Duration{15 * time.Second, 30 * time.Second}
+	tuneRestartGlobals = []time.Duration{30 * time.Second, 60 * time.Second}
+	tuneSaveFile       = ""
+)
+
+// A TuneResult is the outcome of running gen with one combination of settings
+type TuneResult struct {
+	Settings engine.GenSettings
+	Badness  []int
+	Attempts int
+}
+
+// Best is the lowest badness over all of the runs, or worst if none
+// of them found a valid schedule
+func (result *TuneResult) Best() int {
+	best := engine.Worst
+	for _, badness := range result.Badness {
+		if badness < best {
+			best = badness
+		}
+	}
+	return best
+}
+
+// Mean is the average badness over all of the runs, where a run
+// that found no valid schedule counts as worst
+func (result *TuneResult) Mean() float64 {
+	if len(result.Badness) == 0 {
+		return float64(engine.Worst)
+	}
+	total := 0.0
+	for _, badness := range result.Badness {
+		total += float64(badness)
+	}
+	return total / float64(len(result.Badness))
+}
+
+func CommandTune(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		log.Fatalf("unknown option: %s", strings.Join(args, " "))
+	}
+
+	if tuneBudget <= 0 {
+		log.Fatalf("time must be > 0")
+	}
+	if tuneTrials < 0 {
+		log.Fatalf("trials must be >= 0")
+	}
+	if tuneRepeat < 1 {
+		log.Fatalf("repeat must be >= 1")
+	}
+
+	// every combination of the candidate values
+	var grid []engine.GenSettings
+	for _, p := range tunePins {
+		for _, dev := range tunePinDevs {
+			for _, warm := range tuneWarmups {
+				for _, local := range tuneRestartLocals {
+					for _, global := range tuneRestartGlobals {
+						settings := genSettingsFromFlags()
+						settings.Duration = tuneBudget
+						settings.Pin, settings.PinDev = p, dev
+						settings.Warmup, settings.RestartLocal, settings.RestartGlobal = warm, local, global
+						if err := settings.Check(); err != nil {
+							log.Fatalf("%v", err)
+						}
+						grid = append(grid, settings)
+					}
+				}
+			}
+		}
+	}
+	if len(grid) == 0 {
+		log.Fatalf("no settings to try")
+	}
+	if tuneTrials > 0 && tuneTrials < len(grid) {
+		rand.Shuffle(len(grid), func(a, b int) { grid[a], grid[b] = grid[b], grid[a] })
+		grid = grid[:tuneTrials]
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// make sure every section has somewhere it can go before starting
+	makeSectionList(data)
+
+	log.Printf("trying %d combinations of settings %d time(s) each for %v per run (about %v in all)",
+		len(grid), tuneRepeat, tuneBudget, time.Duration(len(grid)*tuneRepeat)*tuneBudget)
+	catchInterrupt()
+
+	var results []*TuneResult
+	for i, settings := range grid {
+		result := &TuneResult{Settings: settings}
+		for n := 0; n < tuneRepeat; n++ {
+			best, stats, err := engine.Search(interruptContext, data, engine.Options{GenSettings: settings})
+			if err != nil {
+				log.Fatalf("%v", err)
+			}
+			if isInterrupted() {
+				// a partial run would not be a fair comparison
+				break
+			}
+			result.Attempts += stats.Successful + stats.Failed
+			if len(best.Placements) > 0 {
+				result.Badness = append(result.Badness, best.Badness)
+			} else {
+				result.Badness = append(result.Badness, engine.Worst)
+			}
+		}
+		if isInterrupted() {
+			break
+		}
+		results = append(results, result)
+		log.Printf("%d/%d: %s: best badness %s", i+1, len(grid), settingsSummary(settings), badnessString(result.Best()))
+	}
+	if len(results) == 0 {
+		log.Printf("interrupted before any settings were tried")
+		os.Exit(ExitInterrupted)
+	}
+
+	sort.SliceStable(results, func(a, b int) bool {
+		if results[a].Mean() != results[b].Mean() {
+			return results[a].Mean() < results[b].Mean()
+		}
+		return results[a].Best() < results[b].Best()
+	})
+
+	out := openOutput()
+	if err := WriteTuneResults(out, results); err != nil {
+		log.Fatalf("writing results: %v", err)
+	}
+	out.Close()
+
+	if tuneSaveFile != "" {
+		if err := saveTuneSettings(tuneSaveFile, results[0]); err != nil {
+			log.Fatalf("saving settings: %v", err)
+		}
+		log.Printf("best settings written to %s", tuneSaveFile)
+	}
+	if isInterrupted() {
+		os.Exit(ExitInterrupted)
+	}
+}
+
+func settingsSummary(settings engine.GenSettings) string {
+	return fmt.Sprintf("pin %g, pindev %g, warmup %v, restartlocal %v, restartglobal %v",
+		settings.Pin, settings.PinDev, settings.Warmup, settings.RestartLocal, settings.RestartGlobal)
+}
+
+func badnessString(badness int) string {
+	if badness >= engine.Worst {
+		return "none"
+	}
+	return fmt.Sprintf("%d", badness)
+}
+
+// WriteTuneResults writes a table of results, best first
+func WriteTuneResults(w io.Writer, results []*TuneResult) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%5s  %6s  %8s  %12s  %13s  %6s  %8s  %10s\n",
+		"pin", "pindev", "warmup", "restartlocal", "restartglobal", "best", "mean", "attempts")
+	for _, result := range results {
+		settings := result.Settings
+		mean := "none"
+		if result.Mean() < float64(engine.Worst) {
+			mean = fmt.Sprintf("%.1f", result.Mean())
+		}
+		fmt.Fprintf(buf, "%5g  %6g  %8v  %12v  %13v  %6s  %8s  %10d\n",
+			settings.Pin, settings.PinDev, settings.Warmup, settings.RestartLocal, settings.RestartGlobal,
+			badnessString(result.Best()), mean, result.Attempts)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// saveTuneSettings writes the winning settings as a config file
+// with a [gen] table, ready to use with --config or to copy into
+// schedule.toml
+func saveTuneSettings(filename string, result *TuneResult) error {
+	settings := result.Settings
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "# found by schedule tune on %s: best badness %s in %v\n",
+		time.Now().Format("2006-01-02"), badnessString(result.Best()), settings.Duration)
+	fmt.Fprintf(buf, "[gen]\n")
+	fmt.Fprintf(buf, "pin = %g\n", settings.Pin)
+	fmt.Fprintf(buf, "pindev = %g\n", settings.PinDev)
+	fmt.Fprintf(buf, "warmup = %q\n", settings.Warmup)
+	fmt.Fprintf(buf, "restartlocal = %q\n", settings.RestartLocal)
+	fmt.Fprintf(buf, "restartglobal = %q\n", settings.RestartGlobal)
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
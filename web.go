@@ -4,16 +4,30 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
 	"syscall/js"
+	"time"
+
+	"github.com/russross/schedule/engine"
 )
 
 const nbsp string = "\u00A0"
 
-var globalInputData *InputData
-var verbose bool
+var globalInputData *engine.InputData
+var globalSchedule engine.Schedule
+
+// A consoleLogger sends engine messages to the JavaScript console,
+// as info messages tagged with the call that led to them
+type consoleLogger struct {
+	name string
+}
+
+func (logger consoleLogger) Printf(format string, args ...interface{}) {
+	js.Global().Get("console").Call("info", "schedule."+logger.name+":", fmt.Sprintf(format, args...))
+}
 
 func main() {
 	log.SetFlags(log.Ltime)
@@ -21,6 +35,24 @@ func main() {
 	js.Global().Get("schedule").Set("setSchedule", js.FuncOf(WasmSetSchedule))
 	js.Global().Get("schedule").Set("slotsNeeded", js.FuncOf(WasmSlotsNeeded))
 	js.Global().Get("schedule").Set("canonicalOutput", js.FuncOf(WasmCanonicalOutput))
+	js.Global().Get("schedule").Set("previewMove", js.FuncOf(WasmPreviewMove))
+	js.Global().Get("schedule").Set("optimize", js.FuncOf(WasmOptimize))
+	js.Global().Get("schedule").Set("stopOptimize", js.FuncOf(WasmStopOptimize))
+	js.Global().Get("schedule").Set("suggestSwaps", js.FuncOf(WasmSuggestSwaps))
+	js.Global().Get("schedule").Set("undo", js.FuncOf(WasmUndo))
+	js.Global().Get("schedule").Set("redo", js.FuncOf(WasmRedo))
+	js.Global().Get("schedule").Set("history", js.FuncOf(WasmHistory))
+	js.Global().Get("schedule").Set("saveState", js.FuncOf(WasmSaveState))
+	js.Global().Get("schedule").Set("restoreState", js.FuncOf(WasmRestoreState))
+	js.Global().Get("schedule").Set("markSaved", js.FuncOf(WasmMarkSaved))
+	js.Global().Get("schedule").Set("isDirty", js.FuncOf(WasmIsDirty))
+	js.Global().Get("schedule").Set("checkInput", js.FuncOf(WasmCheckInput))
+	js.Global().Get("schedule").Set("setInput", js.FuncOf(WasmSetInput))
+	js.Global().Get("schedule").Set("setHighlight", js.FuncOf(WasmSetHighlight))
+	js.Global().Get("schedule").Set("problems", js.FuncOf(WasmProblems))
+	js.Global().Get("schedule").Set("getState", js.FuncOf(WasmGetState))
+	js.Global().Get("schedule").Set("calendar", js.FuncOf(WasmCalendar))
+	js.Global().Get("schedule").Set("inputSummary", js.FuncOf(WasmInputSummary))
 
 	// run forever
 	<-make(chan struct{})
@@ -56,12 +88,13 @@ func WasmSetSchedule(this js.Value, args []js.Value) interface{} {
 			return nil
 		}
 
-		data, err := Parse("schedule.txt", lines)
+		data, err := engine.Parse("schedule.txt", lines)
 		if err != nil {
 			log.Printf("schedule.setSchedule: parsing schedule.txt input: %v", err)
 			return nil
 		}
 		globalInputData = data
+		inputHash = hashInput(args[1].String())
 		log.Println("schedule.setSchedule: schedule.txt ingested and parsed")
 	}
 
@@ -72,9 +105,24 @@ func WasmSetSchedule(this js.Value, args []js.Value) interface{} {
 		return nil
 	}
 	schedule := globalInputData.Score(placements)
+	globalSchedule = schedule
+	recordHistory(schedule)
 
-	// create the table
+	renderSchedule(schedule)
+	return nil
+}
+
+// renderSchedule draws a schedule, its badness, and its problems on
+// the page, then has the page set up its event handlers again. Pages
+// without the grid table draw the schedule themselves using getState,
+// so nothing is drawn for them.
+func renderSchedule(schedule engine.Schedule) {
 	document := js.Global().Get("document")
+	if document.Type() != js.TypeObject || document.Call("getElementById", "grid").Type() != js.TypeObject {
+		return
+	}
+
+	// create the table
 	appendElement := func(parent js.Value, element string) js.Value {
 		elt := document.Call("createElement", element)
 		parent.Call("appendChild", elt)
@@ -128,6 +176,18 @@ func WasmSetSchedule(this js.Value, args []js.Value) interface{} {
 		}
 	}
 
+	// note the problems each course is involved in
+	courseProblems := make(map[*engine.Course][]string)
+	courseWorst := make(map[*engine.Course]int)
+	for i, problem := range schedule.Problems {
+		for _, course := range problem.Courses {
+			courseProblems[course] = append(courseProblems[course], fmt.Sprint(i))
+			if problem.Badness > courseWorst[course] {
+				courseWorst[course] = problem.Badness
+			}
+		}
+	}
+
 	// create one row per time slot
 	for ti, t := range globalInputData.Times {
 		tr := appendElement(tbody, "tr")
@@ -154,6 +214,9 @@ func WasmSetSchedule(this js.Value, args []js.Value) interface{} {
 				td.Call("setAttribute", "data-time-name", t.Name)
 				td.Call("setAttribute", "data-room-name", r.Name)
 				td.Call("setAttribute", "data-slots-available", slots)
+				if highlight.active() {
+					td.Call("setAttribute", "class", "dimmed")
+				}
 				appendText(td, nbsp)
 			default:
 				var index int
@@ -166,8 +229,24 @@ func WasmSetSchedule(this js.Value, args []js.Value) interface{} {
 				td.Call("setAttribute", "data-time-name", t.Name)
 				td.Call("setAttribute", "data-instructor-name", cell.Course.Instructors[0].Name)
 				td.Call("setAttribute", "data-instructor-course-index", index)
+				td.Call("setAttribute", "data-course-id", cell.Course.SectionID())
 				td.Call("setAttribute", "data-slots-available", 0)
 				td.Call("setAttribute", "draggable", "true")
+				var classes []string
+				if highlight.active() {
+					if highlight.matches(cell.Course) {
+						classes = append(classes, "highlighted")
+					} else {
+						classes = append(classes, "dimmed")
+					}
+				}
+				if list := courseProblems[cell.Course]; len(list) > 0 {
+					td.Call("setAttribute", "data-problems", strings.Join(list, " "))
+					classes = append(classes, severityClass(courseWorst[cell.Course]))
+				}
+				if len(classes) > 0 {
+					td.Call("setAttribute", "class", strings.Join(classes, " "))
+				}
 				slots := cell.Course.SlotsNeeded(t)
 				if slots > 1 {
 					td.Call("setAttribute", "rowspan", slots)
@@ -185,15 +264,96 @@ func WasmSetSchedule(this js.Value, args []js.Value) interface{} {
 
 	appendText(badness, fmt.Sprintf("Total badness %d with the following known problems:", schedule.Badness))
 
-	for _, problem := range schedule.Problems {
-		appendText(appendElement(problems, "li"), problem)
+	for i, problem := range schedule.Problems {
+		li := appendElement(problems, "li")
+		li.Call("setAttribute", "data-problem", i)
+		classes := severityClass(problem.Badness)
+		if highlight.active() && !highlight.matchesProblem(problem.Message) {
+			classes += " dimmed"
+		}
+		li.Call("setAttribute", "class", classes)
+		appendText(li, problem.Message)
 	}
 
 	log.Printf("schedule.setSchedule: schedule rendered")
 	js.Global().Get("schedule").Call("setupHover")
 	js.Global().Get("schedule").Call("setupDragDrop")
+	js.Global().Get("schedule").Call("setupProblems")
+}
 
-	return nil
+// severityClass is the class given to problems and the cells involved
+// in them, using the same levels as the terminal colors
+func severityClass(badness int) string {
+	switch {
+	case badness >= engine.Impossible:
+		return "severity-impossible"
+	case badness >= highBadness:
+		return "severity-high"
+	default:
+		return "severity-low"
+	}
+}
+
+// A ProblemCell is a cell of the grid involved in a problem. Room and
+// Time are where the course starts.
+type ProblemCell struct {
+	ID   string `json:"id"`
+	Room string `json:"room"`
+	Time string `json:"time"`
+}
+
+// A WebProblem is a problem with the schedule and the cells involved
+type WebProblem struct {
+	Message  string        `json:"message"`
+	Badness  int           `json:"badness"`
+	Category string        `json:"category"`
+	Severity string        `json:"severity"`
+	Cells    []ProblemCell `json:"cells"`
+}
+
+// Returns a list of WebProblem objects for the schedule being shown,
+// in the same order as the list on the page
+func WasmProblems(this js.Value, args []js.Value) interface{} {
+	if globalInputData == nil {
+		log.Printf("schedule.problems: setSchedule must be called first")
+		return nil
+	}
+	raw, err := json.Marshal(webProblems(globalSchedule))
+	if err != nil {
+		log.Printf("schedule.problems: %v", err)
+		return nil
+	}
+	return js.Global().Get("JSON").Call("parse", string(raw))
+}
+
+func webProblems(schedule engine.Schedule) []WebProblem {
+	where := make(map[*engine.Course]engine.Placement)
+	for _, placement := range schedule.Placements {
+		where[placement.Course] = placement
+	}
+	out := []WebProblem{}
+	for _, problem := range schedule.Problems {
+		elt := WebProblem{
+			Message:  problem.Message,
+			Badness:  problem.Badness,
+			Category: problem.Category(),
+			Severity: strings.TrimPrefix(severityClass(problem.Badness), "severity-"),
+			Cells:    []ProblemCell{},
+		}
+		for _, course := range problem.Courses {
+			placement, present := where[course]
+			if !present {
+				continue
+			}
+			elt.Cells = append(elt.Cells, ProblemCell{
+				ID:   course.SectionID(),
+				Room: globalInputData.Rooms[placement.Room].Name,
+				Time: globalInputData.Times[placement.Time].Name,
+			})
+		}
+		out = append(out, elt)
+	}
+	return out
 }
 
 func WasmSlotsNeeded(this js.Value, args []js.Value) interface{} {
@@ -238,6 +398,133 @@ func WasmSlotsNeeded(this js.Value, args []js.Value) interface{} {
 	return nil
 }
 
+// A MovePreview is the result of schedule.previewMove. If the move is
+// not allowed, Valid is false and Error says why.
+type MovePreview struct {
+	Valid   bool             `json:"valid"`
+	Error   string           `json:"error,omitempty"`
+	Current int              `json:"current"`
+	Badness int              `json:"badness"`
+	Delta   int              `json:"delta"`
+	Added   []PreviewProblem `json:"added"`
+	Removed []PreviewProblem `json:"removed"`
+}
+
+// A PreviewProblem is a problem added or removed by a move
+type PreviewProblem struct {
+	Message string `json:"message"`
+	Badness int    `json:"badness"`
+}
+
+// the section list for globalInputData, built on first use
+var globalSections []*engine.Section
+
+// Call with a course ID, a room name, and a time name. Scores the
+// schedule most recently given to setSchedule with that course moved
+// there (without changing it) and returns a MovePreview object with
+// the change in badness and the problems the move would add and remove.
+func WasmPreviewMove(this js.Value, args []js.Value) interface{} {
+	if len(args) != 3 {
+		log.Printf("schedule.previewMove: expected 3 arguments, found %d", len(args))
+		return nil
+	}
+	if globalInputData == nil || len(globalSchedule.Placements) == 0 {
+		log.Printf("schedule.previewMove: setSchedule must be called first")
+		return nil
+	}
+	data := globalInputData
+	if globalSections == nil {
+		sections, err := data.BuildSectionList()
+		if err != nil {
+			log.Printf("schedule.previewMove: %v", err)
+			return nil
+		}
+		globalSections = sections
+	}
+
+	preview := MovePreview{
+		Current: globalSchedule.Badness,
+		Badness: globalSchedule.Badness,
+		Added:   []PreviewProblem{},
+		Removed: []PreviewProblem{},
+	}
+	course, err := data.FindCourse(args[0].String())
+	var room, t int
+	if err == nil {
+		room, t, err = data.FindRoomTime(args[1].String(), args[2].String())
+	}
+	var placements []engine.Placement
+	if err == nil {
+		placements, err = data.MoveCourse(globalSections, globalSchedule.Placements, course, room, t)
+	}
+	if err != nil {
+		preview.Error = err.Error()
+	} else {
+		moved := data.Score(placements)
+		preview.Valid = true
+		preview.Badness = moved.Badness
+		preview.Delta = moved.Badness - globalSchedule.Badness
+		gone, added := engine.DiffProblems(globalSchedule.Problems, moved.Problems)
+		for _, problem := range added {
+			preview.Added = append(preview.Added, PreviewProblem{Message: problem.Message, Badness: problem.Badness})
+		}
+		for _, problem := range gone {
+			preview.Removed = append(preview.Removed, PreviewProblem{Message: problem.Message, Badness: problem.Badness})
+		}
+	}
+
+	raw, err := json.Marshal(preview)
+	if err != nil {
+		log.Printf("schedule.previewMove: %v", err)
+		return nil
+	}
+	return js.Global().Get("JSON").Call("parse", string(raw))
+}
+
+// Call with an instructor name (or an empty string for everyone) and
+// the first and last days of classes in YYYY-MM-DD form, or two empty
+// strings to use the term given in the input. Returns an iCalendar
+// file with the instructor's classes in the schedule being shown,
+// leaving out the input's closed days, or null if something is wrong
+// with the arguments.
+func WasmCalendar(this js.Value, args []js.Value) interface{} {
+	if len(args) != 3 {
+		log.Printf("schedule.calendar: expected 3 arguments, found %d", len(args))
+		return nil
+	}
+	if globalInputData == nil || len(globalSchedule.Placements) == 0 {
+		log.Printf("schedule.calendar: setSchedule must be called first")
+		return nil
+	}
+	term := globalInputData.Term
+	if args[1].String() != "" || args[2].String() != "" || term == nil {
+		first, last, err := engine.ParseTermDates(args[1].String(), args[2].String())
+		if err != nil {
+			log.Printf("schedule.calendar: %v", err)
+			return nil
+		}
+		term = globalInputData.TermDates(first, last)
+	}
+	name := args[0].String()
+	var instructors []*engine.Instructor
+	for _, instructor := range globalInputData.Instructors {
+		if name == "" || instructor.Name == name {
+			instructors = append(instructors, instructor)
+		}
+	}
+	if len(instructors) == 0 {
+		log.Printf("schedule.calendar: no instructor named %q", name)
+		return nil
+	}
+
+	builder := new(strings.Builder)
+	if err := globalInputData.WriteICal(builder, globalSchedule.Placements, instructors, term, time.Now()); err != nil {
+		log.Printf("schedule.calendar: %v", err)
+		return nil
+	}
+	return builder.String()
+}
+
 func WasmCanonicalOutput(this js.Value, args []js.Value) interface{} {
 	if len(args) != 2 {
 		log.Printf("schedule.canonicalOutput: expected 2 arguments, found %d", len(args))
@@ -258,7 +545,7 @@ func WasmCanonicalOutput(this js.Value, args []js.Value) interface{} {
 	}
 
 	builder := new(strings.Builder)
-	err = globalInputData.WriteJSON(builder, placements)
+	err = globalInputData.WriteJSON(builder, placements, nil)
 	if err != nil {
 		log.Printf("schedule.canonicalOutput: writing JSON: %v", err)
 		return nil
@@ -0,0 +1,311 @@
+// +build wasm
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"syscall/js"
+	"time"
+
+	"github.com/russross/schedule/engine"
+)
+
+// cancels the search running in the browser
+var optimizeCancel struct {
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+}
+
+// OptimizeOptions are the settings a page can give for a search.
+// Times are in seconds, and anything left out gets the same default
+// as the command-line tool (except for the total time).
+type OptimizeOptions struct {
+	Seconds       float64  `json:"seconds"`
+	Pin           *float64 `json:"pin"`
+	PinDev        *float64 `json:"pindev"`
+	Warmup        float64  `json:"warmup"`
+	RestartLocal  float64  `json:"restartlocal"`
+	RestartGlobal float64  `json:"restartglobal"`
+
+	// Log sends the search's progress messages to the console
+	Log bool `json:"log"`
+}
+
+// OptimizeProgress is passed to the progress callback about once a second
+type OptimizeProgress struct {
+	Elapsed  float64 `json:"elapsed"`
+	Attempts int     `json:"attempts"`
+	Failed   int     `json:"failed"`
+	Badness  *int    `json:"badness,omitempty"`
+	Mode     string  `json:"mode"`
+}
+
+// OptimizeResult is passed to the done callback when the search ends
+type OptimizeResult struct {
+	Badness  *int   `json:"badness,omitempty"`
+	Attempts int    `json:"attempts"`
+	Failed   int    `json:"failed"`
+	Stopped  bool   `json:"stopped,omitempty"`
+	GaveUp   bool   `json:"gaveup,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Call with the contents of schedule.txt, the contents of a schedule
+// to start from (or an empty string to start from scratch), the
+// options as JSON, and three callbacks: progress (called with an
+// OptimizeProgress as JSON), best (called with each new best schedule
+// in the current file format, at most a few times a second), and done
+// (called with an OptimizeResult as JSON). The search runs in the
+// background; call this from a Web Worker so the page stays responsive.
+func WasmOptimize(this js.Value, args []js.Value) interface{} {
+	if len(args) != 6 {
+		log.Printf("schedule.optimize: expected 6 arguments, found %d", len(args))
+		return nil
+	}
+	input, start, options := args[0].String(), args[1].String(), args[2].String()
+	onProgress, onBest, onDone := args[3], args[4], args[5]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	optimizeCancel.mutex.Lock()
+	optimizeCancel.cancel = cancel
+	optimizeCancel.mutex.Unlock()
+	go func() {
+		defer cancel()
+		result := optimize(ctx, input, start, options, onProgress, onBest)
+		raw, _ := json.Marshal(result)
+		onDone.Invoke(string(raw))
+	}()
+	return nil
+}
+
+// Stops the search started by schedule.optimize, which then calls its
+// done callback with the best schedule found so far already sent
+func WasmStopOptimize(this js.Value, args []js.Value) interface{} {
+	optimizeCancel.mutex.Lock()
+	if optimizeCancel.cancel != nil {
+		optimizeCancel.cancel()
+	}
+	optimizeCancel.mutex.Unlock()
+	return nil
+}
+
+func optimize(ctx context.Context, input, start, rawOptions string, onProgress, onBest js.Value) *OptimizeResult {
+	var options OptimizeOptions
+	if err := json.Unmarshal([]byte(rawOptions), &options); err != nil {
+		return &OptimizeResult{Error: fmt.Sprintf("reading options: %v", err)}
+	}
+	seconds := func(s float64, def time.Duration) time.Duration {
+		if s <= 0 {
+			return def
+		}
+		return time.Duration(s * float64(time.Second))
+	}
+	settings := engine.GenSettings{
+		Workers:        1,
+		Pin:            95.0,
+		PinDev:         5.0,
+		Duration:       seconds(options.Seconds, time.Minute),
+		Warmup:         seconds(options.Warmup, 15*time.Second),
+		RestartLocal:   seconds(options.RestartLocal, 60*time.Second),
+		RestartGlobal:  seconds(options.RestartGlobal, 120*time.Second),
+		WeightedWarmup: true,
+	}
+	if options.Pin != nil {
+		settings.Pin = *options.Pin
+	}
+	if options.PinDev != nil {
+		settings.PinDev = *options.PinDev
+	}
+	if err := settings.Check(); err != nil {
+		return &OptimizeResult{Error: err.Error()}
+	}
+
+	lines, err := engine.SplitInput(input)
+	if err != nil {
+		return &OptimizeResult{Error: err.Error()}
+	}
+	data, err := engine.Parse("schedule.txt", lines)
+	if err != nil {
+		return &OptimizeResult{Error: err.Error()}
+	}
+	run := engine.Options{GenSettings: settings}
+	if options.Log {
+		run.Report = true
+		run.Logger = consoleLogger{name: "optimize"}
+	}
+	var placements []engine.Placement
+	if start != "" {
+		if placements, err = data.ReadJSON(strings.NewReader(start)); err != nil {
+			return &OptimizeResult{Error: fmt.Sprintf("reading schedule: %v", err)}
+		}
+	}
+	scheduler, err := engine.NewScheduler(data, placements)
+	if err != nil {
+		return &OptimizeResult{Error: err.Error()}
+	}
+
+	// the search never blocks, so it has to pause now and then to let
+	// the browser deliver a request to stop
+	startTime := time.Now()
+	lastYield := startTime
+	yield := func() {
+		if time.Since(lastYield) >= 100*time.Millisecond {
+			time.Sleep(time.Millisecond)
+			lastYield = time.Now()
+		}
+	}
+
+	// new best schedules come quickly early on, so only the latest is
+	// sent when several arrive close together
+	var pending *engine.Schedule
+	var lastBest time.Time
+	sendBest := func() {
+		builder := new(strings.Builder)
+		if err := data.WriteJSON(builder, pending.Placements, nil); err != nil {
+			log.Printf("schedule.optimize: writing JSON: %v", err)
+			return
+		}
+		onBest.Invoke(builder.String())
+		pending = nil
+		lastBest = time.Now()
+	}
+	run.OnBest = func(schedule engine.Schedule) {
+		pending = &schedule
+		if time.Since(lastBest) >= 250*time.Millisecond {
+			sendBest()
+		}
+	}
+
+	var lastProgress time.Time
+	run.OnProgress = func(progress engine.GenProgress) {
+		yield()
+		if pending != nil && time.Since(lastBest) >= 250*time.Millisecond {
+			sendBest()
+		}
+		if !progress.Final && progress.Time.Sub(lastProgress) < time.Second {
+			return
+		}
+		lastProgress = progress.Time
+		out := OptimizeProgress{
+			Elapsed:  progress.Time.Sub(startTime).Round(time.Millisecond).Seconds(),
+			Attempts: progress.Attempts,
+			Failed:   progress.Failed,
+			Mode:     engine.ModeName(progress.Mode),
+		}
+		if progress.Badness < engine.Worst {
+			badness := progress.Badness
+			out.Badness = &badness
+		}
+		raw, _ := json.Marshal(out)
+		onProgress.Invoke(string(raw))
+	}
+
+	if err := scheduler.Start(ctx, run); err != nil {
+		return &OptimizeResult{Error: err.Error()}
+	}
+	stats := scheduler.Wait()
+	best := scheduler.Best()
+	if pending != nil {
+		sendBest()
+	}
+
+	result := &OptimizeResult{
+		Attempts: stats.Successful + stats.Failed,
+		Failed:   stats.Failed,
+		Stopped:  stats.Stopped,
+		GaveUp:   stats.GaveUp,
+	}
+	if len(best.Placements) > 0 {
+		result.Badness = &best.Badness
+	}
+	return result
+}
+
+// A SuggestedMove is one course move in a swap suggestion
+type SuggestedMove struct {
+	ID          string   `json:"id"`
+	Course      string   `json:"course"`
+	Instructors []string `json:"instructors"`
+	FromRoom    string   `json:"fromroom"`
+	FromTime    string   `json:"fromtime"`
+	ToRoom      string   `json:"toroom"`
+	ToTime      string   `json:"totime"`
+}
+
+// A SwapSuggestion is the result of schedule.suggestSwaps. Moves is
+// empty if no improvement was found, and Complete is false if the
+// time ran out before every sequence of swaps was tried.
+type SwapSuggestion struct {
+	Badness  int             `json:"badness"`
+	Current  int             `json:"current"`
+	Complete bool            `json:"complete"`
+	Moves    []SuggestedMove `json:"moves"`
+}
+
+// Call with the maximum number of swaps (as in the swap command) and
+// a time budget in milliseconds. Searches from the schedule most
+// recently given to setSchedule and returns a SwapSuggestion object
+// with the best improving sequence of moves found. The search runs on
+// the calling thread, so keep the budget short.
+func WasmSuggestSwaps(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		log.Printf("schedule.suggestSwaps: expected 2 arguments, found %d", len(args))
+		return nil
+	}
+	if globalInputData == nil || len(globalSchedule.Placements) == 0 {
+		log.Printf("schedule.suggestSwaps: setSchedule must be called first")
+		return nil
+	}
+	depth := args[0].Int()
+	budget := time.Duration(args[1].Float() * float64(time.Millisecond))
+	if depth < 1 {
+		log.Printf("schedule.suggestSwaps: depth must be at least 1")
+		return nil
+	}
+	data := globalInputData
+	sections, err := data.BuildSectionList()
+	if err != nil {
+		log.Printf("schedule.suggestSwaps: %v", err)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+	found, complete := data.BestSwaps(ctx, sections, globalSchedule, depth)
+	out := SwapSuggestion{
+		Badness:  globalSchedule.Badness,
+		Current:  globalSchedule.Badness,
+		Complete: complete,
+		Moves:    []SuggestedMove{},
+	}
+	if len(found.Placements) > 0 && found.Badness < globalSchedule.Badness {
+		out.Badness = found.Badness
+		for _, move := range data.Moves(globalSchedule.Placements, found.Placements) {
+			var instructors []string
+			for _, instructor := range move.Course.Instructors {
+				instructors = append(instructors, instructor.Name)
+			}
+			out.Moves = append(out.Moves, SuggestedMove{
+				ID:          move.Course.SectionID(),
+				Course:      move.Course.Name,
+				Instructors: instructors,
+				FromRoom:    data.Rooms[move.From.Room].Name,
+				FromTime:    data.Times[move.From.Time].Name,
+				ToRoom:      data.Rooms[move.To.Room].Name,
+				ToTime:      data.Times[move.To.Time].Name,
+			})
+		}
+	}
+
+	raw, err := json.Marshal(out)
+	if err != nil {
+		log.Printf("schedule.suggestSwaps: %v", err)
+		return nil
+	}
+	return js.Global().Get("JSON").Call("parse", string(raw))
+}
@@ -0,0 +1,78 @@
+// +build wasm
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"syscall/js"
+
+	"github.com/russross/schedule/engine"
+)
+
+// HighlightOptions picks out the courses of one instructor or one
+// course (by name, or by section ID for a single section). When one is
+// set, the grid marks matching cells with the "highlighted" class and
+// everything else with the "dimmed" class.
+type HighlightOptions struct {
+	Instructor string `json:"instructor"`
+	Course     string `json:"course"`
+}
+
+var highlight HighlightOptions
+
+func (h HighlightOptions) active() bool {
+	return h.Instructor != "" || h.Course != ""
+}
+
+func (h HighlightOptions) matches(course *engine.Course) bool {
+	if h.Instructor != "" {
+		for _, instructor := range course.Instructors {
+			if instructor.Name == h.Instructor {
+				return true
+			}
+		}
+	}
+	return h.Course != "" && (course.Name == h.Course || course.SectionID() == h.Course)
+}
+
+// matchesProblem reports whether a problem message mentions the
+// instructor or course
+func (h HighlightOptions) matchesProblem(message string) bool {
+	return h.Instructor != "" && strings.Contains(message, h.Instructor) ||
+		h.Course != "" && strings.Contains(message, h.Course)
+}
+
+// Call with a HighlightOptions object, or with null to clear it. Shows
+// the current schedule again with the matching cells highlighted and
+// everything else dimmed, and returns the number of cells highlighted.
+func WasmSetHighlight(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		log.Printf("schedule.setHighlight: expected 1 argument, found %d", len(args))
+		return nil
+	}
+	var options HighlightOptions
+	if args[0].Type() == js.TypeObject {
+		raw := js.Global().Get("JSON").Call("stringify", args[0]).String()
+		if err := json.Unmarshal([]byte(raw), &options); err != nil {
+			log.Printf("schedule.setHighlight: %v", err)
+			return nil
+		}
+	}
+	highlight = options
+	if globalInputData == nil || len(globalSchedule.Placements) == 0 {
+		return 0
+	}
+
+	count := 0
+	if highlight.active() {
+		for _, placement := range globalSchedule.Placements {
+			if highlight.matches(placement.Course) {
+				count++
+			}
+		}
+	}
+	renderSchedule(globalSchedule)
+	return count
+}
@@ -0,0 +1,232 @@
+// +build wasm
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+	"syscall/js"
+	"time"
+
+	"github.com/russross/schedule/engine"
+)
+
+// how many schedules to keep for undo
+const maxHistory = 200
+
+// every schedule shown on the page, in the current file format, so
+// edits can be undone and redone without reloading the page.
+// historyPosition is the index of the schedule being shown.
+var scheduleHistory []string
+var historyPosition = -1
+
+// the schedule as last saved (loaded from or written to the server, or
+// downloaded), to tell whether there are unsaved changes
+var savedSchedule string
+
+// identifies the schedule.txt input, so a saved state is only
+// restored on top of the input it was made for
+var inputHash string
+
+func hashInput(input string) string {
+	sum := sha256.Sum256([]byte(input))
+	return hex.EncodeToString(sum[:8])
+}
+
+// recordHistory adds a newly shown schedule to the history, dropping
+// anything that had been undone. Showing the same schedule again (as
+// when a redrawn page is given the schedule it already has) does not
+// add a new entry.
+func recordHistory(schedule engine.Schedule) {
+	builder := new(strings.Builder)
+	if err := globalInputData.WriteJSON(builder, schedule.Placements, nil); err != nil {
+		log.Printf("schedule.setSchedule: writing JSON for history: %v", err)
+		return
+	}
+	state := builder.String()
+	if historyPosition >= 0 && samePlacements(scheduleHistory[historyPosition], state) {
+		return
+	}
+	scheduleHistory = append(scheduleHistory[:historyPosition+1], state)
+	if len(scheduleHistory) > maxHistory {
+		scheduleHistory = scheduleHistory[len(scheduleHistory)-maxHistory:]
+	}
+	historyPosition = len(scheduleHistory) - 1
+	if savedSchedule == "" {
+		savedSchedule = state
+	}
+}
+
+// samePlacements reports whether two schedules in the current file
+// format place every section in the same room and time, ignoring when
+// they were written
+func samePlacements(a, b string) bool {
+	return placementsKey(a) == placementsKey(b)
+}
+
+func placementsKey(state string) string {
+	var schedule engine.JSONSchedule
+	if err := json.Unmarshal([]byte(state), &schedule); err != nil {
+		return state
+	}
+	var keys []string
+	for _, elt := range schedule.Placements {
+		keys = append(keys, elt.ID+" "+elt.Room+" "+elt.Time)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\n")
+}
+
+// showHistory moves to another point in the history and shows that
+// schedule, returning it in the current file format
+func showHistory(name string, position int) interface{} {
+	if position < 0 || position >= len(scheduleHistory) {
+		return nil
+	}
+	placements, err := globalInputData.ReadJSON(strings.NewReader(scheduleHistory[position]))
+	if err != nil {
+		log.Printf("schedule.%s: reading JSON from history: %v", name, err)
+		return nil
+	}
+	historyPosition = position
+	globalSchedule = globalInputData.Score(placements)
+	renderSchedule(globalSchedule)
+	return scheduleHistory[position]
+}
+
+// Goes back to the schedule shown before the current one and shows it.
+// Returns that schedule as JSON in the current file format, or null if
+// there is nothing to undo.
+func WasmUndo(this js.Value, args []js.Value) interface{} {
+	if globalInputData == nil {
+		log.Printf("schedule.undo: setSchedule must be called first")
+		return nil
+	}
+	return showHistory("undo", historyPosition-1)
+}
+
+// Goes forward to the schedule last undone and shows it. Returns that
+// schedule as JSON in the current file format, or null if there is
+// nothing to redo.
+func WasmRedo(this js.Value, args []js.Value) interface{} {
+	if globalInputData == nil {
+		log.Printf("schedule.redo: setSchedule must be called first")
+		return nil
+	}
+	return showHistory("redo", historyPosition+1)
+}
+
+// A HistoryState is the result of schedule.history: every schedule in
+// the history as JSON in the current file format, oldest first, and
+// the index of the one being shown
+type HistoryState struct {
+	Position  int      `json:"position"`
+	Schedules []string `json:"schedules"`
+}
+
+// Returns a HistoryState object describing the undo history
+func WasmHistory(this js.Value, args []js.Value) interface{} {
+	state := HistoryState{Position: historyPosition, Schedules: scheduleHistory}
+	if state.Schedules == nil {
+		state.Schedules = []string{}
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("schedule.history: %v", err)
+		return nil
+	}
+	return js.Global().Get("JSON").Call("parse", string(raw))
+}
+
+// A BrowserState is everything needed to pick up editing where it was
+// left off after the page is reloaded: the undo history, the last
+// saved schedule, and the input they belong to
+type BrowserState struct {
+	Input     string    `json:"input"`
+	Time      time.Time `json:"time"`
+	Saved     string    `json:"saved"`
+	Position  int       `json:"position"`
+	Schedules []string  `json:"schedules"`
+}
+
+// Returns the editing state as a string for the page to keep (in
+// localStorage, for example) and pass to restoreState after a reload
+func WasmSaveState(this js.Value, args []js.Value) interface{} {
+	if globalInputData == nil || historyPosition < 0 {
+		log.Printf("schedule.saveState: setSchedule must be called first")
+		return nil
+	}
+	state := BrowserState{
+		Input:     inputHash,
+		Time:      time.Now(),
+		Saved:     savedSchedule,
+		Position:  historyPosition,
+		Schedules: scheduleHistory,
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("schedule.saveState: %v", err)
+		return nil
+	}
+	return string(raw)
+}
+
+// Call with a string from saveState. Replaces the editing state with
+// it and shows its current schedule, which is returned as JSON in the
+// current file format. Returns null (and changes nothing) if the state
+// cannot be read or was made for a different schedule.txt.
+func WasmRestoreState(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		log.Printf("schedule.restoreState: expected 1 argument, found %d", len(args))
+		return nil
+	}
+	if globalInputData == nil {
+		log.Printf("schedule.restoreState: setSchedule must be called first")
+		return nil
+	}
+	var state BrowserState
+	if err := json.Unmarshal([]byte(args[0].String()), &state); err != nil {
+		log.Printf("schedule.restoreState: %v", err)
+		return nil
+	}
+	if state.Input != inputHash {
+		log.Printf("schedule.restoreState: the state was saved for a different schedule.txt")
+		return nil
+	}
+	if state.Position < 0 || state.Position >= len(state.Schedules) {
+		log.Printf("schedule.restoreState: the state has no current schedule")
+		return nil
+	}
+	for i, schedule := range state.Schedules {
+		if _, err := globalInputData.ReadJSON(strings.NewReader(schedule)); err != nil {
+			log.Printf("schedule.restoreState: schedule %d: %v", i+1, err)
+			return nil
+		}
+	}
+
+	scheduleHistory = state.Schedules
+	savedSchedule = state.Saved
+	return showHistory("restoreState", state.Position)
+}
+
+// Records the schedule being shown as saved
+func WasmMarkSaved(this js.Value, args []js.Value) interface{} {
+	if historyPosition >= 0 {
+		savedSchedule = scheduleHistory[historyPosition]
+	}
+	return nil
+}
+
+// Returns true if the schedule being shown places any section
+// differently from the one last recorded as saved
+func WasmIsDirty(this js.Value, args []js.Value) interface{} {
+	return isDirty()
+}
+
+func isDirty() bool {
+	return historyPosition >= 0 && !samePlacements(scheduleHistory[historyPosition], savedSchedule)
+}
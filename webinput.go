@@ -0,0 +1,222 @@
+// +build wasm
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"syscall/js"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/russross/schedule/engine"
+)
+
+// An InputError is a problem found in edited schedule.txt contents.
+// Line, Column, and Index (the position of the field in the line) are
+// 1-based, and are zero when not known. Kind is one of the kinds of
+// engine.ParseError, such as "syntax" or "unresolved".
+type InputError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Index   int    `json:"index"`
+	Field   string `json:"field,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+// An InputCourse describes one course in an InputSummary
+type InputCourse struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Instructors []string `json:"instructors"`
+}
+
+// An InputSummary describes the data parsed from schedule.txt
+type InputSummary struct {
+	Rooms       []string      `json:"rooms"`
+	Times       []string      `json:"times"`
+	Instructors []string      `json:"instructors"`
+	Courses     []InputCourse `json:"courses"`
+}
+
+// An InputResult is the result of schedule.checkInput and
+// schedule.setInput. If OK is false, Errors lists the problems found.
+// Otherwise Input describes the parsed data, and for setInput,
+// Schedule is the schedule now shown, in the current file format.
+type InputResult struct {
+	OK       bool          `json:"ok"`
+	Errors   []InputError  `json:"errors"`
+	Input    *InputSummary `json:"input,omitempty"`
+	Schedule string        `json:"schedule,omitempty"`
+}
+
+// parseInput parses edited schedule.txt contents, reporting each
+// problem with the position of the field it was found in
+func parseInput(text string) (*engine.InputData, []InputError) {
+	lines, err := engine.SplitInput(text)
+	if err != nil {
+		return nil, []InputError{{Message: err.Error()}}
+	}
+	data, err := engine.Parse("schedule.txt", lines)
+	if err == nil {
+		return data, nil
+	}
+
+	raw := strings.Split(text, "\n")
+	var errs []InputError
+	add := func(e *engine.ParseError) {
+		out := InputError{Line: e.Line, Index: e.Index, Field: e.Field, Kind: string(e.Kind), Message: e.Err.Error()}
+		if e.Line > 0 && e.Line <= len(raw) && e.Index > 0 {
+			out.Column = fieldColumn(raw[e.Line-1], e.Index)
+		}
+		errs = append(errs, out)
+	}
+	switch err := err.(type) {
+	case engine.ParseErrors:
+		for _, e := range err {
+			add(e)
+		}
+	case *engine.ParseError:
+		add(err)
+	default:
+		errs = append(errs, InputError{Message: err.Error()})
+	}
+	return nil, errs
+}
+
+// fieldColumn finds the 1-based column where the given field of a line
+// starts, counting fields from 1, or returns zero if there is no such
+// field
+func fieldColumn(line string, index int) int {
+	column, inField := 0, false
+	for i, r := range line {
+		if unicode.IsSpace(r) {
+			inField = false
+			continue
+		}
+		if !inField {
+			inField = true
+			index--
+			if index == 0 {
+				column = utf8.RuneCountInString(line[:i]) + 1
+				break
+			}
+		}
+	}
+	return column
+}
+
+func summarizeInput(data *engine.InputData) *InputSummary {
+	summary := &InputSummary{
+		Rooms:       []string{},
+		Times:       []string{},
+		Instructors: []string{},
+		Courses:     []InputCourse{},
+	}
+	for _, room := range data.Rooms {
+		summary.Rooms = append(summary.Rooms, room.Name)
+	}
+	for _, t := range data.Times {
+		summary.Times = append(summary.Times, t.Name)
+	}
+	for _, instructor := range data.Instructors {
+		summary.Instructors = append(summary.Instructors, instructor.Name)
+	}
+	for _, course := range data.Courses {
+		elt := InputCourse{ID: course.SectionID(), Name: course.Name}
+		for _, instructor := range course.Instructors {
+			elt.Instructors = append(elt.Instructors, instructor.Name)
+		}
+		summary.Courses = append(summary.Courses, elt)
+	}
+	return summary
+}
+
+func inputResult(name string, result *InputResult) interface{} {
+	if result.Errors == nil {
+		result.Errors = []InputError{}
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("schedule.%s: %v", name, err)
+		return nil
+	}
+	return js.Global().Get("JSON").Call("parse", string(raw))
+}
+
+// Call with edited schedule.txt contents. Parses them without changing
+// anything and returns an InputResult object with either the errors
+// found or a summary of the parsed data.
+func WasmCheckInput(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		log.Printf("schedule.checkInput: expected 1 argument, found %d", len(args))
+		return nil
+	}
+	data, errs := parseInput(args[0].String())
+	if errs != nil {
+		return inputResult("checkInput", &InputResult{Errors: errs})
+	}
+	return inputResult("checkInput", &InputResult{OK: true, Input: summarizeInput(data)})
+}
+
+// Call with edited schedule.txt contents. If they parse, and the
+// schedule being shown still fits them, they replace the input from
+// the first call to setSchedule, and the schedule is scored against
+// them and shown again. The undo history starts over, since earlier
+// schedules may not fit the new input. Returns an InputResult object.
+func WasmSetInput(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		log.Printf("schedule.setInput: expected 1 argument, found %d", len(args))
+		return nil
+	}
+	if globalInputData == nil || historyPosition < 0 {
+		log.Printf("schedule.setInput: setSchedule must be called first")
+		return nil
+	}
+	text := args[0].String()
+	data, errs := parseInput(text)
+	if errs != nil {
+		return inputResult("setInput", &InputResult{Errors: errs})
+	}
+
+	// the current schedule must still make sense with the new input
+	placements, err := data.ReadJSON(strings.NewReader(scheduleHistory[historyPosition]))
+	if err != nil {
+		return inputResult("setInput", &InputResult{Errors: []InputError{{
+			Message: fmt.Sprintf("the current schedule does not fit the new input: %v", err),
+		}}})
+	}
+
+	globalInputData = data
+	globalSections = nil
+	inputHash = hashInput(text)
+	saved := savedSchedule
+	scheduleHistory, historyPosition, savedSchedule = nil, -1, ""
+	globalSchedule = data.Score(placements)
+	recordHistory(globalSchedule)
+	savedSchedule = saved
+	renderSchedule(globalSchedule)
+
+	return inputResult("setInput", &InputResult{
+		OK:       true,
+		Input:    summarizeInput(data),
+		Schedule: scheduleHistory[historyPosition],
+	})
+}
+
+// Returns an InputSummary object describing the input being used
+func WasmInputSummary(this js.Value, args []js.Value) interface{} {
+	if globalInputData == nil {
+		log.Printf("schedule.inputSummary: setSchedule must be called first")
+		return nil
+	}
+	raw, err := json.Marshal(summarizeInput(globalInputData))
+	if err != nil {
+		log.Printf("schedule.inputSummary: %v", err)
+		return nil
+	}
+	return js.Global().Get("JSON").Call("parse", string(raw))
+}
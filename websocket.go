@@ -0,0 +1,189 @@
+// +build !wasm
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the key every WebSocket handshake hashes with the client's key (RFC 6455)
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket frame opcodes
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+// A WebSocket is the server end of a WebSocket connection. Only what
+// the live view needs is supported: the server sends text messages
+// and the client's messages are read and discarded, apart from pings
+// and closes.
+type WebSocket struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex
+	closed chan struct{}
+	once   sync.Once
+}
+
+// AcceptWebSocket completes the handshake for a WebSocket request
+func AcceptWebSocket(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a WebSocket request", http.StatusBadRequest)
+		return nil, fmt.Errorf("not a WebSocket request")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusBadRequest)
+		return nil, fmt.Errorf("unsupported WebSocket version")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets are not supported here", http.StatusInternalServerError)
+		return nil, fmt.Errorf("connection cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	// the server's timeouts are meant for ordinary requests
+	conn.SetDeadline(time.Time{})
+
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	accept := base64.StdEncoding.EncodeToString(hash[:])
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n")
+	fmt.Fprintf(rw, "Upgrade: websocket\r\n")
+	fmt.Fprintf(rw, "Connection: Upgrade\r\n")
+	fmt.Fprintf(rw, "Sec-WebSocket-Accept: %s\r\n\r\n", accept)
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ws := &WebSocket{conn: conn, reader: rw.Reader, closed: make(chan struct{})}
+	go ws.readLoop()
+	return ws, nil
+}
+
+func headerContains(header http.Header, name, value string) bool {
+	for _, line := range header.Values(name) {
+		for _, elt := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(elt), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteText sends one text message
+func (ws *WebSocket) WriteText(msg []byte) error {
+	return ws.writeFrame(wsText, msg)
+}
+
+func (ws *WebSocket) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+
+	ws.mutex.Lock()
+	defer ws.mutex.Unlock()
+	if _, err := ws.conn.Write(append(header, payload...)); err != nil {
+		ws.Close()
+		return err
+	}
+	return nil
+}
+
+// Closed is closed when the connection ends
+func (ws *WebSocket) Closed() <-chan struct{} {
+	return ws.closed
+}
+
+func (ws *WebSocket) Close() {
+	ws.once.Do(func() {
+		close(ws.closed)
+		ws.conn.Close()
+	})
+}
+
+// readLoop reads frames from the client until the connection ends,
+// answering pings and closes
+func (ws *WebSocket) readLoop() {
+	defer ws.Close()
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(ws.reader, head[:]); err != nil {
+			return
+		}
+		opcode := head[0] & 0x0f
+		masked := head[1]&0x80 != 0
+		length := uint64(head[1] & 0x7f)
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+				return
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+				return
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+		if length > 1<<20 {
+			// the live view never expects anything this big
+			return
+		}
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+				return
+			}
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.reader, payload); err != nil {
+			return
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case wsClose:
+			ws.writeFrame(wsClose, nil)
+			return
+		case wsPing:
+			if ws.writeFrame(wsPong, payload) != nil {
+				return
+			}
+		}
+	}
+}
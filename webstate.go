@@ -0,0 +1,141 @@
+// +build wasm
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"syscall/js"
+
+	"github.com/russross/schedule/engine"
+)
+
+// A StateCell is one room at one time in the grid. A course starts in
+// the cell where Start is set and fills the next Slots cells in the
+// same room; the cells it spills into have the same ID with Start
+// unset. An empty cell has no ID, and Open is the number of empty
+// cells in a row starting with it.
+type StateCell struct {
+	ID          string   `json:"id,omitempty"`
+	Course      string   `json:"course,omitempty"`
+	Instructors []string `json:"instructors,omitempty"`
+	Start       bool     `json:"start,omitempty"`
+	Slots       int      `json:"slots,omitempty"`
+	Open        int      `json:"open,omitempty"`
+	Highlighted bool     `json:"highlighted,omitempty"`
+	Problems    []int    `json:"problems,omitempty"`
+}
+
+// A StatePlacement is where one course is placed
+type StatePlacement struct {
+	ID          string   `json:"id"`
+	Course      string   `json:"course"`
+	Instructors []string `json:"instructors"`
+	Room        string   `json:"room"`
+	Time        string   `json:"time"`
+}
+
+// A ScheduleState is the result of schedule.getState: everything the
+// page needs to draw the schedule. Grid has one row per time, with one
+// cell per room in each row. Problems are in the same order as the
+// list drawn on the page, and StateCell.Problems refers to them by
+// index.
+type ScheduleState struct {
+	Badness    int              `json:"badness"`
+	Rooms      []string         `json:"rooms"`
+	Times      []string         `json:"times"`
+	Grid       [][]StateCell    `json:"grid"`
+	Placements []StatePlacement `json:"placements"`
+	Problems   []WebProblem     `json:"problems"`
+	Dirty      bool             `json:"dirty"`
+	CanUndo    bool             `json:"canundo"`
+	CanRedo    bool             `json:"canredo"`
+}
+
+func scheduleState(data *engine.InputData, schedule engine.Schedule) *ScheduleState {
+	state := &ScheduleState{
+		Badness:    schedule.Badness,
+		Rooms:      []string{},
+		Times:      []string{},
+		Grid:       [][]StateCell{},
+		Placements: []StatePlacement{},
+		Problems:   webProblems(schedule),
+		Dirty:      isDirty(),
+		CanUndo:    historyPosition > 0,
+		CanRedo:    historyPosition+1 < len(scheduleHistory),
+	}
+	for _, room := range data.Rooms {
+		state.Rooms = append(state.Rooms, room.Name)
+	}
+	for _, t := range data.Times {
+		state.Times = append(state.Times, t.Name)
+	}
+
+	courseProblems := make(map[*engine.Course][]int)
+	for i, problem := range schedule.Problems {
+		for _, course := range problem.Courses {
+			courseProblems[course] = append(courseProblems[course], i)
+		}
+	}
+	instructorNames := func(course *engine.Course) []string {
+		var names []string
+		for _, instructor := range course.Instructors {
+			names = append(names, instructor.Name)
+		}
+		return names
+	}
+
+	for ti, t := range data.Times {
+		row := make([]StateCell, len(data.Rooms))
+		for ri := range data.Rooms {
+			cell := schedule.RoomTimes[ri][ti]
+			if cell.Course == nil {
+				open := 1
+				for cur := t; cur.Next != nil && schedule.RoomTimes[ri][ti+open].Course == nil; cur = cur.Next {
+					open++
+				}
+				row[ri] = StateCell{Open: open}
+				continue
+			}
+			row[ri] = StateCell{
+				ID:          cell.Course.SectionID(),
+				Course:      cell.Course.Name,
+				Instructors: instructorNames(cell.Course),
+				Start:       !cell.IsSpillover,
+				Highlighted: highlight.active() && highlight.matches(cell.Course),
+				Problems:    courseProblems[cell.Course],
+			}
+			if !cell.IsSpillover {
+				row[ri].Slots = cell.Course.SlotsNeeded(t)
+			}
+		}
+		state.Grid = append(state.Grid, row)
+	}
+
+	for _, placement := range schedule.Placements {
+		state.Placements = append(state.Placements, StatePlacement{
+			ID:          placement.Course.SectionID(),
+			Course:      placement.Course.Name,
+			Instructors: instructorNames(placement.Course),
+			Room:        data.Rooms[placement.Room].Name,
+			Time:        data.Times[placement.Time].Name,
+		})
+	}
+	return state
+}
+
+// Returns a ScheduleState object describing the schedule most recently
+// given to setSchedule (or reached by undo, redo, and so on), for pages
+// that draw the schedule themselves
+func WasmGetState(this js.Value, args []js.Value) interface{} {
+	if globalInputData == nil || len(globalSchedule.Placements) == 0 {
+		log.Printf("schedule.getState: setSchedule must be called first")
+		return nil
+	}
+	raw, err := json.Marshal(scheduleState(globalInputData, globalSchedule))
+	if err != nil {
+		log.Printf("schedule.getState: %v", err)
+		return nil
+	}
+	return js.Global().Get("JSON").Call("parse", string(raw))
+}
@@ -0,0 +1,233 @@
+// +build !wasm
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	whatIfRemove = ""
+	whatIfDrop   = false
+	whatIfBudget = time.Minute
+)
+
+func CommandWhatIf(cmd *cobra.Command, args []string) {
+	if whatIfRemove != "" {
+		if len(args) > 0 {
+			log.Fatalf("usage: schedule whatif --remove-instructor NAME")
+		}
+		whatIfRemoveInstructor()
+		return
+	}
+	if whatIfDrop {
+		log.Fatalf("--drop only makes sense with --remove-instructor")
+	}
+	if len(args) > 1 {
+		log.Fatalf("usage: schedule whatif [moves.txt]")
+	}
+
+	// get the input data and parse it
+	data := readInputData()
+
+	// read the schedule
+	baseline := data.Score(readPlacements(data, prefix+".json"))
+
+	// read the proposed moves
+	var r io.Reader = os.Stdin
+	filename := "standard input"
+	if len(args) == 1 && args[0] != "-" {
+		fp, err := os.Open(args[0])
+		if err != nil {
+			log.Fatalf("opening moves file: %v", err)
+		}
+		defer fp.Close()
+		r, filename = fp, args[0]
+	}
+	moves, err := ReadMoves(data, filename, r)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(moves) == 0 {
+		log.Fatalf("no moves found in %s", filename)
+	}
+
+	out := openOutput()
+	defer out.Close()
+	sections := makeSectionList(data)
+	report := func(placements []engine.Placement) {
+		schedule := data.Score(placements)
+		fmt.Fprintf(out, "badness %d (%+d)\n", schedule.Badness, schedule.Badness-baseline.Badness)
+		gone, added := engine.DiffProblems(baseline.Problems, schedule.Problems)
+		for _, problem := range gone {
+			fmt.Fprintln(out, "    - "+problem.Message)
+		}
+		for _, problem := range added {
+			fmt.Fprintln(out, "    + "+problem.Message)
+		}
+	}
+
+	fmt.Fprintf(out, "Current badness: %d\n\n", baseline.Badness)
+	for i, move := range moves {
+		fmt.Fprintf(out, "%d. %s -> %s %s: ", i+1,
+			move.Course.SectionID(), data.Rooms[move.Room].Name, data.Times[move.Time].Name)
+		placements, err := data.MoveCourses(sections, baseline.Placements, []engine.Placement{move})
+		if err != nil {
+			fmt.Fprintf(out, "not possible: %v\n", err)
+			continue
+		}
+		report(placements)
+	}
+
+	if len(moves) > 1 {
+		fmt.Fprintf(out, "\nAll %d moves together: ", len(moves))
+		placements, err := data.MoveCourses(sections, baseline.Placements, moves)
+		if err != nil {
+			fmt.Fprintf(out, "not possible: %v\n", err)
+			return
+		}
+		report(placements)
+	}
+}
+
+// whatIfRemoveInstructor reports how the current schedule would fare
+// if an instructor left: their sections go to STAFF (or are dropped)
+// and a short search refines the schedule from where it stands
+func whatIfRemoveInstructor() {
+	if whatIfBudget <= 0 {
+		log.Fatalf("time must be > 0")
+	}
+	settings := genSettingsFromFlags()
+	settings.Duration = whatIfBudget
+	if err := settings.Check(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	data := readInputData()
+	baseline := data.Score(readPlacements(data, prefix+".json"))
+	changed, changedSections, err := data.WithoutInstructor(whatIfRemove, whatIfDrop)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// carry the current placements over to the changed input
+	sections := make(map[string]*engine.Course)
+	for _, course := range changed.Courses {
+		sections[course.SectionID()] = course
+	}
+	var placements []engine.Placement
+	for _, placement := range baseline.Placements {
+		if course := sections[placement.Course.SectionID()]; course != nil {
+			placements = append(placements, engine.Placement{Course: course, Room: placement.Room, Time: placement.Time})
+		}
+	}
+	start := changed.Score(placements)
+
+	out := openOutput()
+	defer out.Close()
+	fmt.Fprintf(out, "Current badness: %d\n\n", baseline.Badness)
+	if whatIfDrop {
+		fmt.Fprintf(out, "Without %s, dropping %d section(s):", whatIfRemove, len(changedSections))
+	} else {
+		fmt.Fprintf(out, "Without %s, handing %d section(s) to %s:", whatIfRemove, len(changedSections), engine.StaffName)
+	}
+	for _, id := range changedSections {
+		fmt.Fprintf(out, " %s", id)
+	}
+	fmt.Fprintf(out, "\n")
+	fmt.Fprintf(out, "Before searching: badness %d (%+d)\n", start.Badness, start.Badness-baseline.Badness)
+
+	log.Printf("searching for %v for a better schedule without %s", whatIfBudget, whatIfRemove)
+	catchInterrupt()
+	best, _, err := engine.Search(interruptContext, changed, engine.Options{
+		GenSettings: settings,
+		Start:       start,
+		NoRestarts:  true,
+	})
+	if err != nil {
+		fmt.Fprintf(out, "No schedule found: %v\n", err)
+		return
+	}
+	if len(best.Placements) == 0 || best.Badness > start.Badness {
+		best = start
+	}
+	fmt.Fprintf(out, "After searching: badness %d (%+d)\n", best.Badness, best.Badness-baseline.Badness)
+	gone, added := engine.DiffProblems(baseline.Problems, best.Problems)
+	for _, problem := range gone {
+		fmt.Fprintln(out, "    - "+problem.Message)
+	}
+	for _, problem := range added {
+		fmt.Fprintln(out, "    + "+problem.Message)
+	}
+
+	// report the sections that had to move to make room
+	where := make(map[string]string)
+	for _, placement := range baseline.Placements {
+		where[placement.Course.SectionID()] = data.Rooms[placement.Room].Name + " " + data.Times[placement.Time].Name
+	}
+	var moved []string
+	for _, placement := range best.Placements {
+		id := placement.Course.SectionID()
+		now := changed.Rooms[placement.Room].Name + " " + changed.Times[placement.Time].Name
+		if before, present := where[id]; present && before != now {
+			moved = append(moved, fmt.Sprintf("    %s: %s -> %s", id, before, now))
+		}
+	}
+	for _, course := range best.Unplaced {
+		moved = append(moved, fmt.Sprintf("    %s: %s -> not placed", course.SectionID(), where[course.SectionID()]))
+	}
+	if len(moved) == 0 {
+		fmt.Fprintf(out, "No sections were displaced\n")
+		return
+	}
+	fmt.Fprintf(out, "Displaced sections:\n")
+	for _, line := range moved {
+		fmt.Fprintln(out, line)
+	}
+}
+
+// ReadMoves reads a list of proposed moves, one per line in the form
+//
+//     COURSE ROOM TIME
+//
+// where COURSE is a section ID (or a course name with one section).
+// Blank lines and anything after a # are ignored.
+func ReadMoves(data *engine.InputData, filename string, r io.Reader) ([]engine.Placement, error) {
+	var moves []engine.Placement
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s line %d: expected COURSE ROOM TIME", filename, lineNumber)
+		}
+		course, err := data.FindCourse(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", filename, lineNumber, err)
+		}
+		room, time, err := data.FindRoomTime(fields[1], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", filename, lineNumber, err)
+		}
+		moves = append(moves, engine.Placement{Course: course, Room: room, Time: time})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
@@ -0,0 +1,41 @@
+// Runs a gen search in the background for index.html, so the page
+// stays responsive while the search is running. Send
+// {cmd: 'start', input: ..., schedule: ..., options: {seconds: ...}}
+// to start a search and {cmd: 'stop'} to end it early. Replies are
+// {event: 'progress', progress: ...}, {event: 'best', schedule: ...},
+// and {event: 'done', result: ...}.
+importScripts('wasm_exec.js');
+
+self.schedule = {};
+var ready = (function () {
+    const go = new Go();
+    return WebAssembly.instantiateStreaming(fetch('schedule.wasm'), go.importObject)
+    .then((result) => {
+        go.run(result.instance);
+    });
+})();
+
+self.onmessage = function (e) {
+    var msg = e.data;
+    if (msg.cmd == 'stop') {
+        if (schedule.stopOptimize)
+            schedule.stopOptimize();
+        return;
+    }
+    if (msg.cmd != 'start')
+        return;
+    ready.then(() => {
+        schedule.optimize(msg.input, msg.schedule || '', JSON.stringify(msg.options || {}),
+            function (progress) {
+                postMessage({event: 'progress', progress: JSON.parse(progress)});
+            },
+            function (best) {
+                postMessage({event: 'best', schedule: best});
+            },
+            function (result) {
+                postMessage({event: 'done', result: JSON.parse(result)});
+            });
+    }).catch((err) => {
+        postMessage({event: 'done', result: {error: String(err)}});
+    });
+};
@@ -0,0 +1,187 @@
+// +build !wasm
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/russross/schedule/engine"
+	"github.com/spf13/cobra"
+)
+
+var (
+	yearBudget     = time.Minute
+	yearRounds     = 2
+	yearSequential = false
+)
+
+// A term is the input and current schedule for one term of a year
+type term struct {
+	prefix   string
+	data     *engine.InputData
+	schedule engine.Schedule
+	changed  bool
+}
+
+// CommandYear schedules the terms of a year together. The linkage file
+// (year.txt by default) names the prefix of each term's input and the
+// links between them. Each term in turn is refined with a short search
+// that is steered by the current schedules of the others, keeping the
+// change only if the badness of the whole year goes down.
+func CommandYear(cmd *cobra.Command, args []string) {
+	if len(args) > 1 {
+		log.Fatalf("usage: schedule year [year.txt]")
+	}
+	if yearBudget <= 0 {
+		log.Fatalf("time must be > 0")
+	}
+	if yearRounds < 1 {
+		log.Fatalf("rounds must be >= 1")
+	}
+	settings := genSettingsFromFlags()
+	settings.Duration = yearBudget
+	if err := settings.Check(); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	filename := "year.txt"
+	if len(args) == 1 {
+		filename = args[0]
+	}
+	lines, err := fetchFile(filename)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	link, err := engine.ParseLinkage(filename, lines)
+	if err != nil {
+		log.Printf("%v", err)
+		os.Exit(ExitParse)
+	}
+
+	// read each term, starting from its current schedule if it has one
+	savedPrefix := prefix
+	defer func() { prefix = savedPrefix }()
+	var terms []*term
+	var inputs []*engine.InputData
+	for _, name := range link.Terms {
+		prefix = name
+		t := &term{prefix: name, data: readInputData()}
+		if _, err := os.Stat(name + ".json"); err == nil {
+			t.schedule = t.data.Score(readPlacements(t.data, name+".json"))
+		} else {
+			t.schedule = engine.Schedule{Badness: engine.Worst}
+		}
+		terms = append(terms, t)
+		inputs = append(inputs, t.data)
+	}
+	if err := link.Check(inputs); err != nil {
+		log.Fatalf("%s: %v", filename, err)
+	}
+	schedules := func() []engine.Schedule {
+		var out []engine.Schedule
+		for _, t := range terms {
+			out = append(out, t.schedule)
+		}
+		return out
+	}
+	total := func() int {
+		sum, _ := link.Score(inputs, schedules())
+		for _, t := range terms {
+			sum += t.schedule.Badness
+		}
+		return sum
+	}
+
+	// a sequential run leaves the first term alone (unless it has no
+	// schedule yet) and fits each later term to the one before it
+	catchInterrupt()
+	rounds := yearRounds
+	if yearSequential {
+		rounds = 1
+	}
+	for round := 1; round <= rounds && !isInterrupted(); round++ {
+		for n, t := range terms {
+			if isInterrupted() {
+				break
+			}
+			if yearSequential && n == 0 && len(t.schedule.Placements) > 0 {
+				continue
+			}
+			log.Printf("round %d: searching %s for %v", round, t.prefix, yearBudget)
+			biased, err := link.Bias(inputs, schedules(), n)
+			if err != nil {
+				log.Fatalf("%s: %v", t.prefix, err)
+			}
+			options := engine.Options{GenSettings: settings}
+			if len(t.schedule.Placements) > 0 {
+				options.Start = biased.Score(samePlacements(biased, t.schedule.Placements))
+				options.NoRestarts = true
+			}
+			best, _, err := engine.Search(interruptContext, biased, options)
+			if err != nil {
+				log.Fatalf("%s: %v", t.prefix, err)
+			}
+			if len(best.Placements) == 0 {
+				log.Printf("round %d: no schedule found for %s", round, t.prefix)
+				continue
+			}
+
+			// keep the new schedule only if the year as a whole is better
+			before, old := total(), t.schedule
+			t.schedule = t.data.Score(samePlacements(t.data, best.Placements))
+			if after := total(); after < before {
+				log.Printf("round %d: %s badness %d, year badness %s", round, t.prefix, t.schedule.Badness, badnessString(after))
+				t.changed = true
+			} else {
+				t.schedule = old
+			}
+		}
+	}
+
+	for _, t := range terms {
+		if len(t.schedule.Placements) == 0 {
+			log.Fatalf("no schedule found for %s", t.prefix)
+		}
+	}
+	linkBadness, problems := link.Score(inputs, schedules())
+	for _, t := range terms {
+		fmt.Printf("%s: badness %d\n", t.prefix, t.schedule.Badness)
+	}
+	fmt.Printf("links between terms: badness %d\n", linkBadness)
+	for _, problem := range problems {
+		fmt.Printf("    %s\n", problem.Message)
+	}
+	fmt.Printf("year: badness %d\n", total())
+
+	for _, t := range terms {
+		if t.changed {
+			prefix = t.prefix
+			prevFile, prevHtmlFile = "", ""
+			writeOutputFiles(t.data, t.schedule)
+		}
+	}
+	if isInterrupted() {
+		os.Exit(ExitInterrupted)
+	}
+}
+
+// samePlacements moves a list of placements to another input with the
+// same sections, rooms, and times
+func samePlacements(to *engine.InputData, placements []engine.Placement) []engine.Placement {
+	sections := make(map[string]*engine.Course)
+	for _, course := range to.Courses {
+		sections[course.SectionID()] = course
+	}
+	var out []engine.Placement
+	for _, placement := range placements {
+		course := sections[placement.Course.SectionID()]
+		if course == nil {
+			log.Fatalf("section %s not found", placement.Course.SectionID())
+		}
+		out = append(out, engine.Placement{Course: course, Room: placement.Room, Time: placement.Time})
+	}
+	return out
+}